@@ -9,6 +9,7 @@ import (
 	"os"
 	"reflect"
 	"text/template"
+	"time"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/kelseyhightower/envconfig"
@@ -27,6 +28,7 @@ type constants struct {
 	PublicAddress     string   `envconfig:"PUBLIC_ADDR" doc:"Publicly accessible address for the server, requires schema"`
 	OpenIDRealm       string   `envconfig:"SERVER_OPENID_REALM" default:"http://localhost:8080" doc:"The OpenID Realm (See: [Section 9.2 of the OpenID Spec](https://openid.net/specs/openid-authentication-2_0-12.html#realms))"`
 	AllowedOrigins    []string `envconfig:"ALLOWED_ORIGINS" default:"*"`
+	TrustedProxies    []string `envconfig:"TRUSTED_PROXIES" default:"127.0.0.1,::1" doc:"IP addresses or CIDR ranges of reverse proxies whose X-Real-IP header is trusted"`
 	CookieDomain      string   `envconfig:"SERVER_COOKIE_DOMAIN" default:"" doc:"Cookie URL domain"`
 	LoginRedirectPath string   `envconfig:"SERVER_REDIRECT_PATH" default:"http://localhost:8080/" doc:"URL to redirect user to after a successful login"`
 	CookieStoreSecret string   `envconfig:"COOKIE_STORE_SECRET" default:"secret" doc:"base64 encoded key to use for encrypting cookies"`
@@ -51,18 +53,33 @@ type constants struct {
 
 	ProfilerAddr string `envconfig:"PROFILER_ADDR" doc:"Address to serve the web-based profiler over"`
 
-	SlackbotURL        string   `envconfig:"SLACK_URL" doc:"Slack webhook URL"`
-	SentryDSN          string   `envconfig:"SENTRY_DSN" doc:"Sentry DSN"`
-	DiscordToken       string   `envconfig:"DISCORD_TOKEN" doc:"Discord Token"`
-	DiscordGuildId     string   `envconfig:"DISCORD_GUILD_ID" doc:"Discord Guild ID"`
-	Environment        string   `envconfig:"DEPLOYED_ENV" default:"development" doc:"Deployment environment"`
-	TwitchClientID     string   `envconfig:"TWITCH_CLIENT_ID" doc:"Twitch API Client ID"`
-	TwitchClientSecret string   `envconfig:"TWITCH_CLIENT_SECRET" doc:"Twitch API Client Secret"`
-	ServemeAPIKey      string   `envconfig:"SERVEME_API_KEY" doc:"serveme.tf API Key"`
-	HealthChecks       bool     `envconfig:"HEALTH_CHECKS" default:"false" doc:"Enable health checks"`
-	SecureCookies      bool     `envconfig:"SECURE_COOKIE" doc:"Enable 'secure' flag on cookies" default:"false"`
-	FilteredWords      []string `envconfig:"FILTERED_WORDS"`
-	DemosFolder        string   `envconfig:"DEMOS_FOLDER" doc:"Folder to store STV demos in" default:"demos"`
+	SlackbotURL         string        `envconfig:"SLACK_URL" doc:"Slack webhook URL"`
+	SentryDSN           string        `envconfig:"SENTRY_DSN" doc:"Sentry DSN"`
+	DiscordToken        string        `envconfig:"DISCORD_TOKEN" doc:"Discord Token"`
+	DiscordGuildId      string        `envconfig:"DISCORD_GUILD_ID" doc:"Discord Guild ID"`
+	Environment         string        `envconfig:"DEPLOYED_ENV" default:"development" doc:"Deployment environment"`
+	TwitchClientID      string        `envconfig:"TWITCH_CLIENT_ID" doc:"Twitch API Client ID"`
+	TwitchClientSecret  string        `envconfig:"TWITCH_CLIENT_SECRET" doc:"Twitch API Client Secret"`
+	ServemeAPIKey       string        `envconfig:"SERVEME_API_KEY" doc:"serveme.tf API Key"`
+	HealthChecks        bool          `envconfig:"HEALTH_CHECKS" default:"false" doc:"Enable health checks"`
+	SecureCookies       bool          `envconfig:"SECURE_COOKIE" doc:"Enable 'secure' flag on cookies" default:"false"`
+	FilteredWords       []string      `envconfig:"FILTERED_WORDS"`
+	ChatFloodLimit      int           `envconfig:"CHAT_FLOOD_LIMIT" default:"5" doc:"Number of messages a player can send to a room within CHAT_FLOOD_WINDOW"`
+	ChatFloodWindow     time.Duration `envconfig:"CHAT_FLOOD_WINDOW" default:"10s" doc:"Window over which chat flooding is checked"`
+	ChatRepeatLimit     int           `envconfig:"CHAT_REPEAT_LIMIT" default:"2" doc:"Number of times a player can send the same message to a room within a minute"`
+	ChatMuteDuration    time.Duration `envconfig:"CHAT_MUTE_DURATION" default:"10m" doc:"Length of chat bans issued automatically by the chat filter"`
+	ChatLinkAllowlist   []string      `envconfig:"CHAT_LINK_ALLOWLIST" default:"tf2stadium.com,logs.tf,demos.tf,steamcommunity.com,twitch.tv" doc:"Domains players can post links to in chat"`
+	DemosFolder         string        `envconfig:"DEMOS_FOLDER" doc:"Folder to store STV demos in" default:"demos"`
+	FormatsFile         string        `envconfig:"FORMATS_FILE" doc:"JSON file with additional lobby format definitions"`
+	APITokenRateLimit   int           `envconfig:"API_TOKEN_RATE_LIMIT" default:"60" doc:"Default number of requests per minute allowed for each API token"`
+	LeaderboardInterval time.Duration `envconfig:"LEADERBOARD_INTERVAL" default:"1h" doc:"How often season leaderboards are recomputed"`
+	LogListenAddress    string        `envconfig:"LOG_LISTEN_ADDR" doc:"UDP address to receive game server logs on, disabled if empty"`
+	LogPublicAddress    string        `envconfig:"LOG_PUBLIC_ADDR" doc:"Address game servers send their logs to, defaults to LOG_LISTEN_ADDR"`
+	ServerCheckInterval time.Duration `envconfig:"SERVER_CHECK_INTERVAL" default:"1m" doc:"How often stored servers are health checked over RCON"`
+	ServerMaxFailures   int           `envconfig:"SERVER_MAX_FAILURES" default:"3" doc:"Number of failed health checks in a row after which a stored server is taken out of rotation"`
+	SecretsKey          string        `envconfig:"SECRETS_KEY" doc:"Base64 encoded 32 byte key used to encrypt RCON passwords and server secrets in the database (generate one with -gensecretskey), they're stored in plaintext if empty"`
+	SecretsOldKeys      []string      `envconfig:"SECRETS_OLD_KEYS" doc:"Previous values of SECRETS_KEY, used to decrypt secrets until they're encrypted with the new key by -rotate-secrets-key"`
+	WebhookPrivateAddrs bool          `envconfig:"WEBHOOK_PRIVATE_ADDRS" default:"false" doc:"Allow player webhooks to be sent to private and local addresses, for development"`
 }
 
 var Constants = constants{}
@@ -79,6 +96,9 @@ func init() {
 	if Constants.PublicAddress == "" {
 		Constants.PublicAddress = "http://" + Constants.ListenAddress
 	}
+	if Constants.LogPublicAddress == "" {
+		Constants.LogPublicAddress = Constants.LogListenAddress
+	}
 	if Constants.MockupAuth {
 		logrus.Warning("Mockup authentication enabled.")
 	}
@@ -0,0 +1,155 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package admin
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"strconv"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/TF2Stadium/Helen/config"
+	chelpers "github.com/TF2Stadium/Helen/controllers/controllerhelpers"
+	"github.com/TF2Stadium/Helen/models"
+	"github.com/TF2Stadium/Helen/models/player"
+	"golang.org/x/net/xsrftoken"
+)
+
+var (
+	playerTempl   *template.Template
+	altFlagsTempl *template.Template
+)
+
+type alias struct {
+	*player.Player
+	Bans      []*player.PlayerBan
+	Confirmed bool // confirmed by a moderator, banned with the player's group
+}
+
+func ViewPlayer(w http.ResponseWriter, r *http.Request) {
+	p, err := player.GetPlayerBySteamID(r.URL.Query().Get("steamid"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	confirmed := make(map[uint]bool)
+	for _, alt := range p.GetConfirmedAlts() {
+		confirmed[alt.ID] = true
+	}
+
+	var aliases []alias
+	for _, other := range p.GetAliasGroup() {
+		bans, _ := other.GetActiveBans()
+		aliases = append(aliases, alias{other, bans, confirmed[other.ID]})
+	}
+	bans, _ := p.GetAllBans()
+
+	err = playerTempl.Execute(w, map[string]interface{}{
+		"XSRFToken": xsrftoken.Generate(config.Constants.CookieStoreSecret, "admin", "POST"),
+		"Player":    p,
+		"IPs":       p.GetIPs(),
+		"Aliases":   aliases,
+		"Bans":      bans,
+		"Flags":     p.GetAltFlags(),
+	})
+	if err != nil {
+		logrus.Error(err)
+	}
+}
+
+func ViewAltFlags(w http.ResponseWriter, r *http.Request) {
+	err := altFlagsTempl.Execute(w, map[string]interface{}{
+		"XSRFToken": xsrftoken.Generate(config.Constants.CookieStoreSecret, "admin", "POST"),
+		"Flags":     player.GetAltFlags(),
+	})
+	if err != nil {
+		logrus.Error(err)
+	}
+}
+
+func ReviewAltFlag(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	values := r.Form
+
+	token := values.Get("xsrf-token")
+	if !xsrftoken.Valid(token, config.Constants.CookieStoreSecret, "admin", "POST") {
+		http.Error(w, "invalid xsrf token", http.StatusBadRequest)
+		return
+	}
+
+	id, err := strconv.ParseUint(values.Get("id"), 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid flag ID", http.StatusBadRequest)
+		return
+	}
+
+	flag, err := player.GetAltFlag(uint(id))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	jwt, _ := chelpers.GetToken(r)
+	reviewer := chelpers.GetPlayer(jwt)
+
+	action := fmt.Sprintf("Reviewed alt account flag #%d", id)
+	if values.Get("confirm") == "true" {
+		if err := flag.BannedPlayer.ConfirmAlt(&flag.Player, reviewer.ID); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		action = fmt.Sprintf("Confirmed alt account flag #%d", id)
+	}
+
+	if err := player.ReviewAltFlag(flag.ID); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err := models.LogCustomAdminAction(reviewer.ID, action, 0); err != nil {
+		logrus.Error(err)
+	}
+
+	http.Redirect(w, r, "/admin/alts", http.StatusSeeOther)
+}
+
+//ConfirmAlt records that two accounts sharing an IP belong to the same
+//person, so they're banned together by group bans
+func ConfirmAlt(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	values := r.Form
+
+	token := values.Get("xsrf-token")
+	if !xsrftoken.Valid(token, config.Constants.CookieStoreSecret, "admin", "POST") {
+		http.Error(w, "invalid xsrf token", http.StatusBadRequest)
+		return
+	}
+
+	p, err := player.GetPlayerBySteamID(values.Get("steamid"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	alt, err := player.GetPlayerBySteamID(values.Get("alt"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	jwt, _ := chelpers.GetToken(r)
+	mod := chelpers.GetPlayer(jwt)
+	if err := p.ConfirmAlt(alt, mod.ID); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	action := fmt.Sprintf("Confirmed %s as an alt of %s", alt.SteamID, p.SteamID)
+	if err := models.LogCustomAdminAction(mod.ID, action, 0); err != nil {
+		logrus.Error(err)
+	}
+
+	http.Redirect(w, r, "/admin/player?steamid="+p.SteamID, http.StatusSeeOther)
+}
@@ -0,0 +1,94 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package admin
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/TF2Stadium/Helen/config"
+	"github.com/TF2Stadium/Helen/controllers/broadcaster"
+	chelpers "github.com/TF2Stadium/Helen/controllers/controllerhelpers"
+	"github.com/TF2Stadium/Helen/models"
+	"github.com/TF2Stadium/Helen/models/player"
+	"golang.org/x/net/xsrftoken"
+)
+
+var appealsTempl *template.Template
+
+func ViewBanAppeals(w http.ResponseWriter, r *http.Request) {
+	err := appealsTempl.Execute(w, map[string]interface{}{
+		"XSRFToken": xsrftoken.Generate(config.Constants.CookieStoreSecret, "admin", "POST"),
+		"Appeals":   player.GetPendingBanAppeals(),
+	})
+	if err != nil {
+		logrus.Error(err)
+	}
+}
+
+func ReviewBanAppeal(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	values := r.Form
+
+	token := values.Get("xsrf-token")
+	if !xsrftoken.Valid(token, config.Constants.CookieStoreSecret, "admin", "POST") {
+		http.Error(w, "invalid xsrf token", http.StatusBadRequest)
+		return
+	}
+
+	id, err := strconv.ParseUint(values.Get("id"), 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid appeal ID", http.StatusBadRequest)
+		return
+	}
+
+	appeal, err := player.GetBanAppeal(uint(id))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	jwt, _ := chelpers.GetToken(r)
+	reviewer := chelpers.GetPlayer(jwt)
+	response := values.Get("response")
+
+	var action string
+	switch values.Get("decision") {
+	case "accept":
+		err = appeal.Accept(reviewer.ID, response)
+		action = fmt.Sprintf("Accepted ban appeal #%d (%s), player unbanned", appeal.ID, appeal.Ban.Type.String())
+	case "reject":
+		err = appeal.Reject(reviewer.ID, response)
+		action = fmt.Sprintf("Rejected ban appeal #%d (%s)", appeal.ID, appeal.Ban.Type.String())
+	case "shorten":
+		until, perr := time.Parse("2006-01-02 15:04", values.Get("date")+" "+values.Get("time"))
+		if perr != nil {
+			http.Error(w, "invalid time format", http.StatusBadRequest)
+			return
+		}
+
+		err = appeal.Shorten(reviewer.ID, until, response)
+		action = fmt.Sprintf("Shortened ban appeal #%d (%s) till %v", appeal.ID, appeal.Ban.Type.String(), until)
+	default:
+		http.Error(w, "Invalid decision", http.StatusBadRequest)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := models.LogCustomAdminAction(reviewer.ID, action, appeal.PlayerID); err != nil {
+		logrus.Error(err)
+	}
+	broadcaster.SendMessage(appeal.Player.SteamID, "banAppealReviewed", appeal)
+
+	fmt.Fprintf(w, "%s for player %s (%s)", action, appeal.Player.Name, appeal.Player.SteamID)
+}
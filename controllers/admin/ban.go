@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"html/template"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/Sirupsen/logrus"
@@ -19,6 +20,14 @@ import (
 
 var banlogsTempl *template.Template
 
+var banTypes = map[string]player.BanType{
+	"joinLobby":       player.BanJoin,
+	"joinMumbleLobby": player.BanJoinMumble,
+	"createLobby":     player.BanCreate,
+	"chat":            player.BanChat,
+	"full":            player.BanFull,
+}
+
 func BanPlayer(w http.ResponseWriter, r *http.Request) {
 	err := r.ParseForm()
 	if err != nil {
@@ -30,19 +39,14 @@ func BanPlayer(w http.ResponseWriter, r *http.Request) {
 	reason := values.Get("reason")
 	banType := values.Get("type")
 	remove := values.Get("remove")
+	group := values.Get("group") == "true"
 	token := values.Get("xsrf-token")
 	if !xsrftoken.Valid(token, config.Constants.CookieStoreSecret, "admin", "POST") {
 		http.Error(w, "invalid xsrf token", http.StatusBadRequest)
 		return
 	}
 
-	ban, ok := map[string]player.BanType{
-		"joinLobby":       player.BanJoin,
-		"joinMumbleLobby": player.BanJoinMumble,
-		"createLobby":     player.BanCreate,
-		"chat":            player.BanChat,
-		"full":            player.BanFull,
-	}[banType]
+	ban, ok := banTypes[banType]
 	if !ok {
 		http.Error(w, "Invalid ban type", http.StatusBadRequest)
 		return
@@ -54,7 +58,15 @@ func BanPlayer(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if remove == "true" {
+	if remove == "true" && group {
+		players, err := player.UnbanGroup(ban)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else {
+			fmt.Fprintf(w, "Players %s have been unbanned (%s)", groupNames(players), ban.String())
+		}
+		return
+	} else if remove == "true" {
 		err := player.Unban(ban)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
@@ -76,6 +88,17 @@ func BanPlayer(w http.ResponseWriter, r *http.Request) {
 	jwt, _ := chelpers.GetToken(r)
 	bannedByPlayer := chelpers.GetPlayer(jwt)
 
+	if group {
+		players, err := player.BanGroupUntil(until, ban, reason, bannedByPlayer.ID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		fmt.Fprintf(w, "Players %s have been banned (%s) till %v", groupNames(players), ban.String(), until)
+		return
+	}
+
 	err = player.BanUntil(until, ban, reason, bannedByPlayer.ID)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
@@ -85,6 +108,15 @@ func BanPlayer(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintf(w, "Player %s (%s) has been banned (%s) till %v", player.Name, player.SteamID, ban.String(), until)
 }
 
+func groupNames(players []*player.Player) string {
+	var names []string
+	for _, p := range players {
+		names = append(names, fmt.Sprintf("%s (%s)", p.Name, p.SteamID))
+	}
+
+	return strings.Join(names, ", ")
+}
+
 func GetBanLogs(w http.ResponseWriter, r *http.Request) {
 	values := r.URL.Query()
 	if !xsrftoken.Valid(values.Get("xsrf-token"), config.Constants.CookieStoreSecret, "admin", "POST") {
@@ -0,0 +1,179 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/TF2Stadium/Helen/config"
+	chelpers "github.com/TF2Stadium/Helen/controllers/controllerhelpers"
+	db "github.com/TF2Stadium/Helen/database"
+	"github.com/TF2Stadium/Helen/models"
+	"github.com/TF2Stadium/Helen/models/player"
+	"golang.org/x/net/xsrftoken"
+)
+
+var (
+	banRulesTempl       *template.Template
+	banRulePreviewTempl *template.Template
+)
+
+var reportTypes = map[player.ReportType]string{
+	player.Substitute: player.Substitute.String(),
+	player.Vote:       player.Vote.String(),
+	player.RageQuit:   player.RageQuit.String(),
+}
+
+type banRuleForm struct {
+	Rule        *player.BanRule
+	XSRFToken   string
+	BanForms    map[string]string
+	BanTypes    map[string]player.BanType
+	ReportTypes map[player.ReportType]string
+}
+
+func ViewBanRules(w http.ResponseWriter, r *http.Request) {
+	xsrf := xsrftoken.Generate(config.Constants.CookieStoreSecret, "admin", "POST")
+
+	var forms []banRuleForm
+	// the last form is for adding a new rule
+	for _, rule := range append(player.GetBanRules(), &player.BanRule{}) {
+		forms = append(forms, banRuleForm{rule, xsrf, banForm, banTypes, reportTypes})
+	}
+
+	err := banRulesTempl.Execute(w, forms)
+	if err != nil {
+		logrus.Error(err)
+	}
+}
+
+// parseBanRule returns the rule described by the form values
+func parseBanRule(values url.Values) (*player.BanRule, error) {
+	rule := &player.BanRule{
+		Name:      values.Get("name"),
+		Durations: values.Get("durations"),
+		Reason:    values.Get("reason"),
+		Enabled:   values.Get("enabled") == "true",
+	}
+
+	if id := values.Get("id"); id != "" {
+		id, err := strconv.ParseUint(id, 10, 32)
+		if err != nil {
+			return nil, errors.New("Invalid rule ID")
+		}
+		rule.ID = uint(id)
+	}
+
+	rtype, err := strconv.Atoi(values.Get("reportType"))
+	if _, ok := reportTypes[player.ReportType(rtype)]; err != nil || !ok {
+		return nil, errors.New("Invalid report type")
+	}
+	rule.ReportType = player.ReportType(rtype)
+
+	banType, ok := banTypes[values.Get("banType")]
+	if !ok {
+		return nil, errors.New("Invalid ban type")
+	}
+	rule.BanType = banType
+
+	rule.Window, err = time.ParseDuration(values.Get("window"))
+	if err != nil {
+		return nil, errors.New("Invalid window")
+	}
+
+	rule.Threshold, err = strconv.Atoi(values.Get("threshold"))
+	if err != nil {
+		return nil, errors.New("Invalid threshold")
+	}
+
+	return rule, rule.Validate()
+}
+
+func SaveBanRule(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	values := r.Form
+
+	token := values.Get("xsrf-token")
+	if !xsrftoken.Valid(token, config.Constants.CookieStoreSecret, "admin", "POST") {
+		http.Error(w, "invalid xsrf token", http.StatusBadRequest)
+		return
+	}
+
+	rule, err := parseBanRule(values)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := rule.Save(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	jwt, _ := chelpers.GetToken(r)
+	mod := chelpers.GetPlayer(jwt)
+	action := fmt.Sprintf("Changed ban rule %s (%d %s reports in %v -> %s %s, enabled: %t)",
+		rule.Name, rule.Threshold, rule.ReportType.String(), rule.Window, rule.BanType.String(), rule.Durations, rule.Enabled)
+	models.LogCustomAdminAction(mod.ID, action, 0)
+
+	fmt.Fprintf(w, "Ban rule %s saved.", rule.Name)
+}
+
+func PreviewBanRule(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	values := r.Form
+
+	token := values.Get("xsrf-token")
+	if !xsrftoken.Valid(token, config.Constants.CookieStoreSecret, "admin", "POST") {
+		http.Error(w, "invalid xsrf token", http.StatusBadRequest)
+		return
+	}
+
+	rule, err := parseBanRule(values)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	days, err := strconv.Atoi(values.Get("days"))
+	if err != nil || days <= 0 {
+		http.Error(w, "Invalid number of days", http.StatusBadRequest)
+		return
+	}
+	since := time.Now().AddDate(0, 0, -days)
+
+	bans, err := rule.Preview(since)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// bans which were actually issued by the rule in the same period
+	var actual int
+	db.DB.Model(&player.PlayerBan{}).Where("rule_name = ? AND created_at > ?", rule.Name, since).Count(&actual)
+
+	players := make(map[uint]bool)
+	for _, ban := range bans {
+		players[ban.PlayerID] = true
+	}
+
+	err = banRulePreviewTempl.Execute(w, map[string]interface{}{
+		"Rule":    rule,
+		"Days":    days,
+		"Bans":    bans,
+		"Players": len(players),
+		"Actual":  actual,
+	})
+	if err != nil {
+		logrus.Error(err)
+	}
+}
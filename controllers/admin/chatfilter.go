@@ -0,0 +1,94 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package admin
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"strconv"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/TF2Stadium/Helen/config"
+	chelpers "github.com/TF2Stadium/Helen/controllers/controllerhelpers"
+	"github.com/TF2Stadium/Helen/models"
+	"github.com/TF2Stadium/Helen/models/chat"
+	"golang.org/x/net/xsrftoken"
+)
+
+var chatFilterTempl *template.Template
+
+func ViewChatFilter(w http.ResponseWriter, r *http.Request) {
+	err := chatFilterTempl.Execute(w, map[string]interface{}{
+		"XSRFToken": xsrftoken.Generate(config.Constants.CookieStoreSecret, "admin", "POST"),
+		"Rules":     chat.GetFilterRules(),
+		"Actions":   chat.FilterActions,
+		"Constants": config.Constants,
+	})
+	if err != nil {
+		logrus.Error(err)
+	}
+}
+
+func AddChatFilterRule(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	values := r.Form
+
+	token := values.Get("xsrf-token")
+	if !xsrftoken.Valid(token, config.Constants.CookieStoreSecret, "admin", "POST") {
+		http.Error(w, "invalid xsrf token", http.StatusBadRequest)
+		return
+	}
+
+	action, ok := chat.FilterActions[values.Get("action")]
+	if !ok {
+		http.Error(w, "Invalid action", http.StatusBadRequest)
+		return
+	}
+
+	rule, err := chat.NewFilterRule(values.Get("pattern"), values.Get("regex") == "true", action)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	jwt, _ := chelpers.GetToken(r)
+	mod := chelpers.GetPlayer(jwt)
+	if err := models.LogCustomAdminAction(mod.ID, fmt.Sprintf("Added chat filter rule #%d (%s: %s)", rule.ID, rule.Action.String(), rule.Pattern), 0); err != nil {
+		logrus.Error(err)
+	}
+
+	http.Redirect(w, r, "/admin/chatfilter", http.StatusSeeOther)
+}
+
+func RemoveChatFilterRule(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	values := r.Form
+
+	token := values.Get("xsrf-token")
+	if !xsrftoken.Valid(token, config.Constants.CookieStoreSecret, "admin", "POST") {
+		http.Error(w, "invalid xsrf token", http.StatusBadRequest)
+		return
+	}
+
+	id, err := strconv.ParseUint(values.Get("id"), 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid rule ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := chat.RemoveFilterRule(uint(id)); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	jwt, _ := chelpers.GetToken(r)
+	mod := chelpers.GetPlayer(jwt)
+	if err := models.LogCustomAdminAction(mod.ID, fmt.Sprintf("Removed chat filter rule #%d", id), 0); err != nil {
+		logrus.Error(err)
+	}
+
+	http.Redirect(w, r, "/admin/chatfilter", http.StatusSeeOther)
+}
@@ -64,6 +64,7 @@ func GetChatLogs(w http.ResponseWriter, r *http.Request) {
 
 	order := values.Get("order")
 	var results *gorm.DB
+	var playerID uint
 
 	if values.Get("room") == "" { //Retrieve all messages sent by a specific player
 		if steamID == "" {
@@ -71,7 +72,7 @@ func GetChatLogs(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		playerID := getPlayerID(steamID)
+		playerID = getPlayerID(steamID)
 		if playerID == 0 {
 			http.Error(w, fmt.Sprintf("Couldn't find player with Steam ID %s", steamID), http.StatusNotFound)
 			return
@@ -81,7 +82,7 @@ func GetChatLogs(w http.ResponseWriter, r *http.Request) {
 	} else if steamID == "" { //Retrieve all messages sent to a specfic room
 		results = db.DB.Preload("Player").Where("room = ? AND (created_at >= ? AND created_at <= ?)", room, from, to)
 	} else { //Retrieve all messages sent to a specific room and a speficic player
-		playerID := getPlayerID(steamID)
+		playerID = getPlayerID(steamID)
 		if playerID == 0 {
 			http.Error(w, fmt.Sprintf("Couldn't find player with Steam ID %s", steamID), http.StatusNotFound)
 			return
@@ -101,7 +102,10 @@ func GetChatLogs(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = chatLogsTempl.Execute(w, messages)
+	err = chatLogsTempl.Execute(w, map[string]interface{}{
+		"Messages": messages,
+		"Actions":  chat.GetModerationActions(playerID, room, from, to),
+	})
 	if err != nil {
 		logrus.Error(err)
 	}
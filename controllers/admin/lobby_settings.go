@@ -0,0 +1,210 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package admin
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/TF2Stadium/Helen/config"
+	"github.com/TF2Stadium/Helen/controllers/broadcaster"
+	chelpers "github.com/TF2Stadium/Helen/controllers/controllerhelpers"
+	"github.com/TF2Stadium/Helen/models"
+	"github.com/TF2Stadium/Helen/models/lobby_settings"
+	"github.com/TF2Stadium/Helen/models/player"
+	"golang.org/x/net/xsrftoken"
+)
+
+var lobbySettingsTempl *template.Template
+
+type settingsChange struct {
+	*lobbySettings.Change
+	Admin *player.Player
+}
+
+func ViewLobbySettings(w http.ResponseWriter, r *http.Request) {
+	var changes []settingsChange
+	for _, change := range lobbySettings.GetChanges(50) {
+		admin, _ := player.GetPlayerByID(change.PlayerID)
+		changes = append(changes, settingsChange{change, admin})
+	}
+
+	err := lobbySettingsTempl.Execute(w, map[string]interface{}{
+		"XSRFToken":  xsrftoken.Generate(config.Constants.CookieStoreSecret, "admin", "POST"),
+		"Formats":    lobbySettings.GetLobbyFormats(),
+		"Maps":       lobbySettings.GetLobbyMaps(),
+		"Leagues":    lobbySettings.GetLobbyLeagues(),
+		"Whitelists": lobbySettings.GetLobbyWhitelists(),
+		"Changes":    changes,
+	})
+	if err != nil {
+		logrus.Error(err)
+	}
+}
+
+//parseMapFormats parses a map's formats, like "sixes=1 highlander=2". Formats
+//without an importance have an importance of 0.
+func parseMapFormats(s string) (map[string]int, error) {
+	formats := make(map[string]int)
+	for _, field := range strings.FieldsFunc(s, isListSeparator) {
+		parts := strings.SplitN(field, "=", 2)
+		importance := 0
+		if len(parts) == 2 {
+			var err error
+			importance, err = strconv.Atoi(parts[1])
+			if err != nil {
+				return nil, fmt.Errorf("Invalid importance for format %q", parts[0])
+			}
+		}
+		formats[parts[0]] = importance
+	}
+	return formats, nil
+}
+
+//parseDescriptions parses league descriptions, one per line, like "cp: First to 5 rounds"
+func parseDescriptions(s string) (map[string]string, error) {
+	descriptions := make(map[string]string)
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("Invalid description %q, should be \"maptype: description\"", line)
+		}
+		descriptions[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+	return descriptions, nil
+}
+
+func isListSeparator(r rune) bool {
+	return r == ',' || r == ' '
+}
+
+func SaveLobbySetting(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	values := r.Form
+
+	token := values.Get("xsrf-token")
+	if !xsrftoken.Valid(token, config.Constants.CookieStoreSecret, "admin", "POST") {
+		http.Error(w, "invalid xsrf token", http.StatusBadRequest)
+		return
+	}
+
+	jwt, _ := chelpers.GetToken(r)
+	mod := chelpers.GetPlayer(jwt)
+
+	kind, name := values.Get("kind"), strings.TrimSpace(values.Get("name"))
+	prettyName := strings.TrimSpace(values.Get("prettyName"))
+
+	var err error
+	switch kind {
+	case "format":
+		err = lobbySettings.SaveFormat(mod.ID, name, prettyName, values.Get("important") == "true")
+
+	case "map":
+		var formats map[string]int
+		formats, err = parseMapFormats(values.Get("formats"))
+		if err == nil {
+			err = lobbySettings.SaveMap(mod.ID, name, formats)
+		}
+
+	case "league":
+		formats := make(map[string]bool)
+		for _, format := range strings.FieldsFunc(values.Get("formats"), isListSeparator) {
+			formats[format] = true
+		}
+
+		var descriptions map[string]string
+		descriptions, err = parseDescriptions(values.Get("descriptions"))
+		if err == nil {
+			err = lobbySettings.SaveLeague(mod.ID, name, prettyName, descriptions, formats)
+		}
+
+	case "whitelist":
+		var id int
+		id, err = strconv.Atoi(name)
+		if err != nil {
+			http.Error(w, "Invalid whitelist ID", http.StatusBadRequest)
+			return
+		}
+		err = lobbySettings.SaveWhitelist(mod.ID, id, prettyName, values.Get("league"), values.Get("format"))
+
+	default:
+		http.Error(w, "Invalid setting", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := models.LogCustomAdminAction(mod.ID, fmt.Sprintf("Saved lobby setting %s %s", kind, name), 0); err != nil {
+		logrus.Error(err)
+	}
+	broadcastLobbySettings()
+
+	http.Redirect(w, r, "/admin/lobbysettings/", http.StatusSeeOther)
+}
+
+func RemoveLobbySetting(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	values := r.Form
+
+	token := values.Get("xsrf-token")
+	if !xsrftoken.Valid(token, config.Constants.CookieStoreSecret, "admin", "POST") {
+		http.Error(w, "invalid xsrf token", http.StatusBadRequest)
+		return
+	}
+
+	jwt, _ := chelpers.GetToken(r)
+	mod := chelpers.GetPlayer(jwt)
+
+	kind, name := values.Get("kind"), values.Get("name")
+
+	var err error
+	switch kind {
+	case "format":
+		err = lobbySettings.RemoveFormat(mod.ID, name)
+	case "map":
+		err = lobbySettings.RemoveMap(mod.ID, name)
+	case "league":
+		err = lobbySettings.RemoveLeague(mod.ID, name)
+	case "whitelist":
+		var id int
+		id, err = strconv.Atoi(name)
+		if err != nil {
+			http.Error(w, "Invalid whitelist ID", http.StatusBadRequest)
+			return
+		}
+		err = lobbySettings.RemoveWhitelist(mod.ID, id)
+	default:
+		http.Error(w, "Invalid setting", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := models.LogCustomAdminAction(mod.ID, fmt.Sprintf("Removed lobby setting %s %s", kind, name), 0); err != nil {
+		logrus.Error(err)
+	}
+	broadcastLobbySettings()
+
+	http.Redirect(w, r, "/admin/lobbysettings/", http.StatusSeeOther)
+}
+
+//broadcastLobbySettings sends the changed settings to every connected client,
+//in the same format as the lobbySettingsList constant
+func broadcastLobbySettings() {
+	broadcaster.SendMessageToRoom("0_public", "lobbySettingsList", lobbySettings.LobbySettingsToJSON())
+}
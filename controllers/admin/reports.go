@@ -0,0 +1,152 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package admin
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/TF2Stadium/Helen/config"
+	chelpers "github.com/TF2Stadium/Helen/controllers/controllerhelpers"
+	"github.com/TF2Stadium/Helen/models"
+	"github.com/TF2Stadium/Helen/models/chat"
+	"github.com/TF2Stadium/Helen/models/player"
+	"golang.org/x/net/xsrftoken"
+)
+
+var (
+	reportsTempl *template.Template
+	reportTempl  *template.Template
+)
+
+// ban lengths available as one-click actions on the report page
+var banLengths = []struct {
+	Name     string
+	Duration time.Duration
+}{
+	{"1 day", 24 * time.Hour},
+	{"1 week", 7 * 24 * time.Hour},
+	{"1 month", 30 * 24 * time.Hour},
+	{"1 year", 365 * 24 * time.Hour},
+}
+
+func ViewReports(w http.ResponseWriter, r *http.Request) {
+	err := reportsTempl.Execute(w, player.GetCommunityReports(player.ReportOpen))
+	if err != nil {
+		logrus.Error(err)
+	}
+}
+
+func ViewReport(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(r.URL.Query().Get("id"), 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid report ID", http.StatusBadRequest)
+		return
+	}
+
+	report, err := player.GetCommunityReport(uint(id))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	messages, _ := chat.GetPlayerMessages(&report.Player)
+	bans, _ := report.Player.GetAllBans()
+
+	err = reportTempl.Execute(w, map[string]interface{}{
+		"XSRFToken":   xsrftoken.Generate(config.Constants.CookieStoreSecret, "admin", "POST"),
+		"Report":      report,
+		"Messages":    messages,
+		"Bans":        bans,
+		"Reports":     report.Player.GetCommunityReportsAgainst(),
+		"BanForms":    banForm,
+		"BanLengths":  banLengths,
+		"FrontendURL": config.Constants.LoginRedirectPath,
+	})
+	if err != nil {
+		logrus.Error(err)
+	}
+}
+
+func ReportAction(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	values := r.Form
+
+	token := values.Get("xsrf-token")
+	if !xsrftoken.Valid(token, config.Constants.CookieStoreSecret, "admin", "POST") {
+		http.Error(w, "invalid xsrf token", http.StatusBadRequest)
+		return
+	}
+
+	id, err := strconv.ParseUint(values.Get("id"), 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid report ID", http.StatusBadRequest)
+		return
+	}
+
+	report, err := player.GetCommunityReport(uint(id))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	jwt, _ := chelpers.GetToken(r)
+	mod := chelpers.GetPlayer(jwt)
+
+	if values.Get("action") == "dismiss" {
+		if err := report.Close(player.ReportDismissed, mod.ID); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		models.LogCustomAdminAction(mod.ID, fmt.Sprintf("Dismissed report #%d", report.ID), report.PlayerID)
+		fmt.Fprintf(w, "Report #%d dismissed", report.ID)
+		return
+	}
+
+	ban, ok := banTypes[values.Get("type")]
+	if !ok {
+		http.Error(w, "Invalid ban type", http.StatusBadRequest)
+		return
+	}
+
+	length, err := strconv.Atoi(values.Get("length"))
+	if err != nil || length < 0 || length >= len(banLengths) {
+		http.Error(w, "Invalid ban length", http.StatusBadRequest)
+		return
+	}
+
+	if report.State != player.ReportOpen {
+		http.Error(w, player.ErrReportClosed.Error(), http.StatusBadRequest)
+		return
+	}
+
+	until := time.Now().Add(banLengths[length].Duration)
+	reason := fmt.Sprintf("Reported for %s (report #%d)", report.Category.String(), report.ID)
+	if values.Get("reason") != "" {
+		reason = values.Get("reason")
+	}
+
+	// ban before closing the report, so it stays open if the ban fails.
+	// If another admin acts on the report at the same time, BanUntil
+	// extends the existing ban instead of adding a second one.
+	err = report.Player.BanUntil(until, ban, reason, mod.ID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := report.Close(player.ReportActioned, mod.ID); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	models.LogCustomAdminAction(mod.ID, fmt.Sprintf("Banned player (%s) for %s from report #%d", ban.String(), banLengths[length].Name, report.ID), report.PlayerID)
+	fmt.Fprintf(w, "Player %s (%s) has been banned (%s) till %v", report.Player.Name, report.Player.SteamID, ban.String(), until)
+}
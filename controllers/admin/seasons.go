@@ -0,0 +1,131 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package admin
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/TF2Stadium/Helen/config"
+	chelpers "github.com/TF2Stadium/Helen/controllers/controllerhelpers"
+	"github.com/TF2Stadium/Helen/models"
+	"github.com/TF2Stadium/Helen/models/leaderboard"
+	"golang.org/x/net/xsrftoken"
+)
+
+var seasonsTempl *template.Template
+
+func ViewSeasons(w http.ResponseWriter, r *http.Request) {
+	err := seasonsTempl.Execute(w, map[string]interface{}{
+		"XSRFToken": xsrftoken.Generate(config.Constants.CookieStoreSecret, "admin", "POST"),
+		"Seasons":   leaderboard.GetSeasons(),
+		"Interval":  config.Constants.LeaderboardInterval,
+	})
+	if err != nil {
+		logrus.Error(err)
+	}
+}
+
+func AddSeason(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	values := r.Form
+
+	token := values.Get("xsrf-token")
+	if !xsrftoken.Valid(token, config.Constants.CookieStoreSecret, "admin", "POST") {
+		http.Error(w, "invalid xsrf token", http.StatusBadRequest)
+		return
+	}
+
+	// seasons start and end at midnight UTC
+	start, err := time.Parse("2006-01-02", values.Get("start"))
+	if err != nil {
+		http.Error(w, "Invalid start date", http.StatusBadRequest)
+		return
+	}
+	end, err := time.Parse("2006-01-02", values.Get("end"))
+	if err != nil {
+		http.Error(w, "Invalid end date", http.StatusBadRequest)
+		return
+	}
+
+	season, err := leaderboard.NewSeason(values.Get("name"), start, end)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	jwt, _ := chelpers.GetToken(r)
+	mod := chelpers.GetPlayer(jwt)
+	if err := models.LogCustomAdminAction(mod.ID, fmt.Sprintf("Added season #%d (%s)", season.ID, season.Name), 0); err != nil {
+		logrus.Error(err)
+	}
+
+	http.Redirect(w, r, "/admin/seasons/", http.StatusSeeOther)
+}
+
+func RemoveSeason(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	values := r.Form
+
+	token := values.Get("xsrf-token")
+	if !xsrftoken.Valid(token, config.Constants.CookieStoreSecret, "admin", "POST") {
+		http.Error(w, "invalid xsrf token", http.StatusBadRequest)
+		return
+	}
+
+	id, err := strconv.ParseUint(values.Get("id"), 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid season ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := leaderboard.RemoveSeason(uint(id)); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	jwt, _ := chelpers.GetToken(r)
+	mod := chelpers.GetPlayer(jwt)
+	if err := models.LogCustomAdminAction(mod.ID, fmt.Sprintf("Removed season #%d", id), 0); err != nil {
+		logrus.Error(err)
+	}
+
+	http.Redirect(w, r, "/admin/seasons/", http.StatusSeeOther)
+}
+
+//ComputeSeason recomputes a season's leaderboards right away, instead of
+//waiting for the next periodic update
+func ComputeSeason(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	values := r.Form
+
+	token := values.Get("xsrf-token")
+	if !xsrftoken.Valid(token, config.Constants.CookieStoreSecret, "admin", "POST") {
+		http.Error(w, "invalid xsrf token", http.StatusBadRequest)
+		return
+	}
+
+	id, err := strconv.ParseUint(values.Get("id"), 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid season ID", http.StatusBadRequest)
+		return
+	}
+
+	season, err := leaderboard.GetSeason(uint(id))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err := season.Compute(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/seasons/", http.StatusSeeOther)
+}
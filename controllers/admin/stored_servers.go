@@ -46,6 +46,8 @@ func AddServer(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	// the server's region and status are known before the next round of checks
+	go server.Check()
 
 	w.WriteHeader(http.StatusOK)
 	fmt.Fprintf(w, "Server successfully added (ID: #%d)", server.ID)
@@ -71,12 +73,29 @@ func RemoveServer(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintf(w, "Server successfully deleted.")
 }
 
+//ViewServerPage shows the status of all stored servers, from their last
+//health check
 func ViewServerPage(w http.ResponseWriter, r *http.Request) {
 	err := serverPage.Execute(w, map[string]interface{}{
-		"XSRFToken": xsrftoken.Generate(config.Constants.CookieStoreSecret, "admin", "POST"),
-		"Servers":   gameserver.GetAllStoredServers(),
+		"XSRFToken":     xsrftoken.Generate(config.Constants.CookieStoreSecret, "admin", "POST"),
+		"Servers":       gameserver.GetAllStoredServers(),
+		"CheckInterval": config.Constants.ServerCheckInterval,
 	})
 	if err != nil {
 		logrus.Error(err)
 	}
 }
+
+//CheckServers checks the health of all stored servers right away
+func CheckServers(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+
+	token := r.Form.Get("xsrf-token")
+	if !xsrftoken.Valid(token, config.Constants.CookieStoreSecret, "admin", "POST") {
+		http.Error(w, "invalid xsrf token", http.StatusBadRequest)
+		return
+	}
+
+	gameserver.CheckAllServers()
+	http.Redirect(w, r, "/admin/server/", http.StatusSeeOther)
+}
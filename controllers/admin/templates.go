@@ -9,5 +9,16 @@ func InitAdminTemplates() {
 	banlogsTempl = template.Must(template.ParseFiles("views/admin/templates/ban_logs.html"))
 	chatLogsTempl = template.Must(template.ParseFiles("views/admin/templates/chatlogs.html"))
 	lobbiesTempl = template.Must(template.ParseFiles("views/admin/templates/lobbies.html"))
+	appealsTempl = template.Must(template.ParseFiles("views/admin/templates/appeals.html"))
+	reportsTempl = template.Must(template.ParseFiles("views/admin/templates/reports.html"))
+	reportTempl = template.Must(template.ParseFiles("views/admin/templates/report.html"))
+	banRulesTempl = template.Must(template.ParseFiles("views/admin/templates/ban_rules.html"))
+	banRulePreviewTempl = template.Must(template.ParseFiles("views/admin/templates/ban_rule_preview.html"))
+	playerTempl = template.Must(template.ParseFiles("views/admin/templates/player.html"))
+	altFlagsTempl = template.Must(template.ParseFiles("views/admin/templates/alts.html"))
+	chatFilterTempl = template.Must(template.ParseFiles("views/admin/templates/chatfilter.html"))
+	webhooksTempl = template.Must(template.ParseFiles("views/admin/templates/webhooks.html"))
+	seasonsTempl = template.Must(template.ParseFiles("views/admin/templates/seasons.html"))
+	lobbySettingsTempl = template.Must(template.ParseFiles("views/admin/templates/lobby_settings.html"))
 	adminPageTempl = template.Must(template.ParseFiles("views/admin/index.html"))
 }
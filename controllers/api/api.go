@@ -0,0 +1,66 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+//Package api contains handlers for the read-only HTTP API under /api/v1.
+//Responses use the same decorators as the socket handlers, so their JSON
+//matches the socket payloads.
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+)
+
+const (
+	defaultLimit = 20
+	maxLimit     = 100
+)
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logrus.Error(err)
+	}
+}
+
+func writeError(w http.ResponseWriter, code int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(struct {
+		Error string `json:"error"`
+	}{msg})
+}
+
+//checkMethod returns false (and writes an error) if the request isn't a GET request
+func checkMethod(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		w.Header().Set("Allow", "GET, HEAD")
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return false
+	}
+	return true
+}
+
+//pathParts returns the parts of the request path after prefix
+func pathParts(r *http.Request, prefix string) []string {
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, prefix), "/")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
+
+//queryInt returns the integer value of the given query parameter, or def if
+//it isn't given
+func queryInt(r *http.Request, key string, def int) (int, error) {
+	str := r.URL.Query().Get(key)
+	if str == "" {
+		return def, nil
+	}
+	return strconv.Atoi(str)
+}
@@ -0,0 +1,56 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/TF2Stadium/Helen/models/leaderboard"
+	"github.com/TF2Stadium/Helen/models/lobby/format"
+)
+
+//Seasons serves the list of leaderboard seasons (GET /api/v1/seasons)
+func Seasons(w http.ResponseWriter, r *http.Request) {
+	if !checkMethod(w, r) {
+		return
+	}
+
+	writeJSON(w, leaderboard.GetSeasons())
+}
+
+//Leaderboard serves a leaderboard (GET /api/v1/leaderboards). The
+//leaderboard is picked with the category, format, class (only for the hours
+//category), region and season parameters. The current season and all
+//regions are used if season or region aren't given.
+func Leaderboard(w http.ResponseWriter, r *http.Request) {
+	if !checkMethod(w, r) {
+		return
+	}
+
+	query := r.URL.Query()
+	f, ok := format.GetByName(query.Get("format"))
+	if !ok {
+		writeError(w, http.StatusBadRequest, leaderboard.ErrInvalidFormat.Error())
+		return
+	}
+
+	season, err := queryInt(r, "season", 0)
+	if err != nil || season < 0 {
+		writeError(w, http.StatusBadRequest, "Invalid season ID")
+		return
+	}
+
+	board, err := leaderboard.Get(uint(season), query.Get("category"), query.Get("class"), f, query.Get("region"))
+	switch {
+	case err == leaderboard.ErrSeasonNotFound:
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	case err != nil:
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	writeJSON(w, board)
+}
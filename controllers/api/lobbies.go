@@ -0,0 +1,65 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	db "github.com/TF2Stadium/Helen/database"
+	"github.com/TF2Stadium/Helen/models/lobby"
+)
+
+var lobbyStates = map[string][]lobby.State{
+	"open":       {lobby.Waiting, lobby.Scheduled, lobby.Drafting},
+	"inprogress": {lobby.MapVoting, lobby.ReadyingUp, lobby.InProgress},
+	"":           {lobby.Waiting, lobby.Scheduled, lobby.Drafting, lobby.MapVoting, lobby.ReadyingUp, lobby.InProgress},
+}
+
+//Lobbies lists open and in-progress lobbies (GET /api/v1/lobbies). The state
+//query parameter can be "open" or "inprogress" to only list those lobbies.
+func Lobbies(w http.ResponseWriter, r *http.Request) {
+	if !checkMethod(w, r) {
+		return
+	}
+
+	states, ok := lobbyStates[r.URL.Query().Get("state")]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "state must be open or inprogress")
+		return
+	}
+
+	var lobbies []*lobby.Lobby
+	db.DB.Where("state IN (?)", states).Order("id desc").Find(&lobbies)
+
+	writeJSON(w, lobby.LobbyListData{Lobbies: lobby.DecorateLobbyListData(lobbies, false)})
+}
+
+//Lobby returns a lobby with its slots (GET /api/v1/lobbies/<id>)
+func Lobby(w http.ResponseWriter, r *http.Request) {
+	if !checkMethod(w, r) {
+		return
+	}
+
+	parts := pathParts(r, "/api/v1/lobbies/")
+	if len(parts) != 1 {
+		writeError(w, http.StatusNotFound, "Not found")
+		return
+	}
+
+	id, err := strconv.ParseUint(parts[0], 10, 32)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid lobby ID")
+		return
+	}
+
+	lob, err := lobby.GetLobbyByID(uint(id))
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	writeJSON(w, lobby.DecorateLobbyData(lob, true))
+}
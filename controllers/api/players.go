@@ -0,0 +1,127 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package api
+
+import (
+	"net/http"
+
+	chelpers "github.com/TF2Stadium/Helen/controllers/controllerhelpers"
+	db "github.com/TF2Stadium/Helen/database"
+	"github.com/TF2Stadium/Helen/models/lobby"
+	"github.com/TF2Stadium/Helen/models/player"
+)
+
+//Player serves player profiles (GET /api/v1/players/<steamid>), their
+//match history (GET /api/v1/players/<steamid>/lobbies) and their record
+//against another player (GET /api/v1/players/<steamid>/versus/<steamid>)
+func Player(w http.ResponseWriter, r *http.Request) {
+	if !checkMethod(w, r) {
+		return
+	}
+
+	parts := pathParts(r, "/api/v1/players/")
+	switch {
+	case len(parts) == 1:
+	case len(parts) == 2 && parts[1] == "lobbies":
+	case len(parts) == 3 && parts[1] == "versus":
+	default:
+		writeError(w, http.StatusNotFound, "Not found")
+		return
+	}
+
+	p, err := player.GetPlayerBySteamID(parts[0])
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	switch len(parts) {
+	case 2:
+		playerLobbies(w, r, p)
+		return
+	case 3:
+		other, err := player.GetPlayerBySteamID(parts[2])
+		if err != nil {
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		writeJSON(w, p.GetHeadToHead(other))
+		return
+	}
+
+	p.SetPlayerProfile()
+	writeJSON(w, p)
+}
+
+//playerLobbies returns a page of lobbies the player has played in, newest
+//first. Pages are requested with the limit and before (lobby ID) parameters,
+//next is the value of before for the next page.
+func playerLobbies(w http.ResponseWriter, r *http.Request, p *player.Player) {
+	limit, err := queryInt(r, "limit", defaultLimit)
+	if err != nil || limit <= 0 {
+		writeError(w, http.StatusBadRequest, "Invalid limit")
+		return
+	}
+	if limit > maxLimit {
+		limit = maxLimit
+	}
+
+	before, err := queryInt(r, "before", 0)
+	if err != nil || before < 0 {
+		writeError(w, http.StatusBadRequest, "Invalid lobby ID")
+		return
+	}
+
+	query := db.DB.Model(&lobby.Lobby{}).Joins("INNER JOIN lobby_slots ON lobbies.ID = lobby_slots.lobby_id").
+		Where("lobbies.match_ended = TRUE and lobby_slots.player_id = ? AND lobby_slots.needs_sub = FALSE", p.ID)
+	if before != 0 {
+		query = query.Where("lobbies.ID < ?", before)
+	}
+
+	var lobbies []*lobby.Lobby
+	query.Order("lobbies.id desc").Limit(limit).Find(&lobbies)
+
+	resp := struct {
+		Lobbies []lobby.LobbyData `json:"lobbies"`
+		Next    uint              `json:"next,omitempty"`
+	}{Lobbies: lobby.DecorateMatchHistory(lobbies)}
+	if len(lobbies) == limit {
+		resp.Next = lobbies[len(lobbies)-1].ID
+	}
+
+	writeJSON(w, resp)
+}
+
+//Me serves the profile of the player making the request (GET /api/v1/me).
+//Requests need to be logged in, or have an API token with the profile:read scope.
+func Me(w http.ResponseWriter, r *http.Request) {
+	if !checkMethod(w, r) {
+		return
+	}
+
+	token, err := chelpers.GetToken(r)
+	switch {
+	case err == chelpers.ErrRateLimited:
+		writeError(w, http.StatusTooManyRequests, err.Error())
+		return
+	case err != nil:
+		writeError(w, http.StatusUnauthorized, "Not logged in")
+		return
+	}
+
+	if !token.Claims.(*chelpers.TF2StadiumClaims).HasScope(player.ScopeReadProfile) {
+		writeError(w, http.StatusForbidden, chelpers.ErrNoScope.Error())
+		return
+	}
+
+	p := chelpers.GetPlayer(token)
+	if p == nil {
+		writeError(w, http.StatusUnauthorized, "Not logged in")
+		return
+	}
+
+	p.SetPlayerProfile()
+	writeJSON(w, p)
+}
@@ -31,6 +31,46 @@ func SendMessageToRoom(r string, event string, content interface{}) {
 
 	socket.AuthServer.BroadcastJSON(r, v)
 	socket.UnauthServer.BroadcastJSON(r, v)
+	socket.TokenServer.BroadcastJSON(r, v)
+}
+
+func inRoom(server *wsevent.Server, so *wsevent.Client, r string) bool {
+	for _, room := range server.RoomsJoined(so.ID) {
+		if room == r {
+			return true
+		}
+	}
+	return false
+}
+
+//SendMessageToRoomSkipPlayers sends the message to the room, except to the
+//sockets connected from the given steamids
+func SendMessageToRoomSkipPlayers(r string, skip []string, event string, content interface{}) {
+	if len(skip) == 0 {
+		SendMessageToRoom(r, event, content)
+		return
+	}
+
+	skipped := make(map[string]bool)
+	for _, steamid := range skip {
+		skipped[steamid] = true
+	}
+
+	v := helpers.NewRequest(event, content)
+	// sockets which aren't logged in don't belong to any player
+	socket.UnauthServer.BroadcastJSON(r, v)
+
+	for steamid, sockets := range sessions.GetAllSockets() {
+		if skipped[steamid] {
+			continue
+		}
+
+		for _, so := range sockets {
+			if inRoom(socket.AuthServer, so, r) || inRoom(socket.TokenServer, so, r) {
+				go so.EmitJSON(v)
+			}
+		}
+	}
 }
 
 func SendMessageSkipIDs(skipID, steamid, event string, content interface{}) {
@@ -0,0 +1,129 @@
+package controllerhelpers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TF2Stadium/Helen/config"
+	"github.com/TF2Stadium/Helen/models/player"
+	"github.com/TF2Stadium/wsevent"
+	"github.com/dgrijalva/jwt-go"
+)
+
+var (
+	ErrRateLimited = errors.New("Rate limit exceeded, try again later")
+	ErrNoScope     = errors.New("The API token doesn't have the scope needed for this request")
+)
+
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+var (
+	rateMu      = new(sync.Mutex)
+	rateWindows = make(map[uint]*rateWindow)
+	lastPrune   = time.Now()
+)
+
+// pruneWindows removes the windows of tokens which haven't been used in the
+// last minute, so tokens which stop being used don't stay in the map. rateMu
+// needs to be held.
+func pruneWindows() {
+	if time.Since(lastPrune) < time.Minute {
+		return
+	}
+
+	for id, window := range rateWindows {
+		if time.Since(window.start) >= time.Minute {
+			delete(rateWindows, id)
+		}
+	}
+	lastPrune = time.Now()
+}
+
+//allowRequest returns false if the token has made more requests than it's
+//allowed to in the current minute
+func allowRequest(tokenID uint, limit int) bool {
+	if limit <= 0 {
+		limit = config.Constants.APITokenRateLimit
+	}
+
+	rateMu.Lock()
+	defer rateMu.Unlock()
+	pruneWindows()
+
+	window, ok := rateWindows[tokenID]
+	if !ok || time.Since(window.start) >= time.Minute {
+		window = &rateWindow{start: time.Now()}
+		rateWindows[tokenID] = window
+	}
+
+	window.count++
+	return window.count <= limit
+}
+
+//bearerToken returns the token in the request's Authorization header, if any
+func bearerToken(r *http.Request) (string, bool) {
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return "", false
+	}
+
+	return strings.TrimSpace(strings.TrimPrefix(header, "Bearer ")), true
+}
+
+//getAPIToken returns a jwt token with claims for the player who owns the API token
+func getAPIToken(str string) (*jwt.Token, error) {
+	apiToken, err := player.GetAPIToken(str)
+	if err != nil {
+		return nil, err
+	}
+	if !allowRequest(apiToken.ID, apiToken.RateLimit) {
+		return nil, ErrRateLimited
+	}
+
+	p, err := player.GetPlayerByID(apiToken.PlayerID)
+	if err != nil {
+		return nil, err
+	}
+	apiToken.Used()
+
+	claims := &TF2StadiumClaims{
+		PlayerID:       p.ID,
+		SteamID:        p.SteamID,
+		MumblePassword: p.MumbleAuthkey,
+		Role:           p.Role,
+		IssuedAt:       time.Now().Unix(),
+		Issuer:         config.Constants.PublicAddress,
+		APIToken:       apiToken,
+	}
+
+	return &jwt.Token{Claims: claims, Valid: true}, nil
+}
+
+//CheckScope returns an error if the socket was authenticated with an API
+//token which doesn't have the given scope, has exceeded its rate limit, or
+//has been revoked since the socket connected.
+func CheckScope(so *wsevent.Client, scope string) error {
+	apiToken := so.Token.Claims.(*TF2StadiumClaims).APIToken
+	if apiToken == nil {
+		return nil
+	}
+	if !apiToken.HasScope(scope) {
+		return ErrNoScope
+	}
+	if apiToken.Revoked() {
+		return player.ErrTokenNotFound
+	}
+
+	if !allowRequest(apiToken.ID, apiToken.RateLimit) {
+		return ErrRateLimited
+	}
+	apiToken.Used()
+
+	return nil
+}
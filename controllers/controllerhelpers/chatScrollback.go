@@ -12,5 +12,23 @@ func BroadcastScrollback(so *wsevent.Client, room uint) {
 		return
 	}
 
+	// hide messages from players the player has blocked
+	if so.Token != nil {
+		if p := GetPlayer(so.Token); p != nil {
+			blocked := make(map[uint]bool)
+			for _, id := range p.GetBlockedIDs() {
+				blocked[id] = true
+			}
+
+			visible := messages[:0]
+			for _, message := range messages {
+				if !blocked[message.PlayerID] {
+					visible = append(visible, message)
+				}
+			}
+			messages = visible
+		}
+	}
+
 	so.EmitJSON(helpers.NewRequest("chatScrollback", messages))
 }
@@ -13,6 +13,16 @@ type TF2StadiumClaims struct {
 	Role           authority.AuthRole `json:"role"`
 	IssuedAt       int64              `json:"iat"`
 	Issuer         string             `json:"iss"`
+
+	// set when the request was authenticated with an API token
+	// instead of a JWT cookie
+	APIToken *player.APIToken `json:"-"`
+}
+
+//HasScope returns whether the claims allow actions needing the given scope.
+//Claims from JWT cookies have all scopes.
+func (c TF2StadiumClaims) HasScope(scope string) bool {
+	return c.APIToken == nil || c.APIToken.HasScope(scope)
 }
 
 func playerExists(id uint, steamID string) bool {
@@ -87,6 +87,11 @@ func FilterHTTPRequest(action authority.AuthAction, f func(http.ResponseWriter,
 			return
 		}
 
+		if token.Claims.(*TF2StadiumClaims).APIToken != nil {
+			http.Error(w, "API tokens can't be used for this page", 403)
+			return
+		}
+
 		if !(token.Claims.(*TF2StadiumClaims).Role.Can(action)) {
 			http.Error(w, "Not authorized", 403)
 			return
@@ -0,0 +1,83 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package hooks
+
+import (
+	"github.com/TF2Stadium/Helen/controllers/broadcaster"
+	"github.com/TF2Stadium/Helen/controllers/socket/sessions"
+	"github.com/TF2Stadium/Helen/helpers"
+	"github.com/TF2Stadium/Helen/models/chat"
+	"github.com/TF2Stadium/Helen/models/player"
+	"github.com/TF2Stadium/wsevent"
+)
+
+// Presence is shown to a player's friends
+type Presence struct {
+	SteamID string `json:"steamid"`
+	Name    string `json:"name"`
+	Avatar  string `json:"avatar"`
+	Online  bool   `json:"online"`
+	LobbyID uint   `json:"lobbyID,omitempty"` // lobby the player has a slot in
+}
+
+// GetPresence returns the player's presence
+func GetPresence(p *player.Player) Presence {
+	presence := Presence{
+		SteamID: p.SteamID,
+		Name:    p.Alias(),
+		Avatar:  p.Avatar,
+		Online:  sessions.IsConnected(p.SteamID),
+	}
+	if presence.Online {
+		presence.LobbyID, _ = p.GetLobbyID(false)
+	}
+
+	return presence
+}
+
+// GetFriendList returns the presence of all of the player's friends
+func GetFriendList(p *player.Player) []Presence {
+	list := []Presence{}
+	for _, friend := range p.GetFriends() {
+		list = append(list, GetPresence(friend))
+	}
+
+	return list
+}
+
+// BroadcastPresence sends the player's presence to all friends who are online
+func BroadcastPresence(p *player.Player) {
+	presence := GetPresence(p)
+	for _, friend := range p.GetFriends() {
+		broadcaster.SendMessage(friend.SteamID, "friendPresence", presence)
+	}
+}
+
+// SendBlockList sends the steamids of players the player has blocked to all
+// of their sockets, so their messages can be hidden in rooms
+func SendBlockList(p *player.Player) {
+	broadcaster.SendMessage(p.SteamID, "playerBlockList", blockList(p))
+}
+
+func blockList(p *player.Player) []string {
+	steamids := []string{}
+	for _, blocked := range p.GetBlockedPlayers() {
+		steamids = append(steamids, blocked.SteamID)
+	}
+
+	return steamids
+}
+
+func sendFriendsInfo(so *wsevent.Client, p *player.Player) {
+	requests := []Presence{}
+	for _, requester := range p.GetFriendRequests() {
+		requests = append(requests, GetPresence(requester))
+	}
+
+	so.EmitJSON(helpers.NewRequest("friendList", GetFriendList(p)))
+	so.EmitJSON(helpers.NewRequest("friendRequests", requests))
+	so.EmitJSON(helpers.NewRequest("directMessageUnread", chat.GetUnreadDirectMessages(p)))
+	so.EmitJSON(helpers.NewRequest("playerBlockList", blockList(p)))
+}
@@ -13,20 +13,30 @@ import (
 	"github.com/TF2Stadium/Helen/controllers/broadcaster"
 	chelpers "github.com/TF2Stadium/Helen/controllers/controllerhelpers"
 	"github.com/TF2Stadium/Helen/controllers/socket/sessions"
+	"github.com/TF2Stadium/Helen/helpers"
 	"github.com/TF2Stadium/Helen/models/chat"
 	"github.com/TF2Stadium/Helen/models/lobby"
 	"github.com/TF2Stadium/Helen/models/player"
+	"github.com/TF2Stadium/Helen/models/webhook"
 	"github.com/TF2Stadium/Helen/routes/socket"
 	"github.com/TF2Stadium/wsevent"
 )
 
+//serverFor returns the wsevent server the socket was added to
+func serverFor(so *wsevent.Client) *wsevent.Server {
+	if so.Token != nil && so.Token.Claims.(*chelpers.TF2StadiumClaims).APIToken != nil {
+		return socket.TokenServer
+	}
+	return socket.AuthServer
+}
+
 func AfterLobbyJoin(so *wsevent.Client, lob *lobby.Lobby, player *player.Player) {
 	room := fmt.Sprintf("%s_private", GetLobbyRoom(lob.ID))
 	//make all sockets join the private room, given the one the player joined the lobby on
 	//might close, so lobbyStart and lobbyReadyUp can be sent to other tabs
 	sockets, _ := sessions.GetSockets(player.SteamID)
 	for _, so := range sockets {
-		socket.AuthServer.Join(so, room)
+		serverFor(so).Join(so, room)
 	}
 	if lob.State == lobby.InProgress { // player is a substitute
 		lob.AfterPlayerNotInGameFunc(player, 5*time.Minute, func() {
@@ -39,6 +49,7 @@ func AfterLobbyJoin(so *wsevent.Client, lob *lobby.Lobby, player *player.Player)
 	}
 
 	broadcaster.SendMessage(player.SteamID, "lobbyJoined", lobby.DecorateLobbyData(lob, false))
+	BroadcastPresence(player)
 }
 
 func AfterLobbyLeave(lob *lobby.Lobby, player *player.Player, kicked bool, notReady bool) {
@@ -53,7 +64,86 @@ func AfterLobbyLeave(lob *lobby.Lobby, player *player.Player, kicked bool, notRe
 	sockets, _ := sessions.GetSockets(player.SteamID)
 	//player might have connected from multiple tabs, remove all of them from the room
 	for _, so := range sockets {
-		socket.AuthServer.Leave(so, fmt.Sprintf("%s_private", GetLobbyRoom(lob.ID)))
+		serverFor(so).Leave(so, fmt.Sprintf("%s_private", GetLobbyRoom(lob.ID)))
+	}
+	BroadcastPresence(player)
+}
+
+//StartReadyUp starts the ready up phase for the lobby if all of it's slots
+//have been filled, and it isn't already readying up or in progress (which
+//happens when the player is subbing). Scheduled lobbies are readied up
+//after their server has been set up. Lobbies with a map pool vote for the
+//map first, and are readied up once the vote ends.
+func StartReadyUp(lob *lobby.Lobby) {
+	startReadyUp(lob, true)
+}
+
+//AfterMapVote readies up the lobby once players have voted for the map. The
+//lobbyFilled webhook has already been sent when the vote started.
+func AfterMapVote(lob *lobby.Lobby) {
+	startReadyUp(lob, false)
+}
+
+func startReadyUp(lob *lobby.Lobby, filled bool) {
+	playersCnt := lob.GetPlayerNumber()
+
+	if lob.IsEnoughPlayers(playersCnt) && lob.State == lobby.Waiting && lob.StartMapVote() {
+		return
+	}
+
+	lob.Lock()
+	defer lob.Unlock()
+
+	if !lob.IsEnoughPlayers(playersCnt) || lob.State == lobby.InProgress || lob.State == lobby.ReadyingUp ||
+		lob.State == lobby.Scheduled || lob.State == lobby.MapVoting {
+		return
+	}
+
+	lob.State = lobby.ReadyingUp
+	lob.ReadyUpTimestamp = time.Now().Unix() + 30
+	lob.Save()
+	if filled {
+		lob.SendWebhook(webhook.LobbyFilled)
+	}
+	lob.SendWebhook(webhook.ReadyUpStarted)
+
+	helpers.GlobalWait.Add(1)
+	time.AfterFunc(time.Second*30, func() {
+		state := lob.CurrentState()
+		//if all player's haven't readied up,
+		//remove unreadied players and unready the
+		//rest.
+		//don't do this when:
+		//  lobby.State == Waiting (someone already unreadied up, so all players have been unreadied)
+		// lobby.State == InProgress (all players have readied up, so the lobby has started)
+		// lobby.State == Ended (the lobby has been closed)
+		if state != lobby.Waiting && state != lobby.InProgress && state != lobby.Ended {
+			lob.SetState(lobby.Waiting)
+			removeUnreadyPlayers(lob)
+			lob.UnreadyAllPlayers()
+			//get updated lobby object
+			lob, _ = lobby.GetLobbyByID(lob.ID)
+			lobby.BroadcastLobby(lob)
+		}
+		helpers.GlobalWait.Done()
+	})
+
+	room := fmt.Sprintf("%s_private", GetLobbyRoom(lob.ID))
+	broadcaster.SendMessageToRoom(room, "lobbyReadyUp",
+		struct {
+			Timeout int `json:"timeout"`
+		}{30})
+	lobby.BroadcastLobbyList()
+}
+
+//get list of unready players, remove them from lobby (and add them as spectators)
+//plus, call the after lobby leave hook for each player removed
+func removeUnreadyPlayers(lobby *lobby.Lobby) {
+	players := lobby.GetUnreadyPlayers()
+	lobby.RemoveUnreadyPlayers(true)
+
+	for _, player := range players {
+		AfterLobbyLeave(lobby, player, false, true)
 	}
 }
 
@@ -75,7 +165,7 @@ func AfterLobbySpec(server *wsevent.Server, so *wsevent.Client, player *player.P
 }
 
 func AfterLobbySpecLeave(so *wsevent.Client, lob *lobby.Lobby) {
-	socket.AuthServer.Leave(so, fmt.Sprintf("%s_public", GetLobbyRoom(lob.ID)))
+	serverFor(so).Leave(so, fmt.Sprintf("%s_public", GetLobbyRoom(lob.ID)))
 	sessions.RemoveSpectator(so.ID)
 }
 
@@ -15,7 +15,6 @@ import (
 	"github.com/TF2Stadium/Helen/helpers"
 	"github.com/TF2Stadium/Helen/models/lobby"
 	"github.com/TF2Stadium/Helen/models/player"
-	"github.com/TF2Stadium/Helen/routes/socket"
 	"github.com/TF2Stadium/wsevent"
 )
 
@@ -31,6 +30,9 @@ var emptyMap = make(map[string]string)
 
 func AfterConnectLoggedIn(so *wsevent.Client, player *player.Player) {
 	sessions.AddSocket(player.SteamID, so)
+	if sessions.ConnectedSockets(player.SteamID) == 1 {
+		BroadcastPresence(player)
+	}
 
 	if time.Since(player.ProfileUpdatedAt) >= 30*time.Minute {
 		err := player.UpdatePlayerInfo()
@@ -43,7 +45,7 @@ func AfterConnectLoggedIn(so *wsevent.Client, player *player.Player) {
 	if err == nil {
 		lob, _ := lobby.GetLobbyByIDServer(lobbyID)
 		AfterLobbyJoin(so, lob, player)
-		AfterLobbySpec(socket.AuthServer, so, player, lob)
+		AfterLobbySpec(serverFor(so), so, player, lob)
 		lobby.BroadcastLobbyToUser(lob, so.Token.Claims.(*chelpers.TF2StadiumClaims).SteamID)
 
 		slot := &lobby.LobbySlot{}
@@ -58,6 +60,8 @@ func AfterConnectLoggedIn(so *wsevent.Client, player *player.Player) {
 				}{lob.ReadyUpTimeLeft()}
 
 				so.EmitJSON(helpers.NewRequest("lobbyReadyUp", data))
+			} else if lob.State == lobby.MapVoting {
+				so.EmitJSON(helpers.NewRequest("lobbyMapVote", lobby.DecorateMapVote(lob)))
 			}
 		}
 	}
@@ -68,6 +72,8 @@ func AfterConnectLoggedIn(so *wsevent.Client, player *player.Player) {
 		so.EmitJSON(helpers.NewRequest("playerSettings", emptyMap))
 	}
 
+	sendFriendsInfo(so, player)
+
 	player.SetPlayerProfile()
 	so.EmitJSON(helpers.NewRequest("playerProfile", player))
 	so.EmitJSON(helpers.NewRequest("mumbleInfo", struct {
@@ -7,9 +7,11 @@ package hooks
 import (
 	"time"
 
+	"github.com/TF2Stadium/Helen/controllers/broadcaster"
 	chelpers "github.com/TF2Stadium/Helen/controllers/controllerhelpers"
 	"github.com/TF2Stadium/Helen/controllers/socket/sessions"
 	"github.com/TF2Stadium/Helen/models/lobby"
+	"github.com/TF2Stadium/Helen/models/queue"
 	"github.com/dgrijalva/jwt-go"
 )
 
@@ -22,6 +24,9 @@ func OnDisconnect(socketID string, token *jwt.Token) {
 		}
 
 		sessions.RemoveSocket(socketID, player.SteamID)
+		if sessions.ConnectedSockets(player.SteamID) == 0 {
+			BroadcastPresence(player)
+		}
 		id, _ := sessions.GetSpectating(socketID)
 		if id != 0 {
 			lob, _ := lobby.GetLobbyByID(id)
@@ -39,6 +44,16 @@ func OnDisconnect(socketID string, token *jwt.Token) {
 				}
 			})
 		}
+
+		//players who aren't connected can't ready up, so remove them from the
+		//matchmaking queue as well
+		if _, _, queued := queue.IsQueued(player.ID); queued && sessions.ConnectedSockets(player.SteamID) == 0 {
+			sessions.AfterDisconnectedFunc(player.SteamID, time.Second*30, func() {
+				if queue.Leave(player.ID) == nil {
+					broadcaster.SendMessageToRoom("0_public", "queueStatus", queue.GetStatus())
+				}
+			})
+		}
 	}
 
 }
@@ -1,21 +1,47 @@
 package controllerhelpers
 
 import (
+	"net"
 	"net/http"
-	"regexp"
-)
+	"strings"
 
-var reIP = regexp.MustCompile(`(\d+\.\d+\.\d+\.\d+)`)
+	"github.com/TF2Stadium/Helen/config"
+)
 
+//GetIPAddr returns the IP address of the client. Anyone can set the X-Real-IP
+//header, so it's only used for requests from one of the trusted proxies.
 func GetIPAddr(r *http.Request) string {
-	ip := r.Header.Get("X-Real-IP")
-	if ip != "" {
-		return reIP.FindStringSubmatch(ip)[1]
+	remote := parseIP(r.RemoteAddr)
+	if remote != nil && isTrustedProxy(remote) {
+		if ip := net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-IP"))); ip != nil {
+			return ip.String()
+		}
 	}
 
-	if reIP.MatchString(r.RemoteAddr) {
-		return reIP.FindStringSubmatch(r.RemoteAddr)[1]
+	if remote != nil {
+		return remote.String()
 	}
-
 	return "127.0.0.1"
 }
+
+//parseIP parses an IP address, with or without a port
+func parseIP(addr string) net.IP {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	return net.ParseIP(addr)
+}
+
+func isTrustedProxy(ip net.IP) bool {
+	for _, proxy := range config.Constants.TrustedProxies {
+		if strings.Contains(proxy, "/") {
+			if _, network, err := net.ParseCIDR(proxy); err == nil && network.Contains(ip) {
+				return true
+			}
+		} else if trusted := net.ParseIP(proxy); trusted != nil && trusted.Equal(ip) {
+			return true
+		}
+	}
+
+	return false
+}
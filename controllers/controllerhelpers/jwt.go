@@ -57,7 +57,19 @@ func verifyToken(token *jwt.Token) (interface{}, error) {
 	return signingKey, nil
 }
 
+//GetToken returns the token for the request, either from the JWT cookie,
+//or from the API token in the Authorization header
 func GetToken(r *http.Request) (*jwt.Token, error) {
+	if str, ok := bearerToken(r); ok {
+		return getAPIToken(str)
+	}
+
+	return GetCookieToken(r)
+}
+
+//GetCookieToken returns the token from the JWT cookie, for pages which can't
+//be used with API tokens (like those changing account settings)
+func GetCookieToken(r *http.Request) (*jwt.Token, error) {
 	cookie, err := r.Cookie("auth-jwt")
 	if err != nil {
 		return nil, err
@@ -148,6 +148,8 @@ func SteamLoginCallbackHandler(w http.ResponseWriter, r *http.Request) {
 		database.DB.Create(p)
 	}
 
+	p.RecordIP(controllerhelpers.GetIPAddr(r), player.IPSourceSteam)
+
 	go func() {
 		if time.Since(p.ProfileUpdatedAt) >= 1*time.Hour {
 			err := p.UpdatePlayerInfo()
@@ -25,7 +25,7 @@ type userInfo struct {
 }
 
 func TwitchLoginHandler(w http.ResponseWriter, r *http.Request) {
-	token, err := controllerhelpers.GetToken(r)
+	token, err := controllerhelpers.GetCookieToken(r)
 	if err == http.ErrNoCookie {
 		http.Error(w, "You are not logged in.", http.StatusUnauthorized)
 		return
@@ -58,7 +58,7 @@ func TwitchLoginHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func TwitchAuthHandler(w http.ResponseWriter, r *http.Request) {
-	token, err := controllerhelpers.GetToken(r)
+	token, err := controllerhelpers.GetCookieToken(r)
 	if err == http.ErrNoCookie {
 		http.Error(w, "You are not logged in.", http.StatusUnauthorized)
 		return
@@ -68,7 +68,7 @@ func TwitchAuthHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	id := token.Claims.(*controllerhelpers.TF2StadiumClaims).PlayerID
-	player, _ := player.GetPlayerByID(id)
+	p, _ := player.GetPlayerByID(id)
 
 	values := r.URL.Query()
 	code := values.Get("code")
@@ -78,7 +78,7 @@ func TwitchAuthHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	state := values.Get("state")
-	if state == "" || !xsrftoken.Valid(state, config.Constants.CookieStoreSecret, player.SteamID, "GET") {
+	if state == "" || !xsrftoken.Valid(state, config.Constants.CookieStoreSecret, p.SteamID, "GET") {
 		http.Error(w, "Missing or Invalid XSRF token", http.StatusBadRequest)
 		return
 	}
@@ -131,9 +131,10 @@ func TwitchAuthHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	player.TwitchName = info.Name
-	player.TwitchAccessToken = reply.AccessToken
-	player.Save()
+	p.TwitchName = info.Name
+	p.TwitchAccessToken = reply.AccessToken
+	p.Save()
+	p.RecordIP(controllerhelpers.GetIPAddr(r), player.IPSourceTwitch)
 
 	http.Redirect(w, r, config.Constants.LoginRedirectPath, http.StatusTemporaryRedirect)
 }
@@ -156,7 +157,7 @@ func getUserInfo(token string) (*userInfo, error) {
 }
 
 func TwitchLogoutHandler(w http.ResponseWriter, r *http.Request) {
-	token, err := controllerhelpers.GetToken(r)
+	token, err := controllerhelpers.GetCookieToken(r)
 	if err == http.ErrNoCookie {
 		http.Error(w, "You are not logged in.", http.StatusUnauthorized)
 		return
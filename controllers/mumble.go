@@ -9,7 +9,7 @@ import (
 )
 
 func ResetMumblePassword(w http.ResponseWriter, r *http.Request) {
-	token, err := chelpers.GetToken(r)
+	token, err := chelpers.GetCookieToken(r)
 	if err != nil {
 		http.Error(w, "You aren't logged in.", http.StatusForbidden)
 		return
@@ -0,0 +1,83 @@
+package controllers
+
+import (
+	"crypto/subtle"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/TF2Stadium/Helen/models/lobby"
+	"github.com/TF2Stadium/Helen/models/match"
+)
+
+const maxLogSize = 32 << 20
+
+//UploadLog records the stats of a lobby from its game server's log, for when
+//the log lines sent over UDP were lost. The log is either the request body,
+//or the "logfile" field of a multipart form, like the logs.tf upload API.
+//The request has to be authenticated with the lobby's log secret (sv_logsecret),
+//in the X-Log-Secret header or the "secret" field of the form, and is accepted
+//until the lobby is closed.
+func UploadLog(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxLogSize)
+
+	id, err := strconv.ParseUint(r.URL.Query().Get("lobby"), 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid lobby ID", http.StatusBadRequest)
+		return
+	}
+
+	lob, err := lobby.GetLobbyByIDServer(uint(id))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	// not accepted in the URL, which ends up in access logs
+	secret := r.Header.Get("X-Log-Secret")
+
+	var log io.Reader = r.Body
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, _, err := r.FormFile("logfile")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+		log = file
+
+		if values := r.MultipartForm.Value["secret"]; secret == "" && len(values) != 0 {
+			secret = values[0]
+		}
+	}
+
+	if lob.ServerInfo.LogSecret == "" ||
+		subtle.ConstantTimeCompare([]byte(secret), []byte(lob.ServerInfo.LogSecret)) != 1 {
+		http.Error(w, "Invalid log secret", http.StatusForbidden)
+		return
+	}
+
+	result, err := match.ParseLog(log)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = lob.RecordLog(result)
+	if err == lobby.ErrStatsRecorded {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	} else if err != nil {
+		logrus.Error(err)
+		http.Error(w, "Couldn't record stats", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
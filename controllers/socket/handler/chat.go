@@ -59,13 +59,18 @@ func (Chat) ChatSend(so *wsevent.Client, args struct {
 		return errors.New("Message too long")
 	}
 
-	message := chat.NewChatMessage(*args.Message, *args.Room, p)
-
 	if strings.HasPrefix(*args.Message, "!admin") {
 		chelpers.SendToSlack(*args.Message, p.Name, p.SteamID)
 		return emptySuccess
 	}
 
+	filtered, err := chat.Filter(p, *args.Room, *args.Message)
+	if err != nil {
+		return err
+	}
+
+	message := chat.NewChatMessage(filtered, *args.Room, p)
+
 	message.Save()
 	message.Send()
 
@@ -0,0 +1,142 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	chelpers "github.com/TF2Stadium/Helen/controllers/controllerhelpers"
+	"github.com/TF2Stadium/Helen/controllers/controllerhelpers/hooks"
+	"github.com/TF2Stadium/Helen/helpers"
+	"github.com/TF2Stadium/Helen/models/lobby"
+	"github.com/TF2Stadium/Helen/models/player"
+	"github.com/TF2Stadium/Helen/models/queue"
+	"github.com/TF2Stadium/wsevent"
+)
+
+func (Lobby) LobbyDraftJoin(so *wsevent.Client, args struct {
+	ID *uint `json:"id"`
+}) interface{} {
+	p := chelpers.GetPlayer(so.Token)
+	if banned, until := p.IsBannedWithTime(player.BanJoin); banned {
+		ban, _ := p.GetActiveBan(player.BanJoin)
+		return fmt.Errorf("You have been banned from joining lobbies till %s (%s)", until.Format(time.RFC822), ban.Reason)
+	}
+
+	lob, err := lobby.GetLobbyByID(*args.ID)
+	if err != nil {
+		return err
+	}
+	if !lob.Draft {
+		return lobby.ErrNotDraft
+	}
+
+	if lob.Mumble {
+		if banned, until := p.IsBannedWithTime(player.BanJoinMumble); banned {
+			ban, _ := p.GetActiveBan(player.BanJoinMumble)
+			return fmt.Errorf("You have been banned from joining Mumble lobbies till %s (%s)", until.Format(time.RFC822), ban.Reason)
+		}
+	}
+
+	if lob.RegionLock {
+		region, _ := helpers.GetRegion(chelpers.GetIPAddr(so.Request))
+		if region != lob.RegionCode {
+			return errors.New("This lobby is region locked.")
+		}
+	}
+
+	if err := lob.JoinDraftPool(p); err != nil {
+		return err
+	}
+
+	if queue.Leave(p.ID) == nil {
+		broadcastQueueStatus()
+	}
+
+	hooks.AfterLobbyJoin(so, lob, p)
+	return emptySuccess
+}
+
+//leaveDraftPool removes the player from the pool (and their slot, if they've
+//been picked) of a drafted lobby which hasn't started yet
+func leaveDraftPool(lob *lobby.Lobby, p *player.Player, kicked bool) error {
+	if err := lob.LeaveDraftPool(p); err != nil {
+		return err
+	}
+
+	// players keep their slots after the draft until the lobby starts
+	if _, err := lob.GetPlayerSlot(p); err == nil {
+		lob.RemovePlayer(p)
+	}
+	lob.AddSpectator(p)
+
+	hooks.AfterLobbyLeave(lob, p, kicked, false)
+	return nil
+}
+
+//leaveDraftPools removes the player from the pools of drafted lobbies which
+//haven't started yet, after they've joined another lobby. prevID is the lobby
+//the player was in before, which has already been told they left.
+func leaveDraftPools(p *player.Player, prevID uint) {
+	for _, id := range lobby.GetDraftPoolLobbyIDs(p) {
+		lob, err := lobby.GetLobbyByID(id)
+		if err != nil {
+			continue
+		}
+		if err := lob.LeaveDraftPool(p); err != nil {
+			continue
+		}
+		if id != prevID {
+			hooks.AfterLobbyLeave(lob, p, false, false)
+		}
+	}
+}
+
+func (Lobby) LobbyDraftVote(so *wsevent.Client, args struct {
+	ID      *uint   `json:"id"`
+	SteamID *string `json:"steamid"`
+}) interface{} {
+	lob, err := lobby.GetLobbyByID(*args.ID)
+	if err != nil {
+		return err
+	}
+
+	voter := chelpers.GetPlayer(so.Token)
+	candidate, err := player.GetPlayerBySteamID(*args.SteamID)
+	if err != nil {
+		return err
+	}
+
+	if err := lob.VoteCaptain(voter, candidate); err != nil {
+		return err
+	}
+
+	return emptySuccess
+}
+
+func (Lobby) LobbyDraftPick(so *wsevent.Client, args struct {
+	ID      *uint   `json:"id"`
+	SteamID *string `json:"steamid"`
+	Class   *string `json:"class"`
+}) interface{} {
+	lob, err := lobby.GetLobbyByID(*args.ID)
+	if err != nil {
+		return err
+	}
+
+	captain := chelpers.GetPlayer(so.Token)
+	target, err := player.GetPlayerBySteamID(*args.SteamID)
+	if err != nil {
+		return err
+	}
+
+	if err := lob.PickPlayer(captain, target, *args.Class); err != nil {
+		return err
+	}
+
+	return emptySuccess
+}
@@ -0,0 +1,176 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package handler
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/TF2Stadium/Helen/controllers/broadcaster"
+	chelpers "github.com/TF2Stadium/Helen/controllers/controllerhelpers"
+	"github.com/TF2Stadium/Helen/controllers/controllerhelpers/hooks"
+	"github.com/TF2Stadium/Helen/models/chat"
+	"github.com/TF2Stadium/Helen/models/player"
+	"github.com/TF2Stadium/wsevent"
+)
+
+func (Player) PlayerFriendRequest(so *wsevent.Client, args struct {
+	SteamID *string `json:"steamid"`
+}) interface{} {
+	p := chelpers.GetPlayer(so.Token)
+	target, err := player.GetPlayerBySteamID(*args.SteamID)
+	if err != nil {
+		return err
+	}
+
+	accepted, err := p.SendFriendRequest(target)
+	if err != nil {
+		return err
+	}
+
+	if accepted {
+		// target had already sent a request to the player
+		broadcaster.SendMessage(target.SteamID, "friendAccepted", hooks.GetPresence(p))
+		broadcaster.SendMessage(p.SteamID, "friendAccepted", hooks.GetPresence(target))
+	} else {
+		broadcaster.SendMessage(target.SteamID, "friendRequest", hooks.GetPresence(p))
+	}
+
+	return emptySuccess
+}
+
+func (Player) PlayerFriendAccept(so *wsevent.Client, args struct {
+	SteamID *string `json:"steamid"`
+}) interface{} {
+	p := chelpers.GetPlayer(so.Token)
+	from, err := player.GetPlayerBySteamID(*args.SteamID)
+	if err != nil {
+		return err
+	}
+
+	if err := p.AcceptFriendRequest(from); err != nil {
+		return err
+	}
+
+	broadcaster.SendMessage(from.SteamID, "friendAccepted", hooks.GetPresence(p))
+	broadcaster.SendMessage(p.SteamID, "friendAccepted", hooks.GetPresence(from))
+	return emptySuccess
+}
+
+func (Player) PlayerFriendRemove(so *wsevent.Client, args struct {
+	SteamID *string `json:"steamid"`
+}) interface{} {
+	p := chelpers.GetPlayer(so.Token)
+	other, err := player.GetPlayerBySteamID(*args.SteamID)
+	if err != nil {
+		return err
+	}
+
+	if err := p.RemoveFriend(other); err != nil {
+		return err
+	}
+
+	broadcaster.SendMessage(other.SteamID, "friendRemoved", struct {
+		SteamID string `json:"steamid"`
+	}{p.SteamID})
+	return emptySuccess
+}
+
+func (Player) PlayerFriendList(so *wsevent.Client, _ struct{}) interface{} {
+	p := chelpers.GetPlayer(so.Token)
+
+	requests := []hooks.Presence{}
+	for _, requester := range p.GetFriendRequests() {
+		requests = append(requests, hooks.GetPresence(requester))
+	}
+
+	return newResponse(struct {
+		Friends  []hooks.Presence `json:"friends"`
+		Requests []hooks.Presence `json:"requests"`
+	}{hooks.GetFriendList(p), requests})
+}
+
+func (Player) PlayerBlock(so *wsevent.Client, args struct {
+	SteamID *string `json:"steamid"`
+}) interface{} {
+	p := chelpers.GetPlayer(so.Token)
+	other, err := player.GetPlayerBySteamID(*args.SteamID)
+	if err != nil {
+		return err
+	}
+
+	if err := p.Block(other); err != nil {
+		return err
+	}
+
+	hooks.SendBlockList(p)
+	return emptySuccess
+}
+
+func (Player) PlayerUnblock(so *wsevent.Client, args struct {
+	SteamID *string `json:"steamid"`
+}) interface{} {
+	p := chelpers.GetPlayer(so.Token)
+	other, err := player.GetPlayerBySteamID(*args.SteamID)
+	if err != nil {
+		return err
+	}
+
+	if err := p.Unblock(other); err != nil {
+		return err
+	}
+
+	hooks.SendBlockList(p)
+	return emptySuccess
+}
+
+func (Chat) ChatDirectSend(so *wsevent.Client, args struct {
+	SteamID *string `json:"steamid"`
+	Message *string `json:"message"`
+}) interface{} {
+	p := chelpers.GetPlayer(so.Token)
+	if banned, until := p.IsBannedWithTime(player.BanChat); banned {
+		ban, _ := p.GetActiveBan(player.BanChat)
+		return fmt.Errorf("You've been banned from chatting till %s (%s)", until.Format(time.RFC822), ban.Reason)
+	}
+
+	to, err := player.GetPlayerBySteamID(*args.SteamID)
+	if err != nil {
+		return err
+	}
+
+	message, err := chat.SendDirectMessage(p, to, *args.Message)
+	if err != nil {
+		return err
+	}
+
+	return newResponse(message)
+}
+
+func (Chat) ChatDirectScrollback(so *wsevent.Client, args struct {
+	SteamID *string `json:"steamid"`
+	Before  *uint   `json:"before" empty:"-"` // ID of the oldest message the client has
+}) interface{} {
+	p := chelpers.GetPlayer(so.Token)
+	other, err := player.GetPlayerBySteamID(*args.SteamID)
+	if err != nil {
+		return err
+	}
+
+	return newResponse(chat.GetConversation(p, other, *args.Before))
+}
+
+func (Chat) ChatDirectRead(so *wsevent.Client, args struct {
+	SteamID *string `json:"steamid"`
+}) interface{} {
+	p := chelpers.GetPlayer(so.Token)
+	other, err := player.GetPlayerBySteamID(*args.SteamID)
+	if err != nil {
+		return err
+	}
+
+	chat.MarkDirectMessagesRead(p, other)
+	return emptySuccess
+}
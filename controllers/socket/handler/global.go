@@ -9,6 +9,7 @@ import (
 
 	"github.com/TF2Stadium/Helen/controllers/broadcaster"
 	chelpers "github.com/TF2Stadium/Helen/controllers/controllerhelpers"
+	"github.com/TF2Stadium/Helen/models/lobby/format"
 	"github.com/TF2Stadium/Helen/models/lobby_settings"
 	"github.com/TF2Stadium/wsevent"
 	"github.com/bitly/go-simplejson"
@@ -28,6 +29,8 @@ func (Global) GetConstant(so *wsevent.Client, args struct {
 	switch args.Constant {
 	case "lobbySettingsList":
 		output = lobbySettings.LobbySettingsToJSON()
+	case "formats":
+		return newResponse(format.All())
 	default:
 		return errors.New("Unknown constant.")
 	}
@@ -0,0 +1,43 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package handler
+
+import (
+	"github.com/TF2Stadium/Helen/models/leaderboard"
+	"github.com/TF2Stadium/Helen/models/lobby/format"
+	"github.com/TF2Stadium/wsevent"
+)
+
+//Leaderboard handlers are registered on both the authenticated and the
+//unauthenticated server, since leaderboards are public
+type Leaderboard struct{}
+
+func (Leaderboard) Name(s string) string {
+	return string((s[0])+32) + s[1:]
+}
+
+func (Leaderboard) LeaderboardSeasons(so *wsevent.Client, _ struct{}) interface{} {
+	return newResponse(leaderboard.GetSeasons())
+}
+
+func (Leaderboard) LeaderboardGet(so *wsevent.Client, args struct {
+	Season   *uint   `json:"season" empty:"-"` // current season if not given
+	Category *string `json:"category" valid:"lobbies,hours,subs,rating"`
+	Class    *string `json:"class" empty:"-"`
+	Format   *string `json:"format"`
+	Region   *string `json:"region" empty:"-"`
+}) interface{} {
+	f, ok := format.GetByName(*args.Format)
+	if !ok {
+		return leaderboard.ErrInvalidFormat
+	}
+
+	board, err := leaderboard.Get(*args.Season, *args.Category, *args.Class, f, *args.Region)
+	if err != nil {
+		return err
+	}
+
+	return newResponse(board)
+}
@@ -12,6 +12,7 @@ import (
 	"fmt"
 	"regexp"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/Sirupsen/logrus"
@@ -21,12 +22,15 @@ import (
 	"github.com/TF2Stadium/Helen/controllers/controllerhelpers/hooks"
 	db "github.com/TF2Stadium/Helen/database"
 	"github.com/TF2Stadium/Helen/helpers"
+	"github.com/TF2Stadium/Helen/helpers/secret"
 	"github.com/TF2Stadium/Helen/models/chat"
 	"github.com/TF2Stadium/Helen/models/gameserver"
 	"github.com/TF2Stadium/Helen/models/lobby"
 	"github.com/TF2Stadium/Helen/models/lobby/format"
 	"github.com/TF2Stadium/Helen/models/player"
+	"github.com/TF2Stadium/Helen/models/queue"
 	"github.com/TF2Stadium/Helen/models/rpc"
+	"github.com/TF2Stadium/Helen/models/webhook"
 	"github.com/TF2Stadium/Helen/routes/socket"
 	"github.com/TF2Stadium/servemetf"
 	"github.com/TF2Stadium/wsevent"
@@ -42,14 +46,6 @@ var (
 	reDiscordInvite = regexp.MustCompile(`https:\/\/discord.gg\/[a-zA-Z0-9]+`)
 	reSteamGroup = regexp.MustCompile(`steamcommunity\.com\/groups\/(.+)`)
 	reServer     = regexp.MustCompile(`\w+\:\d+`)
-	playermap    = map[string]format.Format{
-		"debug":      format.Debug,
-		"6s":         format.Sixes,
-		"highlander": format.Highlander,
-		"ultiduo":    format.Ultiduo,
-		"bball":      format.Bball,
-		"4v4":        format.Fours,
-	}
 )
 
 type Restriction struct {
@@ -57,9 +53,12 @@ type Restriction struct {
 	Blu bool `json:"blu,omitempty"`
 }
 type Requirement struct {
-	Hours      int         `json:"hours"`
-	Lobbies    int         `json:"lobbies"`
-	Restricted Restriction `json:"restricted"`
+	Hours       int         `json:"hours"`
+	Lobbies     int         `json:"lobbies"`
+	Reliability float64     `json:"reliability"`
+	MinRating   float64     `json:"minRating"`
+	MaxRating   float64     `json:"maxRating"`
+	Restricted  Restriction `json:"restricted"`
 }
 
 type servemeServer struct {
@@ -74,26 +73,99 @@ func newRequirement(team, class string, requirement Requirement, lob *lobby.Lobb
 		return err
 	}
 	slotReq := &lobby.Requirement{
-		LobbyID: lob.ID,
-		Slot:    slot,
-		Hours:   int(requirement.Hours),
-		Lobbies: int(requirement.Lobbies),
+		LobbyID:     lob.ID,
+		Slot:        slot,
+		Hours:       int(requirement.Hours),
+		Lobbies:     int(requirement.Lobbies),
+		Reliability: requirement.Reliability,
+		MinRating:   requirement.MinRating,
+		MaxRating:   requirement.MaxRating,
 	}
 	slotReq.Save()
 
 	return nil
 }
 
-func (Lobby) LobbyCreate(so *wsevent.Client, args struct {
+//newReserveRequest makes the request for reserving the lobby's server from the
+//provider the creator picked
+func newReserveRequest(so *wsevent.Client, args lobbyCreateArgs, scheduled bool) (*gameserver.ReserveRequest, error) {
+	region, _ := helpers.GetRegion(chelpers.GetIPAddr(so.Request))
+	req := &gameserver.ReserveRequest{
+		Region:  region,
+		SteamID: so.Token.Claims.(*chelpers.TF2StadiumClaims).SteamID,
+	}
+
+	switch *args.ServerType {
+	case "serveme":
+		if args.Serveme == nil {
+			return nil, errors.New("No serveme info given.")
+		}
+		req.ServerID = args.Serveme.Server.ID
+		req.Host = args.Serveme.Server.IPAndPort
+		if scheduled {
+			// the reservation lasts from the setup till after the start time
+			return req, nil
+		}
+
+		var err error
+		if req.StartsAt, err = time.Parse(servemetf.TimeFormat, args.Serveme.StartsAt); err != nil {
+			return nil, err
+		}
+		if req.EndsAt, err = time.Parse(servemetf.TimeFormat, args.Serveme.EndsAt); err != nil {
+			return nil, err
+		}
+	default:
+		// the server's ID, the provider picks a server if it's empty
+		if *args.Server == "" {
+			if scheduled {
+				return nil, errors.New("No server ID given")
+			}
+			return req, nil
+		}
+
+		id, err := strconv.ParseUint(*args.Server, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		req.ServerID = int(id)
+
+		if scheduled && *args.ServerType == "storedServer" {
+			// the server is only taken shortly before the lobby starts
+			server, err := gameserver.FindStoredServer(uint(id))
+			if err != nil {
+				return nil, err
+			}
+			req.Host = server.Address
+		}
+	}
+
+	return req, nil
+}
+
+//releaseReservation releases the server reserved for a lobby which couldn't be
+//created, if there's one
+func releaseReservation(provider gameserver.ServerProvider, res *gameserver.Reservation) {
+	if res == nil {
+		return
+	}
+
+	if err := provider.Release(res); err != nil {
+		logrus.Error(err)
+	}
+}
+
+type lobbyCreateArgs struct {
 	Map         *string        `json:"map"`
-	Type        *string        `json:"type" valid:"debug,6s,highlander,4v4,ultiduo,bball"`
+	Type        *string        `json:"type"`
 	League      *string        `json:"league" valid:"ugc,etf2l,esea,asiafortress,ozfortress,bballtf"`
-	ServerType  *string        `json:"serverType" valid:"server,storedServer,serveme"`
+	ServerType  *string        `json:"serverType"` // "server", or the name of a gameserver.ServerProvider
 	Serveme     *servemeServer `json:"serveme" empty:"-"`
 	Server      *string        `json:"server" empty:"-"`
 	RconPwd     *string        `json:"rconpwd" empty:"-"`
 	WhitelistID *string        `json:"whitelistID"`
 	Mumble      *bool          `json:"mumbleRequired"`
+	// (Unix) time at which the lobby is scheduled to start, 0 if the lobby starts now
+	ScheduledFor *int64 `json:"scheduledFor" empty:"-"`
 
 	Password            *string `json:"password" empty:"-"`
 	SteamGroupWhitelist *string `json:"steamGroupWhitelist" empty:"-"`
@@ -103,6 +175,15 @@ func (Lobby) LobbyCreate(so *wsevent.Client, args struct {
 	TwitchWhitelistSubscribers bool `json:"twitchWhitelistSubs"`
 	TwitchWhitelistFollowers   bool `json:"twitchWhitelistFollows"`
 	RegionLock                 bool `json:"regionLock"`
+	// scrim mode, the creator captains RED and the BLU captain
+	// (if given) captains BLU
+	Scrim      bool    `json:"scrim"`
+	BluCaptain *string `json:"bluCaptain" empty:"-"`
+	// captain draft, captains are chosen by "vote" or by "rating"
+	Draft         bool    `json:"draft"`
+	DraftCaptains *string `json:"draftCaptains" empty:"-"`
+	// maps players vote for once the lobby is full, besides the lobby's map
+	MapPool []string `json:"mapPool"`
 
 	Requirements *struct {
 		Classes map[string]Requirement `json:"classes,omitempty"`
@@ -113,7 +194,14 @@ func (Lobby) LobbyCreate(so *wsevent.Client, args struct {
 		RedChannel *string `json:"redChannel,omitempty"`
 		BluChannel *string `json:"bluChannel,omitempty"`
 	} `json:"discord" empty:"-"`
-}) interface{} {
+}
+
+func (Lobby) LobbyCreate(so *wsevent.Client, args lobbyCreateArgs) interface{} {
+	lobbyType, ok := format.GetByName(*args.Type)
+	if !ok {
+		return errors.New("Invalid lobby type")
+	}
+
 	p := chelpers.GetPlayer(so.Token)
 	if banned, until := p.IsBannedWithTime(player.BanCreate); banned {
 		ban, _ := p.GetActiveBan(player.BanCreate)
@@ -127,87 +215,99 @@ func (Lobby) LobbyCreate(so *wsevent.Client, args struct {
 	}
 
 	var steamGroup string
-	var context *servemetf.Context
-	var reservation servemetf.Reservation
-
-	if *args.SteamGroupWhitelist != "" {
-		if reSteamGroup.MatchString(*args.SteamGroupWhitelist) {
-			steamGroup = reSteamGroup.FindStringSubmatch(*args.SteamGroupWhitelist)[1]
-		} else {
-			return errors.New("Invalid Steam group URL")
+	var scheduledFor time.Time
+	scheduled := args.ScheduledFor != nil && *args.ScheduledFor != 0
+	if scheduled {
+		scheduledFor = time.Unix(*args.ScheduledFor, 0)
+		if err := lobby.CheckScheduleTime(scheduledFor); err != nil {
+			return err
 		}
 	}
 
-	if *args.ServerType == "serveme" {
-		if args.Serveme == nil {
-			return errors.New("No serveme info given.")
-		}
-		var err error
-		var start, end time.Time
+	if args.Scrim && args.Draft {
+		return errors.New("Scrim lobbies can't use a captain draft.")
+	}
+	if args.Draft && (args.DraftCaptains == nil || (*args.DraftCaptains != "vote" && *args.DraftCaptains != "rating")) {
+		return errors.New("Captains can be chosen by vote or by rating.")
+	}
 
-		if start, err = time.Parse(servemetf.TimeFormat, (*args.Serveme).StartsAt); err != nil {
-			return err
-		}
-		if end, err = time.Parse(servemetf.TimeFormat, (*args.Serveme).EndsAt); err != nil {
+	var mapPool []string
+	if len(args.MapPool) != 0 {
+		var err error
+		if mapPool, err = newMapPool(*args.Map, lobbyType, *args.League, *args.WhitelistID, args.MapPool); err != nil {
 			return err
 		}
+	}
 
-		randBytes := make([]byte, 6)
-		rand.Read(randBytes)
-		*args.RconPwd = base64.URLEncoding.EncodeToString(randBytes)
-
-		reservation = servemetf.Reservation{
-			StartsAt:    start.Format(servemetf.TimeFormat),
-			EndsAt:      end.Format(servemetf.TimeFormat),
-			ServerID:    (*args.Serveme).Server.ID,
-			WhitelistID: 1,
-			RCON:        *args.RconPwd,
-			Password:    "foobar",
+	var bluCaptain *player.Player
+	if args.Scrim && args.BluCaptain != nil && *args.BluCaptain != "" {
+		var err error
+		if bluCaptain, err = player.GetPlayerBySteamID(*args.BluCaptain); err != nil {
+			return errors.New("Couldn't find the BLU captain.")
 		}
-
-		context = helpers.GetServemeContextIP(chelpers.GetIPAddr(so.Request))
-		resp, err := context.Create(reservation, so.Token.Claims.(*chelpers.TF2StadiumClaims).SteamID)
-		if err != nil || resp.Reservation.Errors != nil {
-			if err != nil {
-				logrus.Error(err)
-			} else {
-				logrus.Error(resp.Reservation.Errors)
-			}
-
-			return errors.New("Couldn't get serveme reservation")
+		if bluCaptain.SteamID == p.SteamID {
+			return errors.New("You can't captain both teams.")
 		}
+	}
 
-		*args.Server = resp.Reservation.Server.IPAndPort
-		reservation = resp.Reservation
-	} else if *args.ServerType == "storedServer" {
-		if *args.Server == "" {
-			return errors.New("No server ID given")
+	if *args.SteamGroupWhitelist != "" {
+		if reSteamGroup.MatchString(*args.SteamGroupWhitelist) {
+			steamGroup = reSteamGroup.FindStringSubmatch(*args.SteamGroupWhitelist)[1]
+		} else {
+			return errors.New("Invalid Steam group URL")
 		}
+	}
 
-		id, err := strconv.ParseUint(*args.Server, 10, 64)
-		if err != nil {
-			return err
-		}
-		server, err := gameserver.GetStoredServer(uint(id))
-		if err != nil {
-			return err
-		}
-		*args.Server = server.Address
-		*args.RconPwd = server.RCONPassword
-	} else { // *args.ServerType == "server"
+	if (args.TwitchWhitelistSubscribers || args.TwitchWhitelistFollowers) && p.TwitchName == "" {
+		return errors.New("Please connect your twitch account first.")
+	}
+	if args.Discord != nil &&
+		(!reDiscordInvite.MatchString(*args.Discord.RedChannel) || !reDiscordInvite.MatchString(*args.Discord.BluChannel)) {
+		return errors.New("Invalid Discord invite URL")
+	}
+
+	// the server is reserved last, so that it doesn't have to be released
+	// when the arguments are invalid
+	var provider gameserver.ServerProvider
+	var req *gameserver.ReserveRequest
+	var reservation *gameserver.Reservation
+
+	if *args.ServerType == "server" {
 		if args.RconPwd == nil || *args.RconPwd == "" {
 			return errors.New("RCON Password cannot be empty")
 		}
 		if args.Server == nil || *args.Server == "" {
 			return errors.New("Server Address cannot be empty")
 		}
+	} else {
+		var ok bool
+		if provider, ok = gameserver.GetProvider(*args.ServerType); !ok {
+			return errors.New("Invalid server type")
+		}
+
+		var err error
+		if req, err = newReserveRequest(so, args, scheduled); err != nil {
+			return err
+		}
+
+		if scheduled {
+			// the server is reserved shortly before the lobby starts
+			*args.Server = req.Host
+			*args.RconPwd = ""
+		} else {
+			if reservation, err = provider.Reserve(req); err != nil {
+				return err
+			}
+			*args.Server = reservation.Host
+			*args.RconPwd = reservation.RconPassword
+		}
 	}
 
 	var count int
 
-	lobbyType := playermap[*args.Type]
 	db.DB.Model(&gameserver.ServerRecord{}).Where("host = ?", *args.Server).Count(&count)
 	if count != 0 {
+		releaseReservation(provider, reservation)
 		return errors.New("A lobby is already using this server.")
 	}
 
@@ -215,20 +315,15 @@ func (Lobby) LobbyCreate(so *wsevent.Client, args struct {
 	rand.Read(randBytes)
 	serverPwd := base64.URLEncoding.EncodeToString(randBytes)
 
-	//TODO what if playermap[lobbytype] is nil?
 	info := gameserver.ServerRecord{
 		Host:           *args.Server,
-		RconPassword:   *args.RconPwd,
-		ServerPassword: serverPwd,
+		RconPassword:   secret.String(*args.RconPwd),
+		ServerPassword: secret.String(serverPwd),
 	}
 
 	lob := lobby.NewLobby(*args.Map, lobbyType, *args.League, info, *args.WhitelistID, *args.Mumble, steamGroup)
 
 	if args.TwitchWhitelistSubscribers || args.TwitchWhitelistFollowers {
-		if p.TwitchName == "" {
-			return errors.New("Please connect your twitch account first.")
-		}
-
 		lob.TwitchChannel = p.TwitchName
 		if args.TwitchWhitelistFollowers {
 			lob.TwitchRestriction = lobby.TwitchFollowers
@@ -239,79 +334,75 @@ func (Lobby) LobbyCreate(so *wsevent.Client, args struct {
 
 	lob.Discord = args.Discord != nil
 	if lob.Discord {
-		if !reDiscordInvite.MatchString(*args.Discord.RedChannel) || !reDiscordInvite.MatchString(*args.Discord.BluChannel) {
-			return errors.New("Invalid Discord invite URL")
-		}
-
 		lob.DiscordRedChannel = *args.Discord.RedChannel
 		lob.DiscordBluChannel = *args.Discord.BluChannel
 	}
 
 	lob.RegionLock = args.RegionLock
+	lob.Scrim = args.Scrim
+	lob.Draft = args.Draft
+	if lob.Draft {
+		lob.DraftCaptainMode = *args.DraftCaptains
+	}
+	lob.MapPool = strings.Join(mapPool, ",")
 	lob.CreatedBySteamID = p.SteamID
 	lob.RegionCode, lob.RegionName = helpers.GetRegion(*args.Server)
 	if (lob.RegionCode == "" || lob.RegionName == "") && config.Constants.GeoIP {
-		if reservation.ID != 0 {
-			err := context.Delete(reservation.ID, p.SteamID)
-			for err != nil {
-				err = context.Delete(reservation.ID, p.SteamID)
-			}
-		} else if *args.ServerType == "storedServer" {
-			gameserver.PutStoredServer(*args.Server)
-		}
-
+		releaseReservation(provider, reservation)
 		return errors.New("Couldn't find the region for this server.")
 	}
 
 	if lobby.MapRegionFormatExists(lob.MapName, lob.RegionCode, lob.Type) {
-		if reservation.ID != 0 {
-			err := context.Delete(reservation.ID, p.SteamID)
-			for err != nil {
-				err = context.Delete(reservation.ID, p.SteamID)
-			}
-		} else if *args.ServerType == "storedServer" {
-			gameserver.PutStoredServer(*args.Server)
-		}
-
+		releaseReservation(provider, reservation)
 		return errors.New("Your region already has a lobby with this map and format.")
 	}
 
-	if *args.ServerType == "serveme" {
-		lob.ServemeID = reservation.ID
+	if reservation != nil {
+		lob.SetReservation(provider, reservation)
+	}
+
+	if scheduled {
+		lob.ScheduledFor = scheduledFor
+		lob.ScheduledServerType = *args.ServerType
+		if req != nil {
+			lob.ScheduledServerID = req.ServerID
+		}
 	}
 
 	lob.Save()
 	lob.CreateLock()
 
-	if *args.ServerType == "serveme" {
-		now := time.Now()
-
-		for {
-			status, err := context.Status(reservation.ID, p.SteamID)
-			if err != nil {
-				logrus.Error(err)
-			}
-			if status == "ready" {
-				break
-			}
+	if lob.Scrim {
+		lob.SetCaptain("red", p)
+		if bluCaptain != nil {
+			lob.SetCaptain("blu", bluCaptain)
+			sendScrimInvite(lob, bluCaptain, "blu")
+		}
+	}
 
-			time.Sleep(10 * time.Second)
-			if time.Since(now) >= 3*time.Minute {
-				lob.Delete()
-				return errors.New("Couldn't get Serveme reservation, try another server.")
-			}
+	if reservation != nil {
+		if err := provider.Verify(reservation); err != nil {
+			lob.Delete()
+			return err
 		}
 
-		lob.ServemeCheck(context)
+		lob.WatchReservation()
 	}
 
-	err := lob.SetupServer()
-	if err != nil { //lobby setup failed, delete lobby and corresponding server record
-		lob.Delete()
-		return err
-	}
+	if scheduled {
+		// the server is set up shortly before the lobby starts
+		lob.SetState(lobby.Scheduled)
+		lob.Schedule()
+		lob.SendWebhook(webhook.LobbyCreated)
+	} else {
+		err := lob.SetupServer()
+		if err != nil { //lobby setup failed, delete lobby and corresponding server record
+			lob.Delete()
+			return err
+		}
 
-	lob.SetState(lobby.Waiting)
+		lob.SetState(lobby.Waiting)
+	}
 
 	if args.Requirements != nil {
 		for class, requirement := range (*args.Requirements).Classes {
@@ -322,13 +413,17 @@ func (Lobby) LobbyCreate(so *wsevent.Client, args struct {
 				newRequirement("red", class, requirement, lob)
 			}
 		}
-		if args.Requirements.General.Hours != 0 || args.Requirements.General.Lobbies != 0 {
+		general := args.Requirements.General
+		if general.Hours != 0 || general.Lobbies != 0 || general.Reliability != 0 || general.MinRating != 0 || general.MaxRating != 0 {
 			for i := 0; i < 2*format.NumberOfClassesMap[lob.Type]; i++ {
 				req := &lobby.Requirement{
-					LobbyID: lob.ID,
-					Hours:   args.Requirements.General.Hours,
-					Lobbies: args.Requirements.General.Lobbies,
-					Slot:    i,
+					LobbyID:     lob.ID,
+					Hours:       general.Hours,
+					Lobbies:     general.Lobbies,
+					Reliability: general.Reliability,
+					MinRating:   general.MinRating,
+					MaxRating:   general.MaxRating,
+					Slot:        i,
 				}
 				req.Save()
 			}
@@ -347,7 +442,11 @@ func (Lobby) LobbyCreate(so *wsevent.Client, args struct {
 		}
 	}
 
-	chat.NewBotMessage(fmt.Sprintf("Lobby created by %s", p.Alias()), int(lob.ID)).Send()
+	if scheduled {
+		chat.NewBotMessage(fmt.Sprintf("Lobby created by %s, scheduled for %s", p.Alias(), scheduledFor.UTC().Format(time.RFC822)), int(lob.ID)).Send()
+	} else {
+		chat.NewBotMessage(fmt.Sprintf("Lobby created by %s", p.Alias()), int(lob.ID)).Send()
+	}
 
 	lobby.BroadcastLobbyList()
 	return newResponse(
@@ -401,7 +500,7 @@ func (Lobby) ServerVerify(so *wsevent.Client, args struct {
 
 	info := &gameserver.ServerRecord{
 		Host:         *args.Server,
-		RconPassword: *args.Rconpwd,
+		RconPassword: secret.String(*args.Rconpwd),
 	}
 	db.DB.Save(info)
 	defer db.DB.Delete(info)
@@ -445,6 +544,9 @@ func (Lobby) LobbyClose(so *wsevent.Client, args struct {
 // notifs). Bad because it gets restart on Helen restart... but easy
 // for now
 var lobbyJoinLastNotif = make(map[uint]time.Time)
+
+// number of players after which "almost ready" notifs are sent,
+// two thirds of the required players for formats not listed here
 var notifThreshold = map[format.Format]int{
 	format.Highlander: 13,
 	format.Sixes:      8,
@@ -488,6 +590,14 @@ func (Lobby) LobbyJoin(so *wsevent.Client, args struct {
 	if lob.State == lobby.Initializing {
 		return errors.New("Lobby is being setup right now.")
 	}
+	// players can join slots in scheduled lobbies early, they ready up
+	// once the server is set up
+
+	// players are picked into slots by captains in drafted lobbies,
+	// except for substitutes
+	if lob.Draft && lob.State != lobby.InProgress {
+		return lobby.ErrDraftJoin
+	}
 
 	if lob.RegionLock {
 		region, _ := helpers.GetRegion(chelpers.GetIPAddr(so.Request))
@@ -507,7 +617,8 @@ func (Lobby) LobbyJoin(so *wsevent.Client, args struct {
 		return tperr
 	}
 
-	if prevId, _ := p.GetLobbyID(false); prevId != 0 && !sameLobby {
+	prevId, _ := p.GetLobbyID(false)
+	if prevId != 0 && !sameLobby {
 		lob, _ := lobby.GetLobbyByID(prevId)
 		hooks.AfterLobbyLeave(lob, p, false, false)
 	}
@@ -518,54 +629,28 @@ func (Lobby) LobbyJoin(so *wsevent.Client, args struct {
 		return tperr
 	}
 
+	leaveDraftPools(p, prevId)
+
+	if queue.Leave(p.ID) == nil {
+		broadcastQueueStatus()
+	}
+
 	if !sameLobby {
 		hooks.AfterLobbyJoin(so, lob, p)
 	}
 
 	playersCnt := lob.GetPlayerNumber()
 	lastNotif, timerExists := lobbyJoinLastNotif[lob.ID]
-	if playersCnt >= notifThreshold[lob.Type] && !lob.IsEnoughPlayers(playersCnt) && (!timerExists || time.Since(lastNotif).Minutes() > 5) {
+	threshold, ok := notifThreshold[lob.Type]
+	if !ok {
+		threshold = 2 * lob.RequiredPlayers() / 3
+	}
+	if playersCnt >= threshold && !lob.IsEnoughPlayers(playersCnt) && (!timerExists || time.Since(lastNotif).Minutes() > 5) {
 		lob.DiscordNotif(fmt.Sprintf("Almost ready [%d/%d]", playersCnt, lob.RequiredPlayers()))
 		lobbyJoinLastNotif[lob.ID] = time.Now()
 	}
 
-	//check if lobby isn't already in progress (which happens when the player is subbing)
-	lob.Lock()
-	if lob.IsEnoughPlayers(playersCnt) && lob.State != lobby.InProgress && lob.State != lobby.ReadyingUp {
-		lob.State = lobby.ReadyingUp
-		lob.ReadyUpTimestamp = time.Now().Unix() + 30
-		lob.Save()
-
-		helpers.GlobalWait.Add(1)
-		time.AfterFunc(time.Second*30, func() {
-			state := lob.CurrentState()
-			//if all player's haven't readied up,
-			//remove unreadied players and unready the
-			//rest.
-			//don't do this when:
-			//  lobby.State == Waiting (someone already unreadied up, so all players have been unreadied)
-			// lobby.State == InProgress (all players have readied up, so the lobby has started)
-			// lobby.State == Ended (the lobby has been closed)
-			if state != lobby.Waiting && state != lobby.InProgress && state != lobby.Ended {
-				lob.SetState(lobby.Waiting)
-				removeUnreadyPlayers(lob)
-				lob.UnreadyAllPlayers()
-				//get updated lobby object
-				lob, _ = lobby.GetLobbyByID(lob.ID)
-				lobby.BroadcastLobby(lob)
-			}
-			helpers.GlobalWait.Done()
-		})
-
-		room := fmt.Sprintf("%s_private",
-			hooks.GetLobbyRoom(lob.ID))
-		broadcaster.SendMessageToRoom(room, "lobbyReadyUp",
-			struct {
-				Timeout int `json:"timeout"`
-			}{30})
-		lobby.BroadcastLobbyList()
-	}
-	lob.Unlock()
+	hooks.StartReadyUp(lob)
 
 	if lob.State == lobby.InProgress { //this happens when the player is a substitute
 		db.DB.Preload("ServerInfo").First(lob, lob.ID)
@@ -575,17 +660,6 @@ func (Lobby) LobbyJoin(so *wsevent.Client, args struct {
 	return emptySuccess
 }
 
-//get list of unready players, remove them from lobby (and add them as spectators)
-//plus, call the after lobby leave hook for each player removed
-func removeUnreadyPlayers(lobby *lobby.Lobby) {
-	players := lobby.GetUnreadyPlayers()
-	lobby.RemoveUnreadyPlayers(true)
-
-	for _, player := range players {
-		hooks.AfterLobbyLeave(lobby, player, false, true)
-	}
-}
-
 func (Lobby) LobbySpectatorJoin(so *wsevent.Client, args struct {
 	Id *uint `json:"id"`
 }) interface{} {
@@ -659,7 +733,7 @@ func removePlayerFromLobby(lobbyId uint, steamId string) (*lobby.Lobby, *player.
 	return lob, player, lob.AddSpectator(player)
 }
 
-func playerCanKick(lobbyId uint, steamId string) (bool, error) {
+func playerCanKick(lobbyId uint, steamId string, targetSteamId string) (bool, error) {
 	lob, err := lobby.GetLobbyByID(lobbyId)
 	if err != nil {
 		return false, err
@@ -669,12 +743,41 @@ func playerCanKick(lobbyId uint, steamId string) (bool, error) {
 	if err != nil {
 		return false, err
 	}
-	if steamId != lob.CreatedBySteamID && player.Role != helpers.RoleAdmin {
+	if player.Role == helpers.RoleAdmin {
+		return true, nil
+	}
+
+	if lob.Scrim {
+		// in scrims, captains can only kick players from their own team
+		team, ok := lob.CaptainTeam(steamId)
+		if !ok || team != playerTeam(lob, targetSteamId) {
+			return false, errors.New("Only the team's captain can kick players from it")
+		}
+		return true, nil
+	}
+
+	if steamId != lob.CreatedBySteamID {
 		return false, errors.New("Not authorized to kick players")
 	}
 	return true, nil
 }
 
+//playerTeam returns the team the player is playing on in the lobby, or ""
+func playerTeam(lob *lobby.Lobby, steamId string) string {
+	p, err := player.GetPlayerBySteamID(steamId)
+	if err != nil {
+		return ""
+	}
+
+	slot, err := lob.GetPlayerSlot(p)
+	if err != nil {
+		return ""
+	}
+
+	team, _, _ := format.GetSlotTeamClass(lob.Type, slot)
+	return team
+}
+
 func (Lobby) LobbyKick(so *wsevent.Client, args struct {
 	Id      *uint   `json:"id"`
 	Steamid *string `json:"steamid"`
@@ -686,15 +789,31 @@ func (Lobby) LobbyKick(so *wsevent.Client, args struct {
 	if steamId == selfSteamId {
 		return errors.New("Player can't kick himself.")
 	}
-	if ok, tperr := playerCanKick(*args.Id, selfSteamId); !ok {
+	if ok, tperr := playerCanKick(*args.Id, selfSteamId, steamId); !ok {
 		return tperr
 	}
 
+	if lob, err := lobby.GetLobbyByID(*args.Id); err == nil && lob.Draft && lob.State != lobby.InProgress {
+		p, err := player.GetPlayerBySteamID(steamId)
+		if err != nil {
+			return err
+		}
+		if err := leaveDraftPool(lob, p, true); err != nil {
+			return err
+		}
+		return emptySuccess
+	}
+
 	lob, player, tperr := removePlayerFromLobby(*args.Id, steamId)
 	if tperr != nil {
 		return tperr
 	}
 
+	if lob.Scrim {
+		// kicked players need to be invited again to rejoin
+		lob.UninvitePlayer(player)
+	}
+
 	hooks.AfterLobbyLeave(lob, player, true, false)
 
 	// broadcaster.SendMessage(steamId, "sendNotification",
@@ -714,16 +833,31 @@ func (Lobby) LobbyBan(so *wsevent.Client, args struct {
 	if steamId == selfSteamId {
 		return errors.New("Player can't kick himself.")
 	}
-	if ok, tperr := playerCanKick(*args.Id, selfSteamId); !ok {
+	if ok, tperr := playerCanKick(*args.Id, selfSteamId, steamId); !ok {
 		return tperr
 	}
 
+	if lob, err := lobby.GetLobbyByID(*args.Id); err == nil && lob.Draft && lob.State != lobby.InProgress {
+		p, err := player.GetPlayerBySteamID(steamId)
+		if err != nil {
+			return err
+		}
+		if err := leaveDraftPool(lob, p, true); err != nil {
+			return err
+		}
+		lob.BanPlayer(p)
+		return emptySuccess
+	}
+
 	lob, player, tperr := removePlayerFromLobby(*args.Id, steamId)
 	if tperr != nil {
 		return tperr
 	}
 
 	lob.BanPlayer(player)
+	if lob.Scrim {
+		lob.UninvitePlayer(player)
+	}
 
 	hooks.AfterLobbyLeave(lob, player, true, false)
 
@@ -739,6 +873,13 @@ func (Lobby) LobbyLeave(so *wsevent.Client, args struct {
 
 	steamId := so.Token.Claims.(*chelpers.TF2StadiumClaims).SteamID
 
+	if lob, err := lobby.GetLobbyByID(*args.Id); err == nil && lob.Draft && lob.State != lobby.InProgress {
+		if err := leaveDraftPool(lob, chelpers.GetPlayer(so.Token), false); err != nil {
+			return err
+		}
+		return emptySuccess
+	}
+
 	lob, player, tperr := removePlayerFromLobby(*args.Id, steamId)
 	if tperr != nil {
 		return tperr
@@ -789,7 +930,9 @@ func (Lobby) LobbyChangeOwner(so *wsevent.Client, args struct {
 
 	// current owner
 	player1 := chelpers.GetPlayer(so.Token)
-	if lob.CreatedBySteamID != player1.SteamID {
+	// in scrims, captains pass their captaincy with this
+	team, captain := lob.CaptainTeam(player1.SteamID)
+	if lob.CreatedBySteamID != player1.SteamID && !captain {
 		return errors.New("You aren't authorized to change lobby owner.")
 	}
 
@@ -799,11 +942,25 @@ func (Lobby) LobbyChangeOwner(so *wsevent.Client, args struct {
 		return err
 	}
 
-	lob.CreatedBySteamID = player2.SteamID
+	if captain {
+		if otherTeam, ok := lob.CaptainTeam(player2.SteamID); ok && otherTeam != team {
+			return errors.New("That player is already captaining the other team.")
+		}
+		if err := lob.SetCaptain(team, player2); err != nil {
+			return err
+		}
+
+		chat.NewBotMessage(fmt.Sprintf("%s captain changed to %s", lob.TeamName(team), player2.Alias()), int(*args.ID)).Send()
+	}
+
+	if lob.CreatedBySteamID == player1.SteamID {
+		lob.CreatedBySteamID = player2.SteamID
+		chat.NewBotMessage(fmt.Sprintf("Lobby leader changed to %s", player2.Alias()), int(*args.ID)).Send()
+	}
+
 	lob.Save()
 	lobby.BroadcastLobby(lob)
 	lobby.BroadcastLobbyList()
-	chat.NewBotMessage(fmt.Sprintf("Lobby leader changed to %s", player2.Alias()), int(*args.ID)).Send()
 
 	return emptySuccess
 }
@@ -849,7 +1006,16 @@ func (Lobby) LobbySetRequirement(so *wsevent.Client, args struct {
 		req.Lobbies = int(n)
 	case "reliability":
 		f, err = args.Value.Float64()
+		if f < 0 || f > 1 {
+			return errors.New("Reliability must be between 0 and 1.")
+		}
 		req.Reliability = f
+	case "minRating":
+		f, err = args.Value.Float64()
+		req.MinRating = f
+	case "maxRating":
+		f, err = args.Value.Float64()
+		req.MaxRating = f
 	case "password":
 		req.Password = *args.Password
 	default:
@@ -879,7 +1045,11 @@ func (Lobby) LobbySetTeamName(so *wsevent.Client, args struct {
 		return err
 	}
 
-	if player.SteamID != lob.CreatedBySteamID && (player.Role != helpers.RoleAdmin && player.Role != helpers.RoleMod) {
+	// in scrims, captains can name their own team
+	team, captain := lob.CaptainTeam(player.SteamID)
+	captain = captain && team == args.Team
+
+	if player.SteamID != lob.CreatedBySteamID && !captain && (player.Role != helpers.RoleAdmin && player.Role != helpers.RoleMod) {
 		return errors.New("You aren't authorized to do this.")
 	}
 
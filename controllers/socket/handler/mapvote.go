@@ -0,0 +1,104 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package handler
+
+import (
+	"fmt"
+	"strconv"
+
+	chelpers "github.com/TF2Stadium/Helen/controllers/controllerhelpers"
+	"github.com/TF2Stadium/Helen/models/lobby"
+	"github.com/TF2Stadium/Helen/models/lobby/format"
+	"github.com/TF2Stadium/Helen/models/lobby_settings"
+	"github.com/TF2Stadium/wsevent"
+)
+
+//checkPoolLeague checks that the maps in a lobby's map pool can be played with
+//the lobby's league and whitelist. lobbySettings doesn't tie maps to leagues,
+//so the league has to play the format the maps are played in, and the
+//whitelist has to be the league's whitelist for it. Leagues and whitelists
+//which lobbySettings doesn't know about aren't checked.
+func checkPoolLeague(formatName, leagueName, whitelist string) error {
+	if league, ok := lobbySettings.GetLobbyLeague(leagueName); ok {
+		for _, leagueFormat := range league.Formats {
+			if leagueFormat.Format.Name == formatName && !leagueFormat.Used {
+				return fmt.Errorf("%s doesn't play %s, the map pool can't be used", league.PrettyName, formatName)
+			}
+		}
+	}
+
+	id, err := strconv.Atoi(whitelist)
+	if err != nil {
+		return nil
+	}
+	if w, ok := lobbySettings.GetLobbyWhitelist(id); ok {
+		if w.League.Name != leagueName || w.Format.Name != formatName {
+			return fmt.Errorf("%s isn't a whitelist for the lobby's league and format", w.PrettyName)
+		}
+	}
+
+	return nil
+}
+
+//newMapPool returns the maps players vote for in a lobby created with mapName,
+//which is always the first map in the pool. All other maps have to be played
+//in the lobby's format, league and whitelist.
+func newMapPool(mapName string, lobbyType format.Format, league, whitelist string, maps []string) ([]string, error) {
+	def, _ := format.Get(lobbyType)
+	formatName := def.SettingsName
+	if err := checkPoolLeague(formatName, league, whitelist); err != nil {
+		return nil, err
+	}
+
+	pool := []string{mapName}
+	seen := map[string]bool{mapName: true}
+	for _, name := range maps {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		m, ok := lobbySettings.GetLobbyMap(name)
+		if !ok {
+			return nil, fmt.Errorf("Unknown map %s in the map pool", name)
+		}
+		// GetFormat accepts every map for known formats, so check the
+		// formats the map is listed for
+		played := false
+		for _, mapFormat := range m.Formats {
+			played = played || mapFormat.Format.Name == formatName
+		}
+		if !played {
+			return nil, fmt.Errorf("%s isn't played in %s", name, def.PrettyName)
+		}
+
+		pool = append(pool, name)
+	}
+
+	if len(pool) < 2 {
+		return nil, fmt.Errorf("The map pool needs another map besides %s", mapName)
+	}
+	if len(pool) > lobby.MaxMapPool {
+		return nil, fmt.Errorf("The map pool can't have more than %d maps", lobby.MaxMapPool)
+	}
+
+	return pool, nil
+}
+
+func (Lobby) LobbyMapVote(so *wsevent.Client, args struct {
+	ID  *uint   `json:"id"`
+	Map *string `json:"map"`
+}) interface{} {
+	lob, err := lobby.GetLobbyByID(*args.ID)
+	if err != nil {
+		return err
+	}
+
+	if err := lob.VoteMap(chelpers.GetPlayer(so.Token), *args.Map); err != nil {
+		return err
+	}
+
+	return emptySuccess
+}
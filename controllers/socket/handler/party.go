@@ -0,0 +1,294 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/TF2Stadium/Helen/controllers/broadcaster"
+	chelpers "github.com/TF2Stadium/Helen/controllers/controllerhelpers"
+	"github.com/TF2Stadium/Helen/controllers/controllerhelpers/hooks"
+	"github.com/TF2Stadium/Helen/helpers"
+	"github.com/TF2Stadium/Helen/models/lobby"
+	"github.com/TF2Stadium/Helen/models/lobby/format"
+	"github.com/TF2Stadium/Helen/models/party"
+	"github.com/TF2Stadium/Helen/models/player"
+	"github.com/TF2Stadium/Helen/models/queue"
+	"github.com/TF2Stadium/wsevent"
+)
+
+type Party struct{}
+
+func (Party) Name(s string) string {
+	return string((s[0])+32) + s[1:]
+}
+
+type partyMember struct {
+	SteamID string `json:"steamid"`
+	Name    string `json:"name"`
+	Class   string `json:"class"`
+}
+
+type partyData struct {
+	ID      uint          `json:"id"`
+	Leader  string        `json:"leader"` // steamid of the leader
+	Members []partyMember `json:"members"`
+	Invited []string      `json:"invited"`
+}
+
+func decorateParty(pt party.Party) partyData {
+	data := partyData{
+		ID:      pt.ID,
+		Members: []partyMember{},
+		Invited: []string{},
+	}
+
+	for _, id := range pt.Members {
+		p, err := player.GetPlayerByID(id)
+		if err != nil {
+			continue
+		}
+		if id == pt.LeaderID {
+			data.Leader = p.SteamID
+		}
+		data.Members = append(data.Members, partyMember{p.SteamID, p.Alias(), pt.Classes[id]})
+	}
+	for _, id := range pt.Invited {
+		if p, err := player.GetPlayerByID(id); err == nil {
+			data.Invited = append(data.Invited, p.SteamID)
+		}
+	}
+
+	return data
+}
+
+//broadcastParty sends the current state of the party with the given ID to
+//all of it's members
+func broadcastParty(partyID uint) {
+	pt, ok := party.GetByID(partyID)
+	if !ok {
+		return
+	}
+
+	data := decorateParty(pt)
+	for _, member := range data.Members {
+		broadcaster.SendMessage(member.SteamID, "partyUpdate", data)
+	}
+}
+
+func sendPartyLeft(playerID uint) {
+	if p, err := player.GetPlayerByID(playerID); err == nil {
+		broadcaster.SendMessage(p.SteamID, "partyLeft", struct{}{})
+	}
+}
+
+func (Party) PartyInvite(so *wsevent.Client, args struct {
+	SteamID *string `json:"steamid"`
+}) interface{} {
+	p := chelpers.GetPlayer(so.Token)
+	target, err := player.GetPlayerBySteamID(*args.SteamID)
+	if err != nil {
+		return err
+	}
+	if !p.IsFriend(target) {
+		return errors.New("You can only invite friends to your party.")
+	}
+
+	pt, err := party.Invite(p.ID, target.ID)
+	if err != nil {
+		return err
+	}
+
+	broadcaster.SendMessage(target.SteamID, "partyInvite", decorateParty(pt))
+	broadcastParty(pt.ID)
+	return emptySuccess
+}
+
+func (Party) PartyAccept(so *wsevent.Client, args struct {
+	ID *uint `json:"id"`
+}) interface{} {
+	p := chelpers.GetPlayer(so.Token)
+	pt, err := party.Accept(*args.ID, p.ID)
+	if err != nil {
+		return err
+	}
+
+	broadcastParty(pt.ID)
+	return emptySuccess
+}
+
+func (Party) PartyDecline(so *wsevent.Client, args struct {
+	ID *uint `json:"id"`
+}) interface{} {
+	p := chelpers.GetPlayer(so.Token)
+	pt, err := party.Decline(*args.ID, p.ID)
+	if err != nil {
+		return err
+	}
+
+	broadcastParty(pt.ID)
+	if _, ok := party.GetByID(pt.ID); !ok {
+		// the party was disbanded, since nobody else was left in it
+		for _, id := range pt.Members {
+			sendPartyLeft(id)
+		}
+	}
+	return emptySuccess
+}
+
+func (Party) PartyLeave(so *wsevent.Client, _ struct{}) interface{} {
+	p := chelpers.GetPlayer(so.Token)
+	pt, err := party.Leave(p.ID)
+	if err != nil {
+		return err
+	}
+
+	afterPartyLeave(pt)
+	return emptySuccess
+}
+
+func (Party) PartyKick(so *wsevent.Client, args struct {
+	SteamID *string `json:"steamid"`
+}) interface{} {
+	p := chelpers.GetPlayer(so.Token)
+	target, err := player.GetPlayerBySteamID(*args.SteamID)
+	if err != nil {
+		return err
+	}
+
+	pt, err := party.Kick(p.ID, target.ID)
+	if err != nil {
+		return err
+	}
+
+	afterPartyLeave(pt)
+	return emptySuccess
+}
+
+//afterPartyLeave notifies members who aren't in the party anymore, given the
+//party's state before someone left
+func afterPartyLeave(before party.Party) {
+	after, ok := party.GetByID(before.ID)
+	for _, id := range before.Members {
+		if !ok {
+			sendPartyLeft(id)
+			continue
+		}
+
+		if current, _ := party.Get(id); current.ID != after.ID {
+			sendPartyLeft(id)
+		}
+	}
+
+	broadcastParty(before.ID)
+}
+
+func (Party) PartySetClass(so *wsevent.Client, args struct {
+	Class *string `json:"class"`
+}) interface{} {
+	p := chelpers.GetPlayer(so.Token)
+	pt, err := party.SetClass(p.ID, *args.Class)
+	if err != nil {
+		return err
+	}
+
+	broadcastParty(pt.ID)
+	return emptySuccess
+}
+
+func (Party) PartyInfo(so *wsevent.Client, _ struct{}) interface{} {
+	p := chelpers.GetPlayer(so.Token)
+	pt, ok := party.Get(p.ID)
+	if !ok {
+		return party.ErrNotInParty
+	}
+
+	return newResponse(decorateParty(pt))
+}
+
+func (Lobby) LobbyPartyJoin(so *wsevent.Client, args struct {
+	Id       *uint   `json:"id"`
+	Team     *string `json:"team" valid:"red,blu"`
+	Password *string `json:"password" empty:"-"`
+}) interface{} {
+	p := chelpers.GetPlayer(so.Token)
+	pt, ok := party.Get(p.ID)
+	if !ok {
+		return party.ErrNotInParty
+	}
+	if pt.LeaderID != p.ID {
+		return party.ErrNotLeader
+	}
+
+	lob, err := lobby.GetLobbyByID(*args.Id)
+	if err != nil {
+		return err
+	}
+	if lob.RegionLock {
+		region, _ := helpers.GetRegion(chelpers.GetIPAddr(so.Request))
+		if region != lob.RegionCode {
+			return errors.New("This lobby is region locked.")
+		}
+	}
+
+	var players []*player.Player
+	var slots []int
+	prevLobbies := make(map[uint]uint) // player ID -> ID of the lobby the player was in
+
+	for _, id := range pt.Members {
+		member, err := player.GetPlayerByID(id)
+		if err != nil {
+			return err
+		}
+
+		if banned, until := member.IsBannedWithTime(player.BanJoin); banned {
+			return fmt.Errorf("%s has been banned from joining lobbies till %s", member.Alias(), until.Format(time.RFC822))
+		}
+		if lob.Mumble && member.IsBanned(player.BanJoinMumble) {
+			return fmt.Errorf("%s has been banned from joining Mumble lobbies", member.Alias())
+		}
+
+		class, ok := pt.Classes[id]
+		if !ok {
+			return fmt.Errorf("%s hasn't picked a class yet", member.Alias())
+		}
+		slot, err := format.GetSlot(lob.Type, *args.Team, class)
+		if err != nil {
+			return fmt.Errorf("%s: %s", member.Alias(), err.Error())
+		}
+
+		prevLobbies[id], _ = member.GetLobbyID(false)
+		players = append(players, member)
+		slots = append(slots, slot)
+	}
+
+	if err := lob.AddParty(pt.ID, players, slots, *args.Password); err != nil {
+		return err
+	}
+
+	left := false
+	for _, member := range players {
+		if prevID := prevLobbies[member.ID]; prevID != 0 && prevID != lob.ID {
+			prev, _ := lobby.GetLobbyByID(prevID)
+			hooks.AfterLobbyLeave(prev, member, false, false)
+			prev.OnChange(true)
+		}
+		leaveDraftPools(member, prevLobbies[member.ID])
+		if queue.Leave(member.ID) == nil {
+			left = true
+		}
+		if prevLobbies[member.ID] != lob.ID {
+			hooks.AfterLobbyJoin(so, lob, member)
+		}
+	}
+	if left {
+		broadcastQueueStatus()
+	}
+
+	hooks.StartReadyUp(lob)
+	return emptySuccess
+}
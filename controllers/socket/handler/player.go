@@ -6,6 +6,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/TF2Stadium/Helen/config"
 	chelpers "github.com/TF2Stadium/Helen/controllers/controllerhelpers"
 	"github.com/TF2Stadium/Helen/controllers/controllerhelpers/hooks"
 	"github.com/TF2Stadium/Helen/controllers/socket/sessions"
@@ -241,5 +242,103 @@ func (Player) PlayerRecentLobbies(so *wsevent.Client, args struct {
 		Limit(*args.Lobbies).
 		Find(&lobbies)
 
-	return newResponse(lobby.DecorateLobbyListData(lobbies, true))
+	return newResponse(lobby.DecorateMatchHistory(lobbies))
+}
+
+func (Player) PlayerHeadToHead(so *wsevent.Client, args struct {
+	SteamID *string `json:"steamid"`
+	Other   *string `json:"other"` // steamid of the player to compare with
+}) interface{} {
+	p, err := player.GetPlayerBySteamID(*args.SteamID)
+	if err != nil {
+		return err
+	}
+	other, err := player.GetPlayerBySteamID(*args.Other)
+	if err != nil {
+		return err
+	}
+
+	return newResponse(p.GetHeadToHead(other))
+}
+
+func (Player) PlayerTokenCreate(so *wsevent.Client, args struct {
+	Name   *string  `json:"name"`
+	Scopes []string `json:"scopes"`
+}) interface{} {
+	p := chelpers.GetPlayer(so.Token)
+	if len(*args.Name) > 32 {
+		return errors.New("Token name can be atmost 32 characters long.")
+	}
+
+	token, str, err := p.NewAPIToken(*args.Name, args.Scopes, config.Constants.APITokenRateLimit)
+	if err != nil {
+		return err
+	}
+
+	// the token string is only sent once, it can't be retrieved later
+	return newResponse(struct {
+		APIToken *player.APIToken `json:"apiToken"`
+		Token    string           `json:"token"`
+	}{token, str})
+}
+
+func (Player) PlayerTokenList(so *wsevent.Client, _ struct{}) interface{} {
+	p := chelpers.GetPlayer(so.Token)
+	return newResponse(p.GetAPITokens())
+}
+
+func (Player) PlayerTokenRevoke(so *wsevent.Client, args struct {
+	ID *uint `json:"id"`
+}) interface{} {
+	p := chelpers.GetPlayer(so.Token)
+	if err := p.RevokeAPIToken(*args.ID); err != nil {
+		return err
+	}
+
+	return emptySuccess
+}
+
+func (Player) PlayerBanList(so *wsevent.Client, _ struct{}) interface{} {
+	p := chelpers.GetPlayer(so.Token)
+	bans, err := p.GetActiveBans()
+	if err != nil {
+		return err
+	}
+
+	appeals := make(map[uint]*player.BanAppeal)
+	for _, appeal := range p.GetBanAppeals() {
+		appeals[appeal.BanID] = appeal
+	}
+
+	type banData struct {
+		ID     uint              `json:"id"`
+		Type   string            `json:"type"`
+		Until  int64             `json:"until"`
+		Reason string            `json:"reason"`
+		Rule   string            `json:"rule,omitempty"` // for automatic bans
+		Appeal *player.BanAppeal `json:"appeal"`
+	}
+	resp := []banData{}
+	for _, ban := range bans {
+		resp = append(resp, banData{ban.ID, ban.Type.String(), ban.Until.Unix(), ban.Reason, ban.RuleName, appeals[ban.ID]})
+	}
+
+	return newResponse(resp)
+}
+
+func (Player) PlayerBanAppeal(so *wsevent.Client, args struct {
+	ID      *uint   `json:"id"`
+	Message *string `json:"message"`
+}) interface{} {
+	if len(*args.Message) == 0 || len(*args.Message) > 2000 {
+		return errors.New("Appeals need to be between 1 and 2000 characters long.")
+	}
+
+	p := chelpers.GetPlayer(so.Token)
+	appeal, err := p.NewBanAppeal(*args.ID, *args.Message)
+	if err != nil {
+		return err
+	}
+
+	return newResponse(appeal)
 }
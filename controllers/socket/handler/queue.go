@@ -0,0 +1,280 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package handler
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/TF2Stadium/Helen/config"
+	"github.com/TF2Stadium/Helen/controllers/broadcaster"
+	chelpers "github.com/TF2Stadium/Helen/controllers/controllerhelpers"
+	"github.com/TF2Stadium/Helen/controllers/controllerhelpers/hooks"
+	"github.com/TF2Stadium/Helen/helpers"
+	"github.com/TF2Stadium/Helen/helpers/secret"
+	"github.com/TF2Stadium/Helen/models/chat"
+	"github.com/TF2Stadium/Helen/models/gameserver"
+	"github.com/TF2Stadium/Helen/models/lobby"
+	"github.com/TF2Stadium/Helen/models/lobby/format"
+	"github.com/TF2Stadium/Helen/models/lobby_settings"
+	"github.com/TF2Stadium/Helen/models/player"
+	"github.com/TF2Stadium/Helen/models/queue"
+	"github.com/TF2Stadium/wsevent"
+)
+
+type Queue struct{}
+
+func (Queue) Name(s string) string {
+	return string((s[0])+32) + s[1:]
+}
+
+var (
+	errNoQueueServer = errors.New("No free servers available")
+	errNoQueueFormat = errors.New("This format can't be queued for.")
+)
+
+type queueJoinArgs struct {
+	Type    *string  `json:"type"`
+	Classes []string `json:"classes"`
+}
+
+//getQueueFormat returns the definition of the format with the given name, if
+//it can be queued for. Only formats with a league can be queued for, since
+//queued lobbies are created with it.
+func getQueueFormat(name string) (*format.Definition, bool) {
+	f, ok := format.GetByName(name)
+	if !ok {
+		return nil, false
+	}
+
+	def, _ := format.Get(f)
+	return def, def.League != ""
+}
+
+func (Queue) QueueJoin(so *wsevent.Client, args queueJoinArgs) interface{} {
+	p := chelpers.GetPlayer(so.Token)
+	if banned, until := p.IsBannedWithTime(player.BanJoin); banned {
+		ban, _ := p.GetActiveBan(player.BanJoin)
+		return fmt.Errorf("You have been banned from joining lobbies till %s (%s)", until.Format(time.RFC822), ban.Reason)
+	}
+
+	if id, _ := p.GetLobbyID(false); id != 0 {
+		return errors.New("You are already in a lobby.")
+	}
+
+	def, ok := getQueueFormat(*args.Type)
+	if !ok {
+		return errNoQueueFormat
+	}
+
+	region, _ := helpers.GetRegion(chelpers.GetIPAddr(so.Request))
+	if err := queue.Join(def.ID, region, p.ID, p.SteamID, args.Classes); err != nil {
+		return err
+	}
+
+	broadcastQueueStatus()
+	go formQueueLobby(def, region)
+
+	return emptySuccess
+}
+
+func (Queue) QueueLeave(so *wsevent.Client, _ struct{}) interface{} {
+	p := chelpers.GetPlayer(so.Token)
+	if err := queue.Leave(p.ID); err != nil {
+		return err
+	}
+
+	broadcastQueueStatus()
+	return emptySuccess
+}
+
+func (Queue) QueueStatus(so *wsevent.Client, _ struct{}) interface{} {
+	p := chelpers.GetPlayer(so.Token)
+	lobbyType, region, queued := queue.IsQueued(p.ID)
+
+	resp := struct {
+		Queued bool           `json:"queued"`
+		Type   string         `json:"type,omitempty"`
+		Region string         `json:"region,omitempty"`
+		Queues []queue.Status `json:"queues"`
+	}{Queued: queued, Queues: queue.GetStatus()}
+
+	if queued {
+		resp.Type = format.FriendlyNamesMap[lobbyType]
+		resp.Region = region
+	}
+
+	return newResponse(resp)
+}
+
+func broadcastQueueStatus() {
+	broadcaster.SendMessageToRoom("0_public", "queueStatus", queue.GetStatus())
+}
+
+//getQueueServer reserves the best free stored server in the given region
+func getQueueServer(region string) (*gameserver.Reservation, error) {
+	if !config.Constants.GeoIP {
+		region = ""
+	}
+
+	res, err := gameserver.StoredServerProvider{}.Reserve(&gameserver.ReserveRequest{Region: region})
+	if err != nil {
+		return nil, errNoQueueServer
+	}
+	return res, nil
+}
+
+//getQueueMap returns the most important map for the format, and the whitelist
+//for the format's league
+func getQueueMap(def *format.Definition) (mapName string, whitelist string) {
+	importance := -1
+	for _, m := range lobbySettings.GetLobbyMaps() {
+		for _, mapFormat := range m.Formats {
+			if mapFormat.Format.Name == def.SettingsName && mapFormat.Importance > importance {
+				mapName, importance = m.Name, mapFormat.Importance
+			}
+		}
+	}
+
+	for _, w := range lobbySettings.GetLobbyWhitelists() {
+		if w.League.Name == def.League && w.Format.Name == def.SettingsName {
+			whitelist = strconv.Itoa(w.ID)
+			break
+		}
+	}
+
+	return
+}
+
+//queueNotice tells a matched player why they didn't get into a lobby
+type queueNotice struct {
+	Reason string `json:"reason"`
+}
+
+//checkQueuePlayers returns the players for the matched entries, and the
+//entries of players who can't play anymore, like the ones who joined
+//another lobby, or got banned since queueing up
+func checkQueuePlayers(assignment map[int]*queue.Entry) (map[int]*player.Player, []*queue.Entry) {
+	players := make(map[int]*player.Player)
+	var dropped []*queue.Entry
+
+	for slot, e := range assignment {
+		p, err := player.GetPlayerByID(e.PlayerID)
+		if err != nil {
+			logrus.Error(err)
+			dropped = append(dropped, e)
+			continue
+		}
+
+		if id, _ := p.GetLobbyID(false); id != 0 {
+			dropped = append(dropped, e)
+			broadcaster.SendMessage(p.SteamID, "queueLeft", queueNotice{"You joined another lobby."})
+			continue
+		}
+		if banned, _ := p.IsBannedWithTime(player.BanJoin); banned {
+			dropped = append(dropped, e)
+			broadcaster.SendMessage(p.SteamID, "queueLeft", queueNotice{"You have been banned from joining lobbies."})
+			continue
+		}
+
+		players[slot] = p
+	}
+
+	return players, dropped
+}
+
+//formQueueLobby creates a lobby for players in the queue if there are
+//enough of them to fill every slot, and there's a free server for it.
+func formQueueLobby(def *format.Definition, region string) {
+	assignment, ok := queue.Match(def.ID, region)
+	if !ok {
+		return
+	}
+
+	players, dropped := checkQueuePlayers(assignment)
+	if len(dropped) != 0 {
+		// the rest go back into the queue, where other players can take the
+		// dropped players' slots. The queue is shorter now, so this ends.
+		var entries []*queue.Entry
+		for slot, e := range assignment {
+			if _, ok := players[slot]; ok {
+				entries = append(entries, e)
+			}
+		}
+		queue.Requeue(def.ID, region, entries)
+		broadcastQueueStatus()
+		formQueueLobby(def, region)
+		return
+	}
+
+	var entries []*queue.Entry
+	for _, e := range assignment {
+		entries = append(entries, e)
+	}
+
+	res, err := getQueueServer(region)
+	if err != nil {
+		queue.Requeue(def.ID, region, entries)
+		return
+	}
+
+	randBytes := make([]byte, 6)
+	rand.Read(randBytes)
+
+	mapName, whitelist := getQueueMap(def)
+	info := gameserver.ServerRecord{
+		Host:           res.Host,
+		RconPassword:   secret.String(res.RconPassword),
+		ServerPassword: secret.String(base64.URLEncoding.EncodeToString(randBytes)),
+	}
+
+	lob := lobby.NewLobby(mapName, def.ID, def.League, info, whitelist, false, "")
+	lob.Matchmaking = true
+	lob.SetReservation(gameserver.StoredServerProvider{}, res)
+	lob.RegionCode, lob.RegionName = helpers.GetRegion(res.Host)
+	lob.Save()
+	lob.CreateLock()
+
+	if err := lob.SetupServer(); err != nil {
+		logrus.Error(err)
+		lob.Delete()
+		queue.Requeue(def.ID, region, entries)
+		broadcastQueueStatus()
+		return
+	}
+	lob.SetState(lobby.Waiting)
+
+	for slot, p := range players {
+		if err := lob.AddPlayer(p, slot, ""); err != nil {
+			// a lobby with an empty slot can't start, so put everyone back
+			// into the queue. Players are only told they joined the lobby
+			// once every one of them is in it, so nobody has to leave it.
+			logrus.Error(err)
+			lob.Close(true, false)
+			queue.Requeue(def.ID, region, entries)
+			broadcaster.SendMessage(p.SteamID, "queueRequeued", queueNotice{err.Error()})
+			broadcastQueueStatus()
+			return
+		}
+	}
+
+	for _, p := range players {
+		leaveDraftPools(p, 0)
+		hooks.AfterLobbyJoin(nil, lob, p)
+		broadcaster.SendMessage(p.SteamID, "queueMatched", struct {
+			ID uint `json:"id"`
+		}{lob.ID})
+	}
+
+	chat.NewBotMessage("Lobby created by matchmaking", int(lob.ID)).Send()
+	hooks.StartReadyUp(lob)
+	lobby.BroadcastLobbyList()
+	broadcastQueueStatus()
+}
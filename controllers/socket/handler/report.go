@@ -0,0 +1,78 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package handler
+
+import (
+	"errors"
+	"net/url"
+	"regexp"
+	"strconv"
+
+	chelpers "github.com/TF2Stadium/Helen/controllers/controllerhelpers"
+	db "github.com/TF2Stadium/Helen/database"
+	"github.com/TF2Stadium/Helen/models/chat"
+	"github.com/TF2Stadium/Helen/models/lobby"
+	"github.com/TF2Stadium/Helen/models/player"
+	"github.com/TF2Stadium/wsevent"
+)
+
+var reLogstf = regexp.MustCompile(`^https?://(?:www\.)?logs\.tf/(\d+)`)
+
+func (Player) PlayerReport(so *wsevent.Client, args struct {
+	SteamID  *string `json:"steamid"`
+	LobbyID  *uint   `json:"lobbyId"`
+	Category *string `json:"category" valid:"griefing,cheating,chat,other"`
+	Text     *string `json:"text"`
+	Logs     *string `json:"logs" empty:"-"`
+	Demo     *string `json:"demo" empty:"-"`
+}) interface{} {
+	if len(*args.Text) == 0 || len(*args.Text) > 2000 {
+		return errors.New("Reports need to be between 1 and 2000 characters long.")
+	}
+
+	var logsID int
+	if *args.Logs != "" {
+		matches := reLogstf.FindStringSubmatch(*args.Logs)
+		if matches == nil {
+			return errors.New("Invalid logs.tf URL")
+		}
+		logsID, _ = strconv.Atoi(matches[1])
+	}
+	if *args.Demo != "" {
+		if u, err := url.Parse(*args.Demo); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return errors.New("Invalid demo URL")
+		}
+	}
+
+	lob, err := lobby.GetLobbyByID(*args.LobbyID)
+	if err != nil {
+		return err
+	}
+
+	reporter := chelpers.GetPlayer(so.Token)
+	target, err := player.GetPlayerBySteamID(*args.SteamID)
+	if err != nil {
+		return err
+	}
+
+	if !lob.HasPlayer(reporter) {
+		return errors.New("You can only report players from lobbies you've played in.")
+	}
+	// spectators can only be reported for what they've said in chat
+	if !lob.HasPlayer(target) {
+		var count int
+		db.DB.Model(&chat.ChatMessage{}).Where("room = ? AND player_id = ?", lob.ID, target.ID).Count(&count)
+		if count == 0 {
+			return errors.New("That player wasn't in this lobby.")
+		}
+	}
+
+	_, err = reporter.NewCommunityReport(target, lob.ID, player.ReportCategories[*args.Category], *args.Text, logsID, *args.Demo)
+	if err != nil {
+		return err
+	}
+
+	return emptySuccess
+}
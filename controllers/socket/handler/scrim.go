@@ -0,0 +1,187 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package handler
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/TF2Stadium/Helen/controllers/broadcaster"
+	chelpers "github.com/TF2Stadium/Helen/controllers/controllerhelpers"
+	"github.com/TF2Stadium/Helen/controllers/controllerhelpers/hooks"
+	"github.com/TF2Stadium/Helen/helpers"
+	"github.com/TF2Stadium/Helen/models/chat"
+	"github.com/TF2Stadium/Helen/models/lobby"
+	"github.com/TF2Stadium/Helen/models/lobby/format"
+	"github.com/TF2Stadium/Helen/models/player"
+	"github.com/TF2Stadium/wsevent"
+)
+
+func sendScrimInvite(lob *lobby.Lobby, p *player.Player, team string) {
+	broadcaster.SendMessage(p.SteamID, "scrimInvite", struct {
+		ID       uint   `json:"id"`
+		Team     string `json:"team"`
+		TeamName string `json:"teamName"`
+	}{lob.ID, team, lob.TeamName(team)})
+}
+
+func (Lobby) LobbySetCaptain(so *wsevent.Client, args struct {
+	ID      *uint   `json:"id"`
+	Team    *string `json:"team" valid:"red,blu"`
+	SteamID *string `json:"steamid"`
+}) interface{} {
+	lob, err := lobby.GetLobbyByID(*args.ID)
+	if err != nil {
+		return err
+	}
+	if !lob.Scrim {
+		return lobby.ErrNotScrim
+	}
+
+	self := chelpers.GetPlayer(so.Token)
+	if self.SteamID != lob.CreatedBySteamID && (self.Role != helpers.RoleAdmin && self.Role != helpers.RoleMod) {
+		return errors.New("You aren't authorized to do this.")
+	}
+
+	p, err := player.GetPlayerBySteamID(*args.SteamID)
+	if err != nil {
+		return err
+	}
+	if team, ok := lob.CaptainTeam(p.SteamID); ok && team != *args.Team {
+		return errors.New("That player is already captaining the other team.")
+	}
+	if team := playerTeam(lob, p.SteamID); team != "" && team != *args.Team {
+		return errors.New("That player is playing on the other team.")
+	}
+
+	if err := lob.SetCaptain(*args.Team, p); err != nil {
+		return err
+	}
+
+	sendScrimInvite(lob, p, *args.Team)
+	chat.NewBotMessage(fmt.Sprintf("%s captain changed to %s", lob.TeamName(*args.Team), p.Alias()), int(lob.ID)).Send()
+	lobby.BroadcastLobby(lob)
+	return emptySuccess
+}
+
+func (Lobby) LobbyScrimInvite(so *wsevent.Client, args struct {
+	ID      *uint   `json:"id"`
+	SteamID *string `json:"steamid"`
+}) interface{} {
+	lob, err := lobby.GetLobbyByID(*args.ID)
+	if err != nil {
+		return err
+	}
+	if !lob.Scrim {
+		return lobby.ErrNotScrim
+	}
+
+	captain := chelpers.GetPlayer(so.Token)
+	team, ok := lob.CaptainTeam(captain.SteamID)
+	if !ok {
+		return lobby.ErrNotCaptain
+	}
+
+	p, err := player.GetPlayerBySteamID(*args.SteamID)
+	if err != nil {
+		return err
+	}
+	if lob.IsPlayerBanned(p) {
+		return errors.New("That player has been banned from this lobby.")
+	}
+	// players can't be taken away from the other team
+	if invited, ok := lob.GetInvitedTeam(p); ok && invited != team {
+		return errors.New("That player has been invited to the other team.")
+	}
+
+	if err := lob.InvitePlayer(team, p); err != nil {
+		return err
+	}
+
+	sendScrimInvite(lob, p, team)
+	chat.NewBotMessage(fmt.Sprintf("%s invited %s to %s", captain.Alias(), p.Alias(), lob.TeamName(team)), int(lob.ID)).Send()
+	return emptySuccess
+}
+
+func (Lobby) LobbyScrimUninvite(so *wsevent.Client, args struct {
+	ID      *uint   `json:"id"`
+	SteamID *string `json:"steamid"`
+}) interface{} {
+	lob, err := lobby.GetLobbyByID(*args.ID)
+	if err != nil {
+		return err
+	}
+	if !lob.Scrim {
+		return lobby.ErrNotScrim
+	}
+
+	captain := chelpers.GetPlayer(so.Token)
+	team, ok := lob.CaptainTeam(captain.SteamID)
+	if !ok {
+		return lobby.ErrNotCaptain
+	}
+
+	p, err := player.GetPlayerBySteamID(*args.SteamID)
+	if err != nil {
+		return err
+	}
+	if p.SteamID == captain.SteamID {
+		return errors.New("You can't uninvite yourself.")
+	}
+	if invited, ok := lob.GetInvitedTeam(p); !ok || invited != team {
+		return errors.New("That player hasn't been invited to your team.")
+	}
+
+	lob.UninvitePlayer(p)
+
+	// remove the player from their slot, if they've taken one
+	if playerTeam(lob, p.SteamID) == team {
+		if _, _, err := removePlayerFromLobby(lob.ID, p.SteamID); err != nil {
+			return err
+		}
+		hooks.AfterLobbyLeave(lob, p, true, false)
+	}
+
+	return emptySuccess
+}
+
+func (Lobby) LobbySwapSlots(so *wsevent.Client, args struct {
+	ID     *uint   `json:"id"`
+	Team   *string `json:"team" valid:"red,blu"`
+	Class1 *string `json:"class1"`
+	Class2 *string `json:"class2"`
+}) interface{} {
+	lob, err := lobby.GetLobbyByID(*args.ID)
+	if err != nil {
+		return err
+	}
+	if !lob.Scrim {
+		return lobby.ErrNotScrim
+	}
+
+	self := chelpers.GetPlayer(so.Token)
+	if team, ok := lob.CaptainTeam(self.SteamID); (!ok || team != *args.Team) && self.Role != helpers.RoleAdmin {
+		return lobby.ErrNotCaptain
+	}
+
+	slot1, err := format.GetSlot(lob.Type, *args.Team, *args.Class1)
+	if err != nil {
+		return err
+	}
+	slot2, err := format.GetSlot(lob.Type, *args.Team, *args.Class2)
+	if err != nil {
+		return err
+	}
+	if slot1 == slot2 {
+		return emptySuccess
+	}
+
+	if err := lob.SwapSlots(slot1, slot2); err != nil {
+		return err
+	}
+
+	chat.NewBotMessage(fmt.Sprintf("%s swapped %s and %s on %s", self.Alias(), *args.Class1, *args.Class2, lob.TeamName(*args.Team)), int(lob.ID)).Send()
+	return emptySuccess
+}
@@ -0,0 +1,71 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package handler
+
+import (
+	chelpers "github.com/TF2Stadium/Helen/controllers/controllerhelpers"
+	"github.com/TF2Stadium/Helen/models/player"
+	"github.com/TF2Stadium/wsevent"
+)
+
+//Token has the handlers available to sockets authenticated with an API
+//token. Each of them checks the token's scope before calling the actual handler.
+type Token struct{}
+
+func (Token) Name(s string) string {
+	return string((s[0])+32) + s[1:]
+}
+
+func (Token) PlayerProfile(so *wsevent.Client, args struct {
+	Steamid *string `json:"steamid"`
+}) interface{} {
+	if err := chelpers.CheckScope(so, player.ScopeReadProfile); err != nil {
+		return err
+	}
+
+	return Player{}.PlayerProfile(so, args)
+}
+
+func (Token) PlayerSettingsGet(so *wsevent.Client, args struct {
+	Key *string `json:"key"`
+}) interface{} {
+	if err := chelpers.CheckScope(so, player.ScopeReadProfile); err != nil {
+		return err
+	}
+
+	return Player{}.PlayerSettingsGet(so, args)
+}
+
+func (Token) QueueJoin(so *wsevent.Client, args queueJoinArgs) interface{} {
+	if err := chelpers.CheckScope(so, player.ScopeJoinQueue); err != nil {
+		return err
+	}
+
+	return Queue{}.QueueJoin(so, args)
+}
+
+func (Token) QueueLeave(so *wsevent.Client, args struct{}) interface{} {
+	if err := chelpers.CheckScope(so, player.ScopeJoinQueue); err != nil {
+		return err
+	}
+
+	return Queue{}.QueueLeave(so, args)
+}
+
+func (Token) QueueStatus(so *wsevent.Client, args struct{}) interface{} {
+	if err := chelpers.CheckScope(so, player.ScopeJoinQueue); err != nil {
+		return err
+	}
+
+	return Queue{}.QueueStatus(so, args)
+}
+
+func (Token) LobbyCreate(so *wsevent.Client, args lobbyCreateArgs) interface{} {
+	if err := chelpers.CheckScope(so, player.ScopeCreateLobby); err != nil {
+		return err
+	}
+
+	return Lobby{}.LobbyCreate(so, args)
+}
@@ -0,0 +1,56 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package handler
+
+import (
+	chelpers "github.com/TF2Stadium/Helen/controllers/controllerhelpers"
+	"github.com/TF2Stadium/Helen/models/webhook"
+	"github.com/TF2Stadium/wsevent"
+)
+
+func (Player) PlayerWebhookAdd(so *wsevent.Client, args struct {
+	URL    *string  `json:"url"`
+	Events []string `json:"events"`
+}) interface{} {
+	p := chelpers.GetPlayer(so.Token)
+	hook, err := webhook.NewWebhook(p.ID, *args.URL, args.Events)
+	if err != nil {
+		return err
+	}
+
+	// the secret is only sent once, it can't be retrieved later
+	return newResponse(struct {
+		Webhook *webhook.Webhook `json:"webhook"`
+		Secret  string           `json:"secret"`
+	}{hook, hook.Secret})
+}
+
+func (Player) PlayerWebhookList(so *wsevent.Client, _ struct{}) interface{} {
+	p := chelpers.GetPlayer(so.Token)
+	return newResponse(webhook.GetWebhooks(p.ID))
+}
+
+func (Player) PlayerWebhookRemove(so *wsevent.Client, args struct {
+	ID *uint `json:"id"`
+}) interface{} {
+	p := chelpers.GetPlayer(so.Token)
+	if err := webhook.RemoveWebhook(*args.ID, p.ID); err != nil {
+		return err
+	}
+
+	return emptySuccess
+}
+
+func (Player) PlayerWebhookDeliveries(so *wsevent.Client, args struct {
+	ID *uint `json:"id"`
+}) interface{} {
+	p := chelpers.GetPlayer(so.Token)
+	hook, err := webhook.GetWebhook(*args.ID, p.ID)
+	if err != nil {
+		return err
+	}
+
+	return newResponse(hook.Deliveries(20))
+}
@@ -4,12 +4,17 @@ import (
 	"github.com/TF2Stadium/Helen/controllers/controllerhelpers/hooks"
 	"github.com/TF2Stadium/Helen/controllers/socket/handler"
 	"github.com/TF2Stadium/Helen/internal/pprof"
+	"github.com/TF2Stadium/Helen/models/lobby"
 	"github.com/TF2Stadium/Helen/routes/socket"
 	"github.com/dgrijalva/jwt-go"
 )
 
 func RegisterHandlers() {
 	socket.AuthServer.OnDisconnect = hooks.OnDisconnect
+	lobby.ScheduledLobbyReady = hooks.StartReadyUp
+	lobby.DraftFinished = hooks.StartReadyUp
+	lobby.MapVoteFinished = hooks.AfterMapVote
+	socket.TokenServer.OnDisconnect = hooks.OnDisconnect
 	socket.UnauthServer.OnDisconnect = func(string, *jwt.Token) { pprof.Clients.Add(-1) }
 
 	socket.AuthServer.Register(handler.Global{}) //Global Handlers
@@ -18,6 +23,11 @@ func RegisterHandlers() {
 	socket.AuthServer.Register(handler.Chat{})   //Chat Handlers
 	socket.AuthServer.Register(handler.Serveme{})
 	socket.AuthServer.Register(handler.Mumble{})
+	socket.AuthServer.Register(handler.Queue{})
+	socket.AuthServer.Register(handler.Party{})
+	socket.AuthServer.Register(handler.Leaderboard{})
 
 	socket.UnauthServer.Register(handler.Unauth{})
+	socket.UnauthServer.Register(handler.Leaderboard{})
+	socket.TokenServer.Register(handler.Token{})
 }
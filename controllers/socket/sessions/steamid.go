@@ -67,6 +67,18 @@ func GetSockets(steamid string) (sockets []*wsevent.Client, success bool) {
 	return
 }
 
+//GetAllSockets returns the sockets of every connected player, by steamid
+func GetAllSockets() map[string][]*wsevent.Client {
+	socketsMu.RLock()
+	defer socketsMu.RUnlock()
+
+	all := make(map[string][]*wsevent.Client, len(steamIDSockets))
+	for steamid, sockets := range steamIDSockets {
+		all[steamid] = append([]*wsevent.Client(nil), sockets...)
+	}
+	return all
+}
+
 //IsConnected returns whether the given steamid is connected to the website
 func IsConnected(steamid string) bool {
 	_, ok := GetSockets(steamid)
@@ -31,5 +31,5 @@ func StatsHandler(w http.ResponseWriter, r *http.Request) {
 		atomic.LoadInt64(Stats.EUPlayers),
 		atomic.LoadInt64(Stats.AUPlayers),
 		atomic.LoadInt64(Stats.ASPlayers),
-		socket.AuthServer.Clients()+socket.UnauthServer.Clients())
+		socket.AuthServer.Clients()+socket.UnauthServer.Clients()+socket.TokenServer.Clients())
 }
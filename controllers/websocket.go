@@ -20,6 +20,14 @@ var upgrader = websocket.Upgrader{CheckOrigin: func(_ *http.Request) bool { retu
 
 func SocketHandler(w http.ResponseWriter, r *http.Request) {
 	token, err := chelpers.GetToken(r)
+	switch err {
+	case chelpers.ErrRateLimited:
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+		return
+	case player.ErrTokenNotFound:
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
 	if err != nil && err != http.ErrNoCookie { //invalid jwt token
 		logrus.Errorf("Error reading JWT: %v", err)
 		token = nil
@@ -42,7 +50,9 @@ func SocketHandler(w http.ResponseWriter, r *http.Request) {
 
 	var so *wsevent.Client
 
-	if token != nil { //received valid jwt
+	if token != nil && token.Claims.(*chelpers.TF2StadiumClaims).APIToken != nil {
+		so, err = socket.TokenServer.NewClient(upgrader, w, r)
+	} else if token != nil { //received valid jwt
 		so, err = socket.AuthServer.NewClient(upgrader, w, r)
 	} else {
 		so, err = socket.UnauthServer.NewClient(upgrader, w, r)
@@ -70,16 +80,24 @@ func SocketInit(so *wsevent.Client) error {
 	loggedIn := so.Token != nil
 
 	if loggedIn {
-		hooks.AfterConnect(socket.AuthServer, so)
+		claims := so.Token.Claims.(*chelpers.TF2StadiumClaims)
+		if claims.APIToken != nil {
+			hooks.AfterConnect(socket.TokenServer, so)
+		} else {
+			hooks.AfterConnect(socket.AuthServer, so)
+		}
 
-		steamid := so.Token.Claims.(*chelpers.TF2StadiumClaims).SteamID
+		steamid := claims.SteamID
 
-		player, err := player.GetPlayerBySteamID(steamid)
+		p, err := player.GetPlayerBySteamID(steamid)
 		if err != nil {
 			return fmt.Errorf("Couldn't find player record for %s", steamid)
 		}
 
-		hooks.AfterConnectLoggedIn(so, player)
+		if claims.APIToken == nil {
+			go p.RecordIP(chelpers.GetIPAddr(so.Request), player.IPSourceSocket)
+		}
+		hooks.AfterConnectLoggedIn(so, p)
 	} else {
 		hooks.AfterConnect(socket.UnauthServer, so)
 		so.EmitJSON(helpers.NewRequest("playerSettings", "{}"))
@@ -11,8 +11,11 @@ import (
 	"github.com/TF2Stadium/Helen/models"
 	"github.com/TF2Stadium/Helen/models/chat"
 	"github.com/TF2Stadium/Helen/models/gameserver"
+	"github.com/TF2Stadium/Helen/models/leaderboard"
 	"github.com/TF2Stadium/Helen/models/lobby"
+	"github.com/TF2Stadium/Helen/models/lobby_settings"
 	"github.com/TF2Stadium/Helen/models/player"
+	"github.com/TF2Stadium/Helen/models/webhook"
 )
 
 var once = new(sync.Once)
@@ -27,10 +30,42 @@ func Do() {
 	database.DB.AutoMigrate(&models.AdminLogEntry{})
 	database.DB.AutoMigrate(&player.PlayerBan{})
 	database.DB.AutoMigrate(&chat.ChatMessage{})
+	database.DB.AutoMigrate(&chat.FilterRule{})
+	database.DB.AutoMigrate(&chat.ModerationAction{})
+	database.DB.AutoMigrate(&chat.DirectMessage{})
+	database.DB.AutoMigrate(&player.Friendship{})
+	database.DB.AutoMigrate(&player.PlayerBlock{})
 	database.DB.AutoMigrate(&lobby.Requirement{})
 	database.DB.AutoMigrate(&Constant{})
 	database.DB.AutoMigrate(&gameserver.StoredServer{})
 	database.DB.AutoMigrate(&player.Report{})
+	database.DB.AutoMigrate(&player.PlayerRating{})
+	database.DB.AutoMigrate(&player.MatchStats{})
+	database.DB.AutoMigrate(&lobby.ScrimInvite{})
+	database.DB.AutoMigrate(&lobby.DraftPlayer{})
+	database.DB.AutoMigrate(&lobby.MapVote{})
+	database.DB.AutoMigrate(&lobby.LobbyClassTime{})
+	database.DB.AutoMigrate(&lobby.LobbySubstitute{})
+	database.DB.AutoMigrate(&leaderboard.Season{})
+	database.DB.AutoMigrate(&leaderboard.Entry{})
+	database.DB.AutoMigrate(&player.APIToken{})
+	database.DB.AutoMigrate(&player.BanAppeal{})
+	database.DB.AutoMigrate(&player.CommunityReport{})
+	database.DB.AutoMigrate(&player.BanRule{})
+	database.DB.AutoMigrate(&player.PlayerIP{})
+	database.DB.AutoMigrate(&player.AltFlag{})
+	database.DB.AutoMigrate(&player.AltLink{})
+	database.DB.AutoMigrate(&webhook.Webhook{})
+	database.DB.AutoMigrate(&webhook.Delivery{})
+	database.DB.AutoMigrate(&webhook.Attempt{})
+	database.DB.AutoMigrate(&lobbySettings.StoredFormat{})
+	database.DB.AutoMigrate(&lobbySettings.StoredMap{})
+	database.DB.AutoMigrate(&lobbySettings.StoredMapFormat{})
+	database.DB.AutoMigrate(&lobbySettings.StoredLeague{})
+	database.DB.AutoMigrate(&lobbySettings.StoredLeagueDescription{})
+	database.DB.AutoMigrate(&lobbySettings.StoredLeagueFormat{})
+	database.DB.AutoMigrate(&lobbySettings.StoredWhitelist{})
+	database.DB.AutoMigrate(&lobbySettings.Change{})
 
 	database.DB.Model(&lobby.LobbySlot{}).
 		AddUniqueIndex("idx_lobby_slot_lobby_id_slot", "lobby_id", "slot")
@@ -38,6 +73,20 @@ func Do() {
 		AddUniqueIndex("idx_lobby_id_player_id", "lobby_id", "player_id")
 	database.DB.Model(&lobby.LobbySlot{}).
 		AddUniqueIndex("idx_requirement_lobby_id_slot", "lobby_id", "slot")
+	database.DB.Model(&player.PlayerRating{}).
+		AddUniqueIndex("idx_player_rating_player_id_format", "player_id", "format")
+	database.DB.Model(&lobby.ScrimInvite{}).
+		AddUniqueIndex("idx_scrim_invite_lobby_id_player_id", "lobby_id", "player_id")
+	database.DB.Model(&lobby.DraftPlayer{}).
+		AddUniqueIndex("idx_draft_player_lobby_id_player_id", "lobby_id", "player_id")
+	database.DB.Model(&lobby.MapVote{}).
+		AddUniqueIndex("idx_map_vote_lobby_id_player_id", "lobby_id", "player_id")
+	database.DB.Model(&player.MatchStats{}).
+		AddUniqueIndex("idx_match_stats_lobby_id_player_id", "lobby_id", "player_id")
+	database.DB.Model(&leaderboard.Entry{}).
+		AddIndex("idx_leaderboard_entry_board", "season_id", "category", "class", "format", "region")
 
+	player.SaveDefaultBanRules()
 	once.Do(checkSchema)
+	encryptPlaintextSecrets()
 }
@@ -5,14 +5,16 @@
 package migrations
 
 import (
+	"fmt"
 	"math/rand"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/Sirupsen/logrus"
+	"github.com/TF2Stadium/Helen/config"
 	db "github.com/TF2Stadium/Helen/database"
 	"github.com/TF2Stadium/Helen/helpers"
+	"github.com/TF2Stadium/Helen/models/chat"
 	"github.com/TF2Stadium/Helen/models/lobby"
 	"github.com/TF2Stadium/Helen/models/lobby/format"
 	"github.com/TF2Stadium/Helen/models/player"
@@ -34,6 +36,9 @@ var migrationRoutines = map[uint64]func(){
 	12: moveReportsServers,
 	13: dropUnusedColumns,
 	14: downloadSTVDemos,
+	15: computeReliability,
+	16: encryptSecrets,
+	17: setServerProviders,
 }
 
 func whitelist_id_string() {
@@ -197,26 +202,51 @@ func dropUnusedColumns() {
 }
 
 func downloadSTVDemos() {
-	var lobbies []*lobby.Lobby
+	// Lobby doesn't have ServemeID anymore, the column is dropped by setServerProviders
+	var lobbies []struct {
+		ID               uint
+		ServemeID        int
+		CreatedBySteamID string
+		RegionCode       string
+	}
 
 	since := time.Now().Add(time.Hour * 24 * 30 * -1)
-	db.DB.Model(&lobby.Lobby{}).
+	db.DB.Table("lobbies").Select("id, serveme_id, created_by_steam_id, region_code").
 		Where("match_ended = TRUE AND serveme_id <> 0 AND created_at > ?", since).
-		Find(&lobbies)
+		Scan(&lobbies)
 	logrus.Debug("Downloading Demos for ", len(lobbies), " lobbies")
 
 	for _, lob := range lobbies {
-		go func(lobby *lobby.Lobby) {
-			switch strings.ToLower(lobby.RegionCode) {
-			case "na", "sa":
-				lobby.DownloadDemo(helpers.ServemeNA)
-			case "eu", "as":
-				lobby.DownloadDemo(helpers.ServemeEU)
-			case "oc":
-				lobby.DownloadDemo(helpers.ServemeAU)
-			default:
-				lobby.DownloadDemo(helpers.ServemeEU)
+		go func(id uint, servemeID int, steamID, region string) {
+			context := helpers.GetServemeContextRegion(region)
+			file := fmt.Sprintf("%s/%d.dem", config.Constants.DemosFolder, id)
+			if err := context.DownloadDemo(servemeID, steamID, file); err != nil {
+				logrus.Error(err)
+				return
 			}
-		}(lob)
+
+			url := fmt.Sprintf("%s/demos/%d.dem", config.Constants.PublicAddress, id)
+			chat.SendNotification("STV Demo for this lobby is available at "+url, int(id))
+		}(lob.ID, lob.ServemeID, lob.CreatedBySteamID, lob.RegionCode)
 	}
 }
+
+func computeReliability() {
+	var players []*player.Player
+	db.DB.Model(&player.Player{}).Find(&players)
+
+	for _, player := range players {
+		player.UpdateReliability()
+	}
+}
+
+func setServerProviders() {
+	// serveme.tf reservations are recorded in the columns used for all server providers
+	db.DB.Exec("UPDATE lobbies SET server_provider = 'serveme', reservation_id = serveme_id WHERE serveme_id <> 0")
+	// stored servers used by open lobbies are released by their provider once the lobbies close
+	db.DB.Exec(`UPDATE lobbies SET server_provider = 'storedServer', reservation_id = stored_servers.id
+		FROM server_records, stored_servers
+		WHERE server_records.id = lobbies.server_info_id AND stored_servers.address = server_records.host
+		AND lobbies.state <> ? AND (lobbies.server_provider IS NULL OR lobbies.server_provider = '')`, lobby.Ended)
+	db.DB.Model(&lobby.Lobby{}).DropColumn("serveme_id")
+}
@@ -0,0 +1,112 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package migrations
+
+import (
+	"fmt"
+
+	"github.com/Sirupsen/logrus"
+	db "github.com/TF2Stadium/Helen/database"
+	"github.com/TF2Stadium/Helen/helpers/secret"
+)
+
+// table -> columns stored as secret.String
+var secretColumns = map[string][]string{
+	"stored_servers": {"rcon_password"},
+	"server_records": {"rcon_password", "server_password", "log_secret"},
+}
+
+//updateSecrets replaces every secret in the database with f(secret)
+func updateSecrets(f func(string) (string, error)) (int, error) {
+	updated := 0
+
+	for table, columns := range secretColumns {
+		for _, column := range columns {
+			rows, err := db.DB.DB().Query(fmt.Sprintf("SELECT id, %s FROM %s WHERE %s <> ''", column, table, column))
+			if err != nil {
+				return updated, err
+			}
+
+			values := make(map[uint]string)
+			for rows.Next() {
+				var id uint
+				var value string
+				if err := rows.Scan(&id, &value); err != nil {
+					rows.Close()
+					return updated, err
+				}
+				values[id] = value
+			}
+			rows.Close()
+
+			for id, value := range values {
+				newValue, err := f(value)
+				if err != nil {
+					return updated, fmt.Errorf("%s.%s (id %d): %v", table, column, id, err)
+				}
+				if newValue == value {
+					continue
+				}
+
+				// pass a plain string, so the value isn't encrypted again
+				err = db.DB.Exec(fmt.Sprintf("UPDATE %s SET %s = ? WHERE id = ?", table, column), newValue, id).Error
+				if err != nil {
+					return updated, err
+				}
+				updated++
+			}
+		}
+	}
+
+	return updated, nil
+}
+
+func encryptSecrets() {
+	// encrypted values don't fit in varchar(255)
+	for table, columns := range secretColumns {
+		for _, column := range columns {
+			err := db.DB.Exec(fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s TYPE text", table, column)).Error
+			if err != nil {
+				logrus.Fatal(err)
+			}
+		}
+	}
+
+	if !secret.Enabled() {
+		logrus.Warning("SECRETS_KEY isn't set, RCON passwords and server secrets are stored in plaintext")
+	}
+	// existing secrets are encrypted by encryptPlaintextSecrets after the migration
+}
+
+//encryptPlaintextSecrets encrypts secrets which are still stored in plaintext,
+//like the ones stored before SECRETS_KEY was set. Runs on every start.
+func encryptPlaintextSecrets() {
+	if !secret.Enabled() {
+		return
+	}
+
+	n, err := updateSecrets(func(s string) (string, error) {
+		if secret.IsEncrypted(s) {
+			return s, nil
+		}
+		return secret.Encrypt(s)
+	})
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	if n != 0 {
+		logrus.Infof("Encrypted %d secrets stored in plaintext", n)
+	}
+}
+
+//RotateSecretsKey wraps the data keys of all secrets in the database with the
+//current key (SECRETS_KEY), and encrypts the ones which are still stored in
+//plaintext. Secrets encrypted with an old key need it in SECRETS_OLD_KEYS.
+func RotateSecretsKey() (int, error) {
+	if !secret.Enabled() {
+		return 0, secret.ErrNoKey
+	}
+	return updateSecrets(secret.Rewrap)
+}
@@ -37,7 +37,10 @@ const (
 	ActionViewLogs
 	ActionViewPage //view admin pages
 	ActionDeleteChat
-	ModifyServers //add/remove servers
+	ModifyServers       //add/remove servers
+	ModifyWebhooks      //add/remove server-wide webhooks
+	ModifySeasons       //add/remove leaderboard seasons
+	ModifyLobbySettings //add/change/remove maps, leagues, whitelists and formats
 )
 
 var ActionNames = map[authority.AuthAction]string{
@@ -62,4 +65,7 @@ func init() {
 
 	RoleAdmin.Inherit(RoleMod)
 	RoleAdmin.Allow(ActionChangeRole)
+	RoleAdmin.Allow(ModifyWebhooks)
+	RoleAdmin.Allow(ModifySeasons)
+	RoleAdmin.Allow(ModifyLobbySettings)
 }
@@ -30,14 +30,18 @@ func GetRegion(server string) (string, string) {
 		return "", ""
 	}
 
-	arr := strings.Split(server, ":")
-	addr, err := net.ResolveIPAddr("ip4", arr[0])
-	if err != nil {
-		logrus.Error(err.Error())
-		return "", ""
+	ip := net.ParseIP(server) // IPv6 addresses contain colons too
+	if ip == nil {
+		arr := strings.Split(server, ":")
+		addr, err := net.ResolveIPAddr("ip4", arr[0])
+		if err != nil {
+			logrus.Error(err.Error())
+			return "", ""
+		}
+		ip = addr.IP
 	}
 
-	record, err := geodb.Country(addr.IP)
+	record, err := geodb.Country(ip)
 	if err != nil {
 		logrus.Error(err.Error())
 		return "", ""
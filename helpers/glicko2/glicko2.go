@@ -0,0 +1,116 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+//Package glicko2 implements the Glicko-2 rating system, as described in
+//http://www.glicko.net/glicko/glicko2.pdf
+package glicko2
+
+import "math"
+
+const (
+	DefaultRating     = 1500.0
+	DefaultRD         = 350.0
+	DefaultVolatility = 0.06
+
+	scale   = 173.7178
+	tau     = 0.5 // constrains the change in volatility over time
+	epsilon = 0.000001
+)
+
+//Rating represents a player's rating, rating deviation and volatility
+type Rating struct {
+	Rating     float64
+	RD         float64
+	Volatility float64
+}
+
+//Result represents the outcome of a game against an opponent.
+//Score is 1 for a win, 0.5 for a draw and 0 for a loss.
+type Result struct {
+	Opponent Rating
+	Score    float64
+}
+
+//NewRating returns the rating given to players who haven't played yet
+func NewRating() Rating {
+	return Rating{DefaultRating, DefaultRD, DefaultVolatility}
+}
+
+func g(phi float64) float64 {
+	return 1 / math.Sqrt(1+3*phi*phi/(math.Pi*math.Pi))
+}
+
+func expected(mu, muj, phij float64) float64 {
+	return 1 / (1 + math.Exp(-g(phij)*(mu-muj)))
+}
+
+//Update returns the player's new rating after the given results, which are
+//treated as a single rating period. If results is empty, only the rating
+//deviation increases.
+func (r Rating) Update(results []Result) Rating {
+	mu := (r.Rating - DefaultRating) / scale
+	phi := r.RD / scale
+	sigma := r.Volatility
+
+	if len(results) == 0 {
+		phi = math.Sqrt(phi*phi + sigma*sigma)
+		return Rating{r.Rating, math.Min(phi*scale, DefaultRD), sigma}
+	}
+
+	var vInv, deltaSum float64
+	for _, result := range results {
+		muj := (result.Opponent.Rating - DefaultRating) / scale
+		phij := result.Opponent.RD / scale
+		e := expected(mu, muj, phij)
+
+		vInv += g(phij) * g(phij) * e * (1 - e)
+		deltaSum += g(phij) * (result.Score - e)
+	}
+	v := 1 / vInv
+	delta := v * deltaSum
+
+	sigma = newVolatility(sigma, phi, v, delta)
+
+	phiStar := math.Sqrt(phi*phi + sigma*sigma)
+	phi = 1 / math.Sqrt(1/(phiStar*phiStar)+1/v)
+	mu = mu + phi*phi*deltaSum
+
+	return Rating{mu*scale + DefaultRating, phi * scale, sigma}
+}
+
+//newVolatility computes the new volatility with the Illinois algorithm
+func newVolatility(sigma, phi, v, delta float64) float64 {
+	a := math.Log(sigma * sigma)
+	f := func(x float64) float64 {
+		ex := math.Exp(x)
+		d := phi*phi + v + ex
+		return ex*(delta*delta-phi*phi-v-ex)/(2*d*d) - (x-a)/(tau*tau)
+	}
+
+	A := a
+	var B float64
+	if delta*delta > phi*phi+v {
+		B = math.Log(delta*delta - phi*phi - v)
+	} else {
+		k := 1.0
+		for f(a-k*tau) < 0 {
+			k++
+		}
+		B = a - k*tau
+	}
+
+	fA, fB := f(A), f(B)
+	for math.Abs(B-A) > epsilon {
+		C := A + (A-B)*fA/(fB-fA)
+		fC := f(C)
+		if fC*fB <= 0 {
+			A, fA = B, fB
+		} else {
+			fA = fA / 2
+		}
+		B, fB = C, fC
+	}
+
+	return math.Exp(A / 2)
+}
@@ -0,0 +1,44 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package glicko2_test
+
+import (
+	"testing"
+
+	. "github.com/TF2Stadium/Helen/helpers/glicko2"
+	"github.com/stretchr/testify/assert"
+)
+
+// example from http://www.glicko.net/glicko/glicko2.pdf
+func TestUpdate(t *testing.T) {
+	r := Rating{1500, 200, 0.06}
+	r = r.Update([]Result{
+		{Rating{1400, 30, 0.06}, 1},
+		{Rating{1550, 100, 0.06}, 0},
+		{Rating{1700, 300, 0.06}, 0},
+	})
+
+	assert.InDelta(t, 1464.06, r.Rating, 0.01)
+	assert.InDelta(t, 151.52, r.RD, 0.01)
+	assert.InDelta(t, 0.05999, r.Volatility, 0.00001)
+}
+
+func TestNoGames(t *testing.T) {
+	r := Rating{1500, 200, 0.06}.Update(nil)
+	assert.Equal(t, 1500.0, r.Rating)
+	assert.True(t, r.RD > 200)
+
+	r = NewRating().Update(nil)
+	assert.Equal(t, DefaultRD, r.RD)
+}
+
+func TestWinLoss(t *testing.T) {
+	winner := NewRating().Update([]Result{{NewRating(), 1}})
+	loser := NewRating().Update([]Result{{NewRating(), 0}})
+
+	assert.True(t, winner.Rating > DefaultRating)
+	assert.True(t, loser.Rating < DefaultRating)
+	assert.InDelta(t, winner.Rating-DefaultRating, DefaultRating-loser.Rating, 0.001)
+}
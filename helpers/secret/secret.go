@@ -0,0 +1,269 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+//Package secret encrypts sensitive values, like RCON passwords, before they're
+//stored in the database. Every value is encrypted with its own random data
+//key, which is encrypted ("wrapped") with config.Constants.SecretsKey and
+//stored next to it, so changing the key only needs the data keys to be
+//wrapped again.
+package secret
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/TF2Stadium/Helen/config"
+)
+
+// encrypted values look like enc:v1:<key ID>:<wrapped data key>:<ciphertext>
+const prefix = "enc:v1:"
+
+var (
+	ErrInvalidKey = errors.New("Secrets keys have to be base64 encoded 32 byte keys")
+	ErrNoKey      = errors.New("SECRETS_KEY isn't set")
+	ErrUnknownKey = errors.New("Secret was encrypted with an unknown key")
+	ErrMalformed  = errors.New("Malformed encrypted secret")
+)
+
+type key struct {
+	id   string // first bytes of the key's hash, stored with values to find the key they need
+	aead cipher.AEAD
+}
+
+var (
+	current *key
+	keys    = make(map[string]*key) // key ID -> key, includes old keys
+)
+
+//Init loads the current and old keys from the config. Until it's called,
+//secrets are stored in plaintext.
+func Init() error {
+	current = nil
+	keys = make(map[string]*key)
+	if config.Constants.SecretsKey == "" {
+		return nil
+	}
+
+	for _, s := range config.Constants.SecretsOldKeys {
+		k, err := parseKey(s)
+		if err != nil {
+			return err
+		}
+		keys[k.id] = k
+	}
+
+	k, err := parseKey(config.Constants.SecretsKey)
+	if err != nil {
+		return err
+	}
+	current = k
+	keys[k.id] = k
+	return nil
+}
+
+func parseKey(s string) (*key, error) {
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil || len(b) != 32 {
+		return nil, ErrInvalidKey
+	}
+
+	aead, err := newAEAD(b)
+	if err != nil {
+		return nil, err
+	}
+
+	hash := sha256.Sum256(b)
+	return &key{id: hex.EncodeToString(hash[:4]), aead: aead}, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func seal(aead cipher.AEAD, plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func open(aead cipher.AEAD, data []byte) ([]byte, error) {
+	if len(data) < aead.NonceSize() {
+		return nil, ErrMalformed
+	}
+	return aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], nil)
+}
+
+//Enabled returns true if secrets are encrypted before they're stored
+func Enabled() bool {
+	return current != nil
+}
+
+//IsEncrypted returns true if s is an encrypted value
+func IsEncrypted(s string) bool {
+	return strings.HasPrefix(s, prefix)
+}
+
+//Encrypt encrypts plaintext with a new data key, wrapped with the current key.
+//Returns plaintext as it is if there isn't a key, or if it's empty.
+func Encrypt(plaintext string) (string, error) {
+	if current == nil || plaintext == "" {
+		return plaintext, nil
+	}
+
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", err
+	}
+	wrapped, err := seal(current.aead, dataKey)
+	if err != nil {
+		return "", err
+	}
+
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+	ciphertext, err := seal(aead, []byte(plaintext))
+	if err != nil {
+		return "", err
+	}
+
+	return format(current.id, wrapped, ciphertext), nil
+}
+
+//Decrypt decrypts a value returned by Encrypt. Values which aren't encrypted,
+//like the ones stored before a key was set, are returned as they are.
+func Decrypt(s string) (string, error) {
+	if !IsEncrypted(s) {
+		return s, nil
+	}
+
+	e, err := parse(s)
+	if err != nil {
+		return "", err
+	}
+	dataKey, err := e.dataKey()
+	if err != nil {
+		return "", err
+	}
+
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := open(aead, e.ciphertext)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+//Rewrap wraps the data key of an encrypted value with the current key, and
+//encrypts values which aren't encrypted yet. Used for rotating keys.
+func Rewrap(s string) (string, error) {
+	if current == nil {
+		return "", ErrNoKey
+	}
+	if !IsEncrypted(s) {
+		return Encrypt(s)
+	}
+
+	e, err := parse(s)
+	if err != nil {
+		return "", err
+	}
+	if e.keyID == current.id {
+		return s, nil
+	}
+
+	dataKey, err := e.dataKey()
+	if err != nil {
+		return "", err
+	}
+	wrapped, err := seal(current.aead, dataKey)
+	if err != nil {
+		return "", err
+	}
+	return format(current.id, wrapped, e.ciphertext), nil
+}
+
+type envelope struct {
+	keyID      string
+	wrappedKey []byte
+	ciphertext []byte
+}
+
+func format(keyID string, wrappedKey, ciphertext []byte) string {
+	return prefix + keyID + ":" + base64.RawStdEncoding.EncodeToString(wrappedKey) + ":" +
+		base64.RawStdEncoding.EncodeToString(ciphertext)
+}
+
+func parse(s string) (*envelope, error) {
+	parts := strings.Split(strings.TrimPrefix(s, prefix), ":")
+	if len(parts) != 3 {
+		return nil, ErrMalformed
+	}
+
+	e := &envelope{keyID: parts[0]}
+	var err error
+	if e.wrappedKey, err = base64.RawStdEncoding.DecodeString(parts[1]); err != nil {
+		return nil, ErrMalformed
+	}
+	if e.ciphertext, err = base64.RawStdEncoding.DecodeString(parts[2]); err != nil {
+		return nil, ErrMalformed
+	}
+	return e, nil
+}
+
+func (e *envelope) dataKey() ([]byte, error) {
+	k, ok := keys[e.keyID]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	return open(k.aead, e.wrappedKey)
+}
+
+//String is a string which is encrypted when it's stored in the database, and
+//decrypted when it's loaded
+type String string
+
+//Value implements driver.Valuer
+func (s String) Value() (driver.Value, error) {
+	return Encrypt(string(s))
+}
+
+//Scan implements sql.Scanner
+func (s *String) Scan(src interface{}) error {
+	var str string
+	switch v := src.(type) {
+	case string:
+		str = v
+	case []byte:
+		str = string(v)
+	case nil:
+	default:
+		return fmt.Errorf("Can't scan %T into a secret", src)
+	}
+
+	plaintext, err := Decrypt(str)
+	if err != nil {
+		return err
+	}
+	*s = String(plaintext)
+	return nil
+}
@@ -0,0 +1,109 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package secret_test
+
+import (
+	"testing"
+
+	"github.com/TF2Stadium/Helen/config"
+	. "github.com/TF2Stadium/Helen/helpers/secret"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	key1 = "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="
+	key2 = "ZmVkY2JhOTg3NjU0MzIxMGZlZGNiYTk4NzY1NDMyMTA="
+)
+
+func setKeys(t *testing.T, key string, oldKeys ...string) {
+	config.Constants.SecretsKey = key
+	config.Constants.SecretsOldKeys = oldKeys
+	require.NoError(t, Init())
+}
+
+func TestEncrypt(t *testing.T) {
+	setKeys(t, key1)
+	defer setKeys(t, "")
+
+	enc, err := Encrypt("rconpassword")
+	require.NoError(t, err)
+	assert.True(t, IsEncrypted(enc))
+	assert.NotContains(t, enc, "rconpassword")
+
+	// every value gets its own data key
+	enc2, _ := Encrypt("rconpassword")
+	assert.NotEqual(t, enc, enc2)
+
+	dec, err := Decrypt(enc)
+	assert.NoError(t, err)
+	assert.Equal(t, "rconpassword", dec)
+
+	// values stored before encryption was enabled
+	dec, err = Decrypt("plaintext")
+	assert.NoError(t, err)
+	assert.Equal(t, "plaintext", dec)
+
+	enc, _ = Encrypt("")
+	assert.Equal(t, "", enc)
+}
+
+func TestNoKey(t *testing.T) {
+	setKeys(t, "")
+	assert.False(t, Enabled())
+
+	enc, err := Encrypt("rconpassword")
+	assert.NoError(t, err)
+	assert.Equal(t, "rconpassword", enc)
+
+	_, err = Rewrap("rconpassword")
+	assert.Equal(t, ErrNoKey, err)
+
+	config.Constants.SecretsKey = "short"
+	assert.Equal(t, ErrInvalidKey, Init())
+}
+
+func TestRewrap(t *testing.T) {
+	setKeys(t, key1)
+	defer setKeys(t, "")
+	enc, _ := Encrypt("rconpassword")
+
+	setKeys(t, key2)
+	_, err := Decrypt(enc)
+	assert.Equal(t, ErrUnknownKey, err)
+
+	setKeys(t, key2, key1)
+	rewrapped, err := Rewrap(enc)
+	require.NoError(t, err)
+	assert.NotEqual(t, enc, rewrapped)
+
+	again, _ := Rewrap(rewrapped)
+	assert.Equal(t, rewrapped, again)
+
+	// the old key isn't needed anymore
+	setKeys(t, key2)
+	dec, err := Decrypt(rewrapped)
+	assert.NoError(t, err)
+	assert.Equal(t, "rconpassword", dec)
+
+	rewrapped, _ = Rewrap("plaintext")
+	assert.True(t, IsEncrypted(rewrapped))
+}
+
+func TestString(t *testing.T) {
+	setKeys(t, key1)
+	defer setKeys(t, "")
+
+	value, err := String("rconpassword").Value()
+	require.NoError(t, err)
+	assert.True(t, IsEncrypted(value.(string)))
+
+	var s String
+	assert.NoError(t, s.Scan([]byte(value.(string))))
+	assert.Equal(t, String("rconpassword"), s)
+
+	assert.NoError(t, s.Scan(nil))
+	assert.Equal(t, String(""), s)
+}
@@ -16,6 +16,12 @@ var (
 
 func GetServemeContextIP(ipaddr string) *servemetf.Context {
 	continent, _ := GetRegion(ipaddr)
+	return GetServemeContextRegion(continent)
+}
+
+//GetServemeContextRegion returns the serveme.tf site for the region code
+//returned by GetRegion
+func GetServemeContextRegion(continent string) *servemetf.Context {
 	switch strings.ToLower(continent) {
 	case "na": // north america
 		return ServemeNA
@@ -43,18 +43,50 @@ func CleanupDB() {
 
 	tables := []string{
 		"admin_log_entries",
+		"alt_flags",
+		"alt_links",
+		"api_tokens",
+		"ban_appeals",
+		"ban_rules",
 		"banned_players_lobbies",
 		"chat_messages",
+		"community_reports",
+		"direct_messages",
+		"draft_players",
+		"filter_rules",
+		"friendships",
+		"leaderboard_entries",
 		"lobbies",
+		"lobby_class_times",
+		"lobby_settings_changes",
+		"lobby_settings_formats",
+		"lobby_settings_league_descriptions",
+		"lobby_settings_league_formats",
+		"lobby_settings_leagues",
+		"lobby_settings_map_formats",
+		"lobby_settings_maps",
+		"lobby_settings_whitelists",
 		"lobby_slots",
+		"lobby_substitutes",
+		"map_votes",
+		"match_stats",
+		"moderation_actions",
 		"player_bans",
+		"player_blocks",
+		"player_ips",
+		"player_ratings",
 		"player_stats",
 		"players",
 		"reports",
 		"requirements",
+		"scrim_invites",
+		"seasons",
 		"server_records",
 		"spectators_players_lobbies",
 		"stored_servers",
+		"webhook_attempts",
+		"webhook_deliveries",
+		"webhooks",
 	}
 	for _, table := range tables {
 		database.DB.Exec("TRUNCATE TABLE " + table + " RESTART IDENTITY")
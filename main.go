@@ -24,13 +24,18 @@ import (
 	"github.com/TF2Stadium/Helen/database/migrations"
 	"github.com/TF2Stadium/Helen/helpers"
 	_ "github.com/TF2Stadium/Helen/helpers/authority" // to register authority types
-	_ "github.com/TF2Stadium/Helen/internal/pprof"    // to setup expvars
+	"github.com/TF2Stadium/Helen/helpers/secret"
+	_ "github.com/TF2Stadium/Helen/internal/pprof" // to setup expvars
 	"github.com/TF2Stadium/Helen/internal/version"
 	"github.com/TF2Stadium/Helen/models/chat"
 	"github.com/TF2Stadium/Helen/models/event"
+	"github.com/TF2Stadium/Helen/models/gameserver"
+	"github.com/TF2Stadium/Helen/models/leaderboard"
 	"github.com/TF2Stadium/Helen/models/lobby"
+	"github.com/TF2Stadium/Helen/models/lobby/format"
 	"github.com/TF2Stadium/Helen/models/lobby_settings"
 	"github.com/TF2Stadium/Helen/models/rpc"
+	"github.com/TF2Stadium/Helen/models/webhook"
 	"github.com/TF2Stadium/Helen/routes"
 	socketServer "github.com/TF2Stadium/Helen/routes/socket"
 	"github.com/evalphobia/logrus_sentry"
@@ -41,6 +46,9 @@ var (
 	flagGen   = flag.Bool("genkey", false, "write a 32bit key for encrypting cookies the given file, and exit")
 	docPrint  = flag.Bool("printdoc", false, "print the docs for environment variables, and exit.")
 	dbMaxopen = flag.Int("db-maxopen", 80, "maximum number of open database connections allowed.")
+	backfill  = flag.Bool("backfill-stats", false, "store match stats for lobbies with a logs.tf log which don't have them yet, and exit.")
+	genSecret = flag.Bool("gensecretskey", false, "print a new key for SECRETS_KEY, and exit.")
+	rotateKey = flag.Bool("rotate-secrets-key", false, "encrypt all secrets in the database with SECRETS_KEY (decrypting them with SECRETS_OLD_KEYS), and exit.")
 )
 
 func main() {
@@ -57,6 +65,16 @@ func main() {
 		fmt.Println(base64Key)
 		return
 	}
+	if *genSecret {
+		// SECRETS_KEY is an AES-256 key, unlike the cookie key
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			logrus.Fatal(err)
+		}
+
+		fmt.Println(base64.StdEncoding.EncodeToString(key))
+		return
+	}
 	if *docPrint {
 		config.PrintConfigDoc()
 		os.Exit(0)
@@ -94,22 +112,48 @@ func main() {
 		logrus.Info("Running Profiler at ", config.Constants.ProfilerAddr)
 	}
 
+	if err := secret.Init(); err != nil {
+		logrus.Fatal(err)
+	}
+
 	database.Init()
 	database.DB.DB().SetMaxOpenConns(*dbMaxopen)
 	migrations.Do()
 
+	if *rotateKey {
+		n, err := migrations.RotateSecretsKey()
+		if err != nil {
+			logrus.Fatal(err)
+		}
+		logrus.Infof("Encrypted %d secrets with the current key", n)
+		return
+	}
+
+	if *backfill {
+		n := lobby.BackfillMatchStats()
+		logrus.Infof("Stored match stats for %d lobbies", n)
+		return
+	}
+
 	helpers.ConnectAMQP()
 	event.StartListening()
 	helpers.InitGeoIPDB()
 
-	err = lobbySettings.LoadLobbySettingsFromFile("assets/lobbySettingsData.json")
+	err = lobbySettings.InitLobbySettings("assets/lobbySettingsData.json")
 	if err != nil {
 		logrus.Fatal(err)
 	}
 
+	if config.Constants.FormatsFile != "" {
+		err = format.LoadFromFile(config.Constants.FormatsFile)
+		if err != nil {
+			logrus.Fatal(err)
+		}
+	}
+
 	lobby.CreateLocks()
 	rpc.ConnectRPC(helpers.AMQPConn)
-	lobby.RestoreServemeChecks()
+	lobby.RestoreReservationWatches()
 	//go models.TFTVStreamStatusUpdater()
 
 	if config.Constants.SteamIDWhitelist != "" {
@@ -119,6 +163,18 @@ func main() {
 	mux := http.NewServeMux()
 	routes.SetupHTTP(mux)
 	socket.RegisterHandlers()
+	// after RegisterHandlers, which sets the hook for readying up scheduled lobbies
+	lobby.RestoreScheduledLobbies()
+	lobby.RestoreDrafts()
+	lobby.RestoreMapVotes()
+	webhook.StartDelivering()
+	leaderboard.StartComputing()
+	gameserver.StartHealthChecks()
+	if config.Constants.LogListenAddress != "" {
+		if err := lobby.StartLogListener(config.Constants.LogListenAddress); err != nil {
+			logrus.Fatal(err)
+		}
+	}
 
 	corsHandler := cors.New(cors.Options{
 		AllowedOrigins:   config.Constants.AllowedOrigins,
@@ -52,9 +52,15 @@ func NewInGameChatMessage(lobbyID uint, player *player.Player, message string) *
 func (m *ChatMessage) Save() { db.DB.Save(m) }
 
 func (m *ChatMessage) Send() {
-	broadcaster.SendMessageToRoom(fmt.Sprintf("%d_public", m.Room), "chatReceive", m)
+	// players don't get messages from players they've blocked
+	var blockedBy []string
+	if !m.Bot {
+		blockedBy = player.GetBlockedBy(m.PlayerID)
+	}
+
+	broadcaster.SendMessageToRoomSkipPlayers(fmt.Sprintf("%d_public", m.Room), blockedBy, "chatReceive", m)
 	if m.Room != 0 {
-		broadcaster.SendMessageToRoom(fmt.Sprintf("%d_private", m.Room), "chatReceive", m)
+		broadcaster.SendMessageToRoomSkipPlayers(fmt.Sprintf("%d_private", m.Room), blockedBy, "chatReceive", m)
 	}
 }
 
@@ -0,0 +1,137 @@
+package chat
+
+import (
+	"errors"
+	"time"
+
+	"github.com/TF2Stadium/Helen/controllers/broadcaster"
+	db "github.com/TF2Stadium/Helen/database"
+	"github.com/TF2Stadium/Helen/models/player"
+)
+
+//MaxDirectMessageLength is the maximum length of a direct message
+const MaxDirectMessageLength = 500
+
+//DirectMessageRoom is the room direct messages are filtered (and moderation
+//actions on them recorded) as
+const DirectMessageRoom = -1
+
+var ErrDirectMessageTooLong = errors.New("Message too long")
+
+//DirectMessage is a private message sent from one player to another
+type DirectMessage struct {
+	ID        uint      `json:"id"`
+	CreatedAt time.Time `json:"timestamp"`
+
+	SenderID    uint       `json:"-"`
+	RecipientID uint       `json:"-"`
+	Message     string     `sql:"type:varchar(500)" json:"message"`
+	ReadAt      *time.Time `json:"readAt"` // nil if the recipient hasn't read the message yet
+
+	// filled in for the client
+	From string `sql:"-" json:"from"` // steamid of the sender
+	To   string `sql:"-" json:"to"`   // steamid of the recipient
+}
+
+//SendDirectMessage sends a direct message from the player to recipient,
+//through broadcaster.SendMessage. Players can only message their friends.
+func SendDirectMessage(from, to *player.Player, message string) (*DirectMessage, error) {
+	switch {
+	case len(message) == 0:
+		return nil, errors.New("Cannot send an empty message")
+	case len(message) > MaxDirectMessageLength:
+		return nil, ErrDirectMessageTooLong
+	case from.HasBlocked(to) || to.HasBlocked(from):
+		return nil, player.ErrPlayerBlocked
+	case !from.IsFriend(to):
+		return nil, player.ErrNotFriends
+	}
+
+	message, err := Filter(from, DirectMessageRoom, message)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &DirectMessage{
+		SenderID:    from.ID,
+		RecipientID: to.ID,
+		Message:     message,
+	}
+	if err := db.DB.Create(m).Error; err != nil {
+		return nil, err
+	}
+
+	m.From, m.To = from.SteamID, to.SteamID
+	broadcaster.SendMessage(to.SteamID, "directMessageReceive", m)
+	// the sender might have other tabs open
+	broadcaster.SendMessage(from.SteamID, "directMessageReceive", m)
+	return m, nil
+}
+
+//GetConversation returns up to 20 messages between the player and other,
+//oldest first. If before isn't 0, only messages with a smaller ID are
+//returned, for loading older messages.
+func GetConversation(p, other *player.Player, before uint) []*DirectMessage {
+	var messages []*DirectMessage
+	query := db.DB.Where("(sender_id = ? AND recipient_id = ?) OR (sender_id = ? AND recipient_id = ?)",
+		p.ID, other.ID, other.ID, p.ID)
+	if before != 0 {
+		query = query.Where("id < ?", before)
+	}
+	query.Order("id desc").Limit(20).Find(&messages)
+
+	// reverse, so the oldest message comes first
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+	for _, m := range messages {
+		if m.SenderID == p.ID {
+			m.From, m.To = p.SteamID, other.SteamID
+		} else {
+			m.From, m.To = other.SteamID, p.SteamID
+		}
+	}
+
+	return messages
+}
+
+//MarkDirectMessagesRead marks all messages sent by other to the player as
+//read, and lets other know their messages have been read
+func MarkDirectMessagesRead(p, other *player.Player) {
+	now := time.Now()
+	rows := db.DB.Model(&DirectMessage{}).Where("sender_id = ? AND recipient_id = ? AND read_at IS NULL", other.ID, p.ID).
+		UpdateColumn("read_at", now).RowsAffected
+	if rows == 0 {
+		return
+	}
+
+	broadcaster.SendMessage(other.SteamID, "directMessageRead", struct {
+		SteamID string    `json:"steamid"`
+		ReadAt  time.Time `json:"readAt"`
+	}{p.SteamID, now})
+}
+
+//GetUnreadDirectMessages returns the number of unread messages sent to the
+//player, by the sender's steamid
+func GetUnreadDirectMessages(p *player.Player) map[string]int {
+	unread := make(map[string]int)
+
+	rows, err := db.DB.Table("direct_messages").
+		Select("players.steam_id, COUNT(*)").
+		Joins("INNER JOIN players ON players.id = direct_messages.sender_id").
+		Where("direct_messages.recipient_id = ? AND direct_messages.read_at IS NULL", p.ID).
+		Group("players.steam_id").Rows()
+	if err != nil {
+		return unread
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var steamid string
+		var count int
+		rows.Scan(&steamid, &count)
+		unread[steamid] = count
+	}
+
+	return unread
+}
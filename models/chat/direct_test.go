@@ -0,0 +1,51 @@
+package chat_test
+
+import (
+	"testing"
+
+	"github.com/TF2Stadium/Helen/internal/testhelpers"
+	. "github.com/TF2Stadium/Helen/models/chat"
+	"github.com/TF2Stadium/Helen/models/player"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDirectMessages(t *testing.T) {
+	p1 := testhelpers.CreatePlayer()
+	p2 := testhelpers.CreatePlayer()
+
+	_, err := SendDirectMessage(p1, p2, "hi")
+	assert.Equal(t, player.ErrNotFriends, err)
+
+	p1.SendFriendRequest(p2)
+	require.NoError(t, p2.AcceptFriendRequest(p1))
+
+	for _, text := range []string{"hi", "hello", "how are you"} {
+		_, err := SendDirectMessage(p1, p2, text)
+		require.NoError(t, err)
+	}
+	_, err = SendDirectMessage(p2, p1, "good")
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]int{p1.SteamID: 3}, GetUnreadDirectMessages(p2))
+	assert.Equal(t, map[string]int{p2.SteamID: 1}, GetUnreadDirectMessages(p1))
+
+	messages := GetConversation(p2, p1, 0)
+	require.Len(t, messages, 4)
+	assert.Equal(t, "hi", messages[0].Message)
+	assert.Equal(t, p1.SteamID, messages[0].From)
+	assert.Equal(t, p2.SteamID, messages[3].From)
+	assert.Nil(t, messages[0].ReadAt)
+
+	older := GetConversation(p2, p1, messages[2].ID)
+	require.Len(t, older, 2)
+	assert.Equal(t, "hello", older[1].Message)
+
+	MarkDirectMessagesRead(p2, p1)
+	assert.Empty(t, GetUnreadDirectMessages(p2))
+	assert.NotNil(t, GetConversation(p2, p1, 0)[0].ReadAt)
+
+	require.NoError(t, p2.Block(p1))
+	_, err = SendDirectMessage(p1, p2, "hi")
+	assert.Equal(t, player.ErrPlayerBlocked, err)
+}
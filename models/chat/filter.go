@@ -0,0 +1,315 @@
+package chat
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/TF2Stadium/Helen/config"
+	db "github.com/TF2Stadium/Helen/database"
+	"github.com/TF2Stadium/Helen/models/player"
+)
+
+//FilterAction is what the chat filter does with a message
+type FilterAction int
+
+//Filter actions, in increasing order of severity
+const (
+	FilterAllow  FilterAction = iota
+	FilterMask                // matching text is replaced with asterisks
+	FilterReject              // the message isn't sent
+	FilterMute                // the message isn't sent, and the player gets a short chat ban
+)
+
+//FilterActions maps action names (used by the admin page) to actions
+var FilterActions = map[string]FilterAction{
+	"mask":   FilterMask,
+	"reject": FilterReject,
+	"mute":   FilterMute,
+}
+
+func (a FilterAction) String() string {
+	return map[FilterAction]string{
+		FilterAllow:  "allow",
+		FilterMask:   "mask",
+		FilterReject: "reject",
+		FilterMute:   "mute",
+	}[a]
+}
+
+var (
+	ErrFilterRuleNotFound = errors.New("Filter rule not found")
+	ErrEmptyPattern       = errors.New("Filter rules need a pattern")
+)
+
+//FilterRule is a word or regular expression checked against every chat message
+type FilterRule struct {
+	ID        uint `gorm:"primary_key"`
+	CreatedAt time.Time
+
+	Pattern string `sql:"not null"`
+	Regex   bool   // Pattern is a regular expression, otherwise it's matched as a case insensitive word
+	Action  FilterAction
+}
+
+//ModerationAction is a record of the chat filter acting on a message
+type ModerationAction struct {
+	ID        uint `gorm:"primary_key"`
+	CreatedAt time.Time
+
+	PlayerID uint
+	Player   player.Player
+	Room     int
+	Message  string `sql:"type:text"` // the original message
+	Action   FilterAction
+	Reason   string
+}
+
+type compiledRule struct {
+	re     *regexp.Regexp
+	rule   *FilterRule
+	reason string
+}
+
+var (
+	rulesMu     = new(sync.RWMutex)
+	rules       []compiledRule
+	rulesLoaded bool
+
+	reLink = regexp.MustCompile(`(?i)\b(?:https?://|www\.)[^\s]+`)
+)
+
+func isWordChar(b byte) bool {
+	return b == '_' || '0' <= b && b <= '9' || 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z'
+}
+
+func (rule *FilterRule) compile() (*regexp.Regexp, error) {
+	if rule.Regex {
+		return regexp.Compile(rule.Pattern)
+	}
+
+	// match whole words only, so "ass" doesn't mask "class". \b only matches
+	// next to word characters, so it isn't used around words like "a$$"
+	pattern := regexp.QuoteMeta(rule.Pattern)
+	if isWordChar(rule.Pattern[0]) {
+		pattern = `\b` + pattern
+	}
+	if isWordChar(rule.Pattern[len(rule.Pattern)-1]) {
+		pattern += `\b`
+	}
+	return regexp.Compile(`(?i)` + pattern)
+}
+
+//NewFilterRule adds a new filter rule
+func NewFilterRule(pattern string, regex bool, action FilterAction) (*FilterRule, error) {
+	rule := &FilterRule{
+		Pattern: pattern,
+		Regex:   regex,
+		Action:  action,
+	}
+	if pattern == "" {
+		return nil, ErrEmptyPattern
+	}
+	if _, err := rule.compile(); err != nil {
+		return nil, err
+	}
+
+	if err := db.DB.Create(rule).Error; err != nil {
+		return nil, err
+	}
+
+	ReloadFilterRules()
+	return rule, nil
+}
+
+//GetFilterRules returns all filter rules
+func GetFilterRules() []*FilterRule {
+	var rules []*FilterRule
+	db.DB.Order("id").Find(&rules)
+	return rules
+}
+
+//RemoveFilterRule removes the rule with the given ID
+func RemoveFilterRule(id uint) error {
+	rows := db.DB.Where("id = ?", id).Delete(&FilterRule{}).RowsAffected
+	if rows == 0 {
+		return ErrFilterRuleNotFound
+	}
+
+	ReloadFilterRules()
+	return nil
+}
+
+//ReloadFilterRules reloads the filter rules from the database. The words in
+//config.Constants.FilteredWords are always masked.
+func ReloadFilterRules() {
+	var compiled []compiledRule
+	for _, word := range config.Constants.FilteredWords {
+		if word == "" {
+			continue
+		}
+		rule := &FilterRule{Pattern: word, Action: FilterMask}
+		re, _ := rule.compile()
+		compiled = append(compiled, compiledRule{re, rule, "filtered word"})
+	}
+
+	for _, rule := range GetFilterRules() {
+		re, err := rule.compile()
+		if err != nil {
+			logrus.Errorf("Invalid chat filter rule #%d: %v", rule.ID, err)
+			continue
+		}
+		compiled = append(compiled, compiledRule{re, rule, fmt.Sprintf("rule #%d", rule.ID)})
+	}
+
+	rulesMu.Lock()
+	rules = compiled
+	rulesLoaded = true
+	rulesMu.Unlock()
+}
+
+func getCompiledRules() []compiledRule {
+	rulesMu.RLock()
+	loaded := rulesLoaded
+	rulesMu.RUnlock()
+	if !loaded {
+		ReloadFilterRules()
+	}
+
+	rulesMu.RLock()
+	defer rulesMu.RUnlock()
+	return rules
+}
+
+func linkAllowed(link string) bool {
+	if !strings.Contains(link, "://") {
+		link = "http://" + link
+	}
+	u, err := url.Parse(link)
+	if err != nil {
+		return false
+	}
+
+	host := strings.ToLower(u.Host)
+	for _, domain := range config.Constants.ChatLinkAllowlist {
+		domain = strings.ToLower(strings.TrimSpace(domain))
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func recordAction(p *player.Player, room int, message string, action FilterAction, reason string) {
+	db.DB.Create(&ModerationAction{
+		PlayerID: p.ID,
+		Room:     room,
+		Message:  message,
+		Action:   action,
+		Reason:   reason,
+	})
+}
+
+// check returns the strongest action for the message, the reason for it,
+// and the message with masked text redacted
+func check(p *player.Player, room int, message string) (FilterAction, string, string) {
+	var count int
+	db.DB.Model(&ChatMessage{}).Where("player_id = ? AND room = ? AND created_at > ?",
+		p.ID, room, time.Now().Add(-config.Constants.ChatFloodWindow)).Count(&count)
+	if count >= config.Constants.ChatFloodLimit {
+		// players who keep sending messages after being told to slow down
+		// get muted
+		var floods int
+		db.DB.Model(&ModerationAction{}).Where("player_id = ? AND reason = ? AND created_at > ?",
+			p.ID, "flooding", time.Now().Add(-time.Minute)).Count(&floods)
+		if floods >= config.Constants.ChatFloodLimit {
+			return FilterMute, "flooding", message
+		}
+		return FilterReject, "flooding", message
+	}
+
+	db.DB.Model(&ChatMessage{}).Where("player_id = ? AND room = ? AND message = ? AND created_at > ?",
+		p.ID, room, message, time.Now().Add(-time.Minute)).Count(&count)
+	if count >= config.Constants.ChatRepeatLimit {
+		return FilterReject, "repeated message", message
+	}
+
+	for _, link := range reLink.FindAllString(message, -1) {
+		if !linkAllowed(link) {
+			return FilterReject, "link not allowed", message
+		}
+	}
+
+	action := FilterAllow
+	var reasons []string
+	for _, r := range getCompiledRules() {
+		if !r.re.MatchString(message) {
+			continue
+		}
+
+		if r.rule.Action == FilterMask {
+			// keep the length the same, so masked messages still fit in
+			// the message column
+			message = r.re.ReplaceAllStringFunc(message, func(s string) string {
+				return strings.Repeat("*", utf8.RuneCountInString(s))
+			})
+		}
+		if r.rule.Action > action {
+			action = r.rule.Action
+		}
+		reasons = append(reasons, r.reason)
+	}
+
+	return action, strings.Join(reasons, ", "), message
+}
+
+//Filter runs the message the player wants to send to the room through the
+//chat filter. It returns the message to send, which might have been masked,
+//or an error if the message can't be sent.
+func Filter(p *player.Player, room int, message string) (string, error) {
+	action, reason, filtered := check(p, room, message)
+
+	switch action {
+	case FilterAllow:
+		return message, nil
+	case FilterMask:
+		recordAction(p, room, message, action, reason)
+		return filtered, nil
+	case FilterReject:
+		recordAction(p, room, message, action, reason)
+		if reason == "flooding" {
+			return "", errors.New("You're sending messages too fast")
+		}
+		return "", fmt.Errorf("Message not sent (%s)", reason)
+	}
+
+	// FilterMute
+	recordAction(p, room, message, action, reason)
+	until := time.Now().Add(config.Constants.ChatMuteDuration)
+	if err := p.BanUntil(until, player.BanChat, "Muted by the chat filter ("+reason+")", 0); err != nil {
+		logrus.Error(err)
+	}
+
+	return "", fmt.Errorf("You've been muted till %s (%s)", until.Format(time.RFC822), reason)
+}
+
+//GetModerationActions returns actions taken by the chat filter on messages
+//sent to the room between from and to, newest first. If playerID isn't 0, only
+//actions on messages sent by that player are returned.
+func GetModerationActions(playerID uint, room int, from, to time.Time) []*ModerationAction {
+	var actions []*ModerationAction
+	query := db.DB.Preload("Player").Where("room = ? AND created_at >= ? AND created_at <= ?", room, from, to)
+	if playerID != 0 {
+		query = query.Where("player_id = ?", playerID)
+	}
+
+	query.Order("id desc").Find(&actions)
+	return actions
+}
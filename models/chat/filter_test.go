@@ -0,0 +1,77 @@
+package chat_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/TF2Stadium/Helen/internal/testhelpers"
+	. "github.com/TF2Stadium/Helen/models/chat"
+	"github.com/TF2Stadium/Helen/models/player"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilterRules(t *testing.T) {
+	p := testhelpers.CreatePlayer()
+
+	_, err := NewFilterRule("(unclosed", true, FilterReject)
+	assert.Error(t, err)
+
+	mask, err := NewFilterRule("darn", false, FilterMask)
+	require.NoError(t, err)
+	_, err = NewFilterRule(`sp[a4]m+y`, true, FilterReject)
+	require.NoError(t, err)
+
+	message, err := Filter(p, 0, "well DARN it")
+	require.NoError(t, err)
+	assert.Equal(t, "well **** it", message)
+	// words are only masked on their own
+	message, err = Filter(p, 0, "darned darning")
+	require.NoError(t, err)
+	assert.Equal(t, "darned darning", message)
+
+	_, err = Filter(p, 0, "so sp4mmmy")
+	assert.Error(t, err)
+
+	require.NoError(t, RemoveFilterRule(mask.ID))
+	message, err = Filter(p, 0, "well darn it")
+	require.NoError(t, err)
+	assert.Equal(t, "well darn it", message)
+
+	_, err = NewFilterRule("muteme", false, FilterMute)
+	require.NoError(t, err)
+	_, err = Filter(p, 0, "muteme")
+	assert.Error(t, err)
+	assert.True(t, p.IsBanned(player.BanChat))
+
+	actions := GetModerationActions(p.ID, 0, time.Now().Add(-time.Hour), time.Now())
+	require.Len(t, actions, 3)
+	assert.Equal(t, FilterMute, actions[0].Action)
+	assert.Equal(t, "muteme", actions[0].Message)
+}
+
+func TestFilterLinks(t *testing.T) {
+	p := testhelpers.CreatePlayer()
+
+	_, err := Filter(p, 0, "logs at http://logs.tf/1234")
+	assert.NoError(t, err)
+	_, err = Filter(p, 0, "go to www.example.com now")
+	assert.Error(t, err)
+	_, err = Filter(p, 0, "https://evil-logs.tf/1234")
+	assert.Error(t, err)
+}
+
+func TestFilterRepeat(t *testing.T) {
+	p := testhelpers.CreatePlayer()
+
+	for i := 0; i < 2; i++ {
+		message, err := Filter(p, 0, "hello")
+		require.NoError(t, err)
+		NewChatMessage(message, 0, p).Save()
+	}
+
+	_, err := Filter(p, 0, "hello")
+	assert.Error(t, err)
+	_, err = Filter(p, 1, "hello")
+	assert.NoError(t, err)
+}
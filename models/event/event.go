@@ -175,6 +175,7 @@ func matchEnded(lobbyID uint, logsID int) {
 		logrus.Error(err)
 		return
 	}
+	lobby.SendMatchEndedWebhook(logsID)
 	lobby.Close(false, true)
 
 	logs := fmt.Sprintf("http://logs.tf/%d", logsID)
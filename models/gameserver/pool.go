@@ -0,0 +1,154 @@
+package gameserver
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/TF2Stadium/Helen/config"
+	db "github.com/TF2Stadium/Helen/database"
+	"github.com/TF2Stadium/Helen/helpers"
+)
+
+//Health statuses of stored servers
+const (
+	ServerHealthy   = "healthy"
+	ServerUnhealthy = "unhealthy"
+)
+
+var ErrNoServer = errors.New("No free servers available")
+
+//StartHealthChecks starts checking all stored servers periodically, every
+//config.Constants.ServerCheckInterval
+func StartHealthChecks() {
+	go func() {
+		ticker := time.NewTicker(config.Constants.ServerCheckInterval)
+		for {
+			CheckAllServers()
+			<-ticker.C
+		}
+	}()
+}
+
+//CheckAllServers checks the health of every stored server
+func CheckAllServers() {
+	var wg sync.WaitGroup
+	for _, server := range GetAllStoredServers() {
+		wg.Add(1)
+		go func(server *StoredServer) {
+			server.Check()
+			wg.Done()
+		}(server)
+	}
+	wg.Wait()
+}
+
+//Check queries the server's status over RCON, and records whether it's healthy.
+//Servers are taken out of rotation after config.Constants.ServerMaxFailures
+//failed checks in a row, and put back in once a check succeeds.
+func (server *StoredServer) Check() {
+	status, err := QueryStatus(server.Address, string(server.RCONPassword))
+
+	updates := map[string]interface{}{"checked_at": time.Now()}
+	if err != nil {
+		updates["failures"] = server.Failures + 1
+		updates["last_error"] = err.Error()
+		if server.Failures+1 >= config.Constants.ServerMaxFailures {
+			if server.Status != ServerUnhealthy {
+				logrus.Warnf("Stored server %s (%s) is unhealthy: %v", server.Name, server.Address, err)
+			}
+			updates["status"] = ServerUnhealthy
+		}
+	} else {
+		if server.Status == ServerUnhealthy {
+			logrus.Infof("Stored server %s (%s) is healthy again", server.Name, server.Address)
+		}
+		updates["status"] = ServerHealthy
+		updates["failures"] = 0
+		updates["last_error"] = ""
+		updates["players"] = status.Players
+		updates["max_players"] = status.MaxPlayers
+	}
+
+	if server.Region == "" {
+		if region, _ := helpers.GetRegion(server.Address); region != "" {
+			updates["region"] = region
+		}
+	}
+
+	db.DB.Model(&StoredServer{}).Where("id = ?", server.ID).Updates(updates)
+}
+
+//GetBestServer marks the best free server in the given region (or in any
+//region, if region is empty) as used, and returns it. Servers with players on
+//them aren't free, and servers which haven't been checked yet are picked last,
+//when no checked server is free.
+func GetBestServer(region string) (*StoredServer, error) {
+	storeLock.Lock()
+	defer storeLock.Unlock()
+
+	query := db.DB.Model(&StoredServer{}).Where(freeServers, ServerUnhealthy)
+	if region != "" {
+		query = query.Where("region = ?", region)
+	}
+
+	server := &StoredServer{}
+	if err := query.Order("checked_at DESC NULLS LAST, id").First(server).Error; err != nil {
+		return nil, ErrNoServer
+	}
+
+	db.DB.Model(&StoredServer{}).Where("id = ?", server.ID).UpdateColumn("used", true)
+	server.Used = true
+	return server, nil
+}
+
+//StoredServerProvider reserves servers added by admins on the servers page
+type StoredServerProvider struct{}
+
+func (StoredServerProvider) Name() string {
+	return "storedServer"
+}
+
+//Reserve marks the stored server with the given ID as used, or the best free
+//server in the region if there's no ID
+func (StoredServerProvider) Reserve(req *ReserveRequest) (*Reservation, error) {
+	var server *StoredServer
+	var err error
+
+	if req.ServerID == 0 {
+		server, err = GetBestServer(req.Region)
+	} else {
+		server, err = GetStoredServer(uint(req.ServerID))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &Reservation{
+		ID:           int(server.ID),
+		SteamID:      req.SteamID,
+		Host:         server.Address,
+		RconPassword: string(server.RCONPassword),
+	}, nil
+}
+
+//Verify doesn't need to wait for stored servers. Servers which failed their
+//health checks aren't reserved, but new servers can be reserved before
+//they've been checked, so they aren't known to be up.
+func (StoredServerProvider) Verify(*Reservation) error {
+	return nil
+}
+
+func (StoredServerProvider) Release(res *Reservation) error {
+	PutStoredServer(res.Host)
+	return nil
+}
+
+func (StoredServerProvider) DownloadDemo(*Reservation, string) error {
+	return ErrNoDemos
+}
+
+//WatchEnded does nothing, reservations of stored servers only end when
+//they're released
+func (StoredServerProvider) WatchEnded(*Reservation, func()) {}
@@ -0,0 +1,72 @@
+package gameserver_test
+
+import (
+	"testing"
+
+	db "github.com/TF2Stadium/Helen/database"
+	"github.com/TF2Stadium/Helen/internal/testhelpers"
+	. "github.com/TF2Stadium/Helen/models/gameserver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetBestServer(t *testing.T) {
+	testhelpers.CleanupDB()
+
+	busy, _ := NewStoredServer("busy", "127.0.0.1:27015", "rcon")
+	empty, _ := NewStoredServer("empty", "127.0.0.1:27016", "rcon")
+	down, _ := NewStoredServer("down", "127.0.0.1:27017", "rcon")
+	unchecked, _ := NewStoredServer("unchecked", "127.0.0.1:27018", "rcon")
+	db.DB.Model(busy).Updates(map[string]interface{}{"status": ServerHealthy, "region": "eu", "players": 3})
+	db.DB.Model(empty).Updates(map[string]interface{}{"status": ServerHealthy, "region": "eu"})
+	db.DB.Model(down).Updates(map[string]interface{}{"status": ServerUnhealthy, "region": "eu"})
+
+	_, err := GetBestServer("na")
+	assert.Equal(t, ErrNoServer, err)
+
+	server, err := GetBestServer("eu")
+	require.NoError(t, err)
+	assert.Equal(t, empty.ID, server.ID)
+
+	// servers with players on them aren't free, and unhealthy servers
+	// are out of rotation
+	_, err = GetBestServer("eu")
+	assert.Equal(t, ErrNoServer, err)
+
+	// servers are picked before their first health check
+	server, err = GetBestServer("")
+	require.NoError(t, err)
+	assert.Equal(t, unchecked.ID, server.ID)
+
+	_, err = GetBestServer("")
+	assert.Equal(t, ErrNoServer, err)
+	_, err = GetStoredServer(down.ID)
+	assert.Equal(t, ErrServerUnhealthy, err)
+	assert.Empty(t, GetAvailableServers())
+}
+
+func TestStoredServerProvider(t *testing.T) {
+	testhelpers.CleanupDB()
+
+	server, _ := NewStoredServer("server", "127.0.0.1:27015", "rcon")
+	db.DB.Model(server).Updates(map[string]interface{}{"status": ServerHealthy, "region": "eu"})
+
+	provider, ok := GetProvider("storedServer")
+	require.True(t, ok)
+
+	res, err := provider.Reserve(&ReserveRequest{Region: "eu", SteamID: "76561198074578368"})
+	require.NoError(t, err)
+	assert.Equal(t, int(server.ID), res.ID)
+	assert.Equal(t, "127.0.0.1:27015", res.Host)
+	assert.Equal(t, "rcon", res.RconPassword)
+	assert.NoError(t, provider.Verify(res))
+	assert.Equal(t, ErrNoDemos, provider.DownloadDemo(res, "/dev/null"))
+
+	_, err = provider.Reserve(&ReserveRequest{ServerID: int(server.ID)})
+	assert.Equal(t, ErrServerUsed, err)
+
+	require.NoError(t, provider.Release(res))
+	res, err = provider.Reserve(&ReserveRequest{ServerID: int(server.ID)})
+	require.NoError(t, err)
+	assert.Equal(t, "127.0.0.1:27015", res.Host)
+}
@@ -0,0 +1,71 @@
+package gameserver
+
+import (
+	"errors"
+	"time"
+)
+
+//ErrNoDemos is returned by providers which don't keep STV demos
+var ErrNoDemos = errors.New("Server provider doesn't have STV demos")
+
+//ServerProvider reserves game servers for lobbies. Lobbies store the
+//provider's name, and the reservation's ID, to get the provider and the
+//reservation back after Helen restarts.
+type ServerProvider interface {
+	//Name returns the name of the provider, which is also the server type
+	//players pick when creating lobbies
+	Name() string
+
+	//Reserve reserves a server, the server might not be ready yet.
+	Reserve(req *ReserveRequest) (*Reservation, error)
+	//Verify waits till the reserved server is ready to be set up, and
+	//returns an error if it doesn't get ready
+	Verify(res *Reservation) error
+	//Release ends the reservation, freeing the server
+	Release(res *Reservation) error
+
+	//DownloadDemo downloads the STV demo recorded on the reserved server to
+	//the given file, returns ErrNoDemos if the provider doesn't keep them
+	DownloadDemo(res *Reservation, file string) error
+	//WatchEnded calls ended once, when the reservation ends before the lobby
+	//releases it (like when a rented server's time runs out)
+	WatchEnded(res *Reservation, ended func())
+}
+
+//ReserveRequest describes the server a lobby needs
+type ReserveRequest struct {
+	ServerID int    // provider specific server ID, 0 to let the provider pick one
+	Host     string // address of the server, if it's known before it's reserved
+	Region   string // region code, servers are picked from (or rented in) this region
+	SteamID  string // steam ID of the player the server is reserved for
+
+	// how long the server is needed for, used by providers renting servers
+	StartsAt time.Time
+	EndsAt   time.Time
+}
+
+//Reservation is a server reserved by a provider
+type Reservation struct {
+	ID           int    // provider specific reservation ID
+	SteamID      string // steam ID of the player the server is reserved for
+	Host         string
+	RconPassword string
+}
+
+var providers = make(map[string]ServerProvider)
+
+//RegisterProvider makes the provider available for lobbies to use
+func RegisterProvider(provider ServerProvider) {
+	providers[provider.Name()] = provider
+}
+
+//GetProvider returns the provider with the given name
+func GetProvider(name string) (ServerProvider, bool) {
+	provider, ok := providers[name]
+	return provider, ok
+}
+
+func init() {
+	RegisterProvider(ServemeProvider{})
+	RegisterProvider(StoredServerProvider{})
+}
@@ -0,0 +1,135 @@
+package gameserver
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// packet types of the Source RCON protocol
+const (
+	rconAuth          = 3
+	rconAuthResponse  = 2
+	rconExecCommand   = 2
+	rconResponseValue = 0
+)
+
+const rconTimeout = 5 * time.Second
+
+var (
+	ErrRCONAuth     = errors.New("RCON authentication failed")
+	ErrRCONResponse = errors.New("invalid RCON response")
+)
+
+//Status is the state of a game server, as reported by the status command
+type Status struct {
+	Players    int // human players on the server
+	MaxPlayers int
+}
+
+// "players : 2 humans, 0 bots (24 max)", or "players : 2 (24 max)" on older servers
+var rStatusPlayers = regexp.MustCompile(`(?m)^players\s*:\s*(\d+)[^(\n]*\((\d+)`)
+
+//QueryStatus connects to the server over RCON, and returns its status
+func QueryStatus(addr, password string) (*Status, error) {
+	out, err := rconCommand(addr, password, "status")
+	if err != nil {
+		return nil, err
+	}
+
+	m := rStatusPlayers.FindStringSubmatch(out)
+	if m == nil {
+		return nil, ErrRCONResponse
+	}
+
+	status := &Status{}
+	status.Players, _ = strconv.Atoi(m[1])
+	status.MaxPlayers, _ = strconv.Atoi(m[2])
+	return status, nil
+}
+
+//rconCommand runs the command on the server, and returns its output
+func rconCommand(addr, password, command string) (string, error) {
+	conn, err := net.DialTimeout("tcp", addr, rconTimeout)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(rconTimeout))
+
+	if err := writeRCONPacket(conn, 1, rconAuth, password); err != nil {
+		return "", err
+	}
+	// servers send an empty response value before the auth response
+	for {
+		id, typ, _, err := readRCONPacket(conn)
+		if err != nil {
+			return "", err
+		}
+		if typ == rconAuthResponse {
+			if id == -1 {
+				return "", ErrRCONAuth
+			}
+			break
+		}
+	}
+
+	if err := writeRCONPacket(conn, 2, rconExecCommand, command); err != nil {
+		return "", err
+	}
+	// long output is split into multiple packets. Servers answer packets
+	// in order, so the response to this one marks the end of the output.
+	if err := writeRCONPacket(conn, 3, rconResponseValue, ""); err != nil {
+		return "", err
+	}
+
+	var out bytes.Buffer
+	for {
+		id, _, body, err := readRCONPacket(conn)
+		if err != nil {
+			return "", err
+		}
+		if id == 3 {
+			return out.String(), nil
+		}
+		out.WriteString(body)
+	}
+}
+
+func writeRCONPacket(w io.Writer, id, typ int32, body string) error {
+	buf := new(bytes.Buffer)
+	binary.Write(buf, binary.LittleEndian, int32(len(body)+10))
+	binary.Write(buf, binary.LittleEndian, id)
+	binary.Write(buf, binary.LittleEndian, typ)
+	buf.WriteString(body)
+	buf.Write([]byte{0, 0})
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func readRCONPacket(r io.Reader) (id, typ int32, body string, err error) {
+	var size int32
+	if err = binary.Read(r, binary.LittleEndian, &size); err != nil {
+		return
+	}
+	if size < 10 || size > 1<<16 {
+		err = ErrRCONResponse
+		return
+	}
+
+	packet := make([]byte, size)
+	if _, err = io.ReadFull(r, packet); err != nil {
+		return
+	}
+
+	id = int32(binary.LittleEndian.Uint32(packet[0:4]))
+	typ = int32(binary.LittleEndian.Uint32(packet[4:8]))
+	body = string(bytes.TrimRight(packet[8:], "\x00"))
+	return
+}
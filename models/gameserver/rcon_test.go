@@ -0,0 +1,79 @@
+package gameserver_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+
+	. "github.com/TF2Stadium/Helen/models/gameserver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const statusOutput = `hostname: TF2Stadium #1
+version : 3557017/24 3557017 secure
+map     : cp_badlands at: 0 x, 0 y, 0 z
+players : 2 humans, 0 bots (24 max)
+`
+
+func writePacket(w io.Writer, id, typ int32, body string) {
+	buf := new(bytes.Buffer)
+	binary.Write(buf, binary.LittleEndian, int32(len(body)+10))
+	binary.Write(buf, binary.LittleEndian, id)
+	binary.Write(buf, binary.LittleEndian, typ)
+	buf.WriteString(body + "\x00\x00")
+	w.Write(buf.Bytes())
+}
+
+func readPacket(r io.Reader) (id, typ int32, body string) {
+	var size int32
+	binary.Read(r, binary.LittleEndian, &size)
+	packet := make([]byte, size)
+	io.ReadFull(r, packet)
+	return int32(binary.LittleEndian.Uint32(packet)), int32(binary.LittleEndian.Uint32(packet[4:])),
+		string(bytes.TrimRight(packet[8:], "\x00"))
+}
+
+//fakeServer answers RCON requests like a TF2 server, splitting the status
+//output into two packets
+func fakeServer(t *testing.T, password string) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	go func() {
+		defer l.Close()
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		id, _, body := readPacket(conn)
+		writePacket(conn, id, 0, "")
+		if body != password {
+			writePacket(conn, -1, 2, "")
+			return
+		}
+		writePacket(conn, id, 2, "")
+
+		id, _, _ = readPacket(conn)
+		writePacket(conn, id, 0, statusOutput[:20])
+		writePacket(conn, id, 0, statusOutput[20:])
+		id, _, _ = readPacket(conn)
+		writePacket(conn, id, 0, "")
+	}()
+
+	return l.Addr().String()
+}
+
+func TestQueryStatus(t *testing.T) {
+	status, err := QueryStatus(fakeServer(t, "secret"), "secret")
+	require.NoError(t, err)
+	assert.Equal(t, 2, status.Players)
+	assert.Equal(t, 24, status.MaxPlayers)
+
+	_, err = QueryStatus(fakeServer(t, "secret"), "wrong")
+	assert.Equal(t, ErrRCONAuth, err)
+}
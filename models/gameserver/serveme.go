@@ -0,0 +1,105 @@
+package gameserver
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/TF2Stadium/Helen/helpers"
+	"github.com/TF2Stadium/servemetf"
+)
+
+var (
+	ErrServemeReservation = errors.New("Couldn't get serveme reservation")
+	ErrServemeNotReady    = errors.New("Couldn't get serveme reservation, try another server.")
+)
+
+//ServemeProvider rents servers from serveme.tf. The serveme.tf site used
+//depends on the region of the request, or of the reserved server.
+type ServemeProvider struct{}
+
+func (ServemeProvider) Name() string {
+	return "serveme"
+}
+
+func (ServemeProvider) Reserve(req *ReserveRequest) (*Reservation, error) {
+	randBytes := make([]byte, 6)
+	rand.Read(randBytes)
+
+	reservation := servemetf.Reservation{
+		StartsAt:    req.StartsAt.Format(servemetf.TimeFormat),
+		EndsAt:      req.EndsAt.Format(servemetf.TimeFormat),
+		ServerID:    req.ServerID,
+		WhitelistID: 1,
+		RCON:        base64.URLEncoding.EncodeToString(randBytes),
+		Password:    "foobar",
+	}
+
+	context := helpers.GetServemeContextRegion(req.Region)
+	resp, err := context.Create(reservation, req.SteamID)
+	if err != nil || resp.Reservation.Errors != nil {
+		if err != nil {
+			logrus.Error(err)
+		} else {
+			logrus.Error(resp.Reservation.Errors)
+		}
+
+		return nil, ErrServemeReservation
+	}
+
+	return &Reservation{
+		ID:           resp.Reservation.ID,
+		SteamID:      req.SteamID,
+		Host:         resp.Reservation.Server.IPAndPort,
+		RconPassword: reservation.RCON,
+	}, nil
+}
+
+func (ServemeProvider) Verify(res *Reservation) error {
+	context := helpers.GetServemeContext(res.Host)
+	now := time.Now()
+
+	for {
+		status, err := context.Status(res.ID, res.SteamID)
+		if err != nil {
+			logrus.Error(err)
+		}
+		if status == "ready" {
+			return nil
+		}
+
+		time.Sleep(10 * time.Second)
+		if time.Since(now) >= 3*time.Minute {
+			return ErrServemeNotReady
+		}
+	}
+}
+
+func (ServemeProvider) Release(res *Reservation) error {
+	return helpers.GetServemeContext(res.Host).Delete(res.ID, res.SteamID)
+}
+
+func (ServemeProvider) DownloadDemo(res *Reservation, file string) error {
+	return helpers.GetServemeContext(res.Host).DownloadDemo(res.ID, res.SteamID, file)
+}
+
+//WatchEnded checks the status of the reservation every 10 seconds
+func (ServemeProvider) WatchEnded(res *Reservation, ended func()) {
+	context := helpers.GetServemeContext(res.Host)
+
+	go func() {
+		for {
+			done, err := context.Ended(res.ID, res.SteamID)
+			if err != nil {
+				logrus.Error(err)
+			}
+			if done {
+				ended()
+				return
+			}
+			time.Sleep(10 * time.Second)
+		}
+	}()
+}
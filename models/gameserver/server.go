@@ -1,9 +1,11 @@
 package gameserver
 
+import "github.com/TF2Stadium/Helen/helpers/secret"
+
 type ServerRecord struct {
 	ID             uint
 	Host           string
-	LogSecret      string
-	ServerPassword string // sv_password
-	RconPassword   string // rcon_password
+	LogSecret      secret.String `sql:"type:text"` // sv_logsecret, prefixed to the log lines the server sends to Helen
+	ServerPassword secret.String `sql:"type:text"` // sv_password
+	RconPassword   secret.String `sql:"type:text"` // rcon_password
 }
@@ -3,22 +3,34 @@ package gameserver
 import (
 	"errors"
 	"sync"
+	"time"
 
 	db "github.com/TF2Stadium/Helen/database"
+	"github.com/TF2Stadium/Helen/helpers/secret"
 )
 
 type StoredServer struct {
 	ID   uint   `gorm:"primary_key" json:"id"`
 	Name string `json:"name"`
 
-	Address      string `json:"-" sql:"unique"`
-	RCONPassword string `json:"-"`
-	Used         bool   `sql:"default:false" json:"-"`
+	Address      string        `json:"-" sql:"unique"`
+	RCONPassword secret.String `json:"-" sql:"type:text"`
+	Used         bool          `sql:"default:false" json:"-"`
+
+	// updated by the health checks
+	Status     string    `json:"-"` // ServerHealthy or ServerUnhealthy, empty before the first check
+	Players    int       `json:"players"`
+	MaxPlayers int       `json:"maxPlayers"`
+	Region     string    `json:"region"` // region code, like helpers.GetRegion
+	Failures   int       `json:"-"`      // number of consecutive failed checks
+	LastError  string    `json:"-"`
+	CheckedAt  time.Time `json:"-"`
 }
 
 var (
 	ErrServerUsed          = errors.New("server is being used")
 	ErrServerAlreadyExists = errors.New("server already exists")
+	ErrServerUnhealthy     = errors.New("server isn't responding")
 )
 
 func NewStoredServer(name, address, passwd string) (*StoredServer, error) {
@@ -31,7 +43,7 @@ func NewStoredServer(name, address, passwd string) (*StoredServer, error) {
 	server := &StoredServer{
 		Name:         name,
 		Address:      address,
-		RCONPassword: passwd,
+		RCONPassword: secret.String(passwd),
 	}
 
 	db.DB.Save(server)
@@ -42,9 +54,15 @@ func RemoveStoredServer(addr string) {
 	db.DB.Model(&StoredServer{}).Where("address = ?", addr).Delete(&StoredServer{})
 }
 
+// servers which aren't used by a lobby, or by players outside of TF2Stadium,
+// and haven't failed their health checks (new servers don't have a status yet)
+const freeServers = "used = FALSE AND (status IS NULL OR status <> ?) AND (players IS NULL OR players = 0)"
+
+//GetAvailableServers returns all servers which aren't being used, and haven't
+//been taken out of rotation by the health checks
 func GetAvailableServers() []*StoredServer {
 	var servers []*StoredServer
-	db.DB.Model(&StoredServer{}).Where("used = FALSE").Find(&servers)
+	db.DB.Model(&StoredServer{}).Where(freeServers, ServerUnhealthy).Order("id").Find(&servers)
 	return servers
 }
 
@@ -59,11 +77,22 @@ func GetStoredServer(id uint) (*StoredServer, error) {
 	if server.Used {
 		return nil, ErrServerUsed
 	}
+	if server.Status == ServerUnhealthy {
+		return nil, ErrServerUnhealthy
+	}
 
 	db.DB.First(server).UpdateColumn("used", true)
 	return server, err
 }
 
+//FindStoredServer returns the stored server with the given ID, without
+//marking it as used
+func FindStoredServer(id uint) (*StoredServer, error) {
+	server := &StoredServer{}
+	err := db.DB.Model(&StoredServer{}).Where("id = ?", id).First(server).Error
+	return server, err
+}
+
 func PutStoredServer(address string) {
 	storeLock.Lock()
 	db.DB.Model(&StoredServer{}).Where("address = ?", address).UpdateColumn("used", false)
@@ -72,6 +101,6 @@ func PutStoredServer(address string) {
 
 func GetAllStoredServers() []*StoredServer {
 	var servers []*StoredServer
-	db.DB.Model(&StoredServer{}).Find(&servers)
+	db.DB.Model(&StoredServer{}).Order("id").Find(&servers)
 	return servers
 }
@@ -0,0 +1,515 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+//Package leaderboard implements seasonal leaderboards. Seasons are defined
+//by admins, and leaderboards for each season are computed periodically from
+//lobby history into the leaderboard_entries table, split by format and region.
+package leaderboard
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/TF2Stadium/Helen/config"
+	db "github.com/TF2Stadium/Helen/database"
+	"github.com/TF2Stadium/Helen/models/lobby/format"
+	"github.com/TF2Stadium/Helen/models/player"
+)
+
+//Leaderboard categories
+const (
+	Lobbies = "lobbies" // most lobbies played
+	Hours   = "hours"   // most hours played on a class
+	Subs    = "subs"    // fewest times subbed out of a lobby
+	Rating  = "rating"  // highest rating
+)
+
+var validCategories = map[string]bool{
+	Lobbies: true,
+	Hours:   true,
+	Subs:    true,
+	Rating:  true,
+}
+
+const (
+	//Size is the number of players on each leaderboard
+	Size = 50
+	//MinLobbies is the number of lobbies a player needs to play in a season
+	//to be on the subs and rating leaderboards
+	MinLobbies = 10
+)
+
+var (
+	ErrSeasonNotFound  = errors.New("Season not found")
+	ErrInvalidSeason   = errors.New("Seasons need a name, and have to end after they start")
+	ErrInvalidCategory = errors.New("Invalid leaderboard category")
+	ErrInvalidFormat   = errors.New("Invalid lobby format")
+	ErrNoClass         = errors.New("The hours leaderboard needs a class")
+)
+
+//Season is a date range leaderboards are computed for
+type Season struct {
+	ID        uint      `gorm:"primary_key" json:"id"`
+	CreatedAt time.Time `json:"-"`
+
+	Name     string    `sql:"not null" json:"name"`
+	StartsAt time.Time `json:"startsAt"`
+	EndsAt   time.Time `json:"endsAt"`
+	// last time the season's leaderboards were computed, nil if they haven't been yet
+	ComputedAt *time.Time `json:"computedAt"`
+}
+
+//Entry is a player's position on a leaderboard
+type Entry struct {
+	ID uint `gorm:"primary_key" json:"-"`
+
+	SeasonID uint          `sql:"not null" json:"-"`
+	Category string        `sql:"not null" json:"-"`
+	Class    string        `json:"-"` // only for the Hours category
+	Format   format.Format `json:"-"`
+	Region   string        `json:"-"` // empty for the leaderboard across all regions
+
+	Rank     int     `json:"rank"`
+	PlayerID uint    `json:"-"`
+	Value    float64 `json:"value"` // lobbies played, hours, subs or rating
+
+	// filled in by Get
+	SteamID string `sql:"-" json:"steamid"`
+	Name    string `sql:"-" json:"name"`
+	Avatar  string `sql:"-" json:"avatar"`
+}
+
+//TableName sets the table name for Entry
+func (Entry) TableName() string {
+	return "leaderboard_entries"
+}
+
+//NewSeason creates a new season
+func NewSeason(name string, startsAt, endsAt time.Time) (*Season, error) {
+	if name == "" || !endsAt.After(startsAt) {
+		return nil, ErrInvalidSeason
+	}
+
+	season := &Season{Name: name, StartsAt: startsAt, EndsAt: endsAt}
+	err := db.DB.Create(season).Error
+	return season, err
+}
+
+//RemoveSeason deletes the season with the given ID, and it's leaderboards
+func RemoveSeason(id uint) error {
+	rows := db.DB.Where("id = ?", id).Delete(&Season{}).RowsAffected
+	if rows == 0 {
+		return ErrSeasonNotFound
+	}
+
+	db.DB.Where("season_id = ?", id).Delete(&Entry{})
+	return nil
+}
+
+//GetSeasons returns all seasons, newest first
+func GetSeasons() []*Season {
+	var seasons []*Season
+	db.DB.Order("starts_at desc").Find(&seasons)
+	return seasons
+}
+
+//GetSeason returns the season with the given ID
+func GetSeason(id uint) (*Season, error) {
+	season := &Season{}
+	if err := db.DB.First(season, id).Error; err != nil {
+		return nil, ErrSeasonNotFound
+	}
+
+	return season, nil
+}
+
+//CurrentSeason returns the season which is currently running. If no season
+//is running, the season which ended last is returned.
+func CurrentSeason() (*Season, error) {
+	season := &Season{}
+	now := time.Now()
+
+	err := db.DB.Where("starts_at <= ? AND ends_at > ?", now, now).Order("starts_at desc").First(season).Error
+	if err != nil {
+		err = db.DB.Where("ends_at <= ?", now).Order("ends_at desc").First(season).Error
+	}
+	if err != nil {
+		return nil, ErrSeasonNotFound
+	}
+
+	return season, nil
+}
+
+//Leaderboard is a single leaderboard, as sent to clients
+type Leaderboard struct {
+	Season   *Season  `json:"season"`
+	Category string   `json:"category"`
+	Class    string   `json:"class,omitempty"`
+	Format   string   `json:"format"`
+	Region   string   `json:"region"` // empty for all regions
+	Entries  []*Entry `json:"entries"`
+}
+
+//Get returns the leaderboard for the given season, category and format. If
+//seasonID is 0, the current season's leaderboard is returned. class is only
+//used for the Hours category. If region is empty, the leaderboard across all
+//regions is returned.
+func Get(seasonID uint, category, class string, f format.Format, region string) (*Leaderboard, error) {
+	if !validCategories[category] {
+		return nil, ErrInvalidCategory
+	}
+	if category != Hours {
+		class = ""
+	} else if class == "" {
+		return nil, ErrNoClass
+	}
+
+	def, ok := format.Get(f)
+	if !ok {
+		return nil, ErrInvalidFormat
+	}
+
+	var season *Season
+	var err error
+	if seasonID == 0 {
+		season, err = CurrentSeason()
+	} else {
+		season, err = GetSeason(seasonID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []*Entry{}
+	db.DB.Where("season_id = ? AND category = ? AND class = ? AND format = ? AND region = ?",
+		season.ID, category, class, f, region).Order("rank, id").Find(&entries)
+
+	var ids []uint
+	for _, entry := range entries {
+		ids = append(ids, entry.PlayerID)
+	}
+	var players []*player.Player
+	if len(ids) != 0 {
+		db.DB.Where("id IN (?)", ids).Find(&players)
+	}
+
+	playerMap := make(map[uint]*player.Player)
+	for _, p := range players {
+		playerMap[p.ID] = p
+	}
+	for _, entry := range entries {
+		if p, ok := playerMap[entry.PlayerID]; ok {
+			entry.SteamID = p.SteamID
+			entry.Name = p.Alias()
+			entry.Avatar = p.Avatar
+		}
+	}
+
+	return &Leaderboard{
+		Season:   season,
+		Category: category,
+		Class:    class,
+		Format:   def.Name,
+		Region:   region,
+		Entries:  entries,
+	}, nil
+}
+
+//StartComputing starts computing leaderboards periodically, every
+//config.Constants.LeaderboardInterval
+func StartComputing() {
+	go func() {
+		ticker := time.NewTicker(config.Constants.LeaderboardInterval)
+		for {
+			ComputeAll()
+			<-ticker.C
+		}
+	}()
+}
+
+//ComputeAll computes leaderboards for all seasons which have started, and
+//haven't been computed since they ended
+func ComputeAll() {
+	var seasons []*Season
+	db.DB.Where("starts_at <= ? AND (computed_at IS NULL OR computed_at < ends_at)", time.Now()).Find(&seasons)
+
+	for _, season := range seasons {
+		if err := season.Compute(); err != nil {
+			logrus.Errorf("Couldn't compute leaderboards for season #%d: %v", season.ID, err)
+		}
+	}
+}
+
+//board identifies a single leaderboard of a season
+type board struct {
+	category string
+	class    string
+	format   format.Format
+	region   string
+}
+
+//key identifies a player's stats in a format and region
+type key struct {
+	playerID uint
+	format   format.Format
+	region   string
+}
+
+//Compute computes all leaderboards for the season, replacing the old ones
+func (season *Season) Compute() error {
+	now := time.Now()
+
+	played, err := season.lobbiesPlayed()
+	if err != nil {
+		return err
+	}
+	hours, err := season.classHours()
+	if err != nil {
+		return err
+	}
+	subs, err := season.subs()
+	if err != nil {
+		return err
+	}
+	ratings, err := ratings()
+	if err != nil {
+		return err
+	}
+
+	values := make(map[board]map[uint]float64)
+	subValues := make(map[board]map[uint]float64)
+	add := func(values map[board]map[uint]float64, b board, playerID uint, value float64) {
+		// every value counts for the board of it's region, and the board
+		// across all regions
+		regions := []string{""}
+		if b.region != "" {
+			regions = append(regions, b.region)
+		}
+		for _, region := range regions {
+			b.region = region
+			if values[b] == nil {
+				values[b] = make(map[uint]float64)
+			}
+			values[b][playerID] += value
+		}
+	}
+
+	for k, count := range played {
+		add(values, board{Lobbies, "", k.format, k.region}, k.playerID, float64(count))
+	}
+	for k, classes := range hours {
+		for class, seconds := range classes {
+			add(values, board{Hours, class, k.format, k.region}, k.playerID, float64(seconds)/3600)
+		}
+	}
+	for k, count := range subs {
+		add(subValues, board{Subs, "", k.format, k.region}, k.playerID, float64(count))
+	}
+
+	// only players who have played enough lobbies on a board's format and
+	// region are ranked by subs and rating
+	qualified := make(map[board]map[uint]float64)
+	for b, counts := range values {
+		if b.category != Lobbies {
+			continue
+		}
+
+		subsBoard := board{Subs, "", b.format, b.region}
+		ratingBoard := board{Rating, "", b.format, b.region}
+		qualified[subsBoard] = make(map[uint]float64)
+		qualified[ratingBoard] = make(map[uint]float64)
+
+		for playerID, count := range counts {
+			if count < MinLobbies {
+				continue
+			}
+
+			qualified[subsBoard][playerID] = subValues[subsBoard][playerID]
+			if rating, ok := ratings[key{playerID, b.format, ""}]; ok {
+				qualified[ratingBoard][playerID] = rating
+			}
+		}
+	}
+	for b, boardValues := range qualified {
+		values[b] = boardValues
+	}
+
+	tx := db.DB.Begin()
+	tx.Where("season_id = ?", season.ID).Delete(&Entry{})
+	for b, boardValues := range values {
+		var tiebreak map[uint]float64
+		if b.category == Subs {
+			// players with the same number of subs are ranked by lobbies played
+			tiebreak = values[board{Lobbies, "", b.format, b.region}]
+		}
+
+		for _, entry := range rank(boardValues, tiebreak, b.category == Subs) {
+			entry.SeasonID = season.ID
+			entry.Category = b.category
+			entry.Class = b.class
+			entry.Format = b.format
+			entry.Region = b.region
+
+			if err := tx.Create(entry).Error; err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+	}
+	if err := tx.Model(season).UpdateColumn("computed_at", now).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+//rank returns the top Size entries for the given values. Values are sorted
+//in descending order (ascending if asc is true). Ties are broken by the
+//value in tiebreak (higher first), and players with the same value and
+//tiebreak share a rank.
+func rank(values, tiebreak map[uint]float64, asc bool) []*Entry {
+	var entries []*Entry
+	for playerID, value := range values {
+		entries = append(entries, &Entry{PlayerID: playerID, Value: value})
+	}
+
+	sort.Sort(byValue{entries, tiebreak, asc})
+
+	if len(entries) > Size {
+		entries = entries[:Size]
+	}
+	for i, entry := range entries {
+		entry.Rank = i + 1
+		if i != 0 {
+			prev := entries[i-1]
+			if prev.Value == entry.Value && tiebreak[prev.PlayerID] == tiebreak[entry.PlayerID] {
+				entry.Rank = prev.Rank
+			}
+		}
+	}
+
+	return entries
+}
+
+type byValue struct {
+	entries  []*Entry
+	tiebreak map[uint]float64
+	asc      bool
+}
+
+func (b byValue) Len() int      { return len(b.entries) }
+func (b byValue) Swap(i, j int) { b.entries[i], b.entries[j] = b.entries[j], b.entries[i] }
+func (b byValue) Less(i, j int) bool {
+	x, y := b.entries[i], b.entries[j]
+	if x.Value != y.Value {
+		return (x.Value < y.Value) == b.asc
+	}
+	if b.tiebreak[x.PlayerID] != b.tiebreak[y.PlayerID] {
+		return b.tiebreak[x.PlayerID] > b.tiebreak[y.PlayerID]
+	}
+	return x.PlayerID < y.PlayerID
+}
+
+//lobbiesPlayed returns the number of lobbies each player finished during the season
+func (season *Season) lobbiesPlayed() (map[key]int, error) {
+	rows, err := db.DB.Table("lobby_slots").
+		Select("lobby_slots.player_id, lobbies.type, lobbies.region_code, COUNT(*)").
+		Joins("INNER JOIN lobbies ON lobbies.id = lobby_slots.lobby_id").
+		Where("lobbies.match_ended = TRUE AND lobby_slots.needs_sub = FALSE AND lobbies.created_at >= ? AND lobbies.created_at < ?",
+			season.StartsAt, season.EndsAt).
+		Group("lobby_slots.player_id, lobbies.type, lobbies.region_code").Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	played := make(map[key]int)
+	for rows.Next() {
+		var k key
+		var count int
+		if err := rows.Scan(&k.playerID, &k.format, &k.region, &count); err != nil {
+			return nil, err
+		}
+		played[k] = count
+	}
+
+	return played, rows.Err()
+}
+
+//classHours returns the number of seconds each player played each class
+//during the season
+func (season *Season) classHours() (map[key]map[string]int, error) {
+	rows, err := db.DB.Table("lobby_class_times").
+		Select("lobby_class_times.player_id, lobbies.type, lobbies.region_code, lobby_class_times.class, SUM(lobby_class_times.seconds)").
+		Joins("INNER JOIN lobbies ON lobbies.id = lobby_class_times.lobby_id").
+		Where("lobbies.created_at >= ? AND lobbies.created_at < ?", season.StartsAt, season.EndsAt).
+		Group("lobby_class_times.player_id, lobbies.type, lobbies.region_code, lobby_class_times.class").Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hours := make(map[key]map[string]int)
+	for rows.Next() {
+		var k key
+		var class string
+		var seconds int
+		if err := rows.Scan(&k.playerID, &k.format, &k.region, &class, &seconds); err != nil {
+			return nil, err
+		}
+
+		if hours[k] == nil {
+			hours[k] = make(map[string]int)
+		}
+		hours[k][class] = seconds
+	}
+
+	return hours, rows.Err()
+}
+
+//subs returns the number of times each player was subbed out of a lobby
+//during the season
+func (season *Season) subs() (map[key]int, error) {
+	rows, err := db.DB.Table("lobby_substitutes").
+		Select("lobby_substitutes.player_id, lobbies.type, lobbies.region_code, COUNT(*)").
+		Joins("INNER JOIN lobbies ON lobbies.id = lobby_substitutes.lobby_id").
+		Where("lobbies.created_at >= ? AND lobbies.created_at < ?", season.StartsAt, season.EndsAt).
+		Group("lobby_substitutes.player_id, lobbies.type, lobbies.region_code").Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	subs := make(map[key]int)
+	for rows.Next() {
+		var k key
+		var count int
+		if err := rows.Scan(&k.playerID, &k.format, &k.region, &count); err != nil {
+			return nil, err
+		}
+		subs[k] = count
+	}
+
+	return subs, rows.Err()
+}
+
+//ratings returns the current rating of every player who has played a rated
+//match, by format. Ratings aren't kept per season or region, so the region
+//of the returned keys is always empty.
+func ratings() (map[key]float64, error) {
+	var ratings []*player.PlayerRating
+	if err := db.DB.Where("matches > 0").Find(&ratings).Error; err != nil {
+		return nil, err
+	}
+
+	m := make(map[key]float64)
+	for _, r := range ratings {
+		m[key{r.PlayerID, r.Format, ""}] = r.Rating
+	}
+
+	return m, nil
+}
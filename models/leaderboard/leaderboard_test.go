@@ -0,0 +1,107 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package leaderboard_test
+
+import (
+	"testing"
+	"time"
+
+	db "github.com/TF2Stadium/Helen/database"
+	"github.com/TF2Stadium/Helen/internal/testhelpers"
+	. "github.com/TF2Stadium/Helen/models/leaderboard"
+	"github.com/TF2Stadium/Helen/models/lobby"
+	"github.com/TF2Stadium/Helen/models/lobby/format"
+	"github.com/TF2Stadium/Helen/models/player"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	testhelpers.CleanupDB()
+}
+
+func TestNewSeason(t *testing.T) {
+	now := time.Now()
+
+	_, err := NewSeason("", now, now.Add(time.Hour))
+	assert.Equal(t, ErrInvalidSeason, err)
+	_, err = NewSeason("Season", now, now.Add(-time.Hour))
+	assert.Equal(t, ErrInvalidSeason, err)
+
+	season, err := NewSeason("Season", now, now.Add(time.Hour))
+	require.NoError(t, err)
+	_, err = GetSeason(season.ID)
+	assert.NoError(t, err)
+
+	require.NoError(t, RemoveSeason(season.ID))
+	assert.Equal(t, ErrSeasonNotFound, RemoveSeason(season.ID))
+}
+
+//playLobby creates an ended lobby in the given region, with the players
+//in the first slots
+func playLobby(region string, players ...*player.Player) *lobby.Lobby {
+	lob := testhelpers.CreateLobby()
+	lob.RegionCode = region
+	lob.MatchEnded = true
+	lob.State = lobby.Ended
+	lob.Save()
+
+	for i, p := range players {
+		db.DB.Create(&lobby.LobbySlot{LobbyID: lob.ID, PlayerID: p.ID, Slot: i})
+	}
+	return lob
+}
+
+func TestCompute(t *testing.T) {
+	p1 := testhelpers.CreatePlayer()
+	p2 := testhelpers.CreatePlayer()
+	p3 := testhelpers.CreatePlayer()
+
+	var last *lobby.Lobby
+	for i := 0; i < MinLobbies; i++ {
+		last = playLobby("eu", p1, p2)
+	}
+	playLobby("na", p3)
+	db.DB.Create(&lobby.LobbySubstitute{LobbyID: last.ID, PlayerID: p1.ID})
+	db.DB.Create(&lobby.LobbyClassTime{LobbyID: last.ID, PlayerID: p2.ID, Class: "scout", Seconds: 1800})
+
+	season, err := NewSeason("Season", time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	require.NoError(t, season.Compute())
+
+	board, err := Get(season.ID, Lobbies, "", format.Sixes, "")
+	require.NoError(t, err)
+	require.Len(t, board.Entries, 3)
+	assert.Equal(t, 1, board.Entries[0].Rank)
+	assert.Equal(t, 1, board.Entries[1].Rank) // tied with the first player
+	assert.Equal(t, float64(MinLobbies), board.Entries[0].Value)
+	assert.Equal(t, p3.SteamID, board.Entries[2].SteamID)
+	assert.Equal(t, 3, board.Entries[2].Rank)
+
+	board, err = Get(season.ID, Lobbies, "", format.Sixes, "na")
+	require.NoError(t, err)
+	require.Len(t, board.Entries, 1)
+	assert.Equal(t, p3.SteamID, board.Entries[0].SteamID)
+
+	board, err = Get(season.ID, Subs, "", format.Sixes, "eu")
+	require.NoError(t, err)
+	require.Len(t, board.Entries, 2)
+	assert.Equal(t, p2.SteamID, board.Entries[0].SteamID)
+	assert.Equal(t, float64(1), board.Entries[1].Value)
+
+	_, err = Get(season.ID, Hours, "", format.Sixes, "")
+	assert.Equal(t, ErrNoClass, err)
+	board, err = Get(season.ID, Hours, "scout", format.Sixes, "")
+	require.NoError(t, err)
+	require.Len(t, board.Entries, 1)
+	assert.Equal(t, p2.SteamID, board.Entries[0].SteamID)
+	assert.Equal(t, 0.5, board.Entries[0].Value)
+
+	// the current season is used if no season is given
+	board, err = Get(0, Lobbies, "", format.Sixes, "eu")
+	require.NoError(t, err)
+	assert.Equal(t, season.ID, board.Season.ID)
+	assert.Len(t, board.Entries, 2)
+}
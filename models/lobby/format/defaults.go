@@ -0,0 +1,86 @@
+package format
+
+// built-in format definitions
+var defaults = []Definition{
+	{
+		ID:           Sixes,
+		Name:         "6s",
+		PrettyName:   "6s",
+		SettingsName: "sixes",
+		League:       "etf2l",
+		Slots: []Slot{
+			{"scout1", "scout"},
+			{"scout2", "scout"},
+			{"roamer", "soldier"},
+			{"pocket", "soldier"},
+			{"demoman", "demoman"},
+			{"medic", "medic"},
+		},
+		MaxSubs: 4,
+	},
+	{
+		ID:         Highlander,
+		Name:       "highlander",
+		PrettyName: "Highlander",
+		League:     "ugc",
+		Slots: []Slot{
+			{"scout", "scout"},
+			{"soldier", "soldier"},
+			{"pyro", "pyro"},
+			{"demoman", "demoman"},
+			{"heavy", "heavy"},
+			{"engineer", "engineer"},
+			{"medic", "medic"},
+			{"sniper", "sniper"},
+			{"spy", "spy"},
+		},
+		MaxSubs: 5,
+	},
+	{
+		ID:           Fours,
+		Name:         "4v4",
+		PrettyName:   "4v4",
+		SettingsName: "fours",
+		League:       "ugc",
+		Slots: []Slot{
+			{"scout", "scout"},
+			{"soldier", "soldier"},
+			{"demoman", "demoman"},
+			{"medic", "medic"},
+		},
+		MaxSubs: 2,
+	},
+	{
+		ID:         Ultiduo,
+		Name:       "ultiduo",
+		PrettyName: "Ultiduo",
+		League:     "etf2l",
+		Slots: []Slot{
+			{"soldier", "soldier"},
+			{"medic", "medic"},
+		},
+		MaxSubs:   2,
+		Gamemodes: map[string]string{"koth": "ultiduo"},
+	},
+	{
+		ID:         Bball,
+		Name:       "bball",
+		PrettyName: "Bball",
+		League:     "bballtf",
+		Slots: []Slot{
+			{"soldier1", "soldier"},
+			{"soldier2", "soldier"},
+		},
+		MaxSubs:   2,
+		Gamemodes: map[string]string{"ctf": "bball"},
+	},
+	{
+		ID:         Debug,
+		Name:       "debug",
+		PrettyName: "Debug",
+		Slots: []Slot{
+			{"scout", "scout"},
+		},
+		MaxSubs: 2,
+	},
+}
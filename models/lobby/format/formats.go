@@ -1,9 +1,20 @@
+//Package format contains definitions of lobby formats. The built-in formats
+//are defined in defaults.go, additional formats can be loaded from a JSON file
+//with LoadFromFile, without needing any code changes.
 package format
 
-import "errors"
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sort"
+)
 
 type Format int
 
+// IDs for built-in formats. These are stored in the database, and hence
+// should never be changed.
 const (
 	Sixes      Format = iota
 	Highlander        // lol
@@ -13,104 +24,173 @@ const (
 	Debug
 )
 
+//Slot describes a slot on a team
+type Slot struct {
+	Name  string `json:"name"`  // slot name, as used by clients ("scout1", "pocket")
+	Class string `json:"class"` // TF2 class played on the slot ("scout", "soldier")
+}
+
+//Definition describes a lobby format
+type Definition struct {
+	ID         Format `json:"id"`
+	Name       string `json:"name"`       // name used while creating lobbies ("6s", "highlander")
+	PrettyName string `json:"prettyName"` // name shown to players ("6s", "Highlander")
+	Slots      []Slot `json:"slots"`      // slots for each team
+	MaxSubs    int    `json:"maxSubs"`    // lobbies are closed when these many slots need a substitute
+	// gamemodes overriden by the format, as map prefix -> gamemode
+	// (for instance, koth maps are played as "ultiduo" in ultiduo lobbies)
+	Gamemodes map[string]string `json:"gamemodes"`
+	// name of the format in lobbySettings, if it's different ("sixes" for 6s)
+	SettingsName string `json:"settingsName"`
+	// league lobbies created by the queue are played in. Formats without one
+	// can't be queued for.
+	League string `json:"league"`
+}
+
 var (
 	teamMap  = map[string]int{"red": 0, "blu": 1}
 	teamList = []string{"red", "blu"}
 
-	sixesClassMap = map[string]int{
-		"scout1":  0,
-		"scout2":  1,
-		"roamer":  2,
-		"pocket":  3,
-		"demoman": 4,
-		"medic":   5,
-	}
-	sixesClassList = []string{"scout1", "scout2", "roamer", "pocket", "demoman", "medic"}
-
-	hlClassMap = map[string]int{
-		"scout":    0,
-		"soldier":  1,
-		"pyro":     2,
-		"demoman":  3,
-		"heavy":    4,
-		"engineer": 5,
-		"medic":    6,
-		"sniper":   7,
-		"spy":      8,
-	}
-	hlClassList = []string{
-		"scout",
-		"soldier",
-		"pyro",
-		"demoman",
-		"heavy",
-		"engineer",
-		"medic",
-		"sniper",
-		"spy"}
-
-	debugClassMap = map[string]int{
-		"scout": 0,
-	}
-	debugClassList = []string{"scout"}
-
-	bballClassMap = map[string]int{
-		"soldier1": 0,
-		"soldier2": 1,
-	}
-	bballClassList = []string{"soldier1", "soldier2"}
-
-	ultiduoClassMap = map[string]int{
-		"soldier": 0,
-		"medic":   1,
-	}
-	ultiduoClassList = []string{"soldier", "medic"}
-
-	foursClassMap = map[string]int{
-		"scout":   0,
-		"soldier": 1,
-		"demoman": 2,
-		"medic":   3,
-	}
-	foursClassList = []string{"scout", "soldier", "demoman", "medic"}
-
-	typeClassMap = map[Format]map[string]int{
-		Highlander: hlClassMap,
-		Sixes:      sixesClassMap,
-		Fours:      foursClassMap,
-		Ultiduo:    ultiduoClassMap,
-		Bball:      bballClassMap,
-		Debug:      debugClassMap,
-	}
-
-	typeClassList = map[Format][]string{
-		Highlander: hlClassList,
-		Sixes:      sixesClassList,
-		Fours:      foursClassList,
-		Ultiduo:    ultiduoClassList,
-		Bball:      bballClassList,
-		Debug:      debugClassList,
-	}
-
-	NumberOfClassesMap = map[Format]int{
-		Highlander: 9,
-		Sixes:      6,
-		Fours:      4,
-		Ultiduo:    2,
-		Bball:      2,
-		Debug:      1,
-	}
-
-	FriendlyNamesMap = map[Format]string{
-		Highlander: "Highlander",
-		Sixes:      "6s",
-		Fours:      "4v4",
-		Ultiduo:    "Ultiduo",
-		Bball:      "Bball",
-		Debug:      "Debug",
-	}
+	definitions = make(map[Format]*Definition)
+	nameMap     = make(map[string]Format)
+
+	typeClassMap  = make(map[Format]map[string]int)
+	typeClassList = make(map[Format][]string)
+
+	NumberOfClassesMap = make(map[Format]int)
+	FriendlyNamesMap   = make(map[Format]string)
 )
 
+func init() {
+	for _, def := range defaults {
+		if err := Register(def); err != nil {
+			panic(err)
+		}
+	}
+}
+
+//Register adds the given format definition, replacing any existing definition
+//with the same ID.
+func Register(def Definition) error {
+	if def.ID < 0 {
+		return fmt.Errorf("format %q: invalid ID %d", def.Name, def.ID)
+	}
+	if def.Name == "" {
+		return fmt.Errorf("format %d: name cannot be empty", def.ID)
+	}
+	if len(def.Slots) == 0 {
+		return fmt.Errorf("format %q: no slots given", def.Name)
+	}
+	if id, ok := nameMap[def.Name]; ok && id != def.ID {
+		return fmt.Errorf("format %q: name is already used by format %d", def.Name, id)
+	}
+
+	classMap := make(map[string]int)
+	classList := make([]string, len(def.Slots))
+	for i, slot := range def.Slots {
+		if _, ok := classMap[slot.Name]; ok {
+			return fmt.Errorf("format %q: duplicate slot %q", def.Name, slot.Name)
+		}
+		classMap[slot.Name] = i
+		classList[i] = slot.Name
+	}
+
+	if def.PrettyName == "" {
+		def.PrettyName = def.Name
+	}
+	if def.SettingsName == "" {
+		def.SettingsName = def.Name
+	}
+
+	if old, ok := definitions[def.ID]; ok {
+		delete(nameMap, old.Name)
+	}
+
+	definitions[def.ID] = &def
+	nameMap[def.Name] = def.ID
+	typeClassMap[def.ID] = classMap
+	typeClassList[def.ID] = classList
+	NumberOfClassesMap[def.ID] = len(def.Slots)
+	FriendlyNamesMap[def.ID] = def.PrettyName
+
+	return nil
+}
+
+//Load registers format definitions from a JSON array of definitions
+func Load(data []byte) error {
+	var defs []Definition
+	if err := json.Unmarshal(data, &defs); err != nil {
+		return err
+	}
+
+	for _, def := range defs {
+		if err := Register(def); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+//LoadFromFile registers format definitions from the given JSON file
+func LoadFromFile(fileName string) error {
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return err
+	}
+
+	return Load(data)
+}
+
+//Get returns the definition for the given format
+func Get(f Format) (*Definition, bool) {
+	def, ok := definitions[f]
+	return def, ok
+}
+
+//GetByName returns the format with the given name
+func GetByName(name string) (Format, bool) {
+	f, ok := nameMap[name]
+	return f, ok
+}
+
+//All returns definitions for all registered formats, ordered by ID
+func All() []*Definition {
+	var defs []*Definition
+	for _, def := range definitions {
+		defs = append(defs, def)
+	}
+
+	sort.Sort(byID(defs))
+	return defs
+}
+
+type byID []*Definition
+
+func (d byID) Len() int           { return len(d) }
+func (d byID) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
+func (d byID) Less(i, j int) bool { return d[i].ID < d[j].ID }
+
+//MaxSubs returns the number of substitutes after which lobbies of the given
+//format are closed
+func MaxSubs(f Format) int {
+	if def, ok := definitions[f]; ok {
+		return def.MaxSubs
+	}
+	return 0
+}
+
+//Gamemode returns the gamemode overriden by the format for maps with the given prefix
+func Gamemode(f Format, mapPrefix string) (string, bool) {
+	def, ok := definitions[f]
+	if !ok {
+		return "", false
+	}
+
+	mode, ok := def.Gamemodes[mapPrefix]
+	return mode, ok
+}
+
 //GetSlot returns the slot number for given team, class strings and the
 //lobby format
 func GetSlot(lobbytype Format, teamStr string, classStr string) (int, error) {
@@ -138,12 +218,24 @@ func GetSlotTeamClass(lobbytype Format, slot int) (team, class string, err error
 	return
 }
 
+//GetSlotClass returns the TF2 class played on the given slot number
+func GetSlotClass(lobbytype Format, slot int) (string, error) {
+	_, classI, err := getSlotNums(lobbytype, slot)
+	if err != nil {
+		return "", err
+	}
+
+	return definitions[lobbytype].Slots[classI].Class, nil
+}
+
 //given a slot number, returns the numbers for the
 //slot's class and team for the given format
 func getSlotNums(lobbytype Format, slot int) (int, int, error) {
 	classList := typeClassList[lobbytype]
 
-	if slot < len(classList) {
+	if slot < 0 {
+		return 0, 0, errors.New("Invalid slot")
+	} else if slot < len(classList) {
 		return 0, slot, nil
 	} else if slot < 2*len(classList) {
 		return 1, slot - len(classList), nil
@@ -45,3 +45,55 @@ func TestClassMaps(t *testing.T) {
 		assert.Equal(t, team, "red")
 	}
 }
+
+func TestLoad(t *testing.T) {
+	err := Load([]byte(`[{
+		"id": 100,
+		"name": "prolander",
+		"prettyName": "Prolander",
+		"maxSubs": 3,
+		"league": "ugc",
+		"slots": [
+			{"name": "scout", "class": "scout"},
+			{"name": "soldier", "class": "soldier"},
+			{"name": "demoman", "class": "demoman"},
+			{"name": "medic", "class": "medic"},
+			{"name": "sniper", "class": "sniper"},
+			{"name": "flex1", "class": "pyro"},
+			{"name": "flex2", "class": "engineer"}
+		]
+	}]`))
+	assert.NoError(t, err)
+
+	f, ok := GetByName("prolander")
+	assert.True(t, ok)
+	assert.Equal(t, Format(100), f)
+	assert.Equal(t, 7, NumberOfClassesMap[f])
+	assert.Equal(t, "Prolander", FriendlyNamesMap[f])
+	assert.Equal(t, 3, MaxSubs(f))
+
+	def, ok := Get(f)
+	assert.True(t, ok)
+	assert.Equal(t, "prolander", def.SettingsName)
+	assert.Equal(t, "ugc", def.League)
+
+	slot, err := GetSlot(f, "blu", "flex2")
+	assert.NoError(t, err)
+	assert.Equal(t, 13, slot)
+
+	class, err := GetSlotClass(f, slot)
+	assert.NoError(t, err)
+	assert.Equal(t, "engineer", class)
+
+	// built-in formats keep their IDs
+	f, ok = GetByName("6s")
+	assert.True(t, ok)
+	assert.Equal(t, Sixes, f)
+
+	mode, ok := Gamemode(Ultiduo, "koth")
+	assert.True(t, ok)
+	assert.Equal(t, "ultiduo", mode)
+
+	assert.Error(t, Load([]byte(`[{"id": 101, "name": "6s", "slots": [{"name": "scout", "class": "scout"}]}]`)))
+	assert.Error(t, Load([]byte(`[{"id": 102, "name": "empty"}]`)))
+}
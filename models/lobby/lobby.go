@@ -18,14 +18,14 @@ import (
 	"github.com/TF2Stadium/Helen/controllers/broadcaster"
 	db "github.com/TF2Stadium/Helen/database"
 	"github.com/TF2Stadium/Helen/helpers"
+	"github.com/TF2Stadium/Helen/helpers/secret"
 	"github.com/TF2Stadium/Helen/models/chat"
 	"github.com/TF2Stadium/Helen/models/gameserver"
 	"github.com/TF2Stadium/Helen/models/lobby/format"
+	"github.com/TF2Stadium/Helen/models/match"
 	"github.com/TF2Stadium/Helen/models/player"
 	"github.com/TF2Stadium/Helen/models/rpc"
-	"github.com/TF2Stadium/PlayerStatsScraper/steamid"
-	"github.com/TF2Stadium/logstf"
-	"github.com/TF2Stadium/servemetf"
+	"github.com/TF2Stadium/Helen/models/webhook"
 	"github.com/jinzhu/gorm"
 )
 
@@ -36,7 +36,10 @@ const (
 	Waiting      State = 1
 	ReadyingUp   State = 2
 	InProgress   State = 3
+	Scheduled    State = 4
 	Ended        State = 5
+	Drafting     State = 6
+	MapVoting    State = 7
 )
 
 var (
@@ -51,6 +54,8 @@ var (
 	ErrReqHours       = errors.New("You do not have sufficient hours to join that slot")
 	ErrReqLobbies     = errors.New("You have not played sufficient lobbies to join that slot")
 	ErrReqReliability = errors.New("You have insufficient reliability to join that slot")
+	ErrReqMinRating   = errors.New("Your rating is too low to join that slot")
+	ErrReqMaxRating   = errors.New("Your rating is too high to join that slot")
 )
 
 // Represents an occupied player slot in a lobby
@@ -66,6 +71,7 @@ type LobbySlot struct {
 	InGame   bool //true if the player is in the game server
 	InMumble bool //true if the player is in the mumble channel for the lobby
 	NeedsSub bool //true if the slot needs a subtitute player
+	PartyID  uint //ID of the party the player joined with, 0 if they joined alone
 }
 
 //DeleteUnusedServerRecords checks all server records in the DB and deletes them if
@@ -122,7 +128,8 @@ type Lobby struct {
 	PlayerWhitelist   string            // URL of steam group
 	TwitchChannel     string            // twitch channel, slots will be restricted
 	TwitchRestriction TwitchRestriction // restricted to either followers or subs
-	ServemeID         int               // if serveme was used to get this server, stores the server ID
+	ServerProvider    string            // name of the gameserver.ServerProvider the server was reserved from, empty if the creator gave the server
+	ReservationID     int               // the provider's ID for the reservation, like the serveme.tf reservation ID
 
 	// Team name aliases
 	RedTeamName string
@@ -138,26 +145,49 @@ type Lobby struct {
 	BannedPlayers []player.Player `gorm:"many2many:banned_players_lobbies"`     // List of Banned Players
 
 	CreatedBySteamID string // SteamID of the lobby leader/creator
+	Matchmaking      bool   // true if the lobby was formed by the matchmaking queue
+
+	// Scrim mode, slots on each team can only be taken by players invited by the team's captain
+	Scrim             bool
+	RedCaptainSteamID string
+	BluCaptainSteamID string
+
+	// Captain draft, players join a pool and the captains (RedCaptainSteamID
+	// and BluCaptainSteamID) pick them into slots
+	Draft            bool
+	DraftCaptainMode string // "vote" or "rating"
+	DraftPick        int    // number of picks made so far
+	DraftTimestamp   int64  // (Unix) Timestamp at which the current vote/pick times out
+
+	// Map vote, players vote for a map from the pool once the lobby is full,
+	// before readying up
+	MapPool          string // comma separated names of the maps in the pool, empty if there's no vote
+	MapVoteTimestamp int64  // (Unix) Timestamp at which the map vote ends, 0 before it starts
+
+	// Scheduled lobbies
+	ScheduledFor        time.Time // time at which the lobby is scheduled to start
+	ScheduledServerType string    // "server", "storedServer" or "serveme". The server is only set up shortly before the start
+	ScheduledServerID   int       // stored server ID, or serveme server ID
 
 	ReadyUpTimestamp int64 // (Unix) Timestamp at which the ready up timeout started
 	MatchEnded       bool  // if true, the lobby ended with the match ending in the game server
 	LogstfID         int   // logs.tf id (only when match ends)
+	RedScore         int   // final scores, once the match's stats have been recorded
+	BluScore         int
+	StatsSource      string // where the match's stats were recorded from, StatsFromLogsTF or StatsFromLog
 }
 
 func getGamemode(mapName string, lobbyType format.Format) string {
+	prefix := strings.SplitN(mapName, "_", 2)[0]
+	if mode, ok := format.Gamemode(lobbyType, prefix); ok {
+		return mode
+	}
+
 	switch {
 	case strings.HasPrefix(mapName, "koth"):
-		if lobbyType == format.Ultiduo {
-			return "ultiduo"
-		}
-
 		return "koth"
 
 	case strings.HasPrefix(mapName, "ctf"):
-		if lobbyType == format.Bball {
-			return "bball"
-		}
-
 		return "ctf"
 
 	case strings.HasPrefix(mapName, "cp"):
@@ -200,6 +230,9 @@ func NewLobby(mapName string, lobbyType format.Format, league string, serverInfo
 		RedTeamName:     "Red",
 		BluTeamName:     "Blu",
 	}
+	if lobby.ServerInfo.LogSecret == "" {
+		lobby.ServerInfo.LogSecret = secret.String(NewLogSecret())
+	}
 
 	// Must specify CreatedBy manually if the lobby is created by a player
 	return lobby
@@ -209,30 +242,18 @@ func NewLobby(mapName string, lobbyType format.Format, league string, serverInfo
 //Closed lobbies aren't deleted, this function is used for
 //lobbies where the game server had an error while being setup.
 func (lobby *Lobby) Delete() {
-	var count int
-
-	db.DB.Model(&gameserver.ServerRecord{}).Where("host = ?", lobby.ServerInfo.Host).Count(&count)
-	if count != 0 {
-		gameserver.PutStoredServer(lobby.ServerInfo.Host)
-	}
-
-	if lobby.ServemeID != 0 {
-		context := helpers.GetServemeContext(lobby.ServerInfo.Host)
-		err := context.Delete(lobby.ServemeID, lobby.CreatedBySteamID)
-		for err != nil {
-			err = context.Delete(lobby.ServemeID, lobby.CreatedBySteamID)
-		}
-	}
-
+	lobby.releaseServer()
+	lobby.unschedule()
 	db.DB.Delete(lobby)
 	db.DB.Delete(&lobby.ServerInfo)
 
 	lobby.deleteLock()
 }
 
-//GetWaitingLobbies returns a list of lobby objects that haven't been filled yet
+//GetWaitingLobbies returns a list of lobby objects that haven't been filled yet,
+//including scheduled lobbies
 func GetWaitingLobbies() (lobbies []*Lobby) {
-	db.DB.Where("state = ?", Waiting).Order("id desc").Find(&lobbies)
+	db.DB.Where("state IN (?)", []State{Waiting, Scheduled, Drafting}).Order("id desc").Find(&lobbies)
 	return
 }
 
@@ -250,35 +271,66 @@ func (l *Lobby) SetState(s State) {
 	l.State = s
 }
 
-//ServemeCheck checks the status of the serveme reservation for the lobby
-//(if any) every 10 seconds in a goroutine, and closes the lobby if it has ended
-func (l *Lobby) ServemeCheck(context *servemetf.Context) {
-	go func() {
-		for {
-			ended, err := context.Ended(l.ServemeID, l.CreatedBySteamID)
-			if err != nil {
-				logrus.Error(err)
-			}
-			if ended {
-				if l.CurrentState() != Ended {
-					chat.SendNotification("Lobby Closed (Serveme reservation ended.)", int(l.ID))
-					l.Close(true, false)
-				}
-				return
-			}
-			time.Sleep(10 * time.Second)
+//SetReservation records the server reservation the lobby uses, so the server
+//can be released once the lobby closes
+func (l *Lobby) SetReservation(provider gameserver.ServerProvider, res *gameserver.Reservation) {
+	l.ServerProvider = provider.Name()
+	l.ReservationID = res.ID
+}
+
+//getReservation returns the provider and reservation of the lobby's server,
+//the provider is nil if the server wasn't reserved from one
+func (l *Lobby) getReservation() (gameserver.ServerProvider, *gameserver.Reservation) {
+	provider, ok := gameserver.GetProvider(l.ServerProvider)
+	if !ok {
+		return nil, nil
+	}
+
+	return provider, &gameserver.Reservation{
+		ID:           l.ReservationID,
+		SteamID:      l.CreatedBySteamID,
+		Host:         l.ServerInfo.Host,
+		RconPassword: string(l.ServerInfo.RconPassword),
+	}
+}
+
+//releaseServer releases the lobby's server reservation (if any)
+func (l *Lobby) releaseServer() {
+	provider, res := l.getReservation()
+	if provider == nil {
+		return
+	}
+
+	if err := provider.Release(res); err != nil {
+		logrus.Error(err)
+	}
+}
+
+//WatchReservation closes the lobby if the reservation for its server (if any)
+//ends while the lobby is still open
+func (l *Lobby) WatchReservation() {
+	provider, res := l.getReservation()
+	if provider == nil {
+		return
+	}
+
+	provider.WatchEnded(res, func() {
+		if l.CurrentState() != Ended {
+			chat.SendNotification("Lobby Closed (Server reservation ended.)", int(l.ID))
+			l.Close(true, false)
 		}
-	}()
+	})
 }
 
-func RestoreServemeChecks() {
+//RestoreReservationWatches starts watching the server reservations of open
+//lobbies, used after Helen restarts.
+func RestoreReservationWatches() {
 	var ids []uint
-	db.DB.Model(&Lobby{}).Where("state <> ? AND serveme_id <> 0", Ended).Pluck("id", &ids)
+	db.DB.Model(&Lobby{}).Where("state <> ? AND server_provider <> ''", Ended).Pluck("id", &ids)
 
 	for _, id := range ids {
 		lobby, _ := GetLobbyByIDServer(id)
-		context := helpers.GetServemeContext(lobby.ServerInfo.Host)
-		lobby.ServemeCheck(context)
+		lobby.WatchReservation()
 	}
 }
 
@@ -405,6 +457,12 @@ func (lobby *Lobby) AddPlayer(p *player.Player, slot int, password string) error
 		return ErrFilled
 	}
 
+	if lobby.Scrim {
+		if err := lobby.canTakeSlot(p, slot); err != nil {
+			return err
+		}
+	}
+
 	if lobby.HasSlotRequirement(slot) {
 		//check if player fits the requirements for the slot
 		if ok, err := lobby.FitsRequirements(p, slot); !ok {
@@ -418,6 +476,7 @@ func (lobby *Lobby) AddPlayer(p *player.Player, slot int, password string) error
 	}
 
 	var slotChange bool
+	var partyID uint
 	//Check if the player is currently in another lobby
 	if currLobbyID, err := p.GetLobbyID(false); err == nil {
 		if currLobbyID != lobby.ID {
@@ -442,6 +501,8 @@ func (lobby *Lobby) AddPlayer(p *player.Player, slot int, password string) error
 				return ErrNeedsSub
 			}
 			lobby.Lock()
+			// players who joined with a party stay in it
+			db.DB.Model(&LobbySlot{}).Select("party_id").Where("player_id = ? AND lobby_id = ?", p.ID, lobby.ID).Row().Scan(&partyID)
 			db.DB.Where("player_id = ? AND lobby_id = ?", p.ID, lobby.ID).Delete(&LobbySlot{})
 			lobby.Unlock()
 
@@ -450,27 +511,8 @@ func (lobby *Lobby) AddPlayer(p *player.Player, slot int, password string) error
 	}
 
 	if !slotChange {
-		//check if the player is in the steam group whitelist
-		url := fmt.Sprintf(`http://steamcommunity.com/groups/%s/memberslistxml/?xml=1`,
-			lobby.PlayerWhitelist)
-
-		if lobby.PlayerWhitelist != "" && !helpers.IsWhitelisted(p.SteamID, url) {
-			return ErrNotWhitelisted
-		}
-
-		//check if player has been subbed to the twitch channel (if any)
-		//allow channel owners
-		if lobby.TwitchChannel != "" && p.TwitchName != lobby.TwitchChannel {
-			//check if player has connected their twitch account
-			if p.TwitchAccessToken == "" {
-				return errors.New("You need to connect your Twitch Account first to join the lobby.")
-			}
-			if lobby.TwitchRestriction == TwitchSubscribers && !p.IsSubscribed(lobby.TwitchChannel) {
-				return fmt.Errorf("You aren't subscribed to %s", lobby.TwitchChannel)
-			}
-			if lobby.TwitchRestriction == TwitchFollowers && !p.IsFollowing(lobby.TwitchChannel) {
-				return fmt.Errorf("You aren't following %s", lobby.TwitchChannel)
-			}
+		if err := lobby.checkRestrictions(p); err != nil {
+			return err
 		}
 	}
 
@@ -502,6 +544,7 @@ func (lobby *Lobby) AddPlayer(p *player.Player, slot int, password string) error
 		PlayerID: p.ID,
 		LobbyID:  lobby.ID,
 		Slot:     slot,
+		PartyID:  partyID,
 	}
 
 	lobby.Lock()
@@ -519,6 +562,35 @@ func (lobby *Lobby) AddPlayer(p *player.Player, slot int, password string) error
 	return nil
 }
 
+//checkRestrictions checks whether the player is allowed to join the lobby,
+//by the lobby's steam group whitelist and twitch restrictions
+func (lobby *Lobby) checkRestrictions(p *player.Player) error {
+	//check if the player is in the steam group whitelist
+	url := fmt.Sprintf(`http://steamcommunity.com/groups/%s/memberslistxml/?xml=1`,
+		lobby.PlayerWhitelist)
+
+	if lobby.PlayerWhitelist != "" && !helpers.IsWhitelisted(p.SteamID, url) {
+		return ErrNotWhitelisted
+	}
+
+	//check if player has been subbed to the twitch channel (if any)
+	//allow channel owners
+	if lobby.TwitchChannel != "" && p.TwitchName != lobby.TwitchChannel {
+		//check if player has connected their twitch account
+		if p.TwitchAccessToken == "" {
+			return errors.New("You need to connect your Twitch Account first to join the lobby.")
+		}
+		if lobby.TwitchRestriction == TwitchSubscribers && !p.IsSubscribed(lobby.TwitchChannel) {
+			return fmt.Errorf("You aren't subscribed to %s", lobby.TwitchChannel)
+		}
+		if lobby.TwitchRestriction == TwitchFollowers && !p.IsFollowing(lobby.TwitchChannel) {
+			return fmt.Errorf("You aren't following %s", lobby.TwitchChannel)
+		}
+	}
+
+	return nil
+}
+
 //RemovePlayer removes a given player from the lobby
 func (lobby *Lobby) RemovePlayer(player *player.Player) error {
 	lobby.Lock()
@@ -585,6 +657,10 @@ func (lobby *Lobby) RemoveUnreadyPlayers(spec bool) error {
 	err := db.DB.Where("lobby_id = ? AND ready = ?", lobby.ID, false).Delete(&LobbySlot{}).Error
 	lobby.Unlock()
 
+	if lobby.Draft {
+		lobby.removeFromDraftPool()
+	}
+
 	if spec {
 		for _, id := range playerids {
 			p, _ := player.GetPlayerByID(id)
@@ -728,14 +804,47 @@ func (lobby *Lobby) ShuffleAllSlots() error {
 	if lobby.GetPlayerNumber() == lobby.RequiredPlayers() {
 		return errors.New("Cannot shuffle a full lobby")
 	}
+	if lobby.Scrim || lobby.Draft {
+		return errors.New("Cannot shuffle scrim or drafted lobbies")
+	}
 
 	lobby.Lock()
 	lobby.GetAllSlots()
 	classes := format.GetClasses(lobby.Type)
-	swapClass := make(map[string]bool)
+	numClasses := len(classes)
 
+	//classes played by members of the same party are swapped together, so
+	//parties stay on the same team
+	group := make(map[string]string) // class -> class deciding whether it's swapped
+	var find func(string) string
+	find = func(class string) string {
+		if g, ok := group[class]; ok && g != class {
+			group[class] = find(g)
+			return group[class]
+		}
+		return class
+	}
+	partyClass := make(map[uint]string)
+	for _, slot := range lobby.Slots {
+		if slot.PartyID == 0 {
+			continue
+		}
+		class := classes[slot.Slot%numClasses]
+		if other, ok := partyClass[slot.PartyID]; ok {
+			group[find(class)] = find(other)
+		} else {
+			partyClass[slot.PartyID] = class
+		}
+	}
+
+	swapClass := make(map[string]bool)
+	for _, className := range classes {
+		if find(className) == className {
+			swapClass[className] = rand.Intn(2) == 1
+		}
+	}
 	for _, className := range classes {
-		swapClass[className] = rand.Intn(2) == 1
+		swapClass[className] = swapClass[find(className)]
 	}
 
 	err := db.DB.Delete(&LobbySlot{}, "lobby_id = ?", lobby.ID).Error
@@ -743,7 +852,6 @@ func (lobby *Lobby) ShuffleAllSlots() error {
 		return err
 	}
 
-	numClasses := len(classes)
 	for i := range lobby.Slots {
 		slot := &lobby.Slots[i]
 		if swapClass[classes[slot.Slot%numClasses]] {
@@ -774,8 +882,9 @@ func (lobby *Lobby) DiscordNotif(msg string) {
 		}
 
 		byLine := ""
-		player, playerErr := player.GetPlayerBySteamID(lobby.CreatedBySteamID)
-		if playerErr != nil {
+		if lobby.Matchmaking {
+			byLine = " by matchmaking"
+		} else if player, playerErr := player.GetPlayerBySteamID(lobby.CreatedBySteamID); playerErr != nil {
 			logrus.Error(playerErr)
 		} else {
 			byLine = fmt.Sprintf(" by %s", player.Alias())
@@ -801,27 +910,28 @@ func (lobby *Lobby) SetupServer() error {
 		return err
 	}
 
+	lobby.watchLog()
 	rpc.FumbleLobbyCreated(lobby.ID)
 	lobby.DiscordNotif("New Lobby")
+	// scheduled lobbies send this when they're created
+	if lobby.ScheduledFor.IsZero() {
+		lobby.SendWebhook(webhook.LobbyCreated)
+	}
 	return nil
 }
 
 //Close closes the lobby, which has the following effects:
 //
 //  All unfilled substitutes for the lobby are "filled" (ie, their filled field is set to true)
-//  The corresponding ServerRecord is deleted
+//  The corresponding ServerRecord is deleted, and the server's reservation is released
+//  If the match ended, stats parsed from the server's log are recorded
 //
 //If rpc == true, the log listener in Pauling for the corresponding server is stopped, this is
 //used when the lobby is closed manually by a player
 func (lobby *Lobby) Close(doRPC, matchEnded bool) {
-	var count int
-
 	db.DB.Preload("ServerInfo").First(lobby, lobby.ID)
-	db.DB.Model(&gameserver.ServerRecord{}).Where("host = ?", lobby.ServerInfo.Host).Count(&count)
-	if count != 0 {
-		gameserver.PutStoredServer(lobby.ServerInfo.Host)
-	}
-
+	lobby.releaseServer()
+	lobby.unschedule()
 	lobby.SetState(Ended)
 	db.DB.First(lobby).UpdateColumn("match_ended", matchEnded)
 	//db.DB.Exec("DELETE FROM spectators_players_lobbies WHERE lobby_id = ?", lobby.ID)
@@ -831,17 +941,9 @@ func (lobby *Lobby) Close(doRPC, matchEnded bool) {
 	if matchEnded {
 		lobby.UpdateStats()
 	}
-	if lobby.ServemeID != 0 {
-		context := helpers.GetServemeContext(lobby.ServerInfo.Host)
-		err := context.Delete(lobby.ServemeID, lobby.CreatedBySteamID)
-		if err != nil {
-			logrus.Error(err)
-		}
-		if matchEnded {
-			time.AfterFunc(10*time.Second, func() {
-				lobby.DownloadDemo(context)
-			})
-		}
+	lobby.stopParsingLog(matchEnded)
+	if matchEnded && lobby.ServerProvider != "" {
+		time.AfterFunc(10*time.Second, lobby.DownloadDemo)
 	}
 
 	privateRoom := fmt.Sprintf("%d_private", lobby.ID)
@@ -855,14 +957,24 @@ func (lobby *Lobby) Close(doRPC, matchEnded bool) {
 	BroadcastLobby(lobby)
 	BroadcastLobbyList() // has to be done manually for now
 	rpc.FumbleLobbyEnded(lobby.ID)
+	lobby.SendWebhook(webhook.LobbyClosed)
 	lobby.deleteLock()
 }
 
-func (lobby *Lobby) DownloadDemo(context *servemetf.Context) {
+//DownloadDemo downloads the lobby's STV demo from the provider of its server,
+//if it keeps them
+func (lobby *Lobby) DownloadDemo() {
+	provider, res := lobby.getReservation()
+	if provider == nil {
+		return
+	}
+
 	file := fmt.Sprintf("%s/%d.dem", config.Constants.DemosFolder,
 		lobby.ID)
-	err := context.DownloadDemo(lobby.ServemeID, lobby.CreatedBySteamID, file)
-	if err != nil {
+	err := provider.DownloadDemo(res, file)
+	if err == gameserver.ErrNoDemos {
+		return
+	} else if err != nil {
 		logrus.Error(err)
 	} else {
 		url := fmt.Sprintf("%s/demos/%d.dem", config.Constants.PublicAddress, lobby.ID)
@@ -882,37 +994,55 @@ func (lobby *Lobby) UpdateStats() {
 		p.Stats.PlayedCountIncrease(lobby.Type)
 		p.Stats.IncreaseClassCount(lobby.Type, slot.Slot)
 		p.Save()
+		p.UpdateReliability()
 	}
 	lobby.OnChange(false)
 }
 
+//UpdateHours downloads the lobby's log from logs.tf, and records the
+//match's result with RecordResult
 func (lobby *Lobby) UpdateHours(logsID int) error {
 	db.DB.Model(&Lobby{}).Where("id = ?", lobby.ID).UpdateColumn("logstf_id", logsID)
+	if lobby.statsRecorded() {
+		// already recorded from the server's log
+		return nil
+	}
 
-	logs, err := logstf.GetLogs(logsID)
+	result, err := match.FromLogsTF(logsID)
 	if err != nil {
 		return err
 	}
 
-	for steamID, playerStats := range logs.Players {
-		commid, _ := steamid.SteamIdToCommId(steamID)
+	if !lobby.claimStats(StatsFromLogsTF) {
+		return ErrStatsRecorded
+	}
+	lobby.RecordResult(result)
+	return nil
+}
+
+//RecordResult stores the stats of every player in the match, adds the time
+//they played on each class to their PlayerStats, and updates their ratings
+func (lobby *Lobby) RecordResult(result *match.Result) {
+	lobby.saveMatchStats(result)
+
+	for commid, playerStats := range result.Players {
 		player, err := player.GetPlayerWithStats(commid)
 		if err != nil {
 			logrus.Error("Couldn't find player with SteamID ", commid)
 			continue
 		}
 
-		for _, class := range playerStats.ClassStats {
-			totalTime := time.Second * time.Duration(class.TotalTime)
+		for _, class := range playerStats.Classes {
+			totalTime := time.Second * time.Duration(class.Seconds)
 
-			switch class.Type {
+			switch class.Class {
 			case "scout":
 				player.Stats.ScoutHours += totalTime
 			case "soldier":
 				player.Stats.SoldierHours += totalTime
 			case "demoman":
 				player.Stats.DemoHours += totalTime
-			case "heavyweapons":
+			case "heavy":
 				player.Stats.HeavyHours += totalTime
 			case "pyro":
 				player.Stats.PyroHours += totalTime
@@ -930,7 +1060,7 @@ func (lobby *Lobby) UpdateHours(logsID int) error {
 		player.Stats.Save()
 	}
 
-	return nil
+	lobby.UpdateRatings(result.RedScore, result.BluScore)
 }
 
 func (lobby *Lobby) setInGameStatus(player *player.Player, inGame bool) error {
@@ -982,6 +1112,8 @@ func (lobby *Lobby) Start() {
 	rows := db.DB.Model(&Lobby{}).Where("id = ? AND state <> ?", lobby.ID, InProgress).Update("state", InProgress).RowsAffected
 	if rows != 0 { // if == 0, then game is already in progress
 		go rpc.ReExecConfig(lobby.ID, false)
+		lobby.State = InProgress
+		lobby.SendWebhook(webhook.MatchStarted)
 
 		// var playerids []uint
 		// db.DB.Model(&LobbySlot{}).Where("lobby_id = ?", lobby.ID).Pluck("player_id", &playerids)
@@ -998,7 +1130,7 @@ func (lobby *Lobby) Start() {
 //OnChange broadcasts the given lobby to other players. If base is true, broadcasts the lobby list too.
 func (lobby *Lobby) OnChange(base bool) {
 	switch lobby.State {
-	case Waiting, InProgress, ReadyingUp:
+	case Waiting, InProgress, ReadyingUp, MapVoting:
 		BroadcastLobby(lobby)
 		if base {
 			BroadcastLobbyList()
@@ -1025,25 +1157,18 @@ func BroadcastLobbyList() {
 		"lobbyListData", DecorateLobbyListData(GetWaitingLobbies(), false))
 }
 
-var maxSubs = map[format.Format]int{
-	format.Highlander: 5,
-	format.Sixes:      4,
-	format.Bball:      2,
-	format.Ultiduo:    2,
-	format.Debug:      2,
-	format.Fours:      2,
-}
-
 //Substitute sets the needs_sub column of the given slot to true, and broadcasts the new
 //substitute list
 func (lobby *Lobby) Substitute(player *player.Player) {
 	lobby.Lock()
 	db.DB.Model(&LobbySlot{}).Where("lobby_id = ? AND player_id = ?", lobby.ID, player.ID).UpdateColumn("needs_sub", true)
 	lobby.Unlock()
+	db.DB.Create(&LobbySubstitute{LobbyID: lobby.ID, PlayerID: player.ID})
+	lobby.sendWebhook(webhook.PlayerSubstituted, webhookData{SteamID: player.SteamID})
 
 	var count int
 	db.DB.Model(&LobbySlot{}).Where("lobby_id = ? AND needs_sub = TRUE", lobby.ID).Count(&count)
-	if count == maxSubs[lobby.Type] {
+	if count == format.MaxSubs(lobby.Type) {
 		chat.SendNotification("Lobby closed (Too many subs).", int(lobby.ID))
 		lobby.Close(true, false)
 	}
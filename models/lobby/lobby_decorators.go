@@ -49,6 +49,7 @@ type LobbyData struct {
 	MaxPlayers        int    `json:"maxPlayers"`
 	TwitchChannel     string `json:"twitchChannel"`
 	TwitchRestriction string `json:"twitchRestriction"`
+	Matchmaking       bool   `json:"matchmaking"`
 
 	RegionLock bool   `json:"regionLock"`
 	SteamGroup string `json:"steamGroup"`
@@ -56,6 +57,10 @@ type LobbyData struct {
 	RedTeamName string `json:"redTeamName"`
 	BluTeamName string `json:"bluTeamName"`
 
+	Scrim      bool   `json:"scrim"`
+	RedCaptain string `json:"redCaptain,omitempty"` // steamid of the RED captain in scrim lobbies
+	BluCaptain string `json:"bluCaptain,omitempty"` // steamid of the BLU captain in scrim lobbies
+
 	Region struct {
 		Name string `json:"name"`
 		Code string `json:"code"`
@@ -65,10 +70,53 @@ type LobbyData struct {
 
 	Leader      player.Player `json:"leader"`
 	CreatedAt   int64         `json:"createdAt"`
+	ScheduledAt int64         `json:"scheduledAt,omitempty"`
 	State       int           `json:"state"`
 	WhitelistID string        `json:"whitelistId"`
 
 	Spectators []SpecDetails `json:"spectators,omitempty"`
+
+	Draft *DraftDetails `json:"draft,omitempty"`
+
+	MapVote *MapVoteDetails `json:"mapVote,omitempty"`
+
+	Summary *MatchSummary `json:"summary,omitempty"`
+}
+
+//MatchSummary is the final score of a lobby's match, and the stats of every
+//player in it
+type MatchSummary struct {
+	RedScore int                  `json:"redScore"`
+	BluScore int                  `json:"bluScore"`
+	Players  []*player.MatchStats `json:"players"`
+}
+
+type DraftDetails struct {
+	Pool []DraftPoolDetails `json:"pool"`
+	Turn string             `json:"turn,omitempty"` // team which makes the current pick, empty while voting for captains
+	// (Unix) time at which the current vote/pick times out
+	Timeout int64 `json:"timeout,omitempty"`
+}
+
+type DraftPoolDetails struct {
+	Name    string `json:"name"`
+	SteamID string `json:"steamid"`
+	Picked  bool   `json:"picked"`
+	Votes   int    `json:"votes,omitempty"` // number of captain votes
+}
+
+type MapVoteDetails struct {
+	ID    uint                 `json:"id"`
+	Pool  []MapTally           `json:"pool"` // maps in the pool, with their number of votes
+	Votes []MapVoteDetailsVote `json:"votes"`
+	// (Unix) time at which the vote ends, 0 before it has started
+	Timeout int64 `json:"timeout"`
+	Ended   bool  `json:"ended"` // if true, the lobby's map is the one which won the vote
+}
+
+type MapVoteDetailsVote struct {
+	SteamID string `json:"steamid"`
+	Map     string `json:"map"` // map the player voted for
 }
 
 type LobbyListData struct {
@@ -155,6 +203,9 @@ func decorateSlotDetails(lobby *Lobby, slot int, playerInfo bool) SlotDetails {
 }
 
 var stateString = map[State]string{
+	Scheduled:  "Scheduled",
+	Drafting:   "Drafting",
+	MapVoting:  "Voting For Map",
 	Waiting:    "Waiting For Players",
 	InProgress: "Lobby in Progress",
 	Ended:      "Lobby Ended",
@@ -172,9 +223,13 @@ func DecorateLobbyData(lobby *Lobby, playerInfo bool) LobbyData {
 		Discord:           lobby.Discord,
 		TwitchChannel:     lobby.TwitchChannel,
 		TwitchRestriction: lobby.TwitchRestriction.String(),
+		Matchmaking:       lobby.Matchmaking,
 		RegionLock:        lobby.RegionLock,
 		RedTeamName:       lobby.RedTeamName,
 		BluTeamName:       lobby.BluTeamName,
+		Scrim:             lobby.Scrim,
+		RedCaptain:        lobby.RedCaptainSteamID,
+		BluCaptain:        lobby.BluCaptainSteamID,
 
 		SteamGroup: lobby.PlayerWhitelist,
 	}
@@ -199,6 +254,13 @@ func DecorateLobbyData(lobby *Lobby, playerInfo bool) LobbyData {
 
 	lobbyData.Classes = classes
 	lobbyData.WhitelistID = lobby.Whitelist
+	if lobby.State == Scheduled {
+		lobbyData.ScheduledAt = lobby.ScheduledFor.Unix()
+	}
+	if lobby.Draft && lobby.State == Waiting {
+		// players in the pool don't have slots yet
+		lobbyData.Players = lobby.draftPoolSize()
+	}
 
 	if !playerInfo {
 		return lobbyData
@@ -231,9 +293,62 @@ func DecorateLobbyData(lobby *Lobby, playerInfo bool) LobbyData {
 
 	lobbyData.Spectators = spectators
 
+	if lobby.Draft {
+		lobbyData.Draft = decorateDraftDetails(lobby)
+	}
+	if lobby.MapPool != "" {
+		lobbyData.MapVote = DecorateMapVote(lobby)
+	}
+
 	return lobbyData
 }
 
+func decorateDraftDetails(lobby *Lobby) *DraftDetails {
+	details := &DraftDetails{Timeout: lobby.DraftTimestamp}
+	if lobby.State == Drafting && lobby.RedCaptainSteamID != "" {
+		details.Turn = lobby.DraftTurn()
+	}
+
+	votes := lobby.captainVotes()
+	for _, d := range lobby.GetDraftPool() {
+		p, err := player.GetPlayerByID(d.PlayerID)
+		if err != nil {
+			continue
+		}
+
+		details.Pool = append(details.Pool, DraftPoolDetails{
+			Name:    p.Alias(),
+			SteamID: p.SteamID,
+			Picked:  d.Picked,
+			Votes:   votes[p.ID],
+		})
+	}
+
+	return details
+}
+
+//DecorateMapVote returns the lobby's map pool, and the votes for it
+func DecorateMapVote(lobby *Lobby) *MapVoteDetails {
+	details := &MapVoteDetails{
+		ID:      lobby.ID,
+		Pool:    lobby.MapVoteTally(),
+		Votes:   []MapVoteDetailsVote{},
+		Timeout: lobby.MapVoteTimestamp,
+		Ended:   lobby.MapVoteTimestamp != 0 && lobby.State != MapVoting,
+	}
+
+	for _, vote := range lobby.getMapVotes() {
+		p, err := player.GetPlayerByID(vote.PlayerID)
+		if err != nil {
+			continue
+		}
+
+		details.Votes = append(details.Votes, MapVoteDetailsVote{p.SteamID, vote.MapName})
+	}
+
+	return details
+}
+
 func (l LobbyData) Send() {
 	broadcaster.SendMessageToRoom(fmt.Sprintf("%d_public", l.ID), "lobbyData", l)
 }
@@ -253,11 +368,32 @@ func DecorateLobbyListData(lobbies []*Lobby, playerInfo bool) []LobbyData {
 	return lobbyList
 }
 
+//DecorateMatchHistory decorates lobbies like DecorateLobbyListData, adding
+//match summaries to lobbies which have match stats
+func DecorateMatchHistory(lobbies []*Lobby) []LobbyData {
+	lobbyList := DecorateLobbyListData(lobbies, true)
+
+	for i, lobby := range lobbies {
+		stats := player.GetMatchStats(lobby.ID)
+		if len(stats) == 0 {
+			continue
+		}
+
+		lobbyList[i].Summary = &MatchSummary{
+			RedScore: lobby.RedScore,
+			BluScore: lobby.BluScore,
+			Players:  stats,
+		}
+	}
+
+	return lobbyList
+}
+
 func DecorateLobbyConnect(lob *Lobby, player *player.Player, slot int) LobbyConnectData {
 	l := LobbyConnectData{}
 	l.ID = lob.ID
 	l.Time = lob.CreatedAt.Unix()
-	l.Pass = lob.ServerInfo.ServerPassword
+	l.Pass = string(lob.ServerInfo.ServerPassword)
 	l.Game.Host = lob.ServerInfo.Host
 
 	l.Mumble.Address = config.Constants.MumbleAddr
@@ -0,0 +1,512 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package lobby
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	db "github.com/TF2Stadium/Helen/database"
+	"github.com/TF2Stadium/Helen/models/chat"
+	"github.com/TF2Stadium/Helen/models/lobby/format"
+	"github.com/TF2Stadium/Helen/models/player"
+)
+
+const (
+	//DraftVoteTime is how long players in the pool can vote for captains
+	DraftVoteTime = 30 * time.Second
+	//DraftPickTime is how long captains have for each pick, after which a
+	//player is picked for them
+	DraftPickTime = 30 * time.Second
+)
+
+var (
+	ErrNotDraft      = errors.New("This lobby doesn't use a captain draft")
+	ErrDraftStarted  = errors.New("The draft has already started")
+	ErrDraftPoolFull = errors.New("The draft pool is full")
+	ErrNotInPool     = errors.New("Player isn't in the draft pool")
+	ErrNotVoting     = errors.New("Captains aren't being voted for right now")
+	ErrNotPicking    = errors.New("Players aren't being picked right now")
+	ErrNotYourTurn   = errors.New("It isn't your turn to pick")
+	ErrAlreadyPicked = errors.New("That player has already been picked")
+	ErrOtherCaptain  = errors.New("You can't pick the other team's captain")
+	ErrPickYourself  = errors.New("You need to pick a class for yourself")
+	ErrDraftJoin     = errors.New("This lobby uses a captain draft, join the draft pool instead")
+	ErrInLobby       = errors.New("You need to leave your current lobby before joining a draft pool")
+	ErrInOtherPool   = errors.New("You're already in the draft pool of another lobby")
+)
+
+//DraftFinished is called after all players in a drafted lobby have been picked,
+//and the lobby can be readied up.
+var DraftFinished = func(*Lobby) {}
+
+//DraftPlayer is a player in the draft pool of a lobby
+type DraftPlayer struct {
+	ID       uint `json:"-"`
+	LobbyID  uint `json:"-"`
+	PlayerID uint `json:"-"`
+
+	VotedFor uint `json:"-"` // ID of the player this player voted for as captain
+	Picked   bool `json:"picked"`
+}
+
+var (
+	// draftMu serializes all changes to drafts, since timers and captains can
+	// pick at the same time
+	draftMu     = new(sync.Mutex)
+	draftTimers = make(map[uint]*time.Timer)
+)
+
+//GetDraftPool returns all players in the lobby's draft pool, in the order they joined
+func (lobby *Lobby) GetDraftPool() []*DraftPlayer {
+	var pool []*DraftPlayer
+	db.DB.Where("lobby_id = ?", lobby.ID).Order("id").Find(&pool)
+	return pool
+}
+
+func (lobby *Lobby) draftPoolSize() int {
+	var count int
+	db.DB.Model(&DraftPlayer{}).Where("lobby_id = ?", lobby.ID).Count(&count)
+	return count
+}
+
+//InDraftPool returns whether the player is in the lobby's draft pool
+func (lobby *Lobby) InDraftPool(p *player.Player) bool {
+	var count int
+	db.DB.Model(&DraftPlayer{}).Where("lobby_id = ? AND player_id = ?", lobby.ID, p.ID).Count(&count)
+	return count != 0
+}
+
+//GetDraftPoolLobbyIDs returns the IDs of drafted lobbies which haven't started
+//yet, and have the player in their draft pool
+func GetDraftPoolLobbyIDs(p *player.Player) []uint {
+	var ids []uint
+	db.DB.Model(&DraftPlayer{}).Joins("INNER JOIN lobbies ON lobbies.id = draft_players.lobby_id").
+		Where("draft_players.player_id = ? AND lobbies.state IN (?)", p.ID, []State{Waiting, Drafting}).
+		Pluck("draft_players.lobby_id", &ids)
+	return ids
+}
+
+//JoinDraftPool adds the player to the lobby's draft pool. The draft starts
+//once the pool has enough players to fill the lobby.
+func (lobby *Lobby) JoinDraftPool(p *player.Player) error {
+	if !lobby.Draft {
+		return ErrNotDraft
+	}
+	if lobby.IsPlayerBanned(p) {
+		return ErrLobbyBan
+	}
+
+	draftMu.Lock()
+	defer draftMu.Unlock()
+
+	//get updated lobby object
+	db.DB.First(lobby, lobby.ID)
+	if lobby.State != Waiting {
+		return ErrDraftStarted
+	}
+	if lobby.InDraftPool(p) {
+		return nil
+	}
+	// players can only be in one lobby at a time, including draft pools
+	if id, _ := p.GetLobbyID(false); id != 0 {
+		return ErrInLobby
+	}
+	for _, id := range GetDraftPoolLobbyIDs(p) {
+		if id != lobby.ID {
+			return ErrInOtherPool
+		}
+	}
+	if lobby.draftPoolSize() >= lobby.RequiredPlayers() {
+		return ErrDraftPoolFull
+	}
+
+	err := db.DB.Create(&DraftPlayer{LobbyID: lobby.ID, PlayerID: p.ID}).Error
+	if err != nil {
+		return err
+	}
+
+	if lobby.draftPoolSize() == lobby.RequiredPlayers() {
+		lobby.startDraft()
+	} else {
+		BroadcastLobby(lobby)
+		BroadcastLobbyList()
+	}
+
+	return nil
+}
+
+//LeaveDraftPool removes the player from the lobby's draft pool. If the draft
+//has started, it's cancelled, and the lobby waits for the pool to fill again.
+func (lobby *Lobby) LeaveDraftPool(p *player.Player) error {
+	draftMu.Lock()
+	defer draftMu.Unlock()
+
+	if !lobby.InDraftPool(p) {
+		return ErrNotInPool
+	}
+
+	db.DB.Where("lobby_id = ? AND player_id = ?", lobby.ID, p.ID).Delete(&DraftPlayer{})
+	db.DB.First(lobby, lobby.ID)
+	if lobby.State == Drafting {
+		chat.SendNotification("Draft cancelled, a player left the pool.", int(lobby.ID))
+		lobby.cancelDraft()
+	}
+
+	BroadcastLobby(lobby)
+	BroadcastLobbyList()
+	return nil
+}
+
+//startDraft clears all slots, and starts voting for captains (or picks them by
+//rating). draftMu needs to be held.
+func (lobby *Lobby) startDraft() {
+	lobby.Lock()
+	db.DB.Where("lobby_id = ?", lobby.ID).Delete(&LobbySlot{})
+	lobby.Unlock()
+
+	db.DB.Model(&DraftPlayer{}).Where("lobby_id = ?", lobby.ID).
+		Updates(map[string]interface{}{"voted_for": 0, "picked": false})
+
+	lobby.RedCaptainSteamID = ""
+	lobby.BluCaptainSteamID = ""
+	lobby.DraftPick = 0
+	lobby.State = Drafting
+
+	if lobby.DraftCaptainMode == "vote" {
+		lobby.DraftTimestamp = time.Now().Add(DraftVoteTime).Unix()
+		db.DB.Save(lobby)
+		chat.SendNotification("The draft pool is full, vote for captains.", int(lobby.ID))
+		lobby.setDraftTimer(DraftVoteTime, 0)
+	} else {
+		lobby.chooseCaptains()
+	}
+
+	BroadcastLobby(lobby)
+	BroadcastLobbyList()
+}
+
+//cancelDraft removes all picked players from their slots, and makes the
+//lobby wait for the pool to fill again. draftMu needs to be held.
+func (lobby *Lobby) cancelDraft() {
+	lobby.stopDraftTimer()
+
+	lobby.Lock()
+	db.DB.Where("lobby_id = ?", lobby.ID).Delete(&LobbySlot{})
+	lobby.Unlock()
+
+	db.DB.Model(&DraftPlayer{}).Where("lobby_id = ?", lobby.ID).
+		Updates(map[string]interface{}{"voted_for": 0, "picked": false})
+
+	lobby.RedCaptainSteamID = ""
+	lobby.BluCaptainSteamID = ""
+	lobby.DraftPick = 0
+	lobby.DraftTimestamp = 0
+	lobby.State = Waiting
+	db.DB.Save(lobby)
+}
+
+//byRating sorts pool players by their rating for the lobby's format, highest first
+type byRating struct {
+	players []*player.Player
+	ratings map[uint]float64
+	votes   map[uint]int
+}
+
+func (b byRating) Len() int      { return len(b.players) }
+func (b byRating) Swap(i, j int) { b.players[i], b.players[j] = b.players[j], b.players[i] }
+func (b byRating) Less(i, j int) bool {
+	p1, p2 := b.players[i], b.players[j]
+	if b.votes[p1.ID] != b.votes[p2.ID] {
+		return b.votes[p1.ID] > b.votes[p2.ID]
+	}
+	return b.ratings[p1.ID] > b.ratings[p2.ID]
+}
+
+//sortedPool returns the players in the pool which haven't been picked yet,
+//sorted by the number of captain votes, and then by rating.
+func (lobby *Lobby) sortedPool(votes map[uint]int) []*player.Player {
+	b := byRating{ratings: make(map[uint]float64), votes: votes}
+	for _, d := range lobby.GetDraftPool() {
+		if d.Picked {
+			continue
+		}
+
+		p, err := player.GetPlayerByID(d.PlayerID)
+		if err != nil {
+			continue
+		}
+
+		b.players = append(b.players, p)
+		b.ratings[p.ID] = p.GetRating(lobby.Type).Rating
+	}
+
+	sort.Stable(b)
+	return b.players
+}
+
+//VoteCaptain records the voter's vote for candidate as a captain
+func (lobby *Lobby) VoteCaptain(voter, candidate *player.Player) error {
+	draftMu.Lock()
+	defer draftMu.Unlock()
+
+	db.DB.First(lobby, lobby.ID)
+	if lobby.State != Drafting || lobby.DraftCaptainMode != "vote" || lobby.RedCaptainSteamID != "" {
+		return ErrNotVoting
+	}
+	if !lobby.InDraftPool(voter) || !lobby.InDraftPool(candidate) {
+		return ErrNotInPool
+	}
+
+	err := db.DB.Model(&DraftPlayer{}).Where("lobby_id = ? AND player_id = ?", lobby.ID, voter.ID).
+		UpdateColumn("voted_for", candidate.ID).Error
+	if err != nil {
+		return err
+	}
+
+	BroadcastLobby(lobby)
+	return nil
+}
+
+//captainVotes returns the number of captain votes for each player in the pool
+func (lobby *Lobby) captainVotes() map[uint]int {
+	votes := make(map[uint]int)
+	for _, d := range lobby.GetDraftPool() {
+		if d.VotedFor != 0 {
+			votes[d.VotedFor]++
+		}
+	}
+	return votes
+}
+
+//chooseCaptains makes the two players with the most votes (or the highest
+//rating) captains, and starts picking. draftMu needs to be held.
+func (lobby *Lobby) chooseCaptains() {
+	var votes map[uint]int
+	if lobby.DraftCaptainMode == "vote" {
+		votes = lobby.captainVotes()
+	}
+
+	pool := lobby.sortedPool(votes)
+	if len(pool) < 2 {
+		lobby.cancelDraft()
+		return
+	}
+
+	lobby.RedCaptainSteamID = pool[0].SteamID
+	lobby.BluCaptainSteamID = pool[1].SteamID
+	lobby.DraftTimestamp = time.Now().Add(DraftPickTime).Unix()
+	db.DB.Save(lobby)
+
+	chat.SendNotification(pool[0].Alias()+" and "+pool[1].Alias()+" are the captains.", int(lobby.ID))
+	lobby.setDraftTimer(DraftPickTime, lobby.DraftPick)
+}
+
+//DraftTurn returns the team which makes the current pick. Captains pick in a
+//snake order (RED, BLU, BLU, RED, RED, ...).
+func (lobby *Lobby) DraftTurn() string {
+	switch lobby.DraftPick % 4 {
+	case 0, 3:
+		return "red"
+	}
+	return "blu"
+}
+
+//PickPlayer makes the captain pick target for the given class on their team
+func (lobby *Lobby) PickPlayer(captain, target *player.Player, class string) error {
+	draftMu.Lock()
+	defer draftMu.Unlock()
+
+	db.DB.First(lobby, lobby.ID)
+	if lobby.State != Drafting || lobby.RedCaptainSteamID == "" {
+		return ErrNotPicking
+	}
+
+	team := lobby.DraftTurn()
+	if lobby.GetCaptain(team) != captain.SteamID {
+		return ErrNotYourTurn
+	}
+
+	slot, err := format.GetSlot(lobby.Type, team, class)
+	if err != nil {
+		return err
+	}
+	if lobby.IsSlotOccupied(slot) {
+		return ErrFilled
+	}
+
+	if err := lobby.canPick(team, target); err != nil {
+		return err
+	}
+
+	return lobby.pick(target, slot)
+}
+
+//canPick returns an error if target can't be picked by team
+func (lobby *Lobby) canPick(team string, target *player.Player) error {
+	d := &DraftPlayer{}
+	err := db.DB.Where("lobby_id = ? AND player_id = ?", lobby.ID, target.ID).First(d).Error
+	if err != nil {
+		return ErrNotInPool
+	}
+	if d.Picked {
+		return ErrAlreadyPicked
+	}
+
+	captain := lobby.GetCaptain(team)
+	if target.SteamID != captain && (target.SteamID == lobby.RedCaptainSteamID || target.SteamID == lobby.BluCaptainSteamID) {
+		return ErrOtherCaptain
+	}
+	// captains always end up on their own team, so their team's last pick
+	// needs to be the captain if they haven't picked themselves yet
+	if target.SteamID != captain && lobby.openSlots(team) == 1 && !lobby.isPicked(captain) {
+		return ErrPickYourself
+	}
+
+	return nil
+}
+
+func (lobby *Lobby) isPicked(steamID string) bool {
+	p, err := player.GetPlayerBySteamID(steamID)
+	if err != nil {
+		return false
+	}
+
+	var count int
+	db.DB.Model(&DraftPlayer{}).Where("lobby_id = ? AND player_id = ? AND picked = ?", lobby.ID, p.ID, true).Count(&count)
+	return count != 0
+}
+
+//openSlots returns the slots on team which haven't been filled yet
+func (lobby *Lobby) openSlots(team string) int {
+	var n int
+	for _, class := range format.GetClasses(lobby.Type) {
+		slot, _ := format.GetSlot(lobby.Type, team, class)
+		if !lobby.IsSlotOccupied(slot) {
+			n++
+		}
+	}
+	return n
+}
+
+//pick adds target to the slot, and moves on to the next pick. draftMu needs
+//to be held.
+func (lobby *Lobby) pick(target *player.Player, slot int) error {
+	lobby.stopDraftTimer()
+
+	if err := lobby.AddPlayer(target, slot, ""); err != nil {
+		lobby.setDraftTimer(time.Unix(lobby.DraftTimestamp, 0).Sub(time.Now()), lobby.DraftPick)
+		return err
+	}
+
+	db.DB.Model(&DraftPlayer{}).Where("lobby_id = ? AND player_id = ?", lobby.ID, target.ID).UpdateColumn("picked", true)
+	_, class, _ := format.GetSlotTeamClass(lobby.Type, slot)
+	chat.SendNotification(target.Alias()+" was picked as "+class+" for "+lobby.TeamName(lobby.DraftTurn()), int(lobby.ID))
+
+	lobby.DraftPick++
+	if lobby.DraftPick == lobby.RequiredPlayers() {
+		lobby.DraftTimestamp = 0
+		lobby.State = Waiting
+		db.DB.Save(lobby)
+
+		BroadcastLobby(lobby)
+		DraftFinished(lobby)
+		return nil
+	}
+
+	lobby.DraftTimestamp = time.Now().Add(DraftPickTime).Unix()
+	db.DB.Save(lobby)
+	lobby.setDraftTimer(DraftPickTime, lobby.DraftPick)
+
+	BroadcastLobby(lobby)
+	return nil
+}
+
+//autoPick picks the highest rated player left in the pool for the first open
+//class on the team whose turn it is. draftMu needs to be held.
+func (lobby *Lobby) autoPick() {
+	team := lobby.DraftTurn()
+
+	slot := -1
+	for _, class := range format.GetClasses(lobby.Type) {
+		s, _ := format.GetSlot(lobby.Type, team, class)
+		if !lobby.IsSlotOccupied(s) {
+			slot = s
+			break
+		}
+	}
+
+	if slot != -1 {
+		for _, p := range lobby.sortedPool(nil) {
+			if lobby.canPick(team, p) != nil {
+				continue
+			}
+			if lobby.pick(p, slot) == nil {
+				return
+			}
+		}
+	}
+
+	// nobody could be picked (for instance, if players left for another lobby)
+	logrus.Errorf("Couldn't auto-pick a player for lobby #%d", lobby.ID)
+	chat.SendNotification("Draft cancelled, nobody could be picked.", int(lobby.ID))
+	lobby.cancelDraft()
+	BroadcastLobby(lobby)
+	BroadcastLobbyList()
+}
+
+//setDraftTimer sets up a timer for ending the captain vote, or for auto-picking
+//if the captain doesn't pick in time. pick is the pick the timer was set for.
+func (lobby *Lobby) setDraftTimer(d time.Duration, pick int) {
+	id := lobby.ID
+	draftTimers[id] = time.AfterFunc(d, func() {
+		draftMu.Lock()
+		defer draftMu.Unlock()
+
+		//get updated lobby object
+		lobby, err := GetLobbyByID(id)
+		if err != nil || lobby.State != Drafting || lobby.DraftPick != pick {
+			return
+		}
+
+		if lobby.RedCaptainSteamID == "" {
+			lobby.chooseCaptains()
+			BroadcastLobby(lobby)
+		} else {
+			lobby.autoPick()
+		}
+	})
+}
+
+func (lobby *Lobby) stopDraftTimer() {
+	if timer, ok := draftTimers[lobby.ID]; ok {
+		timer.Stop()
+		delete(draftTimers, lobby.ID)
+	}
+}
+
+//RestoreDrafts sets up timers for lobbies which were being drafted, used after
+//Helen restarts.
+func RestoreDrafts() {
+	var lobbies []*Lobby
+	db.DB.Where("state = ?", Drafting).Find(&lobbies)
+
+	draftMu.Lock()
+	defer draftMu.Unlock()
+
+	for _, lobby := range lobbies {
+		lobby.setDraftTimer(time.Unix(lobby.DraftTimestamp, 0).Sub(time.Now()), lobby.DraftPick)
+	}
+}
+
+//removeFromDraftPool removes players which don't have a slot anymore (for
+//instance, after failing to ready up) from the pool of a drafted lobby.
+func (lobby *Lobby) removeFromDraftPool() {
+	db.DB.Where("lobby_id = ? AND picked = ? AND player_id NOT IN (SELECT player_id FROM lobby_slots WHERE lobby_id = ?)",
+		lobby.ID, true, lobby.ID).Delete(&DraftPlayer{})
+}
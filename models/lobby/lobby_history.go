@@ -0,0 +1,117 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package lobby
+
+import (
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	db "github.com/TF2Stadium/Helen/database"
+	"github.com/TF2Stadium/Helen/models/match"
+	"github.com/TF2Stadium/Helen/models/player"
+)
+
+//LobbyClassTime stores how long a player played a class in a lobby, and
+//their stats on it, as reported by the lobby's log
+type LobbyClassTime struct {
+	ID uint `gorm:"primary_key"`
+
+	LobbyID  uint   `sql:"not null"`
+	PlayerID uint   `sql:"not null"`
+	Class    string `sql:"not null"` // "scout", "soldier", ..., "heavy"
+	Seconds  int
+
+	Kills   int
+	Deaths  int
+	Assists int
+	Damage  int
+}
+
+//LobbySubstitute is created when a player leaves a lobby in progress, and
+//their slot needs a substitute
+type LobbySubstitute struct {
+	ID        uint `gorm:"primary_key"`
+	CreatedAt time.Time
+
+	LobbyID  uint `sql:"not null"`
+	PlayerID uint `sql:"not null"`
+}
+
+//saveMatchStats stores the final score, and the match and class stats of
+//every player in the result, replacing the ones stored before
+func (lobby *Lobby) saveMatchStats(result *match.Result) {
+	lobby.RedScore, lobby.BluScore = result.RedScore, result.BluScore
+	db.DB.Model(&Lobby{}).Where("id = ?", lobby.ID).UpdateColumns(map[string]interface{}{
+		"red_score": result.RedScore,
+		"blu_score": result.BluScore,
+	})
+
+	db.DB.Where("lobby_id = ?", lobby.ID).Delete(&LobbyClassTime{})
+	db.DB.Where("lobby_id = ?", lobby.ID).Delete(&player.MatchStats{})
+
+	for commid, stats := range result.Players {
+		p, err := player.GetPlayerBySteamID(commid)
+		if err != nil {
+			// players who joined the server without being in the lobby
+			continue
+		}
+
+		db.DB.Create(&player.MatchStats{
+			LobbyID:  lobby.ID,
+			PlayerID: p.ID,
+			Team:     stats.Team,
+			Kills:    stats.Kills,
+			Deaths:   stats.Deaths,
+			Assists:  stats.Assists,
+			Damage:   stats.Damage,
+			Heals:    stats.Heals,
+			Ubers:    stats.Ubers,
+			Drops:    stats.Drops,
+		})
+
+		for _, class := range stats.Classes {
+			db.DB.Create(&LobbyClassTime{
+				LobbyID:  lobby.ID,
+				PlayerID: p.ID,
+				Class:    class.Class,
+				Seconds:  class.Seconds,
+				Kills:    class.Kills,
+				Deaths:   class.Deaths,
+				Assists:  class.Assists,
+				Damage:   class.Damage,
+			})
+		}
+	}
+}
+
+//BackfillMatchStats stores match stats for lobbies which have a logs.tf log,
+//but no stats yet, and returns the number of lobbies it stored stats for.
+//Players' hours and ratings aren't changed, since they were updated when
+//the lobbies ended.
+func BackfillMatchStats() int {
+	var lobbies []*Lobby
+	db.DB.Where("logstf_id <> 0 AND NOT EXISTS (SELECT 1 FROM match_stats WHERE match_stats.lobby_id = lobbies.id)").
+		Order("id").Find(&lobbies)
+
+	n := 0
+	for i, lobby := range lobbies {
+		if i != 0 {
+			// don't hammer logs.tf
+			time.Sleep(time.Second)
+		}
+
+		result, err := match.FromLogsTF(lobby.LogstfID)
+		if err != nil {
+			logrus.Errorf("Couldn't get log #%d for lobby #%d: %v", lobby.LogstfID, lobby.ID, err)
+			continue
+		}
+
+		lobby.saveMatchStats(result)
+		n++
+		logrus.Infof("Stored match stats for lobby #%d (%d/%d)", lobby.ID, i+1, len(lobbies))
+	}
+
+	return n
+}
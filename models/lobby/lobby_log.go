@@ -0,0 +1,216 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package lobby
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	db "github.com/TF2Stadium/Helen/database"
+	"github.com/TF2Stadium/Helen/models/match"
+)
+
+//Sources a lobby's match stats can be recorded from (Lobby.StatsSource)
+const (
+	StatsFromLogsTF = "logs.tf"
+	StatsFromLog    = "log" // the game server's log, parsed by Helen
+)
+
+var ErrStatsRecorded = errors.New("Stats for this lobby have already been recorded")
+
+//NewLogSecret returns a random value for sv_logsecret, which game servers
+//prefix their log lines with. sv_logsecret has to be a number.
+func NewLogSecret() string {
+	b := make([]byte, 4)
+	rand.Read(b)
+	// never 0, which disables the secret
+	return strconv.FormatUint(uint64(binary.BigEndian.Uint32(b)>>1)+1, 10)
+}
+
+//claimStats marks the lobby's stats as recorded from source, and returns
+//false if they have already been recorded from somewhere else
+func (lobby *Lobby) claimStats(source string) bool {
+	rows := db.DB.Model(&Lobby{}).Where("id = ? AND (stats_source IS NULL OR stats_source = '')", lobby.ID).
+		UpdateColumn("stats_source", source).RowsAffected
+	if rows == 0 {
+		return false
+	}
+
+	lobby.StatsSource = source
+	return true
+}
+
+//statsRecorded returns true if the lobby's stats have been recorded
+func (lobby *Lobby) statsRecorded() bool {
+	var count int
+	db.DB.Model(&Lobby{}).Where("id = ? AND stats_source <> ''", lobby.ID).Count(&count)
+	return count != 0
+}
+
+//RecordLog records the result of the match parsed from the lobby's server log,
+//unless its stats have already been recorded from logs.tf
+func (lobby *Lobby) RecordLog(result *match.Result) error {
+	if !lobby.claimStats(StatsFromLog) {
+		return ErrStatsRecorded
+	}
+
+	logrus.Infof("Recording stats for lobby #%d from its server log", lobby.ID)
+	lobby.RecordResult(result)
+	return nil
+}
+
+//logParser parses the log lines a lobby's server sends to the log listener
+type logParser struct {
+	lobbyID  uint
+	parser   *match.Parser
+	gameOver bool
+}
+
+//logGracePeriod is the time waited after the game is over before a lobby's
+//stats are recorded, so that the final score is parsed
+const logGracePeriod = 5 * time.Second
+
+var (
+	logParsersMu sync.Mutex
+	logParsers   = make(map[string]*logParser) // log secret -> parser
+
+	// log secret -> ID of the open lobby using it. Anyone can send log lines,
+	// so lines with other secrets are dropped without touching the database.
+	logSecrets = make(map[string]uint)
+)
+
+//StartLogListener listens for log lines sent by game servers over UDP, and records
+//the stats of lobbies once their match is over
+func StartLogListener(addr string) error {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return err
+	}
+
+	restoreLogSecrets()
+
+	logrus.Info("Listening for server logs on ", addr)
+	go func() {
+		buf := make([]byte, 2048)
+		for {
+			n, _, err := conn.ReadFrom(buf)
+			if err != nil {
+				logrus.Error(err)
+				if ne, ok := err.(net.Error); ok && ne.Temporary() {
+					continue
+				}
+				return
+			}
+
+			secret, line, ok := match.SplitPacket(buf[:n])
+			if !ok || secret == "" {
+				continue
+			}
+			parseLogLine(secret, line)
+		}
+	}()
+
+	return nil
+}
+
+func parseLogLine(secret, line string) {
+	logParsersMu.Lock()
+	defer logParsersMu.Unlock()
+
+	lp, ok := logParsers[secret]
+	if !ok {
+		lobbyID, ok := logSecrets[secret]
+		if !ok {
+			return
+		}
+
+		lp = &logParser{lobbyID: lobbyID, parser: match.NewParser()}
+		logParsers[secret] = lp
+	}
+
+	lp.parser.ParseLine(line)
+	if lp.parser.GameOver() && !lp.gameOver {
+		lp.gameOver = true
+		time.AfterFunc(logGracePeriod, func() {
+			// the lobby might have been closed in the meantime
+			if takeLogParser(secret) == lp {
+				lp.record()
+			}
+		})
+	}
+}
+
+//takeLogParser removes the parser for the given secret, and returns it
+func takeLogParser(secret string) *logParser {
+	logParsersMu.Lock()
+	defer logParsersMu.Unlock()
+
+	lp := logParsers[secret]
+	delete(logParsers, secret)
+	return lp
+}
+
+//watchLog starts accepting log lines sent with the lobby's log secret
+func (lobby *Lobby) watchLog() {
+	logSecret := string(lobby.ServerInfo.LogSecret)
+	if logSecret == "" {
+		return
+	}
+
+	logParsersMu.Lock()
+	logSecrets[logSecret] = lobby.ID
+	logParsersMu.Unlock()
+}
+
+//restoreLogSecrets accepts log lines for all open lobbies, used after Helen restarts.
+func restoreLogSecrets() {
+	var lobbies []*Lobby
+	db.DB.Preload("ServerInfo").Where("state <> ?", Ended).Find(&lobbies)
+
+	for _, lobby := range lobbies {
+		lobby.watchLog()
+	}
+}
+
+//record records the stats parsed by lp, if any rounds were played
+func (lp *logParser) record() {
+	result := lp.parser.Result()
+	if result.Length == 0 {
+		return
+	}
+
+	lobby, err := GetLobbyByID(lp.lobbyID)
+	if err != nil {
+		logrus.Error(err)
+		return
+	}
+
+	if err := lobby.RecordLog(result); err != nil && err != ErrStatsRecorded {
+		logrus.Error(err)
+	}
+}
+
+//stopParsingLog stops parsing the lobby's server log, and records the stats
+//parsed so far if the match ended
+func (lobby *Lobby) stopParsingLog(matchEnded bool) {
+	logSecret := string(lobby.ServerInfo.LogSecret)
+	if logSecret == "" {
+		return
+	}
+
+	logParsersMu.Lock()
+	delete(logSecrets, logSecret)
+	logParsersMu.Unlock()
+
+	if lp := takeLogParser(logSecret); lp != nil && matchEnded {
+		lp.record()
+	}
+}
@@ -0,0 +1,264 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package lobby
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/TF2Stadium/Helen/controllers/broadcaster"
+	db "github.com/TF2Stadium/Helen/database"
+	"github.com/TF2Stadium/Helen/models/chat"
+	"github.com/TF2Stadium/Helen/models/player"
+	"github.com/TF2Stadium/Helen/models/rpc"
+	"github.com/TF2Stadium/Helen/models/webhook"
+)
+
+const (
+	//MapVoteTime is how long players have to vote for the map
+	MapVoteTime = 30 * time.Second
+	//MaxMapPool is the largest number of maps players can vote for
+	MaxMapPool = 5
+)
+
+var (
+	ErrNoMapVote     = errors.New("This lobby doesn't have a map vote")
+	ErrNotVotingMap  = errors.New("The map isn't being voted for right now")
+	ErrMapNotInPool  = errors.New("That map isn't in the map pool")
+	ErrVoteNotInSlot = errors.New("Only players in the lobby can vote for the map")
+)
+
+//MapVoteFinished is called after the map vote has ended, and the lobby can be
+//readied up.
+var MapVoteFinished = func(*Lobby) {}
+
+//MapVote is a player's vote for a map in the lobby's map pool
+type MapVote struct {
+	ID       uint
+	LobbyID  uint
+	PlayerID uint
+	MapName  string
+}
+
+var (
+	// mapVoteMu serializes all changes to map votes, since the vote can be
+	// ended by the timer and the last player voting at the same time
+	mapVoteMu     = new(sync.Mutex)
+	mapVoteTimers = make(map[uint]*time.Timer)
+)
+
+//GetMapPool returns the maps players can vote for, starting with the map the
+//lobby was created with
+func (lobby *Lobby) GetMapPool() []string {
+	if lobby.MapPool == "" {
+		return nil
+	}
+	return strings.Split(lobby.MapPool, ",")
+}
+
+func (lobby *Lobby) inMapPool(mapName string) bool {
+	for _, name := range lobby.GetMapPool() {
+		if name == mapName {
+			return true
+		}
+	}
+	return false
+}
+
+//StartMapVote starts the map vote, if the lobby has a map pool and players
+//haven't voted yet. Returns true if the map is being voted for, in which case
+//the lobby is readied up by MapVoteFinished once the vote ends.
+//Lobbies only vote after their server has been set up, so the winning map is
+//changed to right away.
+func (lobby *Lobby) StartMapVote() bool {
+	if lobby.MapPool == "" {
+		return false
+	}
+
+	mapVoteMu.Lock()
+	defer mapVoteMu.Unlock()
+
+	//get updated lobby object
+	db.DB.First(lobby, lobby.ID)
+	if lobby.MapVoteTimestamp != 0 {
+		// players vote once, after that the lobby readies up with the map they chose
+		return lobby.State == MapVoting
+	}
+	if lobby.State != Waiting {
+		return false
+	}
+
+	db.DB.Where("lobby_id = ?", lobby.ID).Delete(&MapVote{})
+	lobby.State = MapVoting
+	lobby.MapVoteTimestamp = time.Now().Add(MapVoteTime).Unix()
+	db.DB.Save(lobby)
+
+	chat.SendNotification("The lobby is full, vote for the map.", int(lobby.ID))
+	lobby.setMapVoteTimer(MapVoteTime)
+	lobby.SendWebhook(webhook.LobbyFilled)
+
+	lobby.broadcastMapVote()
+	BroadcastLobby(lobby)
+	BroadcastLobbyList()
+	return true
+}
+
+//VoteMap records the player's vote for a map in the pool. The vote ends early
+//once every player in the lobby has voted.
+func (lobby *Lobby) VoteMap(p *player.Player, mapName string) error {
+	ended, err := lobby.voteMap(p, mapName)
+	if ended {
+		MapVoteFinished(lobby)
+	}
+	return err
+}
+
+func (lobby *Lobby) voteMap(p *player.Player, mapName string) (bool, error) {
+	if lobby.MapPool == "" {
+		return false, ErrNoMapVote
+	}
+
+	mapVoteMu.Lock()
+	defer mapVoteMu.Unlock()
+
+	//get updated lobby object
+	db.DB.First(lobby, lobby.ID)
+	if lobby.State != MapVoting {
+		return false, ErrNotVotingMap
+	}
+	if !lobby.HasPlayer(p) {
+		return false, ErrVoteNotInSlot
+	}
+	if !lobby.inMapPool(mapName) {
+		return false, ErrMapNotInPool
+	}
+
+	rows := db.DB.Model(&MapVote{}).Where("lobby_id = ? AND player_id = ?", lobby.ID, p.ID).
+		UpdateColumn("map_name", mapName).RowsAffected
+	if rows == 0 {
+		err := db.DB.Create(&MapVote{LobbyID: lobby.ID, PlayerID: p.ID, MapName: mapName}).Error
+		if err != nil {
+			return false, err
+		}
+	}
+
+	if len(lobby.getMapVotes()) == lobby.RequiredPlayers() {
+		lobby.endMapVote()
+		return true, nil
+	}
+
+	lobby.broadcastMapVote()
+	return false, nil
+}
+
+//getMapVotes returns the votes of players who are still in the lobby
+func (lobby *Lobby) getMapVotes() []*MapVote {
+	var votes []*MapVote
+	db.DB.Where("lobby_id = ? AND player_id IN (SELECT player_id FROM lobby_slots WHERE lobby_id = ?)",
+		lobby.ID, lobby.ID).Order("id").Find(&votes)
+	return votes
+}
+
+//MapTally is the number of votes for a map in the pool
+type MapTally struct {
+	Map   string `json:"map"`
+	Votes int    `json:"votes"`
+}
+
+//MapVoteTally returns the number of votes for each map, in the pool's order
+func (lobby *Lobby) MapVoteTally() []MapTally {
+	votes := make(map[string]int)
+	for _, vote := range lobby.getMapVotes() {
+		votes[vote.MapName]++
+	}
+
+	var tally []MapTally
+	for _, name := range lobby.GetMapPool() {
+		tally = append(tally, MapTally{name, votes[name]})
+	}
+	return tally
+}
+
+//endMapVote changes the lobby's map to the one with the most votes, ties going
+//to the map which comes first in the pool. mapVoteMu needs to be held.
+func (lobby *Lobby) endMapVote() {
+	lobby.stopMapVoteTimer()
+
+	winner, most := lobby.MapName, 0
+	for _, t := range lobby.MapVoteTally() {
+		if t.Votes > most {
+			winner, most = t.Map, t.Votes
+		}
+	}
+
+	changed := winner != lobby.MapName
+	lobby.MapName = winner
+	lobby.Mode = getGamemode(winner, lobby.Type)
+	lobby.State = Waiting
+	db.DB.Save(lobby)
+
+	if changed {
+		go func(id uint) {
+			if err := rpc.ReExecConfig(id, true); err != nil {
+				logrus.Error(err)
+			}
+		}(lobby.ID)
+	}
+
+	chat.SendNotification(fmt.Sprintf("%s won the map vote.", winner), int(lobby.ID))
+	lobby.broadcastMapVote()
+	BroadcastLobby(lobby)
+	BroadcastLobbyList()
+}
+
+//broadcastMapVote sends the pool and everyone's votes to players in the lobby
+func (lobby *Lobby) broadcastMapVote() {
+	room := fmt.Sprintf("%d_private", lobby.ID)
+	broadcaster.SendMessageToRoom(room, "lobbyMapVote", DecorateMapVote(lobby))
+}
+
+//setMapVoteTimer sets up a timer for ending the map vote
+func (lobby *Lobby) setMapVoteTimer(d time.Duration) {
+	id := lobby.ID
+	mapVoteTimers[id] = time.AfterFunc(d, func() {
+		mapVoteMu.Lock()
+
+		//get updated lobby object
+		lobby, err := GetLobbyByID(id)
+		if err != nil || lobby.State != MapVoting {
+			mapVoteMu.Unlock()
+			return
+		}
+
+		lobby.endMapVote()
+		mapVoteMu.Unlock()
+		MapVoteFinished(lobby)
+	})
+}
+
+func (lobby *Lobby) stopMapVoteTimer() {
+	if timer, ok := mapVoteTimers[lobby.ID]; ok {
+		timer.Stop()
+		delete(mapVoteTimers, lobby.ID)
+	}
+}
+
+//RestoreMapVotes sets up timers for lobbies whose map was being voted for,
+//used after Helen restarts.
+func RestoreMapVotes() {
+	var lobbies []*Lobby
+	db.DB.Where("state = ?", MapVoting).Find(&lobbies)
+
+	mapVoteMu.Lock()
+	defer mapVoteMu.Unlock()
+
+	for _, lobby := range lobbies {
+		lobby.setMapVoteTimer(time.Unix(lobby.MapVoteTimestamp, 0).Sub(time.Now()))
+	}
+}
@@ -0,0 +1,143 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package lobby
+
+import (
+	"errors"
+	"fmt"
+
+	db "github.com/TF2Stadium/Helen/database"
+	"github.com/TF2Stadium/Helen/models/lobby/format"
+	"github.com/TF2Stadium/Helen/models/player"
+	"github.com/TF2Stadium/Helen/models/rpc"
+)
+
+var (
+	ErrPartyLobbyType   = errors.New("Parties can't join scrim or drafted lobbies")
+	ErrPartyLobbyState  = errors.New("Parties can only join lobbies which haven't filled up yet")
+	ErrPartySameTeam    = errors.New("Party members need to be on the same team")
+	ErrPartySameSlot    = errors.New("Party members can't pick the same class")
+	ErrPartySlotsFilled = errors.New("Some of the slots picked by the party have been filled")
+)
+
+//AddParty adds the players of a party to the given slots (players[i] is added
+//to slots[i]). Either all players are added, or none of them are. Players in
+//other lobbies which haven't started are removed from them.
+func (lobby *Lobby) AddParty(partyID uint, players []*player.Player, slots []int, password string) error {
+	if lobby.Scrim || lobby.Draft {
+		return ErrPartyLobbyType
+	}
+	if lobby.State != Waiting && lobby.State != Scheduled {
+		return ErrPartyLobbyState
+	}
+
+	var team string
+	taken := make(map[int]bool)
+	var prevLobbies []uint
+	ids := make([]uint, len(players))
+
+	for i, p := range players {
+		slot := slots[i]
+		if slot >= 2*format.NumberOfClassesMap[lobby.Type] || slot < 0 {
+			return ErrBadSlot
+		}
+		if taken[slot] {
+			return ErrPartySameSlot
+		}
+		taken[slot] = true
+
+		slotTeam, _, _ := format.GetSlotTeamClass(lobby.Type, slot)
+		if team != "" && slotTeam != team {
+			return ErrPartySameTeam
+		}
+		team = slotTeam
+
+		if err := lobby.checkPartyMember(p, slot, password); err != nil {
+			return fmt.Errorf("%s: %s", p.Alias(), err.Error())
+		}
+
+		if id, err := p.GetLobbyID(false); err == nil && id != lobby.ID {
+			prev, _ := GetLobbyByID(id)
+			if prev.State == InProgress {
+				return fmt.Errorf("%s is playing in another lobby", p.Alias())
+			}
+			prevLobbies = append(prevLobbies, id)
+		}
+		ids[i] = p.ID
+	}
+
+	lobby.Lock()
+	tx := db.DB.Begin()
+
+	// members already in the lobby might be swapping slots with each other
+	tx.Where("lobby_id = ? AND player_id IN (?)", lobby.ID, ids).Delete(&LobbySlot{})
+
+	var count int
+	tx.Model(&LobbySlot{}).Where("lobby_id = ? AND slot IN (?)", lobby.ID, slots).Count(&count)
+	if count != 0 {
+		tx.Rollback()
+		lobby.Unlock()
+		return ErrPartySlotsFilled
+	}
+
+	if len(prevLobbies) != 0 {
+		tx.Where("player_id IN (?) AND lobby_id IN (?)", ids, prevLobbies).Delete(&LobbySlot{})
+	}
+
+	for i, p := range players {
+		slot := &LobbySlot{
+			PlayerID: p.ID,
+			LobbyID:  lobby.ID,
+			Slot:     slots[i],
+			PartyID:  partyID,
+		}
+		if err := tx.Create(slot).Error; err != nil {
+			tx.Rollback()
+			lobby.Unlock()
+			return err
+		}
+	}
+
+	err := tx.Commit().Error
+	lobby.Unlock()
+	if err != nil {
+		return err
+	}
+
+	for i, p := range players {
+		lobby.RemoveSpectator(p, true)
+		p.SetMumbleUsername(lobby.Type, slots[i])
+		if p.TwitchName != "" {
+			rpc.TwitchBotAnnouce(p.TwitchName, lobby.ID)
+		}
+	}
+	lobby.OnChange(true)
+
+	return nil
+}
+
+//checkPartyMember checks whether the player can join the lobby in the given
+//slot as part of a party
+func (lobby *Lobby) checkPartyMember(p *player.Player, slot int, password string) error {
+	if lobby.IsPlayerBanned(p) {
+		return ErrLobbyBan
+	}
+
+	if lobby.HasSlotRequirement(slot) {
+		if ok, err := lobby.FitsRequirements(p, slot); !ok {
+			return err
+		}
+
+		req, _ := lobby.GetSlotRequirement(slot)
+		if password != req.Password {
+			return ErrInvalidPassword
+		}
+	}
+
+	if !lobby.HasPlayer(p) {
+		return lobby.checkRestrictions(p)
+	}
+	return nil
+}
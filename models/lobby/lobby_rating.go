@@ -0,0 +1,80 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package lobby
+
+import (
+	"math"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/TF2Stadium/Helen/helpers/glicko2"
+	"github.com/TF2Stadium/Helen/models/lobby/format"
+	"github.com/TF2Stadium/Helen/models/player"
+)
+
+//teamRating returns the composite rating of a team, which is used as the opponent
+//for every player on the other team.
+func teamRating(ratings []*player.PlayerRating) glicko2.Rating {
+	var rating, rd float64
+	for _, r := range ratings {
+		rating += r.Rating
+		rd += r.RD * r.RD
+	}
+
+	n := float64(len(ratings))
+	return glicko2.Rating{
+		Rating:     rating / n,
+		RD:         math.Sqrt(rd / n),
+		Volatility: glicko2.DefaultVolatility,
+	}
+}
+
+//UpdateRatings updates the Glicko-2 ratings of all players in the lobby, given
+//the final scores of both teams.
+func (lobby *Lobby) UpdateRatings(redScore, bluScore int) {
+	var red, blu []*player.PlayerRating
+
+	for _, slot := range lobby.GetAllSlots() {
+		if slot.NeedsSub {
+			continue
+		}
+
+		p, err := player.GetPlayerByID(slot.PlayerID)
+		if err != nil {
+			logrus.Error(err)
+			continue
+		}
+
+		team, _, _ := format.GetSlotTeamClass(lobby.Type, slot.Slot)
+		if team == "red" {
+			red = append(red, p.GetRating(lobby.Type))
+		} else {
+			blu = append(blu, p.GetRating(lobby.Type))
+		}
+	}
+
+	if len(red) == 0 || len(blu) == 0 {
+		return
+	}
+
+	var redResult float64
+	switch {
+	case redScore > bluScore:
+		redResult = 1
+	case redScore == bluScore:
+		redResult = 0.5
+	}
+
+	// compute both teams' ratings before updating either of them
+	redRating, bluRating := teamRating(red), teamRating(blu)
+
+	for _, r := range red {
+		r.Update(r.Glicko2().Update([]glicko2.Result{{Opponent: bluRating, Score: redResult}}))
+		r.Save()
+	}
+	for _, r := range blu {
+		r.Update(r.Glicko2().Update([]glicko2.Result{{Opponent: redRating, Score: 1 - redResult}}))
+		r.Save()
+	}
+}
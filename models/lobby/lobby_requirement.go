@@ -18,6 +18,8 @@ type Requirement struct {
 	Hours       int     `json:"hours"`       // minimum hours needed
 	Lobbies     int     `json:"lobbies"`     // minimum lobbies played
 	Reliability float64 `json:"reliability"` // minimum reliability needed
+	MinRating   float64 `json:"minRating"`   // minimum rating needed (0 if none)
+	MaxRating   float64 `json:"maxRating"`   // maximum rating allowed (0 if none)
 	Password    string  `json:"-"`           // Slot password, if any
 }
 
@@ -61,7 +63,6 @@ func (lobby *Lobby) HasRequirements() bool {
 
 //FitsRequirements checks if the player fits the requirement to be added to the given slot in the lobby
 func (l *Lobby) FitsRequirements(player *player.Player, slot int) (bool, error) {
-	//BUG(vibhavp): FitsRequirements doesn't check reliability
 	var req *Requirement
 
 	slotReq, err := l.GetSlotRequirement(slot)
@@ -88,5 +89,19 @@ func (l *Lobby) FitsRequirements(player *player.Player, slot int) (bool, error)
 		return false, ErrReqLobbies
 	}
 
+	if player.Stats.Reliability < req.Reliability {
+		return false, ErrReqReliability
+	}
+
+	if req.MinRating != 0 || req.MaxRating != 0 {
+		rating := player.GetRating(l.Type).Rating
+		if req.MinRating != 0 && rating < req.MinRating {
+			return false, ErrReqMinRating
+		}
+		if req.MaxRating != 0 && rating > req.MaxRating {
+			return false, ErrReqMaxRating
+		}
+	}
+
 	return true, nil
 }
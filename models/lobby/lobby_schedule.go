@@ -0,0 +1,206 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package lobby
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/TF2Stadium/Helen/controllers/broadcaster"
+	db "github.com/TF2Stadium/Helen/database"
+	"github.com/TF2Stadium/Helen/helpers"
+	"github.com/TF2Stadium/Helen/helpers/secret"
+	"github.com/TF2Stadium/Helen/models/chat"
+	"github.com/TF2Stadium/Helen/models/gameserver"
+	"github.com/TF2Stadium/Helen/models/player"
+)
+
+const (
+	//ScheduledSetupTime is how long before the scheduled start the server for
+	//a scheduled lobby is set up
+	ScheduledSetupTime = 10 * time.Minute
+	//MaxScheduleTime is how far in the future lobbies can be scheduled
+	MaxScheduleTime = 14 * 24 * time.Hour
+
+	// how long reservations of rented servers for scheduled lobbies last after the start time
+	scheduledReservationTime = 2 * time.Hour
+)
+
+// reminders are sent to all players in a scheduled lobby these long before it starts
+var reminders = []time.Duration{time.Hour, 15 * time.Minute}
+
+var (
+	ErrScheduleTooSoon = errors.New("Lobbies can only be scheduled atleast 10 minutes in the future")
+	ErrScheduleTooLate = errors.New("Lobbies can only be scheduled upto 2 weeks in the future")
+)
+
+//ScheduledLobbyReady is called after the server for a scheduled lobby has been
+//set up, and the lobby can be readied up.
+var ScheduledLobbyReady = func(*Lobby) {}
+
+var (
+	scheduleMu     = new(sync.Mutex)
+	scheduleTimers = make(map[uint][]*time.Timer)
+)
+
+//CheckScheduleTime returns an error if lobbies can't be scheduled to start at t
+func CheckScheduleTime(t time.Time) error {
+	if t.Sub(time.Now()) < ScheduledSetupTime {
+		return ErrScheduleTooSoon
+	}
+	if t.Sub(time.Now()) > MaxScheduleTime {
+		return ErrScheduleTooLate
+	}
+
+	return nil
+}
+
+//Schedule sets up timers for sending reminders to players and for setting up
+//the server for a scheduled lobby.
+func (lobby *Lobby) Schedule() {
+	lobby.unschedule()
+
+	scheduleMu.Lock()
+	defer scheduleMu.Unlock()
+
+	var timers []*time.Timer
+	for _, before := range reminders {
+		d := lobby.ScheduledFor.Add(-before).Sub(time.Now())
+		if d < 0 {
+			continue
+		}
+
+		minutes := int(before.Minutes())
+		timers = append(timers, time.AfterFunc(d, func() {
+			lobby.sendReminder(minutes)
+		}))
+	}
+
+	// if Helen was down at the time the lobby had to be set up,
+	// this sets it up right away.
+	d := lobby.ScheduledFor.Add(-ScheduledSetupTime).Sub(time.Now())
+	timers = append(timers, time.AfterFunc(d, lobby.startScheduled))
+
+	scheduleTimers[lobby.ID] = timers
+}
+
+func (lobby *Lobby) unschedule() {
+	scheduleMu.Lock()
+	defer scheduleMu.Unlock()
+
+	for _, timer := range scheduleTimers[lobby.ID] {
+		timer.Stop()
+	}
+	delete(scheduleTimers, lobby.ID)
+}
+
+//RestoreScheduledLobbies sets up timers for all scheduled lobbies, used after
+//Helen restarts.
+func RestoreScheduledLobbies() {
+	var lobbies []*Lobby
+	db.DB.Where("state = ?", Scheduled).Find(&lobbies)
+
+	for _, lobby := range lobbies {
+		lobby.Schedule()
+	}
+}
+
+func (lobby *Lobby) sendReminder(minutes int) {
+	if lobby.CurrentState() != Scheduled {
+		return
+	}
+
+	msg := fmt.Sprintf("Lobby starts in %d minutes", minutes)
+	chat.SendNotification(msg, int(lobby.ID))
+	for _, slot := range lobby.GetAllSlots() {
+		p, err := player.GetPlayerByID(slot.PlayerID)
+		if err != nil {
+			continue
+		}
+
+		broadcaster.SendMessage(p.SteamID, "lobbyReminder", struct {
+			ID      uint  `json:"id"`
+			StartAt int64 `json:"startAt"`
+		}{lobby.ID, lobby.ScheduledFor.Unix()})
+	}
+
+	lobby.DiscordNotif(fmt.Sprintf("Starting in %d minutes [%d/%d]", minutes, lobby.GetPlayerNumber(), lobby.RequiredPlayers()))
+}
+
+//startScheduled sets up the server for a scheduled lobby, and opens it for
+//readying up
+func (lobby *Lobby) startScheduled() {
+	lobby.unschedule()
+
+	//get updated lobby object
+	lobby, err := GetLobbyByIDServer(lobby.ID)
+	if err != nil || lobby.State != Scheduled {
+		return
+	}
+
+	if err := lobby.setupScheduledServer(); err != nil {
+		logrus.Error(err)
+		chat.SendNotification("Lobby closed (Couldn't set up the server).", int(lobby.ID))
+		lobby.Close(false, false)
+		return
+	}
+
+	if err := lobby.SetupServer(); err != nil {
+		logrus.Error(err)
+		chat.SendNotification("Lobby closed (Couldn't set up the server).", int(lobby.ID))
+		lobby.Close(false, false)
+		return
+	}
+
+	lobby.SetState(Waiting)
+	chat.SendNotification("The server is ready, lobby is open for readying up.", int(lobby.ID))
+	BroadcastLobby(lobby)
+	BroadcastLobbyList()
+
+	ScheduledLobbyReady(lobby)
+}
+
+//setupScheduledServer reserves the server used by a scheduled lobby from its
+//provider, like taking the stored server or making a serveme reservation.
+func (lobby *Lobby) setupScheduledServer() error {
+	provider, ok := gameserver.GetProvider(lobby.ScheduledServerType)
+	if !ok {
+		// the creator gave the server
+		return nil
+	}
+
+	region, _ := helpers.GetRegion(lobby.ServerInfo.Host)
+	res, err := provider.Reserve(&gameserver.ReserveRequest{
+		ServerID: lobby.ScheduledServerID,
+		Host:     lobby.ServerInfo.Host,
+		Region:   region,
+		SteamID:  lobby.CreatedBySteamID,
+		StartsAt: time.Now(),
+		EndsAt:   lobby.ScheduledFor.Add(scheduledReservationTime),
+	})
+	if err != nil {
+		return err
+	}
+
+	lobby.SetReservation(provider, res)
+	lobby.ServerInfo.Host = res.Host
+	lobby.ServerInfo.RconPassword = secret.String(res.RconPassword)
+	db.DB.Model(&Lobby{}).Where("id = ?", lobby.ID).Updates(map[string]interface{}{
+		"server_provider": lobby.ServerProvider,
+		"reservation_id":  lobby.ReservationID,
+	})
+	if err := db.DB.Save(&lobby.ServerInfo).Error; err != nil {
+		return err
+	}
+
+	if err := provider.Verify(res); err != nil {
+		return err
+	}
+	lobby.WatchReservation()
+	return nil
+}
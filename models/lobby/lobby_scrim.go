@@ -0,0 +1,209 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package lobby
+
+import (
+	"errors"
+
+	db "github.com/TF2Stadium/Helen/database"
+	"github.com/TF2Stadium/Helen/models/lobby/format"
+	"github.com/TF2Stadium/Helen/models/player"
+)
+
+var (
+	ErrNotInvited  = errors.New("You haven't been invited to this team")
+	ErrNotCaptain  = errors.New("You aren't the captain of this team")
+	ErrNotScrim    = errors.New("This lobby isn't a scrim")
+	ErrScrimSwap   = errors.New("Slots can only be swapped within the same team")
+	ErrScrimInGame = errors.New("Slots can't be swapped once the lobby has started")
+)
+
+//ScrimInvite stores an invite to a team in a scrim lobby. Invited players can
+//only occupy slots on the team they've been invited to.
+type ScrimInvite struct {
+	ID      uint `json:"-"`
+	LobbyID uint `json:"-"`
+
+	PlayerID uint   `json:"-"`
+	Team     string `json:"team"` // "red" or "blu"
+}
+
+//GetCaptain returns the steamid of the captain for the given team
+func (lobby *Lobby) GetCaptain(team string) string {
+	switch team {
+	case "red":
+		return lobby.RedCaptainSteamID
+	case "blu":
+		return lobby.BluCaptainSteamID
+	}
+
+	return ""
+}
+
+//TeamName returns the name of the given team
+func (lobby *Lobby) TeamName(team string) string {
+	if team == "blu" {
+		return lobby.BluTeamName
+	}
+	return lobby.RedTeamName
+}
+
+//SetCaptain makes the given player captain of team. The player is invited to
+//the team, so they can take a slot on it.
+func (lobby *Lobby) SetCaptain(team string, p *player.Player) error {
+	switch team {
+	case "red":
+		lobby.RedCaptainSteamID = p.SteamID
+	case "blu":
+		lobby.BluCaptainSteamID = p.SteamID
+	default:
+		return errors.New("team must be red or blu.")
+	}
+
+	if err := lobby.InvitePlayer(team, p); err != nil {
+		return err
+	}
+
+	return db.DB.Model(&Lobby{}).Where("id = ?", lobby.ID).Updates(map[string]interface{}{
+		"red_captain_steam_id": lobby.RedCaptainSteamID,
+		"blu_captain_steam_id": lobby.BluCaptainSteamID,
+	}).Error
+}
+
+//CaptainTeam returns the team the given player is the captain of
+func (lobby *Lobby) CaptainTeam(steamID string) (string, bool) {
+	if !lobby.Scrim || steamID == "" {
+		return "", false
+	}
+
+	switch steamID {
+	case lobby.RedCaptainSteamID:
+		return "red", true
+	case lobby.BluCaptainSteamID:
+		return "blu", true
+	}
+
+	return "", false
+}
+
+//InvitePlayer invites the player to the given team, replacing any invite
+//they had to the other team.
+func (lobby *Lobby) InvitePlayer(team string, p *player.Player) error {
+	if !lobby.Scrim {
+		return ErrNotScrim
+	}
+	if team != "red" && team != "blu" {
+		return errors.New("team must be red or blu.")
+	}
+
+	invite := &ScrimInvite{}
+	err := db.DB.Where("lobby_id = ? AND player_id = ?", lobby.ID, p.ID).First(invite).Error
+	if err != nil {
+		invite = &ScrimInvite{LobbyID: lobby.ID, PlayerID: p.ID}
+	}
+
+	invite.Team = team
+	return db.DB.Save(invite).Error
+}
+
+//UninvitePlayer removes the player's invite to the lobby
+func (lobby *Lobby) UninvitePlayer(p *player.Player) error {
+	return db.DB.Where("lobby_id = ? AND player_id = ?", lobby.ID, p.ID).Delete(&ScrimInvite{}).Error
+}
+
+//GetInvitedTeam returns the team the player has been invited to
+func (lobby *Lobby) GetInvitedTeam(p *player.Player) (string, bool) {
+	invite := &ScrimInvite{}
+	err := db.DB.Where("lobby_id = ? AND player_id = ?", lobby.ID, p.ID).First(invite).Error
+	if err != nil {
+		return "", false
+	}
+
+	return invite.Team, true
+}
+
+//GetInvites returns the steamids of all players invited to the given team
+func (lobby *Lobby) GetInvites(team string) []string {
+	var steamIDs []string
+	db.DB.Table("scrim_invites").
+		Joins("INNER JOIN players ON scrim_invites.player_id = players.id").
+		Where("scrim_invites.lobby_id = ? AND scrim_invites.team = ?", lobby.ID, team).
+		Pluck("players.steam_id", &steamIDs)
+
+	return steamIDs
+}
+
+//canTakeSlot returns an error if the player isn't allowed to take the given
+//slot in a scrim lobby
+func (lobby *Lobby) canTakeSlot(p *player.Player, slot int) error {
+	team, _, err := format.GetSlotTeamClass(lobby.Type, slot)
+	if err != nil {
+		return ErrBadSlot
+	}
+
+	if lobby.GetCaptain(team) == p.SteamID {
+		return nil
+	}
+	if invited, ok := lobby.GetInvitedTeam(p); !ok || invited != team {
+		return ErrNotInvited
+	}
+
+	return nil
+}
+
+//SwapSlots swaps the players occupying the two given slots, which need to be on
+//the same team. Either of the slots can be empty.
+func (lobby *Lobby) SwapSlots(slot1, slot2 int) error {
+	if lobby.State != Waiting && lobby.State != Scheduled {
+		return ErrScrimInGame
+	}
+
+	team1, _, err1 := format.GetSlotTeamClass(lobby.Type, slot1)
+	team2, _, err2 := format.GetSlotTeamClass(lobby.Type, slot2)
+	if err1 != nil || err2 != nil {
+		return ErrBadSlot
+	}
+	if team1 != team2 {
+		return ErrScrimSwap
+	}
+
+	// both players need to fit the requirements of the slot they're moved to,
+	// like they would when joining it
+	for _, swap := range [][2]int{{slot1, slot2}, {slot2, slot1}} {
+		id, err := lobby.GetPlayerIDBySlot(swap[0])
+		if err != nil || !lobby.HasSlotRequirement(swap[1]) {
+			continue
+		}
+		p, err := player.GetPlayerByID(id)
+		if err != nil {
+			return err
+		}
+		if ok, err := lobby.FitsRequirements(p, swap[1]); !ok {
+			return err
+		}
+	}
+
+	lobby.Lock()
+	tx := db.DB.Begin()
+	// slot numbers are unique per lobby, so move the first slot out of the
+	// way before moving the second one into its place
+	tx.Model(&LobbySlot{}).Where("lobby_id = ? AND slot = ?", lobby.ID, slot1).UpdateColumn("slot", -1)
+	tx.Model(&LobbySlot{}).Where("lobby_id = ? AND slot = ?", lobby.ID, slot2).UpdateColumn("slot", slot1)
+	err := tx.Model(&LobbySlot{}).Where("lobby_id = ? AND slot = ?", lobby.ID, -1).UpdateColumn("slot", slot2).Error
+	if err != nil {
+		tx.Rollback()
+		lobby.Unlock()
+		return err
+	}
+	err = tx.Commit().Error
+	lobby.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	lobby.OnChange(true)
+	return nil
+}
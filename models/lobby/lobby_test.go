@@ -6,6 +6,7 @@ package lobby_test
 
 import (
 	"testing"
+	"time"
 
 	db "github.com/TF2Stadium/Helen/database"
 	_ "github.com/TF2Stadium/Helen/helpers"
@@ -14,6 +15,7 @@ import (
 	"github.com/TF2Stadium/Helen/models/gameserver"
 	. "github.com/TF2Stadium/Helen/models/lobby"
 	"github.com/TF2Stadium/Helen/models/lobby/format"
+	"github.com/TF2Stadium/Helen/models/match"
 	. "github.com/TF2Stadium/Helen/models/player"
 	"github.com/TF2Stadium/PlayerStatsScraper/steamid"
 	"github.com/TF2Stadium/logstf"
@@ -580,3 +582,324 @@ func TestUpdateHours(t *testing.T) {
 	assert.Equal(t, logsID, lobby.LogstfID)
 	//TODO: check player.Stats for updated hours
 }
+
+func TestRecordLog(t *testing.T) {
+	t.Parallel()
+	lobby := testhelpers.CreateLobby()
+	defer lobby.Close(false, true)
+	assert.NotEmpty(t, lobby.ServerInfo.LogSecret)
+
+	red, blu := testhelpers.CreatePlayer(), testhelpers.CreatePlayer()
+	require.NoError(t, lobby.AddPlayer(red, 0, ""))
+	require.NoError(t, lobby.AddPlayer(blu, 6, ""))
+
+	result := &match.Result{
+		RedScore: 3,
+		BluScore: 1,
+		Length:   1800,
+		Players: map[string]*match.PlayerStats{
+			red.SteamID: {Team: "red", Kills: 10, Classes: []*match.ClassStats{{Class: "scout", Seconds: 1800, Kills: 10}}},
+			blu.SteamID: {Team: "blu", Deaths: 10, Classes: []*match.ClassStats{{Class: "scout", Seconds: 1800}}},
+		},
+	}
+
+	require.NoError(t, lobby.RecordLog(result))
+	assert.Equal(t, ErrStatsRecorded, lobby.RecordLog(result))
+
+	lobby, _ = GetLobbyByID(lobby.ID)
+	assert.Equal(t, StatsFromLog, lobby.StatsSource)
+	assert.Equal(t, 3, lobby.RedScore)
+
+	stats := GetMatchStats(lobby.ID)
+	require.Len(t, stats, 2)
+	assert.Equal(t, 1, red.GetRating(lobby.Type).Matches)
+
+	// stats from logs.tf aren't recorded again
+	assert.NoError(t, lobby.UpdateHours(1000928))
+	assert.Len(t, GetMatchStats(lobby.ID), 2)
+}
+
+func TestUpdateRatings(t *testing.T) {
+	t.Parallel()
+	lobby := testhelpers.CreateLobby()
+	defer lobby.Close(false, true)
+
+	var players []*Player
+	for i := 0; i < 12; i++ {
+		player := testhelpers.CreatePlayer()
+		require.NoError(t, lobby.AddPlayer(player, i, ""))
+		players = append(players, player)
+	}
+
+	lobby.UpdateRatings(5, 2)
+
+	for i, player := range players {
+		rating := player.GetRating(lobby.Type)
+		assert.Equal(t, 1, rating.Matches)
+		if i < 6 { // red
+			assert.True(t, rating.Rating > 1500)
+		} else {
+			assert.True(t, rating.Rating < 1500)
+		}
+	}
+}
+
+func TestRatingRequirements(t *testing.T) {
+	t.Parallel()
+	lobby := testhelpers.CreateLobby()
+	defer lobby.Close(false, true)
+	player := testhelpers.CreatePlayer()
+	player.GameHours = 200
+	player.Save()
+
+	req := &Requirement{
+		LobbyID:   lobby.ID,
+		Slot:      0,
+		MinRating: 1600,
+	}
+	req.Save()
+
+	err := lobby.AddPlayer(player, 0, "")
+	assert.Equal(t, ErrReqMinRating, err)
+
+	req.MinRating = 0
+	req.MaxRating = 1400
+	req.Save()
+
+	err = lobby.AddPlayer(player, 0, "")
+	assert.Equal(t, ErrReqMaxRating, err)
+
+	req.MaxRating = 1600
+	req.Save()
+
+	err = lobby.AddPlayer(player, 0, "")
+	assert.NoError(t, err)
+}
+
+func TestReliabilityRequirement(t *testing.T) {
+	t.Parallel()
+	lobby := testhelpers.CreateLobby()
+	defer lobby.Close(false, true)
+	lobby2 := testhelpers.CreateLobby()
+	defer lobby2.Close(false, true)
+
+	player := testhelpers.CreatePlayer()
+	player.GameHours = 200
+	player.Save()
+	player.NewReport(Substitute, lobby2.ID)
+
+	req := &Requirement{
+		LobbyID:     lobby.ID,
+		Slot:        0,
+		Reliability: 0.5,
+	}
+	req.Save()
+
+	err := lobby.AddPlayer(player, 0, "")
+	assert.Equal(t, ErrReqReliability, err)
+
+	req.Reliability = 0
+	req.Save()
+
+	err = lobby.AddPlayer(player, 0, "")
+	assert.NoError(t, err)
+}
+
+func TestScheduledLobby(t *testing.T) {
+	t.Parallel()
+	lobby := testhelpers.CreateLobby()
+
+	assert.Equal(t, ErrScheduleTooSoon, CheckScheduleTime(time.Now().Add(time.Minute)))
+	assert.Equal(t, ErrScheduleTooLate, CheckScheduleTime(time.Now().Add(MaxScheduleTime+time.Hour)))
+	require.NoError(t, CheckScheduleTime(time.Now().Add(time.Hour)))
+
+	lobby.ScheduledFor = time.Now().Add(time.Hour)
+	lobby.ScheduledServerType = "server"
+	lobby.Save()
+	lobby.SetState(Scheduled)
+	lobby.Schedule()
+
+	player := testhelpers.CreatePlayer()
+	require.NoError(t, lobby.AddPlayer(player, 0, ""))
+
+	var found bool
+	for _, l := range GetWaitingLobbies() {
+		if l.ID == lobby.ID {
+			found = true
+		}
+	}
+	assert.True(t, found, "scheduled lobbies should be listed")
+
+	lobby.Close(false, false)
+	assert.Equal(t, Ended, lobby.CurrentState())
+}
+
+func TestScrimLobby(t *testing.T) {
+	t.Parallel()
+	lobby := testhelpers.CreateLobby()
+	defer lobby.Close(false, true)
+
+	lobby.Scrim = true
+	lobby.Save()
+
+	red := testhelpers.CreatePlayer()
+	blu := testhelpers.CreatePlayer()
+	require.NoError(t, lobby.SetCaptain("red", red))
+	require.NoError(t, lobby.SetCaptain("blu", blu))
+
+	team, ok := lobby.CaptainTeam(blu.SteamID)
+	assert.True(t, ok)
+	assert.Equal(t, "blu", team)
+
+	// captains can only take slots on their own team
+	assert.Equal(t, ErrNotInvited, lobby.AddPlayer(red, 6, ""))
+	require.NoError(t, lobby.AddPlayer(red, 0, ""))
+
+	player := testhelpers.CreatePlayer()
+	assert.Equal(t, ErrNotInvited, lobby.AddPlayer(player, 1, ""))
+
+	require.NoError(t, lobby.InvitePlayer("blu", player))
+	assert.Contains(t, lobby.GetInvites("blu"), player.SteamID)
+	assert.Equal(t, ErrNotInvited, lobby.AddPlayer(player, 1, ""))
+	require.NoError(t, lobby.AddPlayer(player, 7, ""))
+
+	require.NoError(t, lobby.SwapSlots(7, 8))
+	slot, err := lobby.GetPlayerSlot(player)
+	require.NoError(t, err)
+	assert.Equal(t, 8, slot)
+
+	// players can't be swapped into slots they don't fit the requirements of
+	player.GameHours = 200
+	player.Save()
+	req := &Requirement{
+		LobbyID:   lobby.ID,
+		Slot:      9,
+		MinRating: 100000,
+	}
+	req.Save()
+	assert.Equal(t, ErrReqMinRating, lobby.SwapSlots(8, 9))
+	assert.Equal(t, ErrReqMinRating, lobby.SwapSlots(9, 8))
+
+	assert.Equal(t, ErrScrimSwap, lobby.SwapSlots(0, 8))
+	assert.Error(t, lobby.ShuffleAllSlots())
+
+	require.NoError(t, lobby.UninvitePlayer(player))
+	_, ok = lobby.GetInvitedTeam(player)
+	assert.False(t, ok)
+}
+
+func TestDraftLobby(t *testing.T) {
+	t.Parallel()
+	lobby := NewLobby("koth_ultiduo", format.Ultiduo, "etf2l", gameserver.ServerRecord{}, "0", false, "")
+	lobby.Draft = true
+	lobby.DraftCaptainMode = "rating"
+	lobby.State = Waiting
+	lobby.Save()
+	lobby.CreateLock()
+	defer lobby.Close(false, true)
+
+	var players []*Player
+	for i := 0; i < 4; i++ {
+		p := testhelpers.CreatePlayer()
+		require.NoError(t, lobby.JoinDraftPool(p))
+		players = append(players, p)
+	}
+	assert.Equal(t, ErrDraftStarted, lobby.JoinDraftPool(testhelpers.CreatePlayer()))
+
+	lobby, _ = GetLobbyByID(lobby.ID)
+	require.Equal(t, Drafting, lobby.State)
+	// everyone has the same rating, so the first players to join are the captains
+	assert.Equal(t, players[0].SteamID, lobby.RedCaptainSteamID)
+	assert.Equal(t, players[1].SteamID, lobby.BluCaptainSteamID)
+	assert.Equal(t, ErrNotVoting, lobby.VoteCaptain(players[2], players[3]))
+
+	assert.Equal(t, "red", lobby.DraftTurn())
+	assert.Equal(t, ErrNotYourTurn, lobby.PickPlayer(players[1], players[2], "soldier"))
+	assert.Equal(t, ErrOtherCaptain, lobby.PickPlayer(players[0], players[1], "soldier"))
+	require.NoError(t, lobby.PickPlayer(players[0], players[2], "soldier"))
+	assert.Equal(t, ErrAlreadyPicked, lobby.PickPlayer(players[1], players[2], "soldier"))
+
+	// snake order, BLU picks twice
+	require.NoError(t, lobby.PickPlayer(players[1], players[3], "soldier"))
+	require.NoError(t, lobby.PickPlayer(players[1], players[1], "medic"))
+	require.NoError(t, lobby.PickPlayer(players[0], players[0], "medic"))
+
+	lobby, _ = GetLobbyByID(lobby.ID)
+	assert.Equal(t, Waiting, lobby.State)
+	assert.Equal(t, 4, lobby.GetPlayerNumber())
+
+	slot, err := lobby.GetPlayerSlot(players[3])
+	require.NoError(t, err)
+	assert.Equal(t, 2, slot)
+}
+
+func TestDraftPoolOtherLobby(t *testing.T) {
+	t.Parallel()
+	var lobbies []*Lobby
+	for i := 0; i < 2; i++ {
+		lobby := NewLobby("koth_ultiduo", format.Ultiduo, "etf2l", gameserver.ServerRecord{}, "0", false, "")
+		lobby.Draft = true
+		lobby.State = Waiting
+		lobby.Save()
+		lobby.CreateLock()
+		defer lobby.Close(false, true)
+		lobbies = append(lobbies, lobby)
+	}
+
+	p := testhelpers.CreatePlayer()
+	require.NoError(t, lobbies[0].JoinDraftPool(p))
+	assert.Equal(t, []uint{lobbies[0].ID}, GetDraftPoolLobbyIDs(p))
+	assert.Equal(t, ErrInOtherPool, lobbies[1].JoinDraftPool(p))
+
+	require.NoError(t, lobbies[0].LeaveDraftPool(p))
+	assert.Empty(t, GetDraftPoolLobbyIDs(p))
+
+	other := NewLobby("cp_badlands", format.Sixes, "etf2l", gameserver.ServerRecord{}, "0", false, "")
+	other.Save()
+	other.CreateLock()
+	defer other.Close(false, true)
+	require.NoError(t, other.AddPlayer(p, 0, ""))
+	assert.Equal(t, ErrInLobby, lobbies[1].JoinDraftPool(p))
+}
+
+func TestMapVote(t *testing.T) {
+	t.Parallel()
+	lobby := NewLobby("koth_ultiduo", format.Ultiduo, "etf2l", gameserver.ServerRecord{}, "0", false, "")
+	lobby.MapPool = "koth_ultiduo,ultiduo_baloo,ultiduo_grove_b4"
+	lobby.State = Waiting
+	lobby.Save()
+	lobby.CreateLock()
+	defer lobby.Close(false, true)
+
+	var players []*Player
+	for i := 0; i < 4; i++ {
+		p := testhelpers.CreatePlayer()
+		require.NoError(t, lobby.AddPlayer(p, i, ""))
+		players = append(players, p)
+	}
+	assert.Equal(t, ErrNotVotingMap, lobby.VoteMap(players[0], "ultiduo_baloo"))
+
+	require.True(t, lobby.StartMapVote())
+	lobby, _ = GetLobbyByID(lobby.ID)
+	require.Equal(t, MapVoting, lobby.State)
+
+	assert.Equal(t, ErrMapNotInPool, lobby.VoteMap(players[0], "cp_badlands"))
+	assert.Equal(t, ErrVoteNotInSlot, lobby.VoteMap(testhelpers.CreatePlayer(), "ultiduo_baloo"))
+
+	require.NoError(t, lobby.VoteMap(players[0], "ultiduo_grove_b4"))
+	// players can change their vote
+	require.NoError(t, lobby.VoteMap(players[0], "ultiduo_baloo"))
+	require.NoError(t, lobby.VoteMap(players[1], "ultiduo_baloo"))
+	require.NoError(t, lobby.VoteMap(players[2], "koth_ultiduo"))
+	assert.Equal(t, []MapTally{{"koth_ultiduo", 1}, {"ultiduo_baloo", 2}, {"ultiduo_grove_b4", 0}}, lobby.MapVoteTally())
+
+	// the vote ends once everyone has voted
+	require.NoError(t, lobby.VoteMap(players[3], "koth_ultiduo"))
+	lobby, _ = GetLobbyByID(lobby.ID)
+	assert.Equal(t, Waiting, lobby.State)
+	// ties go to the map which comes first in the pool
+	assert.Equal(t, "koth_ultiduo", lobby.MapName)
+
+	// players only vote once
+	assert.False(t, lobby.StartMapVote())
+}
@@ -0,0 +1,48 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package lobby
+
+import (
+	db "github.com/TF2Stadium/Helen/database"
+	"github.com/TF2Stadium/Helen/models/player"
+	"github.com/TF2Stadium/Helen/models/webhook"
+)
+
+type webhookData struct {
+	Lobby   LobbyData `json:"lobby"`
+	SteamID string    `json:"steamid,omitempty"` // for playerSubstituted
+	LogsID  int       `json:"logsID,omitempty"`  // for matchEnded
+}
+
+// webhookPlayers returns the IDs of players whose webhooks receive events for
+// the lobby, the lobby's creator and all players in it
+func (lobby *Lobby) webhookPlayers() []uint {
+	var ids []uint
+	db.DB.Model(&LobbySlot{}).Where("lobby_id = ?", lobby.ID).Pluck("player_id", &ids)
+
+	if lobby.CreatedBySteamID != "" {
+		if p, err := player.GetPlayerBySteamID(lobby.CreatedBySteamID); err == nil {
+			ids = append(ids, p.ID)
+		}
+	}
+
+	return ids
+}
+
+func (lobby *Lobby) sendWebhook(event string, data webhookData) {
+	data.Lobby = DecorateLobbyData(lobby, true)
+	webhook.Send(event, lobby.webhookPlayers(), data)
+}
+
+//SendWebhook sends the given event for the lobby to webhooks
+func (lobby *Lobby) SendWebhook(event string) {
+	lobby.sendWebhook(event, webhookData{})
+}
+
+//SendMatchEndedWebhook sends the matchEnded event, with the logs.tf ID for the
+//match, to webhooks
+func (lobby *Lobby) SendMatchEndedWebhook(logsID int) {
+	lobby.sendWebhook(webhook.MatchEnded, webhookData{LogsID: logsID})
+}
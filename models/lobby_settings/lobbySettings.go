@@ -8,28 +8,29 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"sync"
 
 	"github.com/TF2Stadium/Helen/assets"
 	"github.com/bitly/go-simplejson"
 )
 
 type LobbyFormat struct {
-	//gorm.Model -- not in a database yet
+	// stored in the database as StoredFormat
 	Name       string
 	PrettyName string
 	Important  bool
 }
 
 type LobbyMapFormat struct {
-	//gorm.Model -- not in a database yet
+	// stored in the database as StoredMapFormat
 	Format     *LobbyFormat
 	Importance int
 }
 
 type LobbyMap struct {
-	//gorm.Model -- not in a database yet
+	// stored in the database as StoredMap
 	Name    string
-	Formats []*LobbyMapFormat `gorm:"many2many:lobby_map_formats"`
+	Formats []*LobbyMapFormat
 }
 
 func (m *LobbyMap) GetFormat(formatName string) (*LobbyMapFormat, bool) {
@@ -51,27 +52,27 @@ func (m *LobbyMap) GetFormat(formatName string) (*LobbyMapFormat, bool) {
 type MapType string
 
 type LobbyLeagueDescription struct {
-	//gorm.Model -- not in a database yet
+	// stored in the database as StoredLeagueDescription
 	MapType     MapType
 	Description string
 }
 
 type LobbyLeagueFormat struct {
-	//gorm.Model -- not in a database yet
+	// stored in the database as StoredLeagueFormat
 	Format *LobbyFormat
 	Used   bool
 }
 
 type LobbyLeague struct {
-	//gorm.Model -- not in a database yet
+	// stored in the database as StoredLeague
 	Name         string
 	PrettyName   string
-	Descriptions []*LobbyLeagueDescription `gorm:"many2many:lobby_league_descriptions"`
-	Formats      []*LobbyLeagueFormat      `gorm:"many2many:lobby_league_formats"`
+	Descriptions []*LobbyLeagueDescription
+	Formats      []*LobbyLeagueFormat
 }
 
 type LobbyWhitelist struct {
-	//gorm.Model -- not in a database yet
+	// stored in the database as StoredWhitelist
 	ID         int
 	PrettyName string
 	League     *LobbyLeague
@@ -90,7 +91,14 @@ var lobbyLeagueFromName map[string]int
 var LobbyWhitelists []LobbyWhitelist
 var lobbyWhitelistFromID map[int]int
 
+//settingsMu guards the settings above, which are replaced as a whole when
+//they're changed, and never modified in place
+var settingsMu sync.RWMutex
+
 func GetLobbyFormat(formatName string) (*LobbyFormat, bool) {
+	settingsMu.RLock()
+	defer settingsMu.RUnlock()
+
 	if format, ok := lobbyFormatFromName[formatName]; ok {
 		return &LobbyFormats[format], true
 	}
@@ -98,6 +106,9 @@ func GetLobbyFormat(formatName string) (*LobbyFormat, bool) {
 }
 
 func GetLobbyMap(mapName string) (*LobbyMap, bool) {
+	settingsMu.RLock()
+	defer settingsMu.RUnlock()
+
 	if amap, ok := lobbyMapFromName[mapName]; ok {
 		return &LobbyMaps[amap], true
 	}
@@ -105,6 +116,9 @@ func GetLobbyMap(mapName string) (*LobbyMap, bool) {
 }
 
 func GetLobbyLeague(leagueName string) (*LobbyLeague, bool) {
+	settingsMu.RLock()
+	defer settingsMu.RUnlock()
+
 	if league, ok := lobbyLeagueFromName[leagueName]; ok {
 		return &LobbyLeagues[league], true
 	}
@@ -112,86 +126,175 @@ func GetLobbyLeague(leagueName string) (*LobbyLeague, bool) {
 }
 
 func GetLobbyWhitelist(whitelistId int) (*LobbyWhitelist, bool) {
+	settingsMu.RLock()
+	defer settingsMu.RUnlock()
+
 	if whitelist, ok := lobbyWhitelistFromID[whitelistId]; ok {
 		return &LobbyWhitelists[whitelist], true
 	}
 	return nil, false
 }
 
+//GetLobbyFormats returns all formats
+func GetLobbyFormats() []LobbyFormat {
+	settingsMu.RLock()
+	defer settingsMu.RUnlock()
+	return LobbyFormats
+}
+
+//GetLobbyMaps returns all maps
+func GetLobbyMaps() []LobbyMap {
+	settingsMu.RLock()
+	defer settingsMu.RUnlock()
+	return LobbyMaps
+}
+
+//GetLobbyLeagues returns all leagues
+func GetLobbyLeagues() []LobbyLeague {
+	settingsMu.RLock()
+	defer settingsMu.RUnlock()
+	return LobbyLeagues
+}
+
+//GetLobbyWhitelists returns all whitelists
+func GetLobbyWhitelists() []LobbyWhitelist {
+	settingsMu.RLock()
+	defer settingsMu.RUnlock()
+	return LobbyWhitelists
+}
+
 func LoadLobbySettingsFromFile(fileName string) error {
 	data := assets.MustAsset(fileName)
 	return LoadLobbySettings(data)
 }
 
+//settingsData is the JSON format of lobby settings, used by
+//assets/lobbySettingsData.json
+type settingsData struct {
+	Formats    []formatData    `json:"formats"`
+	Maps       []mapData       `json:"maps"`
+	Leagues    []leagueData    `json:"leagues"`
+	Whitelists []whitelistData `json:"whitelists"`
+}
+
+type formatData struct {
+	Name       string `json:"name"`
+	PrettyName string `json:"prettyName"`
+	Important  bool   `json:"important"`
+}
+
+type mapData struct {
+	Name    string         `json:"name"`
+	Formats map[string]int `json:"formats"`
+}
+
+type leagueData struct {
+	Name         string            `json:"name"`
+	PrettyName   string            `json:"prettyName"`
+	Descriptions map[string]string `json:"descriptions"`
+	Formats      map[string]bool   `json:"formats"`
+}
+
+type whitelistData struct {
+	ID         int    `json:"id"`
+	PrettyName string `json:"prettyName"`
+	League     string `json:"league"`
+	Format     string `json:"format"`
+}
+
 func LoadLobbySettings(data []byte) error {
-	var args struct {
-		Formats []struct {
-			Name       string `json:"name"`
-			PrettyName string `json:"prettyName"`
-			Important  bool   `json:"important"`
-		} `json:"formats"`
-		Maps []struct {
-			Name    string         `json:"name"`
-			Formats map[string]int `json:"formats"`
-		} `json:"maps"`
-		Leagues []struct {
-			Name         string            `json:"name"`
-			PrettyName   string            `json:"prettyName"`
-			Descriptions map[string]string `json:"descriptions"`
-			Formats      map[string]bool   `json:"formats"`
-		} `json:"leagues"`
-		Whitelists []struct {
-			ID         int    `json:"id"`
-			PrettyName string `json:"prettyName"`
-			League     string `json:"league"`
-			Format     string `json:"format"`
-		} `json:"whitelists"`
+	var args settingsData
+	err := json.Unmarshal(data, &args)
+	if err != nil {
+		return err
 	}
 
-	err := json.Unmarshal(data, &args)
+	s, err := newSettings(&args)
 	if err != nil {
 		return err
 	}
+	s.apply()
+	return nil
+}
+
+//settings is a complete set of lobby settings, built by newSettings
+type settings struct {
+	formats             []LobbyFormat
+	lobbyFormatFromName map[string]int
+
+	maps             []LobbyMap
+	lobbyMapFromName map[string]int
+
+	leagues             []LobbyLeague
+	lobbyLeagueFromName map[string]int
+
+	whitelists           []LobbyWhitelist
+	lobbyWhitelistFromID map[int]int
+}
+
+//newSettings builds lobby settings from args, and returns an error if
+//they aren't valid
+func newSettings(args *settingsData) (*settings, error) {
+	s := &settings{}
+
+	getFormat := func(name string) (*LobbyFormat, bool) {
+		if i, ok := s.lobbyFormatFromName[name]; ok {
+			return &s.formats[i], true
+		}
+		return nil, false
+	}
 
 	// formats
-	LobbyFormats = make([]LobbyFormat, len(args.Formats))
-	lobbyFormatFromName = make(map[string]int)
+	s.formats = make([]LobbyFormat, len(args.Formats))
+	s.lobbyFormatFromName = make(map[string]int)
 	for i, format := range args.Formats {
-		LobbyFormats[i] = LobbyFormat{
+		if _, ok := s.lobbyFormatFromName[format.Name]; ok {
+			return nil, fmt.Errorf("Format %q exists more than once", format.Name)
+		}
+
+		s.formats[i] = LobbyFormat{
 			Name:       format.Name,
 			PrettyName: format.PrettyName,
 			Important:  format.Important,
 		}
-		lobbyFormatFromName[format.Name] = i
+		s.lobbyFormatFromName[format.Name] = i
 	}
 
 	// maps
-	LobbyMaps = make([]LobbyMap, len(args.Maps))
-	lobbyMapFromName = make(map[string]int)
+	s.maps = make([]LobbyMap, len(args.Maps))
+	s.lobbyMapFromName = make(map[string]int)
 	for i, amap := range args.Maps {
+		if _, ok := s.lobbyMapFromName[amap.Name]; ok {
+			return nil, fmt.Errorf("Map %q exists more than once", amap.Name)
+		}
+
 		lobbyMap := LobbyMap{
 			Name:    amap.Name,
 			Formats: make([]*LobbyMapFormat, 0, len(amap.Formats)),
 		}
 		for name, importance := range amap.Formats {
-			if lobbyFormat, ok := GetLobbyFormat(name); ok {
+			if lobbyFormat, ok := getFormat(name); ok {
 				lobbyMap.Formats = append(lobbyMap.Formats, &LobbyMapFormat{
 					Format:     lobbyFormat,
 					Importance: importance,
 				})
 			} else {
-				return errors.New(fmt.Sprintf("Referenced a non existing format %q", name))
+				return nil, errors.New(fmt.Sprintf("Referenced a non existing format %q", name))
 			}
 		}
 
-		LobbyMaps[i] = lobbyMap
-		lobbyMapFromName[amap.Name] = i
+		s.maps[i] = lobbyMap
+		s.lobbyMapFromName[amap.Name] = i
 	}
 
 	// leagues
-	LobbyLeagues = make([]LobbyLeague, len(args.Leagues))
-	lobbyLeagueFromName = make(map[string]int)
+	s.leagues = make([]LobbyLeague, len(args.Leagues))
+	s.lobbyLeagueFromName = make(map[string]int)
 	for i, league := range args.Leagues {
+		if _, ok := s.lobbyLeagueFromName[league.Name]; ok {
+			return nil, fmt.Errorf("League %q exists more than once", league.Name)
+		}
+
 		lobbyLeague := LobbyLeague{
 			Name:         league.Name,
 			PrettyName:   league.PrettyName,
@@ -206,48 +309,66 @@ func LoadLobbySettings(data []byte) error {
 			lobbyLeague.Descriptions = append(lobbyLeague.Descriptions, lobbyLeagueDescription)
 		}
 		for name, used := range league.Formats {
-			if lobbyFormat, ok := GetLobbyFormat(name); ok {
+			if lobbyFormat, ok := getFormat(name); ok {
 				lobbyLeagueFormat := &LobbyLeagueFormat{
 					Format: lobbyFormat,
 					Used:   used,
 				}
 				lobbyLeague.Formats = append(lobbyLeague.Formats, lobbyLeagueFormat)
 			} else {
-				return errors.New(fmt.Sprintf("Referenced a non existing format %q", name))
+				return nil, errors.New(fmt.Sprintf("Referenced a non existing format %q", name))
 			}
 		}
 
-		LobbyLeagues[i] = lobbyLeague
-		lobbyLeagueFromName[league.Name] = i
+		s.leagues[i] = lobbyLeague
+		s.lobbyLeagueFromName[league.Name] = i
 	}
 
 	// whitelists
-	LobbyWhitelists = make([]LobbyWhitelist, len(args.Whitelists))
-	lobbyWhitelistFromID = make(map[int]int)
+	s.whitelists = make([]LobbyWhitelist, len(args.Whitelists))
+	s.lobbyWhitelistFromID = make(map[int]int)
 	for i, whitelist := range args.Whitelists {
-		if lobbyLeague, ok := GetLobbyLeague(whitelist.League); ok {
-			if lobbyFormat, ok := GetLobbyFormat(whitelist.Format); ok {
+		if _, ok := s.lobbyWhitelistFromID[whitelist.ID]; ok {
+			return nil, fmt.Errorf("Whitelist %d exists more than once", whitelist.ID)
+		}
+
+		if l, ok := s.lobbyLeagueFromName[whitelist.League]; ok {
+			if lobbyFormat, ok := getFormat(whitelist.Format); ok {
 				lobbyWhitelist := LobbyWhitelist{
 					ID:         whitelist.ID,
 					PrettyName: whitelist.PrettyName,
-					League:     lobbyLeague,
+					League:     &s.leagues[l],
 					Format:     lobbyFormat,
 				}
 
-				LobbyWhitelists[i] = lobbyWhitelist
-				lobbyWhitelistFromID[whitelist.ID] = i
+				s.whitelists[i] = lobbyWhitelist
+				s.lobbyWhitelistFromID[whitelist.ID] = i
 			} else {
-				return errors.New(fmt.Sprintf("Referenced a non existing format %q", whitelist.Format))
+				return nil, errors.New(fmt.Sprintf("Referenced a non existing format %q", whitelist.Format))
 			}
 		} else {
-			return errors.New(fmt.Sprintf("Referenced a non existing league %q", whitelist.League))
+			return nil, errors.New(fmt.Sprintf("Referenced a non existing league %q", whitelist.League))
 		}
 	}
 
-	return nil
+	return s, nil
+}
+
+//apply makes s the current lobby settings
+func (s *settings) apply() {
+	settingsMu.Lock()
+	defer settingsMu.Unlock()
+
+	LobbyFormats, lobbyFormatFromName = s.formats, s.lobbyFormatFromName
+	LobbyMaps, lobbyMapFromName = s.maps, s.lobbyMapFromName
+	LobbyLeagues, lobbyLeagueFromName = s.leagues, s.lobbyLeagueFromName
+	LobbyWhitelists, lobbyWhitelistFromID = s.whitelists, s.lobbyWhitelistFromID
 }
 
 func LobbySettingsToJSON() *simplejson.Json {
+	settingsMu.RLock()
+	defer settingsMu.RUnlock()
+
 	j := simplejson.New()
 
 	// formats
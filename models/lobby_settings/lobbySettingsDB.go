@@ -0,0 +1,536 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package lobbySettings
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/TF2Stadium/Helen/assets"
+	db "github.com/TF2Stadium/Helen/database"
+	"github.com/jinzhu/gorm"
+)
+
+//StoredFormat is a lobby format stored in the database
+type StoredFormat struct {
+	ID         uint   `gorm:"primary_key"`
+	Name       string `sql:"not null;unique"`
+	PrettyName string
+	Important  bool
+}
+
+//StoredMap is a map stored in the database, with its importance for each format
+type StoredMap struct {
+	ID      uint              `gorm:"primary_key"`
+	Name    string            `sql:"not null;unique"`
+	Formats []StoredMapFormat `gorm:"ForeignKey:MapID"`
+}
+
+type StoredMapFormat struct {
+	ID         uint `gorm:"primary_key"`
+	MapID      uint `sql:"not null"`
+	Format     string
+	Importance int
+}
+
+//StoredLeague is a league stored in the database
+type StoredLeague struct {
+	ID           uint   `gorm:"primary_key"`
+	Name         string `sql:"not null;unique"`
+	PrettyName   string
+	Descriptions []StoredLeagueDescription `gorm:"ForeignKey:LeagueID"`
+	Formats      []StoredLeagueFormat      `gorm:"ForeignKey:LeagueID"`
+}
+
+type StoredLeagueDescription struct {
+	ID          uint `gorm:"primary_key"`
+	LeagueID    uint `sql:"not null"`
+	MapType     string
+	Description string
+}
+
+type StoredLeagueFormat struct {
+	ID       uint `gorm:"primary_key"`
+	LeagueID uint `sql:"not null"`
+	Format   string
+	Used     bool
+}
+
+//StoredWhitelist is a whitelist stored in the database
+type StoredWhitelist struct {
+	ID          uint `gorm:"primary_key"`
+	WhitelistID int  `sql:"not null;unique"` // whitelist.tf ID
+	PrettyName  string
+	League      string
+	Format      string
+}
+
+func (StoredFormat) TableName() string {
+	return "lobby_settings_formats"
+}
+
+func (StoredMap) TableName() string {
+	return "lobby_settings_maps"
+}
+
+func (StoredMapFormat) TableName() string {
+	return "lobby_settings_map_formats"
+}
+
+func (StoredLeague) TableName() string {
+	return "lobby_settings_leagues"
+}
+
+func (StoredLeagueDescription) TableName() string {
+	return "lobby_settings_league_descriptions"
+}
+
+func (StoredLeagueFormat) TableName() string {
+	return "lobby_settings_league_formats"
+}
+
+func (StoredWhitelist) TableName() string {
+	return "lobby_settings_whitelists"
+}
+
+//Change is an entry in the audit trail of changes made to lobby settings
+type Change struct {
+	ID        uint `gorm:"primary_key"`
+	CreatedAt time.Time
+	PlayerID  uint // admin who made the change
+
+	Kind   string // "format", "map", "league" or "whitelist"
+	Name   string // name of the changed format, map or league, or the whitelist ID
+	Action string // "add", "update" or "remove"
+	Old    string // JSON, as in assets/lobbySettingsData.json, empty when added
+	New    string // empty when removed
+}
+
+func (Change) TableName() string {
+	return "lobby_settings_changes"
+}
+
+var (
+	ErrNotFound = errors.New("That setting doesn't exist")
+
+	reFormatName = regexp.MustCompile(`^[a-z0-9-]+$`)
+	reMapName    = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+)
+
+//editMu serializes changes, so that every change is validated against the
+//settings it's applied to
+var editMu sync.Mutex
+
+//InitLobbySettings loads the lobby settings stored in the database. If there
+//aren't any, they're seeded from the given JSON file first.
+func InitLobbySettings(seedFile string) error {
+	editMu.Lock()
+	defer editMu.Unlock()
+
+	var count int
+	db.DB.Model(&StoredFormat{}).Count(&count)
+	if count == 0 {
+		var data settingsData
+		if err := json.Unmarshal(assets.MustAsset(seedFile), &data); err != nil {
+			return err
+		}
+		if err := seed(&data); err != nil {
+			return err
+		}
+		logrus.Info("Seeded lobby settings from ", seedFile)
+	}
+
+	data := readDB()
+	s, err := newSettings(data)
+	if err != nil {
+		return err
+	}
+	s.apply()
+	return nil
+}
+
+func seed(data *settingsData) error {
+	if _, err := newSettings(data); err != nil {
+		return err
+	}
+
+	tx := db.DB.Begin()
+	for i := range data.Formats {
+		if err := saveFormat(tx, &data.Formats[i]); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	for i := range data.Maps {
+		if err := saveMap(tx, &data.Maps[i]); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	for i := range data.Leagues {
+		if err := saveLeague(tx, &data.Leagues[i]); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	for i := range data.Whitelists {
+		if err := saveWhitelist(tx, &data.Whitelists[i]); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit().Error
+}
+
+//readDB returns the settings stored in the database
+func readDB() *settingsData {
+	data := &settingsData{}
+
+	var formats []StoredFormat
+	db.DB.Order("id").Find(&formats)
+	for _, f := range formats {
+		data.Formats = append(data.Formats, formatData{f.Name, f.PrettyName, f.Important})
+	}
+
+	var maps []StoredMap
+	db.DB.Preload("Formats").Order("id").Find(&maps)
+	for _, m := range maps {
+		d := mapData{Name: m.Name, Formats: make(map[string]int)}
+		for _, f := range m.Formats {
+			d.Formats[f.Format] = f.Importance
+		}
+		data.Maps = append(data.Maps, d)
+	}
+
+	var leagues []StoredLeague
+	db.DB.Preload("Descriptions").Preload("Formats").Order("id").Find(&leagues)
+	for _, l := range leagues {
+		d := leagueData{
+			Name:         l.Name,
+			PrettyName:   l.PrettyName,
+			Descriptions: make(map[string]string),
+			Formats:      make(map[string]bool),
+		}
+		for _, desc := range l.Descriptions {
+			d.Descriptions[desc.MapType] = desc.Description
+		}
+		for _, f := range l.Formats {
+			d.Formats[f.Format] = f.Used
+		}
+		data.Leagues = append(data.Leagues, d)
+	}
+
+	var whitelists []StoredWhitelist
+	db.DB.Order("id").Find(&whitelists)
+	for _, w := range whitelists {
+		data.Whitelists = append(data.Whitelists, whitelistData{w.WhitelistID, w.PrettyName, w.League, w.Format})
+	}
+
+	return data
+}
+
+func saveFormat(tx *gorm.DB, f *formatData) error {
+	stored := &StoredFormat{}
+	tx.Where("name = ?", f.Name).First(stored)
+	stored.Name, stored.PrettyName, stored.Important = f.Name, f.PrettyName, f.Important
+	return tx.Save(stored).Error
+}
+
+func saveMap(tx *gorm.DB, m *mapData) error {
+	stored := &StoredMap{}
+	tx.Where("name = ?", m.Name).First(stored)
+	stored.Name = m.Name
+	if err := tx.Save(stored).Error; err != nil {
+		return err
+	}
+
+	tx.Where("map_id = ?", stored.ID).Delete(&StoredMapFormat{})
+	for format, importance := range m.Formats {
+		err := tx.Create(&StoredMapFormat{MapID: stored.ID, Format: format, Importance: importance}).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func saveLeague(tx *gorm.DB, l *leagueData) error {
+	stored := &StoredLeague{}
+	tx.Where("name = ?", l.Name).First(stored)
+	stored.Name, stored.PrettyName = l.Name, l.PrettyName
+	if err := tx.Save(stored).Error; err != nil {
+		return err
+	}
+
+	tx.Where("league_id = ?", stored.ID).Delete(&StoredLeagueDescription{})
+	tx.Where("league_id = ?", stored.ID).Delete(&StoredLeagueFormat{})
+	for mapType, description := range l.Descriptions {
+		err := tx.Create(&StoredLeagueDescription{LeagueID: stored.ID, MapType: mapType, Description: description}).Error
+		if err != nil {
+			return err
+		}
+	}
+	for format, used := range l.Formats {
+		err := tx.Create(&StoredLeagueFormat{LeagueID: stored.ID, Format: format, Used: used}).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func saveWhitelist(tx *gorm.DB, w *whitelistData) error {
+	stored := &StoredWhitelist{}
+	tx.Where("whitelist_id = ?", w.ID).First(stored)
+	stored.WhitelistID, stored.PrettyName, stored.League, stored.Format = w.ID, w.PrettyName, w.League, w.Format
+	return tx.Save(stored).Error
+}
+
+//edit applies a change to the stored settings. modify changes data, and
+//returns the old and updated values of the changed setting, which are nil
+//when it's added or removed. The changed settings are validated before
+//write stores the change in the database, and they're made the current settings.
+func edit(playerID uint, kind, name string,
+	modify func(data *settingsData) (old, updated interface{}, err error),
+	write func(tx *gorm.DB) error) error {
+
+	editMu.Lock()
+	defer editMu.Unlock()
+
+	data := readDB()
+	old, updated, err := modify(data)
+	if err != nil {
+		return err
+	}
+	s, err := newSettings(data)
+	if err != nil {
+		return err
+	}
+
+	change := &Change{
+		PlayerID: playerID,
+		Kind:     kind,
+		Name:     name,
+		Old:      toJSON(old),
+		New:      toJSON(updated),
+	}
+	switch {
+	case old == nil:
+		change.Action = "add"
+	case updated == nil:
+		change.Action = "remove"
+	default:
+		change.Action = "update"
+	}
+
+	tx := db.DB.Begin()
+	if err := write(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Create(change).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+
+	s.apply()
+	return nil
+}
+
+func toJSON(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	bytes, _ := json.Marshal(v)
+	return string(bytes)
+}
+
+//SaveFormat adds a format, or updates the format with the same name
+func SaveFormat(playerID uint, name, prettyName string, important bool) error {
+	if !reFormatName.MatchString(name) {
+		return errors.New("Format names can only contain lowercase letters, numbers and dashes")
+	}
+	if prettyName == "" {
+		return errors.New("The format needs a pretty name")
+	}
+
+	f := formatData{name, prettyName, important}
+	return edit(playerID, "format", name, func(data *settingsData) (interface{}, interface{}, error) {
+		for i, old := range data.Formats {
+			if old.Name == name {
+				data.Formats[i] = f
+				return old, f, nil
+			}
+		}
+		data.Formats = append(data.Formats, f)
+		return nil, f, nil
+	}, func(tx *gorm.DB) error {
+		return saveFormat(tx, &f)
+	})
+}
+
+//RemoveFormat removes a format. Formats used by any map, league or whitelist
+//can't be removed.
+func RemoveFormat(playerID uint, name string) error {
+	return edit(playerID, "format", name, func(data *settingsData) (interface{}, interface{}, error) {
+		for i, old := range data.Formats {
+			if old.Name == name {
+				data.Formats = append(data.Formats[:i], data.Formats[i+1:]...)
+				return old, nil, nil
+			}
+		}
+		return nil, nil, ErrNotFound
+	}, func(tx *gorm.DB) error {
+		return tx.Where("name = ?", name).Delete(&StoredFormat{}).Error
+	})
+}
+
+//SaveMap adds a map, or updates the map with the same name. formats maps
+//format names to the map's importance in them.
+func SaveMap(playerID uint, name string, formats map[string]int) error {
+	if !reMapName.MatchString(name) {
+		return errors.New("Map names can only contain letters, numbers and underscores")
+	}
+	for format, importance := range formats {
+		if importance < 0 {
+			return fmt.Errorf("Importance for %q can't be negative", format)
+		}
+	}
+
+	m := mapData{name, formats}
+	return edit(playerID, "map", name, func(data *settingsData) (interface{}, interface{}, error) {
+		for i, old := range data.Maps {
+			if old.Name == name {
+				data.Maps[i] = m
+				return old, m, nil
+			}
+		}
+		data.Maps = append(data.Maps, m)
+		return nil, m, nil
+	}, func(tx *gorm.DB) error {
+		return saveMap(tx, &m)
+	})
+}
+
+//RemoveMap removes a map
+func RemoveMap(playerID uint, name string) error {
+	return edit(playerID, "map", name, func(data *settingsData) (interface{}, interface{}, error) {
+		for i, old := range data.Maps {
+			if old.Name == name {
+				data.Maps = append(data.Maps[:i], data.Maps[i+1:]...)
+				return old, nil, nil
+			}
+		}
+		return nil, nil, ErrNotFound
+	}, func(tx *gorm.DB) error {
+		stored := &StoredMap{}
+		if err := tx.Where("name = ?", name).First(stored).Error; err != nil {
+			return err
+		}
+		tx.Where("map_id = ?", stored.ID).Delete(&StoredMapFormat{})
+		return tx.Delete(stored).Error
+	})
+}
+
+//SaveLeague adds a league, or updates the league with the same name.
+//descriptions maps map types ("cp", "koth", ...) to the league's rules for them,
+//formats maps format names to whether the league uses them.
+func SaveLeague(playerID uint, name, prettyName string, descriptions map[string]string, formats map[string]bool) error {
+	if !reFormatName.MatchString(name) {
+		return errors.New("League names can only contain lowercase letters, numbers and dashes")
+	}
+	if prettyName == "" {
+		return errors.New("The league needs a pretty name")
+	}
+
+	l := leagueData{name, prettyName, descriptions, formats}
+	return edit(playerID, "league", name, func(data *settingsData) (interface{}, interface{}, error) {
+		for i, old := range data.Leagues {
+			if old.Name == name {
+				data.Leagues[i] = l
+				return old, l, nil
+			}
+		}
+		data.Leagues = append(data.Leagues, l)
+		return nil, l, nil
+	}, func(tx *gorm.DB) error {
+		return saveLeague(tx, &l)
+	})
+}
+
+//RemoveLeague removes a league. Leagues with whitelists can't be removed.
+func RemoveLeague(playerID uint, name string) error {
+	return edit(playerID, "league", name, func(data *settingsData) (interface{}, interface{}, error) {
+		for i, old := range data.Leagues {
+			if old.Name == name {
+				data.Leagues = append(data.Leagues[:i], data.Leagues[i+1:]...)
+				return old, nil, nil
+			}
+		}
+		return nil, nil, ErrNotFound
+	}, func(tx *gorm.DB) error {
+		stored := &StoredLeague{}
+		if err := tx.Where("name = ?", name).First(stored).Error; err != nil {
+			return err
+		}
+		tx.Where("league_id = ?", stored.ID).Delete(&StoredLeagueDescription{})
+		tx.Where("league_id = ?", stored.ID).Delete(&StoredLeagueFormat{})
+		return tx.Delete(stored).Error
+	})
+}
+
+//SaveWhitelist adds a whitelist, or updates the whitelist with the same ID
+func SaveWhitelist(playerID uint, id int, prettyName, league, format string) error {
+	if id <= 0 {
+		return errors.New("Invalid whitelist ID")
+	}
+	if prettyName == "" {
+		return errors.New("The whitelist needs a pretty name")
+	}
+
+	w := whitelistData{id, prettyName, league, format}
+	return edit(playerID, "whitelist", fmt.Sprint(id), func(data *settingsData) (interface{}, interface{}, error) {
+		for i, old := range data.Whitelists {
+			if old.ID == id {
+				data.Whitelists[i] = w
+				return old, w, nil
+			}
+		}
+		data.Whitelists = append(data.Whitelists, w)
+		return nil, w, nil
+	}, func(tx *gorm.DB) error {
+		return saveWhitelist(tx, &w)
+	})
+}
+
+//RemoveWhitelist removes a whitelist
+func RemoveWhitelist(playerID uint, id int) error {
+	return edit(playerID, "whitelist", fmt.Sprint(id), func(data *settingsData) (interface{}, interface{}, error) {
+		for i, old := range data.Whitelists {
+			if old.ID == id {
+				data.Whitelists = append(data.Whitelists[:i], data.Whitelists[i+1:]...)
+				return old, nil, nil
+			}
+		}
+		return nil, nil, ErrNotFound
+	}, func(tx *gorm.DB) error {
+		return tx.Where("whitelist_id = ?", id).Delete(&StoredWhitelist{}).Error
+	})
+}
+
+//GetChanges returns the last n changes made to lobby settings, newest first
+func GetChanges(n int) []*Change {
+	var changes []*Change
+	db.DB.Order("id desc").Limit(n).Find(&changes)
+	return changes
+}
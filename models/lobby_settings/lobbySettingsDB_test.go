@@ -0,0 +1,61 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package lobbySettings_test
+
+import (
+	"testing"
+
+	"github.com/TF2Stadium/Helen/internal/testhelpers"
+	. "github.com/TF2Stadium/Helen/models/lobby_settings"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoredSettings(t *testing.T) {
+	testhelpers.CleanupDB()
+	admin := testhelpers.CreatePlayerAdmin()
+
+	require.NoError(t, InitLobbySettings("assets/lobbySettingsData.json"))
+	_, ok := GetLobbyMap("cp_badlands")
+	require.True(t, ok)
+
+	// maps can be added without reloading
+	require.NoError(t, SaveMap(admin.ID, "cp_process_f12", map[string]int{"sixes": 2}))
+	amap, ok := GetLobbyMap("cp_process_f12")
+	require.True(t, ok)
+	mapFormat, ok := amap.GetFormat("sixes")
+	require.True(t, ok)
+	assert.Equal(t, 2, mapFormat.Importance)
+
+	// invalid changes aren't stored
+	assert.Error(t, SaveMap(admin.ID, "cp_process_f12", map[string]int{"sevens": 1}))
+	assert.Error(t, SaveMap(admin.ID, "cp process", nil))
+	assert.Error(t, RemoveFormat(admin.ID, "sixes"))
+	assert.Equal(t, ErrNotFound, RemoveMap(admin.ID, "cp_nonexistent"))
+
+	require.NoError(t, SaveWhitelist(admin.ID, 9000, "ETF2L 6v6 (Season 30)", "etf2l", "sixes"))
+	whitelist, ok := GetLobbyWhitelist(9000)
+	require.True(t, ok)
+	assert.Equal(t, "etf2l", whitelist.League.Name)
+	assert.Error(t, RemoveLeague(admin.ID, "etf2l"))
+
+	require.NoError(t, RemoveMap(admin.ID, "cp_process_f12"))
+	_, ok = GetLobbyMap("cp_process_f12")
+	assert.False(t, ok)
+
+	// settings are loaded from the database, and not seeded again
+	require.NoError(t, InitLobbySettings("assets/lobbySettingsData.json"))
+	_, ok = GetLobbyWhitelist(9000)
+	assert.True(t, ok)
+
+	changes := GetChanges(10)
+	require.Len(t, changes, 3)
+	assert.Equal(t, "remove", changes[0].Action)
+	assert.Equal(t, "cp_process_f12", changes[0].Name)
+	assert.NotEmpty(t, changes[0].Old)
+	assert.Empty(t, changes[0].New)
+	assert.Equal(t, "add", changes[2].Action)
+	assert.Equal(t, admin.ID, changes[2].PlayerID)
+}
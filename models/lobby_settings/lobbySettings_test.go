@@ -116,3 +116,21 @@ func TestSettingsLoad(t *testing.T) {
 		assert.NoError(err)
 	}
 }
+
+func TestSettingsLoadInvalid(t *testing.T) {
+	assert.NoError(t, LoadLobbySettings(testSettingsData))
+
+	for _, data := range []string{
+		`{"formats": [{"name": "sixes"}], "maps": [{"name": "cp_badlands", "formats": {"fours": 1}}]}`,
+		`{"formats": [{"name": "sixes"}, {"name": "sixes"}]}`,
+		`{"formats": [{"name": "sixes"}], "maps": [{"name": "cp_badlands"}, {"name": "cp_badlands"}]}`,
+		`{"formats": [{"name": "sixes"}], "whitelists": [{"id": 1, "league": "etf2l", "format": "sixes"}]}`,
+	} {
+		assert.Error(t, LoadLobbySettings([]byte(data)), data)
+	}
+
+	// invalid settings don't replace the loaded ones
+	assert.Len(t, LobbyFormats, 3)
+	_, ok := GetLobbyWhitelist(3250)
+	assert.True(t, ok)
+}
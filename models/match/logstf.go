@@ -0,0 +1,119 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package match
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+var logsClient = &http.Client{Timeout: 30 * time.Second}
+
+//logsTFLog is the part of the logs.tf JSON API response Helen uses
+type logsTFLog struct {
+	Length int `json:"length"`
+	Teams  struct {
+		Red struct {
+			Score int `json:"score"`
+		} `json:"Red"`
+		Blue struct {
+			Score int `json:"score"`
+		} `json:"Blue"`
+	} `json:"teams"`
+	Players map[string]struct {
+		Team    string `json:"team"`
+		Kills   int    `json:"kills"`
+		Deaths  int    `json:"deaths"`
+		Assists int    `json:"assists"`
+		Damage  int    `json:"dmg"`
+		Heal    int    `json:"heal"`
+		Ubers   int    `json:"ubers"`
+		Drops   int    `json:"drops"`
+
+		ClassStats []struct {
+			Type      string `json:"type"`
+			Kills     int    `json:"kills"`
+			Deaths    int    `json:"deaths"`
+			Assists   int    `json:"assists"`
+			Damage    int    `json:"dmg"`
+			TotalTime int    `json:"total_time"`
+		} `json:"class_stats"`
+	} `json:"players"`
+}
+
+//FromLogsTF downloads the log with the given ID from logs.tf
+func FromLogsTF(logsID int) (*Result, error) {
+	resp, err := logsClient.Get(fmt.Sprintf("https://logs.tf/json/%d", logsID))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("logs.tf returned %s for log #%d", resp.Status, logsID)
+	}
+
+	return DecodeLogsTF(resp.Body)
+}
+
+//DecodeLogsTF reads a log in the logs.tf JSON format
+func DecodeLogsTF(r io.Reader) (*Result, error) {
+	var log logsTFLog
+	if err := json.NewDecoder(r).Decode(&log); err != nil {
+		return nil, err
+	}
+
+	result := &Result{
+		RedScore: log.Teams.Red.Score,
+		BluScore: log.Teams.Blue.Score,
+		Length:   log.Length,
+		Players:  make(map[string]*PlayerStats),
+	}
+
+	for steamid, p := range log.Players {
+		commid, err := CommID(steamid)
+		if err != nil {
+			logrus.Warningf("logs.tf: invalid steamid %s", steamid)
+			continue
+		}
+
+		stats := &PlayerStats{
+			Kills:   p.Kills,
+			Deaths:  p.Deaths,
+			Assists: p.Assists,
+			Damage:  p.Damage,
+			Heals:   p.Heal,
+			Ubers:   p.Ubers,
+			Drops:   p.Drops,
+		}
+		switch strings.ToLower(p.Team) {
+		case "red":
+			stats.Team = "red"
+		case "blue", "blu":
+			stats.Team = "blu"
+		}
+
+		for _, class := range p.ClassStats {
+			stats.Classes = append(stats.Classes, &ClassStats{
+				Class:   ClassName(class.Type),
+				Seconds: class.TotalTime,
+				Kills:   class.Kills,
+				Deaths:  class.Deaths,
+				Assists: class.Assists,
+				Damage:  class.Damage,
+			})
+		}
+
+		result.Players[commid] = stats
+	}
+
+	return result, nil
+}
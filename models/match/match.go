@@ -0,0 +1,103 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+//Package match contains the results of matches played in lobbies, and
+//functions for getting them from logs.tf or parsing them from TF2 server logs.
+package match
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+//Result is the outcome of a match, with the stats of every player who played in it
+type Result struct {
+	RedScore int
+	BluScore int
+	Length   int // match length in seconds
+
+	Players map[string]*PlayerStats // 64 bit steamid -> stats
+}
+
+//PlayerStats are a player's stats in a single match
+type PlayerStats struct {
+	Team    string // "red" or "blu"
+	Kills   int
+	Deaths  int
+	Assists int
+	Damage  int
+	Heals   int // healing done as medic
+	Ubers   int
+	Drops   int // ubers lost by dying
+
+	Classes []*ClassStats
+}
+
+//ClassStats are a player's stats while playing a class in a single match
+type ClassStats struct {
+	Class   string // "scout", "soldier", ..., "heavy"
+	Seconds int    // time played
+	Kills   int
+	Deaths  int
+	Assists int
+	Damage  int
+}
+
+//classNames maps class names used by TF2 and logs.tf to the ones used by Helen
+var classNames = map[string]string{
+	"heavyweapons": "heavy",
+}
+
+//ClassName returns the name Helen uses for the given TF2 class name
+func ClassName(class string) string {
+	class = strings.ToLower(class)
+	if name, ok := classNames[class]; ok {
+		return name
+	}
+	return class
+}
+
+//steamID64Base is the 64 bit steamid of the individual account with ID 0
+const steamID64Base = 76561197960265728
+
+var ErrInvalidSteamID = errors.New("Invalid steamid")
+
+//CommID returns the 64 bit steamid for a steamid in the [U:1:1234] or
+//STEAM_0:0:1234 formats, as used in TF2 logs
+func CommID(steamid string) (string, error) {
+	switch {
+	case strings.HasPrefix(steamid, "[U:1:") && strings.HasSuffix(steamid, "]"):
+		id, err := strconv.ParseUint(steamid[5:len(steamid)-1], 10, 32)
+		if err != nil {
+			return "", ErrInvalidSteamID
+		}
+		return strconv.FormatUint(steamID64Base+id, 10), nil
+
+	case strings.HasPrefix(steamid, "STEAM_"):
+		parts := strings.Split(steamid[6:], ":")
+		if len(parts) != 3 {
+			return "", ErrInvalidSteamID
+		}
+		y, err1 := strconv.ParseUint(parts[1], 10, 1)
+		z, err2 := strconv.ParseUint(parts[2], 10, 32)
+		if err1 != nil || err2 != nil {
+			return "", ErrInvalidSteamID
+		}
+		return strconv.FormatUint(steamID64Base+z*2+y, 10), nil
+	}
+
+	return "", ErrInvalidSteamID
+}
+
+//Winner returns the team which won the match, or an empty string if it was a draw
+func (r *Result) Winner() string {
+	switch {
+	case r.RedScore > r.BluScore:
+		return "red"
+	case r.BluScore > r.RedScore:
+		return "blu"
+	}
+	return ""
+}
@@ -0,0 +1,60 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package match_test
+
+import (
+	"os"
+	"testing"
+
+	. "github.com/TF2Stadium/Helen/models/match"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommID(t *testing.T) {
+	id, err := CommID("[U:1:1]")
+	require.NoError(t, err)
+	assert.Equal(t, "76561197960265729", id)
+
+	id, err = CommID("STEAM_0:1:2")
+	require.NoError(t, err)
+	assert.Equal(t, "76561197960265733", id)
+
+	for _, invalid := range []string{"", "[U:1:x]", "STEAM_0:2:1", "76561197960265729"} {
+		_, err = CommID(invalid)
+		assert.Equal(t, ErrInvalidSteamID, err, invalid)
+	}
+}
+
+func TestDecodeLogsTF(t *testing.T) {
+	f, err := os.Open("testdata/logstf.json")
+	require.NoError(t, err)
+	defer f.Close()
+
+	result, err := DecodeLogsTF(f)
+	require.NoError(t, err)
+	assert.Equal(t, 5, result.RedScore)
+	assert.Equal(t, 2, result.BluScore)
+	assert.Equal(t, "red", result.Winner())
+	assert.Equal(t, 1800, result.Length)
+	require.Len(t, result.Players, 2)
+
+	scout := result.Players["76561197960265729"]
+	require.NotNil(t, scout)
+	assert.Equal(t, "red", scout.Team)
+	assert.Equal(t, 22, scout.Kills)
+	assert.Equal(t, 6400, scout.Damage)
+	require.Len(t, scout.Classes, 2)
+	assert.Equal(t, "scout", scout.Classes[0].Class)
+	assert.Equal(t, 1500, scout.Classes[0].Seconds)
+	assert.Equal(t, "heavy", scout.Classes[1].Class)
+
+	medic := result.Players["76561197960265733"]
+	require.NotNil(t, medic)
+	assert.Equal(t, "blu", medic.Team)
+	assert.Equal(t, 21000, medic.Heals)
+	assert.Equal(t, 8, medic.Ubers)
+	assert.Equal(t, 2, medic.Drops)
+}
@@ -0,0 +1,464 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package match
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrNoMatch = errors.New("The log doesn't contain a match")
+
+//timeLayout is the layout of the timestamp at the start of every log line,
+//after the "L "
+const timeLayout = "01/02/2006 - 15:04:05"
+
+//playerRe matches a player in a log line, like "name<2><[U:1:1234]><Red>",
+//capturing their name, steamid and team
+const playerRe = `"(.*?)<\d+><([^>]*)><([^>]*)>"`
+
+var (
+	rLine = regexp.MustCompile(`^L (\d\d/\d\d/\d{4} - \d\d:\d\d:\d\d): (.*)$`)
+
+	rKill      = regexp.MustCompile(`^` + playerRe + ` killed ` + playerRe + ` with "([^"]*)"`)
+	rSuicide   = regexp.MustCompile(`^` + playerRe + ` committed suicide with "([^"]*)"`)
+	rTriggered = regexp.MustCompile(`^` + playerRe + ` triggered "([^"]*)"(?: against ` + playerRe + `)?`)
+	rRole      = regexp.MustCompile(`^` + playerRe + ` (?:changed role to|spawned as) "([^"]*)"`)
+	rJoined    = regexp.MustCompile(`^` + playerRe + ` joined team "([^"]*)"`)
+	rLeft      = regexp.MustCompile(`^` + playerRe + ` disconnected`)
+	rWorld     = regexp.MustCompile(`^World triggered "([^"]*)"`)
+	rScore     = regexp.MustCompile(`^Team "([^"]*)" (?:current|final) score "(\d+)"`)
+	// properties at the end of a line, like (damage "42")
+	rProperty = regexp.MustCompile(`\((\w+) "([^"]*)"\)`)
+)
+
+//logPlayer is a player's state while their log is parsed
+type logPlayer struct {
+	stats   *PlayerStats
+	classes map[string]*ClassStats
+
+	class string    // current class, empty if unknown
+	since time.Time // time at which the player started playing class
+}
+
+//Parser reads the lines of a TF2 server log, and computes the result
+//of the match in it. Lines before the first round starts are ignored, as
+//are lines after the game is over, except for the final score.
+type Parser struct {
+	players map[string]*logPlayer // steamid, as used in the log -> player
+
+	redScore, bluScore int
+	redWins, bluWins   int // rounds won, in case the log doesn't have the final score
+	scored             bool
+
+	started, over bool
+	paused        bool
+	start, last   time.Time
+	pausedAt      time.Time
+	pausedFor     time.Duration
+}
+
+//NewParser returns a new Parser
+func NewParser() *Parser {
+	return &Parser{players: make(map[string]*logPlayer)}
+}
+
+//ParseLog reads a TF2 server log, and returns the result of the match in it
+func ParseLog(r io.Reader) (*Result, error) {
+	p := NewParser()
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		p.ParseLine(scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if !p.started {
+		return nil, ErrNoMatch
+	}
+	return p.Result(), nil
+}
+
+//SplitPacket returns the log secret and the log line in a UDP packet sent by
+//a TF2 server after logaddress_add. secret is empty if sv_logsecret isn't set
+//on the server.
+func SplitPacket(packet []byte) (secret, line string, ok bool) {
+	if len(packet) < 5 || !bytes.HasPrefix(packet, []byte{0xff, 0xff, 0xff, 0xff}) {
+		return "", "", false
+	}
+	packet = bytes.TrimRight(packet[4:], "\x00\r\n")
+
+	switch packet[0] {
+	case 'R':
+		return "", string(packet[1:]), true
+	case 'S':
+		// sv_logsecret is a number, so the line starts at the first non digit
+		i := 1
+		for i < len(packet) && packet[i] >= '0' && packet[i] <= '9' {
+			i++
+		}
+		return string(packet[1:i]), string(packet[i:]), true
+	}
+
+	return "", "", false
+}
+
+//GameOver returns true if the parser has seen the end of the match
+func (p *Parser) GameOver() bool {
+	return p.over
+}
+
+//ParseLine parses a single log line. Lines the parser doesn't understand are
+//ignored.
+func (p *Parser) ParseLine(line string) {
+	m := rLine.FindStringSubmatch(strings.TrimRight(line, "\x00\r\n"))
+	if m == nil {
+		return
+	}
+	t, err := time.Parse(timeLayout, m[1])
+	if err != nil {
+		return
+	}
+	body := m[2]
+
+	// the final score is logged after the game is over
+	if m := rScore.FindStringSubmatch(body); m != nil {
+		if !p.started {
+			return
+		}
+		score, _ := strconv.Atoi(m[2])
+		switch teamName(m[1]) {
+		case "red":
+			p.redScore = score
+		case "blu":
+			p.bluScore = score
+		}
+		p.scored = true
+		return
+	}
+
+	if p.over {
+		return
+	}
+	if p.started {
+		p.last = t
+	}
+
+	if m := rWorld.FindStringSubmatch(body); m != nil {
+		p.world(t, m[1], body)
+		return
+	}
+
+	if m := rRole.FindStringSubmatch(body); m != nil {
+		pl := p.player(m[2], m[3])
+		if pl != nil {
+			p.changeClass(pl, t, ClassName(m[4]))
+		}
+		return
+	}
+
+	if m := rJoined.FindStringSubmatch(body); m != nil {
+		if pl := p.player(m[2], m[4]); pl != nil {
+			// the player is dead until they spawn on their new team
+			p.changeClass(pl, t, "")
+		}
+		return
+	}
+
+	if m := rLeft.FindStringSubmatch(body); m != nil {
+		if pl := p.player(m[2], m[3]); pl != nil {
+			p.changeClass(pl, t, "")
+		}
+		return
+	}
+
+	if !p.started || p.paused {
+		return
+	}
+
+	if m := rKill.FindStringSubmatch(body); m != nil {
+		props := properties(body)
+		if props["customkill"] == "feign_death" {
+			return
+		}
+
+		killer, victim := p.player(m[2], m[3]), p.player(m[5], m[6])
+		if killer != nil && killer != victim {
+			killer.stats.Kills++
+			if c := killer.classStats(); c != nil {
+				c.Kills++
+			}
+		}
+		if victim != nil {
+			victim.stats.Deaths++
+			if c := victim.classStats(); c != nil {
+				c.Deaths++
+			}
+		}
+		return
+	}
+
+	if m := rSuicide.FindStringSubmatch(body); m != nil {
+		if pl := p.player(m[2], m[3]); pl != nil {
+			pl.stats.Deaths++
+			if c := pl.classStats(); c != nil {
+				c.Deaths++
+			}
+		}
+		return
+	}
+
+	if m := rTriggered.FindStringSubmatch(body); m != nil {
+		p.triggered(m, properties(body))
+	}
+}
+
+func (p *Parser) triggered(m []string, props map[string]string) {
+	pl := p.player(m[2], m[3])
+	if pl == nil {
+		return
+	}
+
+	target := p.player(m[6], m[7]) // nil if there's no target
+
+	switch m[4] {
+	case "kill assist":
+		pl.stats.Assists++
+		if c := pl.classStats(); c != nil {
+			c.Assists++
+		}
+
+	case "damage":
+		// self and team damage isn't counted
+		if target == pl || (target != nil && target.stats.Team == pl.stats.Team) {
+			return
+		}
+		damage, _ := strconv.Atoi(props["damage"])
+		pl.stats.Damage += damage
+		if c := pl.classStats(); c != nil {
+			c.Damage += damage
+		}
+
+	case "healed":
+		healing, _ := strconv.Atoi(props["healing"])
+		pl.stats.Heals += healing
+
+	case "chargedeployed":
+		pl.stats.Ubers++
+
+	case "medic_death":
+		// the target is the medic who died, and had a full uber if ubercharge is 1
+		if target != nil && props["ubercharge"] == "1" {
+			target.stats.Drops++
+		}
+	}
+}
+
+func (p *Parser) world(t time.Time, event, body string) {
+	switch event {
+	case "Round_Start":
+		if p.started {
+			return
+		}
+		p.started = true
+		p.start, p.last = t, t
+		// time played before the match doesn't count
+		for _, pl := range p.players {
+			pl.since = t
+		}
+
+	case "Round_Win":
+		if !p.started {
+			return
+		}
+		switch teamName(properties(body)["winner"]) {
+		case "red":
+			p.redWins++
+		case "blu":
+			p.bluWins++
+		}
+
+	case "Game_Paused":
+		if !p.started || p.paused {
+			return
+		}
+		for _, pl := range p.players {
+			pl.addTime(t)
+		}
+		p.paused = true
+		p.pausedAt = t
+
+	case "Game_Unpaused":
+		if !p.paused {
+			return
+		}
+		for _, pl := range p.players {
+			pl.since = t
+		}
+		p.paused = false
+		p.pausedFor += t.Sub(p.pausedAt)
+
+	case "Game_Over":
+		if !p.started {
+			return
+		}
+		p.end(t)
+		p.over = true
+	}
+}
+
+//end stops the clock for every player
+func (p *Parser) end(t time.Time) {
+	if p.paused {
+		p.pausedFor += t.Sub(p.pausedAt)
+		p.paused = false
+		return
+	}
+	for _, pl := range p.players {
+		pl.addTime(t)
+	}
+}
+
+//player returns the player with the given steamid, and sets their team
+//if the log shows them on red or blu. Returns nil for the console, bots
+//and spectators.
+func (p *Parser) player(steamid, team string) *logPlayer {
+	if steamid == "" || steamid == "Console" || steamid == "BOT" {
+		return nil
+	}
+
+	pl, ok := p.players[steamid]
+	if !ok {
+		pl = &logPlayer{
+			stats:   &PlayerStats{},
+			classes: make(map[string]*ClassStats),
+		}
+		p.players[steamid] = pl
+	}
+	if team := teamName(team); team != "" {
+		pl.stats.Team = team
+	}
+	return pl
+}
+
+func (p *Parser) changeClass(pl *logPlayer, t time.Time, class string) {
+	if class == pl.class {
+		return
+	}
+	if p.started && !p.paused {
+		pl.addTime(t)
+	}
+	pl.class = class
+	pl.since = t
+}
+
+//addTime adds the time since pl.since to the player's current class
+func (pl *logPlayer) addTime(t time.Time) {
+	if c := pl.classStats(); c != nil && t.After(pl.since) {
+		c.Seconds += int(t.Sub(pl.since) / time.Second)
+	}
+	pl.since = t
+}
+
+//classStats returns the stats of the class the player is playing, or nil
+//if it isn't known
+func (pl *logPlayer) classStats() *ClassStats {
+	if pl.class == "" {
+		return nil
+	}
+
+	c, ok := pl.classes[pl.class]
+	if !ok {
+		c = &ClassStats{Class: pl.class}
+		pl.classes[pl.class] = c
+	}
+	return c
+}
+
+//Result returns the result of the match, as parsed so far. If the game
+//isn't over, the match is assumed to end at the last line parsed.
+func (p *Parser) Result() *Result {
+	result := &Result{
+		RedScore: p.redWins,
+		BluScore: p.bluWins,
+		Players:  make(map[string]*PlayerStats),
+	}
+	if p.scored {
+		result.RedScore, result.BluScore = p.redScore, p.bluScore
+	}
+	if !p.started {
+		return result
+	}
+
+	pausedFor := p.pausedFor
+	if p.paused {
+		pausedFor += p.last.Sub(p.pausedAt)
+	}
+	result.Length = int((p.last.Sub(p.start) - pausedFor) / time.Second)
+
+	for steamid, pl := range p.players {
+		commid, err := CommID(steamid)
+		if err != nil || pl.stats.Team == "" {
+			continue
+		}
+
+		stats := *pl.stats
+		stats.Classes = nil
+		for name, c := range pl.classes {
+			class := *c
+			if !p.over && !p.paused && name == pl.class && p.last.After(pl.since) {
+				// the player is still playing the class
+				class.Seconds += int(p.last.Sub(pl.since) / time.Second)
+			}
+			stats.Classes = append(stats.Classes, &class)
+		}
+		sort.Sort(bySeconds(stats.Classes))
+
+		result.Players[commid] = &stats
+	}
+
+	return result
+}
+
+//bySeconds sorts classes by the time played on them, most played first
+type bySeconds []*ClassStats
+
+func (c bySeconds) Len() int      { return len(c) }
+func (c bySeconds) Swap(i, j int) { c[i], c[j] = c[j], c[i] }
+func (c bySeconds) Less(i, j int) bool {
+	if c[i].Seconds != c[j].Seconds {
+		return c[i].Seconds > c[j].Seconds
+	}
+	return c[i].Class < c[j].Class
+}
+
+//teamName returns the team name Helen uses for a team in the log
+func teamName(team string) string {
+	switch strings.ToLower(team) {
+	case "red":
+		return "red"
+	case "blue", "blu":
+		return "blu"
+	}
+	return ""
+}
+
+//properties returns the properties at the end of a log line, like (damage "42")
+func properties(body string) map[string]string {
+	props := make(map[string]string)
+	for _, m := range rProperty.FindAllStringSubmatch(body, -1) {
+		props[m[1]] = m[2]
+	}
+	return props
+}
@@ -0,0 +1,140 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package match_test
+
+import (
+	"bufio"
+	"os"
+	"strings"
+	"testing"
+
+	. "github.com/TF2Stadium/Helen/models/match"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func classSeconds(stats *PlayerStats) map[string]int {
+	seconds := make(map[string]int)
+	for _, class := range stats.Classes {
+		seconds[class.Class] = class.Seconds
+	}
+	return seconds
+}
+
+func TestParseLog(t *testing.T) {
+	f, err := os.Open("testdata/match.log")
+	require.NoError(t, err)
+	defer f.Close()
+
+	result, err := ParseLog(f)
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.RedScore)
+	assert.Equal(t, 1, result.BluScore)
+	assert.Equal(t, "red", result.Winner())
+	// 6 minutes, minus a minute paused
+	assert.Equal(t, 300, result.Length)
+	require.Len(t, result.Players, 4)
+
+	scout := result.Players["76561197960266729"]
+	require.NotNil(t, scout)
+	assert.Equal(t, "red", scout.Team)
+	// pregame, paused, feign death and post game kills aren't counted
+	assert.Equal(t, 2, scout.Kills)
+	assert.Equal(t, 0, scout.Deaths)
+	// self and team damage aren't counted
+	assert.Equal(t, 105, scout.Damage)
+	require.Len(t, scout.Classes, 2)
+	assert.Equal(t, "demoman", scout.Classes[0].Class)
+	assert.Equal(t, 240, scout.Classes[0].Seconds)
+	assert.Equal(t, 1, scout.Classes[0].Kills)
+	assert.Equal(t, "scout", scout.Classes[1].Class)
+	assert.Equal(t, 60, scout.Classes[1].Seconds)
+	assert.Equal(t, 1, scout.Classes[1].Kills)
+	assert.Equal(t, 105, scout.Classes[1].Damage)
+
+	medic := result.Players["76561197960266730"]
+	require.NotNil(t, medic)
+	assert.Equal(t, "red", medic.Team)
+	assert.Equal(t, 1, medic.Assists)
+	assert.Equal(t, 1, medic.Deaths)
+	assert.Equal(t, 40, medic.Heals)
+	assert.Equal(t, 1, medic.Drops)
+	assert.Equal(t, map[string]int{"medic": 300}, classSeconds(medic))
+
+	soldier := result.Players["76561197960266731"]
+	require.NotNil(t, soldier)
+	assert.Equal(t, "blu", soldier.Team)
+	assert.Equal(t, 1, soldier.Kills)
+	assert.Equal(t, 2, soldier.Deaths)
+	assert.Equal(t, 110, soldier.Damage)
+	assert.Equal(t, map[string]int{"soldier": 300}, classSeconds(soldier))
+
+	bluMedic := result.Players["76561197960266732"]
+	require.NotNil(t, bluMedic)
+	assert.Equal(t, 1, bluMedic.Ubers)
+	assert.Equal(t, 1, bluMedic.Assists)
+	assert.Equal(t, 0, bluMedic.Drops)
+}
+
+func TestParseLogNoMatch(t *testing.T) {
+	log := `L 10/18/2026 - 20:00:00: Log file started (file "logs/L1018000.log") (game "/home/tf2/tf") (version "7000000")
+L 10/18/2026 - 20:00:10: "Scout Red<3><[U:1:1001]><Red>" changed role to "scout"`
+
+	_, err := ParseLog(strings.NewReader(log))
+	assert.Equal(t, ErrNoMatch, err)
+}
+
+func TestParserInProgress(t *testing.T) {
+	f, err := os.Open("testdata/match.log")
+	require.NoError(t, err)
+	defer f.Close()
+
+	p := NewParser()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		p.ParseLine(line)
+		if strings.Contains(line, `"Round_Win" (winner "Red")`) {
+			break
+		}
+	}
+	require.False(t, p.GameOver())
+
+	result := p.Result()
+	assert.Equal(t, 1, result.RedScore)
+	assert.Equal(t, 0, result.BluScore)
+	assert.Equal(t, 120, result.Length)
+	// the class the player is still playing counts up to the last line
+	assert.Equal(t, map[string]int{"scout": 60, "demoman": 60},
+		classSeconds(result.Players["76561197960266729"]))
+
+	// getting the result doesn't change the parser's state
+	for scanner.Scan() {
+		p.ParseLine(scanner.Text())
+	}
+	assert.True(t, p.GameOver())
+	assert.Equal(t, 300, p.Result().Length)
+	assert.Equal(t, map[string]int{"scout": 60, "demoman": 240},
+		classSeconds(p.Result().Players["76561197960266729"]))
+}
+
+func TestSplitPacket(t *testing.T) {
+	line := `L 10/18/2026 - 20:01:00: World triggered "Round_Start"`
+
+	secret, got, ok := SplitPacket([]byte("\xff\xff\xff\xffS123456" + line + "\n\x00"))
+	require.True(t, ok)
+	assert.Equal(t, "123456", secret)
+	assert.Equal(t, line, got)
+
+	secret, got, ok = SplitPacket([]byte("\xff\xff\xff\xffR" + line + "\n\x00"))
+	require.True(t, ok)
+	assert.Empty(t, secret)
+	assert.Equal(t, line, got)
+
+	for _, invalid := range []string{"", "\xff\xff\xff\xff", "\xff\xff\xff\xffX" + line, line} {
+		_, _, ok = SplitPacket([]byte(invalid))
+		assert.False(t, ok, invalid)
+	}
+}
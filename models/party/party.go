@@ -0,0 +1,251 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+//Package party implements parties, groups of players who join lobbies
+//together. A party is created when a player invites a friend, and is led by
+//that player until they leave.
+package party
+
+import (
+	"errors"
+	"sync"
+)
+
+//MaxSize is the maximum number of players in a party
+const MaxSize = 6
+
+var (
+	ErrNotInParty     = errors.New("You aren't in a party.")
+	ErrAlreadyInParty = errors.New("This player is already in a party.")
+	ErrNotLeader      = errors.New("Only the party leader can do this.")
+	ErrPartyFull      = errors.New("The party is full.")
+	ErrNoInvite       = errors.New("You haven't been invited to this party.")
+	ErrPartyNotFound  = errors.New("Party not found.")
+	ErrNotMember      = errors.New("This player isn't in your party.")
+)
+
+//Party is a snapshot of a party's state. Changing it doesn't change the party.
+type Party struct {
+	ID       uint
+	LeaderID uint
+	Members  []uint          // player IDs, in the order they joined
+	Classes  map[uint]string // player ID -> class the player wants to play
+	Invited  []uint
+}
+
+var (
+	mu      = new(sync.Mutex)
+	parties = make(map[uint]*Party)
+	members = make(map[uint]uint) // player ID -> ID of the party the player is in
+	lastID  uint
+)
+
+func (p *Party) copy() Party {
+	c := Party{
+		ID:       p.ID,
+		LeaderID: p.LeaderID,
+		Members:  append([]uint{}, p.Members...),
+		Classes:  make(map[uint]string),
+		Invited:  append([]uint{}, p.Invited...),
+	}
+	for id, class := range p.Classes {
+		c.Classes[id] = class
+	}
+
+	return c
+}
+
+func (p *Party) isInvited(playerID uint) bool {
+	for _, id := range p.Invited {
+		if id == playerID {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Party) removeInvite(playerID uint) {
+	for i, id := range p.Invited {
+		if id == playerID {
+			p.Invited = append(p.Invited[:i], p.Invited[i+1:]...)
+			return
+		}
+	}
+}
+
+func (p *Party) removeMember(playerID uint) {
+	for i, id := range p.Members {
+		if id == playerID {
+			p.Members = append(p.Members[:i], p.Members[i+1:]...)
+			break
+		}
+	}
+	delete(p.Classes, playerID)
+	delete(members, playerID)
+
+	if len(p.Members) == 0 || (len(p.Members) == 1 && len(p.Invited) == 0) {
+		// nobody left to play with
+		for _, id := range p.Members {
+			delete(members, id)
+		}
+		delete(parties, p.ID)
+		return
+	}
+
+	if p.LeaderID == playerID && len(p.Members) != 0 {
+		p.LeaderID = p.Members[0]
+	}
+}
+
+//Invite invites the player to the leader's party. If the leader isn't in a
+//party, a new party is created.
+func Invite(leaderID, playerID uint) (Party, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if _, ok := members[playerID]; ok {
+		return Party{}, ErrAlreadyInParty
+	}
+
+	p, ok := parties[members[leaderID]]
+	if !ok {
+		lastID++
+		p = &Party{
+			ID:       lastID,
+			LeaderID: leaderID,
+			Members:  []uint{leaderID},
+			Classes:  make(map[uint]string),
+		}
+		parties[p.ID] = p
+		members[leaderID] = p.ID
+	} else if p.LeaderID != leaderID {
+		return Party{}, ErrNotLeader
+	}
+
+	if len(p.Members)+len(p.Invited) >= MaxSize {
+		return Party{}, ErrPartyFull
+	}
+	if !p.isInvited(playerID) {
+		p.Invited = append(p.Invited, playerID)
+	}
+
+	return p.copy(), nil
+}
+
+//Accept accepts an invite to the party with the given ID
+func Accept(partyID, playerID uint) (Party, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	p, ok := parties[partyID]
+	if !ok {
+		return Party{}, ErrPartyNotFound
+	}
+	if !p.isInvited(playerID) {
+		return Party{}, ErrNoInvite
+	}
+	if _, ok := members[playerID]; ok {
+		return Party{}, ErrAlreadyInParty
+	}
+
+	p.removeInvite(playerID)
+	p.Members = append(p.Members, playerID)
+	members[playerID] = p.ID
+	return p.copy(), nil
+}
+
+//Decline declines an invite to the party with the given ID
+func Decline(partyID, playerID uint) (Party, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	p, ok := parties[partyID]
+	if !ok || !p.isInvited(playerID) {
+		return Party{}, ErrNoInvite
+	}
+
+	p.removeInvite(playerID)
+	c := p.copy()
+	if len(p.Members) == 1 && len(p.Invited) == 0 {
+		p.removeMember(p.LeaderID)
+	}
+	return c, nil
+}
+
+//Leave removes the player from their party. If the player was the leader,
+//the member who joined after them becomes the leader. The party's state
+//before the player left is returned.
+func Leave(playerID uint) (Party, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	p, ok := parties[members[playerID]]
+	if !ok {
+		return Party{}, ErrNotInParty
+	}
+
+	c := p.copy()
+	p.removeMember(playerID)
+	return c, nil
+}
+
+//Kick removes the player from the leader's party. The party's state before
+//the player was removed is returned.
+func Kick(leaderID, playerID uint) (Party, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	p, ok := parties[members[leaderID]]
+	if !ok {
+		return Party{}, ErrNotInParty
+	}
+	if p.LeaderID != leaderID {
+		return Party{}, ErrNotLeader
+	}
+	if members[playerID] != p.ID || playerID == leaderID {
+		return Party{}, ErrNotMember
+	}
+
+	c := p.copy()
+	p.removeMember(playerID)
+	return c, nil
+}
+
+//SetClass sets the class the player wants to play when the party joins a lobby
+func SetClass(playerID uint, class string) (Party, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	p, ok := parties[members[playerID]]
+	if !ok {
+		return Party{}, ErrNotInParty
+	}
+
+	p.Classes[playerID] = class
+	return p.copy(), nil
+}
+
+//Get returns the party the player is in
+func Get(playerID uint) (Party, bool) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	p, ok := parties[members[playerID]]
+	if !ok {
+		return Party{}, false
+	}
+	return p.copy(), true
+}
+
+//GetByID returns the party with the given ID
+func GetByID(partyID uint) (Party, bool) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	p, ok := parties[partyID]
+	if !ok {
+		return Party{}, false
+	}
+	return p.copy(), true
+}
@@ -0,0 +1,112 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package party_test
+
+import (
+	"testing"
+
+	. "github.com/TF2Stadium/Helen/models/party"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInviteAccept(t *testing.T) {
+	p, err := Invite(1, 2)
+	require.NoError(t, err)
+	assert.Equal(t, uint(1), p.LeaderID)
+	assert.Equal(t, []uint{1}, p.Members)
+	assert.Equal(t, []uint{2}, p.Invited)
+
+	_, err = Accept(p.ID, 3)
+	assert.Equal(t, ErrNoInvite, err)
+
+	p, err = Accept(p.ID, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []uint{1, 2}, p.Members)
+	assert.Empty(t, p.Invited)
+
+	_, err = Invite(1, 2)
+	assert.Equal(t, ErrAlreadyInParty, err)
+	_, err = Invite(2, 3)
+	assert.Equal(t, ErrNotLeader, err)
+
+	got, ok := Get(2)
+	require.True(t, ok)
+	assert.Equal(t, p.ID, got.ID)
+
+	Leave(2)
+	_, ok = Get(1)
+	assert.False(t, ok)
+}
+
+func TestDecline(t *testing.T) {
+	p, err := Invite(10, 11)
+	require.NoError(t, err)
+
+	_, err = Decline(p.ID, 11)
+	require.NoError(t, err)
+	// nobody is left in the party
+	_, ok := GetByID(p.ID)
+	assert.False(t, ok)
+	_, ok = Get(10)
+	assert.False(t, ok)
+}
+
+func TestLeaveKick(t *testing.T) {
+	p, _ := Invite(20, 21)
+	Invite(20, 22)
+	Accept(p.ID, 21)
+	Accept(p.ID, 22)
+
+	_, err := Kick(21, 22)
+	assert.Equal(t, ErrNotLeader, err)
+	_, err = Kick(20, 23)
+	assert.Equal(t, ErrNotMember, err)
+
+	before, err := Leave(20)
+	require.NoError(t, err)
+	assert.Equal(t, uint(20), before.LeaderID)
+
+	p, ok := GetByID(p.ID)
+	require.True(t, ok)
+	assert.Equal(t, uint(21), p.LeaderID)
+	assert.Equal(t, []uint{21, 22}, p.Members)
+
+	_, err = Kick(21, 22)
+	require.NoError(t, err)
+	_, ok = GetByID(p.ID)
+	assert.False(t, ok)
+
+	_, err = Leave(21)
+	assert.Equal(t, ErrNotInParty, err)
+}
+
+func TestPartyFull(t *testing.T) {
+	var p Party
+	var err error
+	for i := uint(31); i < 30+MaxSize; i++ {
+		p, err = Invite(30, i)
+		require.NoError(t, err)
+	}
+
+	_, err = Invite(30, 40)
+	assert.Equal(t, ErrPartyFull, err)
+
+	for _, id := range p.Invited {
+		Decline(p.ID, id)
+	}
+}
+
+func TestSetClass(t *testing.T) {
+	_, err := SetClass(50, "scout")
+	assert.Equal(t, ErrNotInParty, err)
+
+	p, _ := Invite(50, 51)
+	p, err = SetClass(50, "scout")
+	require.NoError(t, err)
+	assert.Equal(t, "scout", p.Classes[50])
+
+	Decline(p.ID, 51)
+}
@@ -74,6 +74,9 @@ type JSONFields struct {
 	//PlaceholderLobbies       *[]LobbyData `sql:"-" json:"lobbies"`
 	PlaceholderStats *PlayerStats `sql:"-" json:"stats"`
 	PlaceholderBans  []*PlayerBan `sql:"-" json:"bans"`
+
+	PlaceholderRatings map[string]*PlayerRating `sql:"-" json:"ratings,omitempty"`
+	PlaceholderCareer  *CareerStats             `sql:"-" json:"careerStats,omitempty"`
 }
 
 // Create a new player with the given steam id.
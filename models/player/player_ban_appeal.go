@@ -0,0 +1,165 @@
+package player
+
+import (
+	"errors"
+	"time"
+
+	db "github.com/TF2Stadium/Helen/database"
+	"github.com/jinzhu/gorm"
+)
+
+//AppealState is the state of a ban appeal
+type AppealState int
+
+//Appeal states
+const (
+	AppealPending AppealState = iota
+	AppealAccepted
+	AppealRejected
+	AppealShortened
+)
+
+var (
+	ErrAppealNotFound     = errors.New("Ban appeal not found")
+	ErrAlreadyAppealed    = errors.New("You've already appealed this ban")
+	ErrBanNotActive       = errors.New("This ban isn't active")
+	ErrAppealDecided      = errors.New("This appeal has already been reviewed")
+	ErrInvalidShortenTime = errors.New("Bans can only be shortened to a time between now and the current end of the ban")
+)
+
+//BanAppeal is an appeal made by a banned player against one of their bans
+type BanAppeal struct {
+	ID        uint      `gorm:"primary_key" json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+
+	BanID    uint      `sql:"not null;unique" json:"banID"` // players can appeal a ban only once
+	Ban      PlayerBan `gorm:"ForeignKey:BanID" json:"-"`
+	PlayerID uint      `json:"-"`
+	Player   Player    `gorm:"ForeignKey:PlayerID" json:"-"`
+
+	Message string      `sql:"type:text" json:"message"`
+	State   AppealState `json:"state"`
+
+	ReviewedByPlayerID uint   `json:"-"`
+	Response           string `sql:"type:text" json:"response"` // shown to the player
+}
+
+func (s AppealState) String() string {
+	return map[AppealState]string{
+		AppealPending:   "pending",
+		AppealAccepted:  "accepted",
+		AppealRejected:  "rejected",
+		AppealShortened: "shortened",
+	}[s]
+}
+
+func (s AppealState) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+//NewBanAppeal creates an appeal against the player's ban with the given ID
+func (player *Player) NewBanAppeal(banID uint, message string) (*BanAppeal, error) {
+	ban := &PlayerBan{}
+	err := db.DB.Where("id = ? AND player_id = ? AND active = TRUE AND until > now()", banID, player.ID).First(ban).Error
+	if err != nil {
+		return nil, ErrBanNotActive
+	}
+
+	var count int
+	db.DB.Model(&BanAppeal{}).Where("ban_id = ?", banID).Count(&count)
+	if count != 0 {
+		return nil, ErrAlreadyAppealed
+	}
+
+	appeal := &BanAppeal{
+		BanID:    ban.ID,
+		PlayerID: player.ID,
+		Message:  message,
+	}
+	err = db.DB.Create(appeal).Error
+	return appeal, err
+}
+
+//GetBanAppeals returns all appeals made by the player
+func (player *Player) GetBanAppeals() []*BanAppeal {
+	var appeals []*BanAppeal
+	db.DB.Where("player_id = ?", player.ID).Order("id desc").Find(&appeals)
+	return appeals
+}
+
+//GetBanAppeal returns the appeal with the given ID
+func GetBanAppeal(id uint) (*BanAppeal, error) {
+	appeal := &BanAppeal{}
+	err := db.DB.Preload("Player").Preload("Ban").First(appeal, id).Error
+	if err != nil {
+		return nil, ErrAppealNotFound
+	}
+
+	return appeal, nil
+}
+
+//GetPendingBanAppeals returns all appeals which haven't been reviewed yet,
+//oldest first
+func GetPendingBanAppeals() []*BanAppeal {
+	var appeals []*BanAppeal
+	db.DB.Preload("Player").Preload("Ban").Where("state = ?", AppealPending).Order("id").Find(&appeals)
+	return appeals
+}
+
+func (appeal *BanAppeal) decide(tx *gorm.DB, state AppealState, reviewedBy uint, response string) error {
+	rows := tx.Model(&BanAppeal{}).Where("id = ? AND state = ?", appeal.ID, AppealPending).Updates(map[string]interface{}{
+		"state":                 state,
+		"reviewed_by_player_id": reviewedBy,
+		"response":              response,
+	}).RowsAffected
+	if rows == 0 {
+		return ErrAppealDecided
+	}
+
+	appeal.State = state
+	appeal.ReviewedByPlayerID = reviewedBy
+	appeal.Response = response
+	return nil
+}
+
+//Accept accepts the appeal, and unbans the player
+func (appeal *BanAppeal) Accept(reviewedBy uint, response string) error {
+	// the appeal is only accepted if the player is unbanned
+	tx := db.DB.Begin()
+	if err := appeal.decide(tx, AppealAccepted, reviewedBy, response); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := unban(tx, appeal.PlayerID, appeal.Ban.Type); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+//Reject rejects the appeal, the ban stays as it is
+func (appeal *BanAppeal) Reject(reviewedBy uint, response string) error {
+	return appeal.decide(db.DB, AppealRejected, reviewedBy, response)
+}
+
+//Shorten shortens the ban to the given time
+func (appeal *BanAppeal) Shorten(reviewedBy uint, until time.Time, response string) error {
+	if until.Before(time.Now()) || !until.Before(appeal.Ban.Until) {
+		return ErrInvalidShortenTime
+	}
+	tx := db.DB.Begin()
+	if err := appeal.decide(tx, AppealShortened, reviewedBy, response); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Model(&PlayerBan{}).Where("id = ?", appeal.BanID).UpdateColumn("until", until).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	appeal.Ban.Until = until
+	return tx.Commit().Error
+}
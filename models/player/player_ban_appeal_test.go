@@ -0,0 +1,67 @@
+package player_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/TF2Stadium/Helen/internal/testhelpers"
+	. "github.com/TF2Stadium/Helen/models/player"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	testhelpers.CleanupDB()
+}
+
+func TestBanAppeal(t *testing.T) {
+	t.Parallel()
+
+	p := testhelpers.CreatePlayer()
+	mod := testhelpers.CreatePlayer()
+	p.BanUntil(time.Now().Add(time.Hour), BanJoin, "testing", mod.ID)
+	ban, err := p.GetActiveBan(BanJoin)
+	require.NoError(t, err)
+
+	other := testhelpers.CreatePlayer()
+	_, err = other.NewBanAppeal(ban.ID, "not my ban")
+	assert.Equal(t, ErrBanNotActive, err)
+
+	appeal, err := p.NewBanAppeal(ban.ID, "please")
+	require.NoError(t, err)
+	_, err = p.NewBanAppeal(ban.ID, "please again")
+	assert.Equal(t, ErrAlreadyAppealed, err)
+
+	appeal, err = GetBanAppeal(appeal.ID)
+	require.NoError(t, err)
+	assert.Equal(t, AppealPending, appeal.State)
+
+	assert.Equal(t, ErrInvalidShortenTime, appeal.Shorten(mod.ID, time.Now().Add(2*time.Hour), ""))
+	assert.NoError(t, appeal.Accept(mod.ID, "ok"))
+	assert.False(t, p.IsBanned(BanJoin))
+	assert.Equal(t, ErrAppealDecided, appeal.Reject(mod.ID, ""))
+}
+
+func TestBanAppealShorten(t *testing.T) {
+	t.Parallel()
+
+	p := testhelpers.CreatePlayer()
+	mod := testhelpers.CreatePlayer()
+	p.BanUntil(time.Now().Add(24*time.Hour), BanChat, "testing", mod.ID)
+	ban, err := p.GetActiveBan(BanChat)
+	require.NoError(t, err)
+
+	appeal, err := p.NewBanAppeal(ban.ID, "please")
+	require.NoError(t, err)
+	appeal, _ = GetBanAppeal(appeal.ID)
+
+	until := time.Now().Add(time.Hour)
+	require.NoError(t, appeal.Shorten(mod.ID, until, "shortened"))
+	banned, newUntil := p.IsBannedWithTime(BanChat)
+	assert.True(t, banned)
+	assert.WithinDuration(t, until, newUntil, time.Second)
+
+	appeals := p.GetBanAppeals()
+	require.Len(t, appeals, 1)
+	assert.Equal(t, AppealShortened, appeals[0].State)
+}
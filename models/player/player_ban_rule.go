@@ -0,0 +1,277 @@
+package player
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	db "github.com/TF2Stadium/Helen/database"
+)
+
+// BanRule is a rule for automatically banning players who get reported
+// multiple times. The rule fires when a player gets Threshold reports of
+// ReportType within Window, and bans them for the next duration in Durations,
+// so repeat offenders get longer bans.
+type BanRule struct {
+	ID        uint `gorm:"primary_key"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	Name       string     `sql:"not null;unique"`
+	ReportType ReportType // type of reports the rule applies to
+	Window     time.Duration
+	Threshold  int     // number of reports in the window (including the new one) needed
+	BanType    BanType // type of ban
+	Durations  string  // comma separated list of ban durations, for the 1st, 2nd... ban
+	Reason     string  // reason shown to the banned player
+	Enabled    bool
+}
+
+// DefaultBanRules are used when there are no rules in the database
+var DefaultBanRules = []*BanRule{
+	{
+		Name:       "Substitute",
+		ReportType: Substitute,
+		Window:     30 * time.Minute,
+		Threshold:  2,
+		BanType:    BanJoin,
+		Durations:  "30m,2h,24h,168h",
+		Reason:     "For !subbing twice in the last 30 minutes",
+		Enabled:    true,
+	},
+	{
+		Name:       "Vote",
+		ReportType: Vote,
+		Window:     30 * time.Minute,
+		Threshold:  2,
+		BanType:    BanJoin,
+		Durations:  "30m,2h,24h,168h",
+		Reason:     "For getting !repped from a lobby multiple times in the last 30 minutes",
+		Enabled:    true,
+	},
+	{
+		Name:       "RageQuit",
+		ReportType: RageQuit,
+		Window:     30 * time.Minute,
+		Threshold:  2,
+		BanType:    BanJoin,
+		Durations:  "30m,2h,24h,168h",
+		Reason:     "For ragequitting a lobby multiple times in the last 30 minutes",
+		Enabled:    true,
+	},
+}
+
+var ErrBanRuleNotFound = errors.New("Ban rule not found")
+
+// GetBanRules returns all ban rules, or the default rules if there are none in
+// the database
+func GetBanRules() []*BanRule {
+	var rules []*BanRule
+	db.DB.Order("id").Find(&rules)
+	if len(rules) == 0 {
+		return DefaultBanRules
+	}
+
+	return rules
+}
+
+// GetBanRule returns the rule with the given ID
+func GetBanRule(id uint) (*BanRule, error) {
+	rule := &BanRule{}
+	if err := db.DB.First(rule, id).Error; err != nil {
+		return nil, ErrBanRuleNotFound
+	}
+
+	return rule, nil
+}
+
+// SaveDefaultBanRules saves the default rules in the database, if it doesn't
+// have any rules yet
+func SaveDefaultBanRules() {
+	var count int
+	db.DB.Model(&BanRule{}).Count(&count)
+	if count != 0 {
+		return
+	}
+
+	for _, rule := range DefaultBanRules {
+		r := *rule
+		db.DB.Create(&r)
+	}
+}
+
+// ParseDurations parses a comma separated list of durations
+func ParseDurations(str string) ([]time.Duration, error) {
+	var durations []time.Duration
+	for _, s := range strings.Split(str, ",") {
+		d, err := time.ParseDuration(strings.TrimSpace(s))
+		if err != nil {
+			return nil, err
+		}
+		if d <= 0 {
+			return nil, fmt.Errorf("Invalid ban duration %s", s)
+		}
+		durations = append(durations, d)
+	}
+
+	return durations, nil
+}
+
+// Validate returns an error if the rule can't be used
+func (rule *BanRule) Validate() error {
+	if rule.Name == "" {
+		return errors.New("Rules need a name")
+	}
+	if rule.Window <= 0 {
+		return errors.New("Window needs to be positive")
+	}
+	if rule.Threshold < 1 {
+		return errors.New("Threshold needs to be atleast 1")
+	}
+	_, err := ParseDurations(rule.Durations)
+	return err
+}
+
+// Save saves the rule in the database
+func (rule *BanRule) Save() error {
+	if err := rule.Validate(); err != nil {
+		return err
+	}
+
+	// the default rules are only used when there aren't any rules in the
+	// database, so they need to be saved along with the first change
+	SaveDefaultBanRules()
+	existing := &BanRule{}
+	query := db.DB.Where("name = ?", rule.Name)
+	if rule.ID != 0 {
+		query = db.DB.Where("id = ?", rule.ID)
+	}
+	if err := query.First(existing).Error; err != nil {
+		if rule.ID != 0 {
+			return ErrBanRuleNotFound
+		}
+		return db.DB.Create(rule).Error
+	}
+
+	// only update the fields which can be edited, rule might not have the others
+	err := db.DB.Model(existing).Updates(map[string]interface{}{
+		"name":        rule.Name,
+		"report_type": rule.ReportType,
+		"window":      rule.Window,
+		"threshold":   rule.Threshold,
+		"ban_type":    rule.BanType,
+		"durations":   rule.Durations,
+		"reason":      rule.Reason,
+		"enabled":     rule.Enabled,
+	}).Error
+	rule.ID, rule.CreatedAt, rule.UpdatedAt = existing.ID, existing.CreatedAt, existing.UpdatedAt
+	return err
+}
+
+// duration returns the length of the ban for the given offense, starting at 0
+func (rule *BanRule) duration(offense int) time.Duration {
+	durations, err := ParseDurations(rule.Durations)
+	if err != nil || len(durations) == 0 {
+		logrus.Errorf("Invalid durations for ban rule %s: %s", rule.Name, rule.Durations)
+		return 30 * time.Minute
+	}
+
+	if offense >= len(durations) {
+		offense = len(durations) - 1
+	}
+	return durations[offense]
+}
+
+// applyBanRules bans the player according to the rules which fire for a new
+// report of the given type
+func (player *Player) applyBanRules(rtype ReportType) {
+	for _, rule := range GetBanRules() {
+		if !rule.Enabled || rule.ReportType != rtype {
+			continue
+		}
+
+		var count int
+		db.DB.Model(&Report{}).Where("player_id = ? AND created_at > ? AND type = ?",
+			player.ID, time.Now().Add(-rule.Window), rtype).Count(&count)
+		if count < rule.Threshold {
+			continue
+		}
+
+		var offenses int
+		db.DB.Model(&PlayerBan{}).Where("player_id = ? AND rule_name = ?", player.ID, rule.Name).Count(&offenses)
+
+		until := time.Now().Add(rule.duration(offenses))
+		if err := player.banByRule(until, rule); err != nil {
+			logrus.Error(err)
+		}
+	}
+}
+
+// banByRule bans the player till the given time, recording the rule which
+// caused the ban
+func (player *Player) banByRule(until time.Time, rule *BanRule) error {
+	ban := PlayerBan{
+		PlayerID: player.ID,
+		Type:     rule.BanType,
+		Until:    until,
+		Reason:   rule.Reason,
+		RuleName: rule.Name,
+	}
+
+	return db.DB.Create(&ban).Error
+}
+
+// RulePreviewBan is a ban which a rule would have issued
+type RulePreviewBan struct {
+	PlayerID uint
+	Player   *Player
+	At       time.Time
+	Duration time.Duration
+	Offense  int // 1 for the player's first ban by this rule, and so on
+}
+
+// Preview returns the bans the rule would have issued for reports made since
+// the given time. Earlier bans by the rule aren't considered for escalation.
+func (rule *BanRule) Preview(since time.Time) ([]RulePreviewBan, error) {
+	if err := rule.Validate(); err != nil {
+		return nil, err
+	}
+
+	var reports []Report
+	db.DB.Where("type = ? AND created_at > ?", rule.ReportType, since.Add(-rule.Window)).
+		Order("created_at").Find(&reports)
+
+	recent := make(map[uint][]time.Time) // player ID -> times of recent reports
+	offenses := make(map[uint]int)
+	bans := []RulePreviewBan{}
+
+	for _, report := range reports {
+		times := append(recent[report.PlayerID], report.CreatedAt)
+		// only keep reports within the window
+		for len(times) != 0 && !times[0].After(report.CreatedAt.Add(-rule.Window)) {
+			times = times[1:]
+		}
+		recent[report.PlayerID] = times
+
+		if report.CreatedAt.Before(since) || len(times) < rule.Threshold {
+			continue
+		}
+
+		offense := offenses[report.PlayerID]
+		offenses[report.PlayerID]++
+		bans = append(bans, RulePreviewBan{
+			PlayerID: report.PlayerID,
+			At:       report.CreatedAt,
+			Duration: rule.duration(offense),
+			Offense:  offense + 1,
+		})
+	}
+
+	for i := range bans {
+		bans[i].Player, _ = GetPlayerByID(bans[i].PlayerID)
+	}
+
+	return bans, nil
+}
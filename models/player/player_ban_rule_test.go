@@ -0,0 +1,123 @@
+package player_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/TF2Stadium/Helen/internal/testhelpers"
+	. "github.com/TF2Stadium/Helen/models/player"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	testhelpers.CleanupDB()
+}
+
+func TestParseDurations(t *testing.T) {
+	durations, err := ParseDurations("30m, 2h,24h")
+	require.NoError(t, err)
+	assert.Equal(t, []time.Duration{30 * time.Minute, 2 * time.Hour, 24 * time.Hour}, durations)
+
+	_, err = ParseDurations("30m,foo")
+	assert.Error(t, err)
+	_, err = ParseDurations("-1h")
+	assert.Error(t, err)
+}
+
+func TestBanRuleEscalation(t *testing.T) {
+	t.Parallel()
+	p := testhelpers.CreatePlayer()
+
+	p.NewReport(RageQuit, 1)
+	assert.False(t, p.IsBanned(BanJoin))
+
+	p.NewReport(RageQuit, 2)
+	banned, until := p.IsBannedWithTime(BanJoin)
+	assert.True(t, banned)
+	assert.WithinDuration(t, time.Now().Add(30*time.Minute), until, time.Minute)
+
+	// repeat offenders get longer bans
+	p.NewReport(RageQuit, 3)
+	_, until = p.IsBannedWithTime(BanJoin)
+	assert.WithinDuration(t, time.Now().Add(2*time.Hour), until, time.Minute)
+
+	bans, _ := p.GetAllBans()
+	require.Len(t, bans, 2)
+	assert.Equal(t, "RageQuit", bans[0].RuleName)
+}
+
+func TestBanRuleSave(t *testing.T) {
+	t.Parallel()
+	rule := &BanRule{
+		Name:       "save",
+		ReportType: Vote,
+		Window:     time.Hour,
+		Threshold:  5,
+		BanType:    BanJoin,
+		Durations:  "1h",
+	}
+	require.NoError(t, rule.Save())
+	saved, err := GetBanRule(rule.ID)
+	require.NoError(t, err)
+
+	// rules edited from the admin page only have the edited fields
+	edited := &BanRule{
+		ID:         rule.ID,
+		Name:       "save",
+		ReportType: Vote,
+		Window:     time.Hour,
+		Threshold:  6,
+		BanType:    BanJoin,
+		Durations:  "1h,24h",
+	}
+	require.NoError(t, edited.Save())
+
+	rule, err = GetBanRule(rule.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 6, rule.Threshold)
+	assert.Equal(t, "1h,24h", rule.Durations)
+	assert.WithinDuration(t, saved.CreatedAt, rule.CreatedAt, time.Second)
+
+	edited.ID = rule.ID + 1000
+	assert.Equal(t, ErrBanRuleNotFound, edited.Save())
+}
+
+func TestBanRulePreview(t *testing.T) {
+	t.Parallel()
+	p := testhelpers.CreatePlayer()
+	p.NewReport(Vote, 1)
+	p.NewReport(Vote, 2)
+
+	rule := &BanRule{
+		Name:       "preview",
+		ReportType: Vote,
+		Window:     time.Hour,
+		Threshold:  2,
+		BanType:    BanJoin,
+		Durations:  "1h,24h",
+	}
+
+	bans, err := rule.Preview(time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+
+	var found []RulePreviewBan
+	for _, ban := range bans {
+		if ban.PlayerID == p.ID {
+			found = append(found, ban)
+		}
+	}
+	require.Len(t, found, 1)
+	assert.Equal(t, time.Hour, found[0].Duration)
+	assert.Equal(t, 1, found[0].Offense)
+
+	rule.Threshold = 3
+	bans, _ = rule.Preview(time.Now().Add(-time.Hour))
+	for _, ban := range bans {
+		assert.NotEqual(t, p.ID, ban.PlayerID)
+	}
+
+	rule.Durations = "foo"
+	_, err = rule.Preview(time.Now())
+	assert.Error(t, err)
+}
@@ -30,6 +30,8 @@ type PlayerBan struct {
 	Until  time.Time // Time until which the ban is valid
 	Reason string    // Reason for the ban
 	Active bool      `sql:"default:true"` // Whether the ban is active
+
+	RuleName string // name of the BanRule which caused the ban, for automatic bans
 }
 
 func (t BanType) String() string {
@@ -75,7 +77,11 @@ func (player *Player) BanUntil(tim time.Time, t BanType, reason string, bannedBy
 }
 
 func (player *Player) Unban(t BanType) error {
-	return db.DB.Model(&PlayerBan{}).Where("player_id = ? AND type = ? AND active = TRUE", player.ID, t).
+	return unban(db.DB, player.ID, t)
+}
+
+func unban(tx *gorm.DB, playerID uint, t BanType) error {
+	return tx.Model(&PlayerBan{}).Where("player_id = ? AND type = ? AND active = TRUE", playerID, t).
 		Update("active", "FALSE").Error
 }
 
@@ -0,0 +1,158 @@
+package player
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	db "github.com/TF2Stadium/Helen/database"
+)
+
+//ReportCategory is the reason a player was reported by another player
+type ReportCategory int
+
+//Report categories
+const (
+	ReportGriefing ReportCategory = iota
+	ReportCheating
+	ReportChatAbuse
+	ReportOther
+)
+
+//ReportCategories maps category names (used by the frontend) to categories
+var ReportCategories = map[string]ReportCategory{
+	"griefing": ReportGriefing,
+	"cheating": ReportCheating,
+	"chat":     ReportChatAbuse,
+	"other":    ReportOther,
+}
+
+func (c ReportCategory) String() string {
+	return map[ReportCategory]string{
+		ReportGriefing:  "griefing",
+		ReportCheating:  "cheating",
+		ReportChatAbuse: "chat abuse",
+		ReportOther:     "other",
+	}[c]
+}
+
+//ReportState is the state of a community report
+type ReportState int
+
+//Report states
+const (
+	ReportOpen     ReportState = iota
+	ReportActioned             // the reported player was banned
+	ReportDismissed
+)
+
+func (s ReportState) String() string {
+	return map[ReportState]string{
+		ReportOpen:      "open",
+		ReportActioned:  "actioned",
+		ReportDismissed: "dismissed",
+	}[s]
+}
+
+//MaxDailyReports is the number of reports a player can make in 24 hours
+const MaxDailyReports = 10
+
+var (
+	ErrReportNotFound  = errors.New("Report not found")
+	ErrAlreadyReported = errors.New("You've already reported this player for this lobby")
+	ErrReportYourself  = errors.New("You can't report yourself")
+	ErrTooManyReports  = fmt.Errorf("You can only make %d reports a day", MaxDailyReports)
+	ErrReportClosed    = errors.New("This report has already been closed")
+)
+
+//CommunityReport is a report made by a player against another player they
+//played a lobby with. Unlike Report, these are reviewed by moderators.
+type CommunityReport struct {
+	ID        uint      `gorm:"primary_key" json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"-"`
+
+	ReporterID uint   `json:"-"`
+	Reporter   Player `gorm:"ForeignKey:ReporterID" json:"-"`
+	PlayerID   uint   `json:"-"` // ID of the reported player
+	Player     Player `gorm:"ForeignKey:PlayerID" json:"-"`
+	LobbyID    uint   `json:"lobbyID"`
+
+	Category ReportCategory `json:"-"`
+	Text     string         `sql:"type:text" json:"text"`
+	LogsID   int            `json:"logsID,omitempty"` // logs.tf ID
+	DemoURL  string         `json:"demoURL,omitempty"`
+
+	State              ReportState `json:"-"`
+	ReviewedByPlayerID uint        `json:"-"`
+}
+
+//NewCommunityReport creates a report by the player against target
+func (player *Player) NewCommunityReport(target *Player, lobbyID uint, category ReportCategory, text string, logsID int, demoURL string) (*CommunityReport, error) {
+	if player.ID == target.ID {
+		return nil, ErrReportYourself
+	}
+
+	var count int
+	db.DB.Model(&CommunityReport{}).Where("reporter_id = ? AND player_id = ? AND lobby_id = ?", player.ID, target.ID, lobbyID).Count(&count)
+	if count != 0 {
+		return nil, ErrAlreadyReported
+	}
+
+	db.DB.Model(&CommunityReport{}).Where("reporter_id = ? AND created_at > ?", player.ID, time.Now().Add(-24*time.Hour)).Count(&count)
+	if count >= MaxDailyReports {
+		return nil, ErrTooManyReports
+	}
+
+	report := &CommunityReport{
+		ReporterID: player.ID,
+		PlayerID:   target.ID,
+		LobbyID:    lobbyID,
+		Category:   category,
+		Text:       text,
+		LogsID:     logsID,
+		DemoURL:    demoURL,
+	}
+	err := db.DB.Create(report).Error
+	return report, err
+}
+
+//GetCommunityReport returns the report with the given ID
+func GetCommunityReport(id uint) (*CommunityReport, error) {
+	report := &CommunityReport{}
+	err := db.DB.Preload("Reporter").Preload("Player").First(report, id).Error
+	if err != nil {
+		return nil, ErrReportNotFound
+	}
+
+	return report, nil
+}
+
+//GetCommunityReports returns all reports in the given state, oldest first
+func GetCommunityReports(state ReportState) []*CommunityReport {
+	var reports []*CommunityReport
+	db.DB.Preload("Reporter").Preload("Player").Where("state = ?", state).Order("id").Find(&reports)
+	return reports
+}
+
+//GetCommunityReportsAgainst returns all reports made against the player
+func (player *Player) GetCommunityReportsAgainst() []*CommunityReport {
+	var reports []*CommunityReport
+	db.DB.Preload("Reporter").Where("player_id = ?", player.ID).Order("id desc").Find(&reports)
+	return reports
+}
+
+//Close closes the report with the given state
+func (report *CommunityReport) Close(state ReportState, reviewedBy uint) error {
+	rows := db.DB.Model(&CommunityReport{}).Where("id = ? AND state = ?", report.ID, ReportOpen).Updates(map[string]interface{}{
+		"state":                 state,
+		"reviewed_by_player_id": reviewedBy,
+	}).RowsAffected
+	if rows == 0 {
+		return ErrReportClosed
+	}
+
+	report.State = state
+	report.ReviewedByPlayerID = reviewedBy
+	return nil
+}
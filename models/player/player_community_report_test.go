@@ -0,0 +1,44 @@
+package player_test
+
+import (
+	"testing"
+
+	"github.com/TF2Stadium/Helen/internal/testhelpers"
+	. "github.com/TF2Stadium/Helen/models/player"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	testhelpers.CleanupDB()
+}
+
+func TestCommunityReport(t *testing.T) {
+	t.Parallel()
+
+	reporter := testhelpers.CreatePlayer()
+	target := testhelpers.CreatePlayer()
+	mod := testhelpers.CreatePlayer()
+
+	_, err := reporter.NewCommunityReport(reporter, 1, ReportCheating, "", 0, "")
+	assert.Equal(t, ErrReportYourself, err)
+
+	report, err := reporter.NewCommunityReport(target, 1, ReportCheating, "aimbot", 123, "")
+	require.NoError(t, err)
+	_, err = reporter.NewCommunityReport(target, 1, ReportGriefing, "again", 0, "")
+	assert.Equal(t, ErrAlreadyReported, err)
+
+	report, err = GetCommunityReport(report.ID)
+	require.NoError(t, err)
+	assert.Equal(t, target.ID, report.Player.ID)
+	assert.Equal(t, reporter.ID, report.Reporter.ID)
+	assert.Equal(t, 123, report.LogsID)
+
+	assert.Len(t, target.GetCommunityReportsAgainst(), 1)
+
+	require.NoError(t, report.Close(ReportDismissed, mod.ID))
+	assert.Equal(t, ErrReportClosed, report.Close(ReportActioned, mod.ID))
+
+	report, _ = GetCommunityReport(report.ID)
+	assert.Equal(t, ReportDismissed, report.State)
+}
@@ -28,6 +28,8 @@ func (p *Player) setJSONFields(stats, lobbies, streaming, bans bool) {
 	if stats {
 		p.Stats.Total = p.Stats.TotalLobbies()
 		p.PlaceholderStats = &p.Stats
+		p.PlaceholderRatings = p.GetRatings()
+		p.PlaceholderCareer = p.GetCareerStats()
 	}
 
 	p.PlaceholderTags = new([]string)
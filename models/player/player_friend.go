@@ -0,0 +1,178 @@
+package player
+
+import (
+	"errors"
+	"time"
+
+	db "github.com/TF2Stadium/Helen/database"
+)
+
+var (
+	ErrFriendYourself        = errors.New("You can't add yourself as a friend")
+	ErrAlreadyFriends        = errors.New("You're already friends with this player")
+	ErrFriendRequestSent     = errors.New("You've already sent a friend request to this player")
+	ErrFriendRequestNotFound = errors.New("This player hasn't sent you a friend request")
+	ErrNotFriends            = errors.New("You aren't friends with this player")
+	ErrPlayerBlocked         = errors.New("You can't interact with this player")
+	ErrBlockYourself         = errors.New("You can't block yourself")
+)
+
+//Friendship is a friend request from PlayerID to FriendID, which becomes a
+//friendship once it has been accepted
+type Friendship struct {
+	ID        uint `gorm:"primary_key"`
+	CreatedAt time.Time
+
+	PlayerID uint `sql:"not null"` // player who sent the request
+	FriendID uint `sql:"not null"`
+	Accepted bool
+}
+
+//PlayerBlock is created when a player blocks another player. Blocked players
+//can't send friend requests or direct messages to the player, and their
+//messages are hidden from the player in all rooms.
+type PlayerBlock struct {
+	ID        uint `gorm:"primary_key"`
+	CreatedAt time.Time
+
+	PlayerID  uint `sql:"not null"`
+	BlockedID uint `sql:"not null"`
+}
+
+func (player *Player) friendship(other *Player) (*Friendship, error) {
+	f := &Friendship{}
+	err := db.DB.Where("(player_id = ? AND friend_id = ?) OR (player_id = ? AND friend_id = ?)",
+		player.ID, other.ID, other.ID, player.ID).First(f).Error
+	return f, err
+}
+
+//HasBlocked returns whether the player has blocked other
+func (player *Player) HasBlocked(other *Player) bool {
+	var count int
+	db.DB.Model(&PlayerBlock{}).Where("player_id = ? AND blocked_id = ?", player.ID, other.ID).Count(&count)
+	return count != 0
+}
+
+//SendFriendRequest sends a friend request to target. If target already sent
+//a request to the player, it gets accepted instead.
+func (player *Player) SendFriendRequest(target *Player) (accepted bool, err error) {
+	if player.ID == target.ID {
+		return false, ErrFriendYourself
+	}
+	if player.HasBlocked(target) || target.HasBlocked(player) {
+		return false, ErrPlayerBlocked
+	}
+
+	f, err := player.friendship(target)
+	if err == nil {
+		switch {
+		case f.Accepted:
+			return false, ErrAlreadyFriends
+		case f.PlayerID == player.ID:
+			return false, ErrFriendRequestSent
+		}
+		return true, player.AcceptFriendRequest(target)
+	}
+
+	return false, db.DB.Create(&Friendship{PlayerID: player.ID, FriendID: target.ID}).Error
+}
+
+//AcceptFriendRequest accepts a friend request sent to the player by from
+func (player *Player) AcceptFriendRequest(from *Player) error {
+	rows := db.DB.Model(&Friendship{}).Where("player_id = ? AND friend_id = ? AND accepted = FALSE", from.ID, player.ID).
+		UpdateColumn("accepted", true).RowsAffected
+	if rows == 0 {
+		return ErrFriendRequestNotFound
+	}
+
+	return nil
+}
+
+//RemoveFriend removes other from the player's friends. This also declines or
+//cancels a pending friend request.
+func (player *Player) RemoveFriend(other *Player) error {
+	rows := db.DB.Where("(player_id = ? AND friend_id = ?) OR (player_id = ? AND friend_id = ?)",
+		player.ID, other.ID, other.ID, player.ID).Delete(&Friendship{}).RowsAffected
+	if rows == 0 {
+		return ErrNotFriends
+	}
+
+	return nil
+}
+
+//IsFriend returns whether the player and other are friends
+func (player *Player) IsFriend(other *Player) bool {
+	f, err := player.friendship(other)
+	return err == nil && f.Accepted
+}
+
+//GetFriends returns the player's friends
+func (player *Player) GetFriends() []*Player {
+	var ids []uint
+	db.DB.Model(&Friendship{}).Where("player_id = ? AND accepted = TRUE", player.ID).Pluck("friend_id", &ids)
+	var theirs []uint
+	db.DB.Model(&Friendship{}).Where("friend_id = ? AND accepted = TRUE", player.ID).Pluck("player_id", &theirs)
+	ids = append(ids, theirs...)
+
+	friends := []*Player{}
+	if len(ids) != 0 {
+		db.DB.Where("id IN (?)", ids).Order("name").Find(&friends)
+	}
+	return friends
+}
+
+//GetFriendRequests returns players who have sent the player a friend request
+//which hasn't been accepted yet
+func (player *Player) GetFriendRequests() []*Player {
+	var ids []uint
+	db.DB.Model(&Friendship{}).Where("friend_id = ? AND accepted = FALSE", player.ID).Pluck("player_id", &ids)
+
+	players := []*Player{}
+	if len(ids) != 0 {
+		db.DB.Where("id IN (?)", ids).Order("id").Find(&players)
+	}
+	return players
+}
+
+//Block blocks other, removing them from the player's friends
+func (player *Player) Block(other *Player) error {
+	if player.ID == other.ID {
+		return ErrBlockYourself
+	}
+	if player.HasBlocked(other) {
+		return nil
+	}
+
+	player.RemoveFriend(other)
+	return db.DB.Create(&PlayerBlock{PlayerID: player.ID, BlockedID: other.ID}).Error
+}
+
+//Unblock unblocks other
+func (player *Player) Unblock(other *Player) error {
+	return db.DB.Where("player_id = ? AND blocked_id = ?", player.ID, other.ID).Delete(&PlayerBlock{}).Error
+}
+
+//GetBlockedIDs returns the IDs of players the player has blocked
+func (player *Player) GetBlockedIDs() []uint {
+	var ids []uint
+	db.DB.Model(&PlayerBlock{}).Where("player_id = ?", player.ID).Pluck("blocked_id", &ids)
+	return ids
+}
+
+//GetBlockedBy returns the steamids of players who have blocked the player with
+//the given ID
+func GetBlockedBy(playerID uint) []string {
+	var steamids []string
+	db.DB.Table("players").Joins("INNER JOIN player_blocks ON player_blocks.player_id = players.id").
+		Where("player_blocks.blocked_id = ?", playerID).Pluck("players.steam_id", &steamids)
+	return steamids
+}
+
+//GetBlockedPlayers returns the players the player has blocked
+func (player *Player) GetBlockedPlayers() []*Player {
+	players := []*Player{}
+	if ids := player.GetBlockedIDs(); len(ids) != 0 {
+		db.DB.Where("id IN (?)", ids).Order("name").Find(&players)
+	}
+	return players
+}
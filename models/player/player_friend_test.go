@@ -0,0 +1,85 @@
+package player_test
+
+import (
+	"testing"
+
+	"github.com/TF2Stadium/Helen/internal/testhelpers"
+	. "github.com/TF2Stadium/Helen/models/player"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFriendRequests(t *testing.T) {
+	t.Parallel()
+
+	p1 := testhelpers.CreatePlayer()
+	p2 := testhelpers.CreatePlayer()
+
+	_, err := p1.SendFriendRequest(p1)
+	assert.Equal(t, ErrFriendYourself, err)
+
+	accepted, err := p1.SendFriendRequest(p2)
+	require.NoError(t, err)
+	assert.False(t, accepted)
+	_, err = p1.SendFriendRequest(p2)
+	assert.Equal(t, ErrFriendRequestSent, err)
+	assert.False(t, p1.IsFriend(p2))
+
+	requests := p2.GetFriendRequests()
+	require.Len(t, requests, 1)
+	assert.Equal(t, p1.ID, requests[0].ID)
+	assert.Equal(t, ErrFriendRequestNotFound, p1.AcceptFriendRequest(p2))
+
+	require.NoError(t, p2.AcceptFriendRequest(p1))
+	assert.True(t, p1.IsFriend(p2))
+	assert.True(t, p2.IsFriend(p1))
+	assert.Empty(t, p2.GetFriendRequests())
+	require.Len(t, p1.GetFriends(), 1)
+	assert.Equal(t, p2.ID, p1.GetFriends()[0].ID)
+
+	_, err = p2.SendFriendRequest(p1)
+	assert.Equal(t, ErrAlreadyFriends, err)
+
+	require.NoError(t, p2.RemoveFriend(p1))
+	assert.False(t, p1.IsFriend(p2))
+	assert.Equal(t, ErrNotFriends, p2.RemoveFriend(p1))
+}
+
+func TestMutualFriendRequest(t *testing.T) {
+	t.Parallel()
+
+	p1 := testhelpers.CreatePlayer()
+	p2 := testhelpers.CreatePlayer()
+
+	_, err := p1.SendFriendRequest(p2)
+	require.NoError(t, err)
+	accepted, err := p2.SendFriendRequest(p1)
+	require.NoError(t, err)
+	assert.True(t, accepted)
+	assert.True(t, p1.IsFriend(p2))
+}
+
+func TestBlock(t *testing.T) {
+	t.Parallel()
+
+	p1 := testhelpers.CreatePlayer()
+	p2 := testhelpers.CreatePlayer()
+
+	p1.SendFriendRequest(p2)
+	require.NoError(t, p2.AcceptFriendRequest(p1))
+
+	require.NoError(t, p1.Block(p2))
+	assert.True(t, p1.HasBlocked(p2))
+	assert.False(t, p2.HasBlocked(p1))
+	assert.False(t, p1.IsFriend(p2))
+	assert.Equal(t, []uint{p2.ID}, p1.GetBlockedIDs())
+	assert.Equal(t, []string{p1.SteamID}, GetBlockedBy(p2.ID))
+	assert.Empty(t, GetBlockedBy(p1.ID))
+
+	_, err := p2.SendFriendRequest(p1)
+	assert.Equal(t, ErrPlayerBlocked, err)
+
+	require.NoError(t, p1.Unblock(p2))
+	assert.Empty(t, p1.GetBlockedIDs())
+	assert.Empty(t, GetBlockedBy(p2.ID))
+}
@@ -0,0 +1,283 @@
+package player
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	db "github.com/TF2Stadium/Helen/database"
+	"github.com/jinzhu/gorm"
+)
+
+//IP sources
+const (
+	IPSourceSteam  = "steam"
+	IPSourceTwitch = "twitch"
+	IPSourceSocket = "socket"
+)
+
+const (
+	//MaxSharedIPPlayers is the number of accounts an IP can be shared by
+	//before it stops linking them, to avoid lumping together everyone behind
+	//the same university or carrier NAT
+	MaxSharedIPPlayers = 10
+	//MaxAliasGroup is the maximum size of an alias group
+	MaxAliasGroup = 50
+)
+
+var (
+	ErrAltFlagNotFound = errors.New("Flag not found")
+	ErrNoSharedIP      = errors.New("The accounts haven't used the same IP")
+)
+
+//PlayerIP is an IP address a player has logged in or connected from
+type PlayerIP struct {
+	ID        uint `gorm:"primary_key"`
+	CreatedAt time.Time
+	LastSeen  time.Time
+
+	PlayerID uint   `sql:"not null;unique_index:idx_player_ip"`
+	IP       string `sql:"not null;unique_index:idx_player_ip;index"`
+	Source   string // where the IP was first seen
+	Count    int    // number of times the IP was seen
+}
+
+//AltFlag is created when an account shows up from an IP a player with an
+//active ban has used
+type AltFlag struct {
+	ID        uint `gorm:"primary_key"`
+	CreatedAt time.Time
+
+	PlayerID       uint   // the new account
+	Player         Player `gorm:"ForeignKey:PlayerID"`
+	BannedPlayerID uint
+	BannedPlayer   Player `gorm:"ForeignKey:BannedPlayerID"`
+	IP             string
+	Reviewed       bool
+}
+
+//AltLink is a link between two accounts sharing an IP, which a moderator
+//confirmed to belong to the same person. Group bans only ban confirmed alts.
+type AltLink struct {
+	ID        uint `gorm:"primary_key"`
+	CreatedAt time.Time
+
+	PlayerID    uint `sql:"not null;unique_index:idx_alt_link"` // the account with the lower ID
+	AltID       uint `sql:"not null;unique_index:idx_alt_link"`
+	ConfirmedBy uint // ID of the moderator
+}
+
+//normalizeIP returns the IP in its canonical form (so that IPv6 addresses
+//written differently match), or an empty string if it shouldn't be recorded
+func normalizeIP(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil || parsed.IsLoopback() || parsed.IsUnspecified() {
+		return ""
+	}
+	return parsed.String()
+}
+
+//RecordIP records that the player used the given IP address. If this is
+//the first time the player has used the address, and a banned player has used
+//it before, the player gets flagged as a possible alt account.
+func (player *Player) RecordIP(ip string, source string) {
+	ip = normalizeIP(ip)
+	if ip == "" {
+		return
+	}
+
+	rows := db.DB.Model(&PlayerIP{}).Where("player_id = ? AND ip = ?", player.ID, ip).
+		UpdateColumns(map[string]interface{}{
+			"last_seen": time.Now(),
+			"count":     gorm.Expr("count + 1"),
+		}).RowsAffected
+	if rows != 0 {
+		return
+	}
+
+	record := &PlayerIP{
+		PlayerID: player.ID,
+		IP:       ip,
+		Source:   source,
+		LastSeen: time.Now(),
+		Count:    1,
+	}
+	if err := db.DB.Create(record).Error; err != nil {
+		// the record was created concurrently
+		return
+	}
+
+	player.flagIfBannedIP(ip)
+}
+
+func (player *Player) flagIfBannedIP(ip string) {
+	var ids []uint
+	db.DB.Model(&PlayerIP{}).Where("ip = ? AND player_id <> ?", ip, player.ID).Pluck("player_id", &ids)
+	if len(ids) == 0 || len(ids) >= MaxSharedIPPlayers {
+		return
+	}
+
+	var banned []uint
+	db.DB.Model(&PlayerBan{}).Where("player_id IN (?) AND active = TRUE AND until > now()", ids).
+		Pluck("DISTINCT player_id", &banned)
+
+	for _, id := range banned {
+		var count int
+		db.DB.Model(&AltFlag{}).Where("player_id = ? AND banned_player_id = ?", player.ID, id).Count(&count)
+		if count != 0 {
+			continue
+		}
+
+		logrus.Warningf("Player %s (ID %d) connected from %s, used by banned player ID %d", player.SteamID, player.ID, ip, id)
+		db.DB.Create(&AltFlag{
+			PlayerID:       player.ID,
+			BannedPlayerID: id,
+			IP:             ip,
+		})
+	}
+}
+
+//GetIPs returns all IPs used by the player, most recently used first
+func (player *Player) GetIPs() []*PlayerIP {
+	var ips []*PlayerIP
+	db.DB.Where("player_id = ?", player.ID).Order("last_seen desc").Find(&ips)
+	return ips
+}
+
+//GetAliasGroup returns all other accounts linked to the player through
+//shared IPs, including accounts linked through other aliases.
+func (player *Player) GetAliasGroup() []*Player {
+	seen := map[uint]bool{player.ID: true}
+	var ids []uint
+	queue := []uint{player.ID}
+
+	for len(queue) != 0 && len(seen) < MaxAliasGroup {
+		id := queue[0]
+		queue = queue[1:]
+
+		var ips []string
+		db.DB.Model(&PlayerIP{}).Where("player_id = ?", id).Pluck("ip", &ips)
+
+		for _, ip := range ips {
+			var shared []uint
+			db.DB.Model(&PlayerIP{}).Where("ip = ?", ip).Pluck("player_id", &shared)
+			if len(shared) > MaxSharedIPPlayers {
+				continue
+			}
+
+			for _, other := range shared {
+				if seen[other] || len(seen) >= MaxAliasGroup {
+					continue
+				}
+				seen[other] = true
+				ids = append(ids, other)
+				queue = append(queue, other)
+			}
+		}
+	}
+
+	players := []*Player{}
+	if len(ids) != 0 {
+		db.DB.Where("id IN (?)", ids).Order("id").Find(&players)
+	}
+	return players
+}
+
+//ConfirmAlt records that a moderator confirmed alt to be an alt account of
+//the player. The accounts need to have used the same IP.
+func (player *Player) ConfirmAlt(alt *Player, confirmedBy uint) error {
+	var count int
+	db.DB.Model(&PlayerIP{}).
+		Where("player_id = ? AND ip IN (SELECT ip FROM player_ips WHERE player_id = ?)", player.ID, alt.ID).
+		Count(&count)
+	if player.ID == alt.ID || count == 0 {
+		return ErrNoSharedIP
+	}
+
+	link := &AltLink{PlayerID: player.ID, AltID: alt.ID, ConfirmedBy: confirmedBy}
+	if link.AltID < link.PlayerID {
+		link.PlayerID, link.AltID = link.AltID, link.PlayerID
+	}
+
+	db.DB.Model(&AltLink{}).Where("player_id = ? AND alt_id = ?", link.PlayerID, link.AltID).Count(&count)
+	if count != 0 {
+		return nil
+	}
+	return db.DB.Create(link).Error
+}
+
+//GetConfirmedAlts returns the accounts moderators confirmed to be alts of the player
+func (player *Player) GetConfirmedAlts() []*Player {
+	var ids, altIDs []uint
+	db.DB.Model(&AltLink{}).Where("player_id = ?", player.ID).Pluck("alt_id", &ids)
+	db.DB.Model(&AltLink{}).Where("alt_id = ?", player.ID).Pluck("player_id", &altIDs)
+	ids = append(ids, altIDs...)
+
+	players := []*Player{}
+	if len(ids) != 0 {
+		db.DB.Where("id IN (?)", ids).Order("id").Find(&players)
+	}
+	return players
+}
+
+//BanGroupUntil bans the player and their confirmed alts, and returns the
+//accounts that were banned. Accounts which are only linked by IP (through
+//GetAliasGroup) aren't banned, since IPs can be shared by unrelated players.
+func (player *Player) BanGroupUntil(tim time.Time, t BanType, reason string, bannedBy uint) ([]*Player, error) {
+	players := append([]*Player{player}, player.GetConfirmedAlts()...)
+	for _, p := range players {
+		if err := p.BanUntil(tim, t, reason, bannedBy); err != nil {
+			return nil, err
+		}
+	}
+
+	return players, nil
+}
+
+//UnbanGroup removes bans of the given type for the player and their confirmed
+//alts, and returns the accounts that were unbanned
+func (player *Player) UnbanGroup(t BanType) ([]*Player, error) {
+	players := append([]*Player{player}, player.GetConfirmedAlts()...)
+	for _, p := range players {
+		if err := p.Unban(t); err != nil {
+			return nil, err
+		}
+	}
+
+	return players, nil
+}
+
+//GetAltFlags returns all flags which haven't been reviewed, oldest first
+func GetAltFlags() []*AltFlag {
+	var flags []*AltFlag
+	db.DB.Preload("Player").Preload("BannedPlayer").Where("reviewed = FALSE").Order("id").Find(&flags)
+	return flags
+}
+
+//GetAltFlags returns all flags for the player
+func (player *Player) GetAltFlags() []*AltFlag {
+	var flags []*AltFlag
+	db.DB.Preload("BannedPlayer").Where("player_id = ?", player.ID).Order("id desc").Find(&flags)
+	return flags
+}
+
+//GetAltFlag returns the flag with the given ID
+func GetAltFlag(id uint) (*AltFlag, error) {
+	flag := &AltFlag{}
+	err := db.DB.Preload("Player").Preload("BannedPlayer").First(flag, id).Error
+	if err != nil {
+		return nil, ErrAltFlagNotFound
+	}
+	return flag, nil
+}
+
+//ReviewAltFlag marks the flag with the given ID as reviewed
+func ReviewAltFlag(id uint) error {
+	rows := db.DB.Model(&AltFlag{}).Where("id = ?", id).UpdateColumn("reviewed", true).RowsAffected
+	if rows == 0 {
+		return ErrAltFlagNotFound
+	}
+
+	return nil
+}
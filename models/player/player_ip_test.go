@@ -0,0 +1,102 @@
+package player_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/TF2Stadium/Helen/internal/testhelpers"
+	. "github.com/TF2Stadium/Helen/models/player"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	testhelpers.CleanupDB()
+}
+
+func TestRecordIP(t *testing.T) {
+	t.Parallel()
+
+	p := testhelpers.CreatePlayer()
+	p.RecordIP("127.0.0.1", IPSourceSteam)
+	assert.Empty(t, p.GetIPs())
+
+	p.RecordIP("10.1.1.1", IPSourceSteam)
+	p.RecordIP("10.1.1.1", IPSourceSocket)
+	ips := p.GetIPs()
+	require.Len(t, ips, 1)
+	assert.Equal(t, "10.1.1.1", ips[0].IP)
+	assert.Equal(t, IPSourceSteam, ips[0].Source)
+	assert.Equal(t, 2, ips[0].Count)
+
+	p.RecordIP("2001:DB8:0::1", IPSourceSocket)
+	p.RecordIP("2001:db8::1", IPSourceSocket)
+	ips = p.GetIPs()
+	require.Len(t, ips, 2)
+	assert.Equal(t, "2001:db8::1", ips[0].IP)
+	assert.Equal(t, 2, ips[0].Count)
+}
+
+func TestAliasGroup(t *testing.T) {
+	t.Parallel()
+
+	p1 := testhelpers.CreatePlayer()
+	p2 := testhelpers.CreatePlayer()
+	p3 := testhelpers.CreatePlayer()
+	other := testhelpers.CreatePlayer()
+
+	// p1 and p3 are only linked through p2
+	p1.RecordIP("10.2.1.1", IPSourceSteam)
+	p2.RecordIP("10.2.1.1", IPSourceSteam)
+	p2.RecordIP("10.2.1.2", IPSourceSocket)
+	p3.RecordIP("10.2.1.2", IPSourceSteam)
+	other.RecordIP("10.2.1.3", IPSourceSteam)
+
+	group := p1.GetAliasGroup()
+	require.Len(t, group, 2)
+	assert.Equal(t, p2.ID, group[0].ID)
+	assert.Equal(t, p3.ID, group[1].ID)
+	assert.Empty(t, other.GetAliasGroup())
+
+	// only confirmed alts are banned with the group
+	mod := testhelpers.CreatePlayer()
+	assert.Equal(t, ErrNoSharedIP, p3.ConfirmAlt(p1, mod.ID))
+	assert.Equal(t, ErrNoSharedIP, p1.ConfirmAlt(other, mod.ID))
+	require.NoError(t, p3.ConfirmAlt(p2, mod.ID))
+	require.NoError(t, p2.ConfirmAlt(p3, mod.ID))
+
+	banned, err := p3.BanGroupUntil(time.Now().Add(time.Hour), BanJoin, "alts", mod.ID)
+	require.NoError(t, err)
+	require.Len(t, banned, 2)
+	assert.Equal(t, p2.ID, banned[1].ID)
+	assert.True(t, p2.IsBanned(BanJoin))
+	assert.False(t, p1.IsBanned(BanJoin))
+	assert.False(t, other.IsBanned(BanJoin))
+
+	_, err = p2.UnbanGroup(BanJoin)
+	require.NoError(t, err)
+	assert.False(t, p3.IsBanned(BanJoin))
+}
+
+func TestAltFlag(t *testing.T) {
+	t.Parallel()
+
+	banned := testhelpers.CreatePlayer()
+	mod := testhelpers.CreatePlayer()
+	banned.RecordIP("10.3.1.1", IPSourceSteam)
+	banned.BanUntil(time.Now().Add(time.Hour), BanFull, "testing", mod.ID)
+
+	alt := testhelpers.CreatePlayer()
+	alt.RecordIP("10.3.1.1", IPSourceSteam)
+	alt.RecordIP("10.3.1.1", IPSourceSocket)
+
+	flags := alt.GetAltFlags()
+	require.Len(t, flags, 1)
+	assert.Equal(t, banned.ID, flags[0].BannedPlayerID)
+	assert.Equal(t, "10.3.1.1", flags[0].IP)
+
+	require.NoError(t, ReviewAltFlag(flags[0].ID))
+	for _, flag := range GetAltFlags() {
+		assert.NotEqual(t, flags[0].ID, flag.ID)
+	}
+}
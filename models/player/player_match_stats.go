@@ -0,0 +1,201 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package player
+
+import (
+	db "github.com/TF2Stadium/Helen/database"
+)
+
+//MatchStats are a player's stats in a single lobby, taken from the lobby's log.
+//Stats for each class the player played are in the lobby_class_times table.
+type MatchStats struct {
+	ID uint `gorm:"primary_key" json:"-"`
+
+	LobbyID  uint   `sql:"not null" json:"-"`
+	PlayerID uint   `sql:"not null" json:"-"`
+	Team     string `json:"team"`
+
+	Kills   int `json:"kills"`
+	Deaths  int `json:"deaths"`
+	Assists int `json:"assists"`
+	Damage  int `json:"damage"`
+	Heals   int `json:"heals"`
+	Ubers   int `json:"ubers"`
+	Drops   int `json:"drops"`
+
+	SteamID string `sql:"-" json:"steamid"`
+	Name    string `sql:"-" json:"name"`
+}
+
+//GetMatchStats returns the stats of all players in the given lobby
+func GetMatchStats(lobbyID uint) []*MatchStats {
+	var stats []*MatchStats
+	db.DB.Where("lobby_id = ?", lobbyID).Order("team, kills desc").Find(&stats)
+
+	for _, s := range stats {
+		if p, err := GetPlayerByID(s.PlayerID); err == nil {
+			s.SteamID = p.SteamID
+			s.Name = p.Alias()
+		}
+	}
+	return stats
+}
+
+//CareerStats are a player's average stats over all matches they have stats for
+type CareerStats struct {
+	Matches int `json:"matches"`
+
+	// averages per match
+	Kills   float64 `json:"kills"`
+	Deaths  float64 `json:"deaths"`
+	Assists float64 `json:"assists"`
+	Damage  float64 `json:"damage"`
+	Heals   float64 `json:"heals"`
+	Ubers   float64 `json:"ubers"`
+	Drops   float64 `json:"drops"`
+
+	Classes map[string]*ClassCareerStats `json:"classes"`
+}
+
+//ClassCareerStats are a player's total stats on a class
+type ClassCareerStats struct {
+	Hours   float64 `json:"hours"`
+	Kills   int     `json:"kills"`
+	Deaths  int     `json:"deaths"`
+	Assists int     `json:"assists"`
+	Damage  int     `json:"damage"`
+	KD      float64 `json:"kd"` // kills per death
+}
+
+//GetCareerStats returns the player's career stats
+func (player *Player) GetCareerStats() *CareerStats {
+	career := &CareerStats{Classes: make(map[string]*ClassCareerStats)}
+
+	db.DB.Table("match_stats").
+		Select("COUNT(*), COALESCE(AVG(kills), 0), COALESCE(AVG(deaths), 0), COALESCE(AVG(assists), 0), COALESCE(AVG(damage), 0), COALESCE(AVG(heals), 0), COALESCE(AVG(ubers), 0), COALESCE(AVG(drops), 0)").
+		Where("player_id = ?", player.ID).Row().
+		Scan(&career.Matches, &career.Kills, &career.Deaths, &career.Assists, &career.Damage, &career.Heals, &career.Ubers, &career.Drops)
+
+	rows, err := db.DB.Table("lobby_class_times").
+		Select("class, SUM(seconds), SUM(kills), SUM(deaths), SUM(assists), SUM(damage)").
+		Where("player_id = ?", player.ID).
+		Group("class").Rows()
+	if err != nil {
+		return career
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var class string
+		var seconds int
+		stats := &ClassCareerStats{}
+		rows.Scan(&class, &seconds, &stats.Kills, &stats.Deaths, &stats.Assists, &stats.Damage)
+
+		stats.Hours = float64(seconds) / 3600
+		stats.KD = float64(stats.Kills)
+		if stats.Deaths != 0 {
+			stats.KD /= float64(stats.Deaths)
+		}
+		career.Classes[class] = stats
+	}
+
+	return career
+}
+
+//HeadToHead compares two players over the lobbies they played together
+type HeadToHead struct {
+	Together     int `json:"together"`     // lobbies played on the same team
+	TogetherWins int `json:"togetherWins"` // lobbies won on the same team
+
+	Against int `json:"against"` // lobbies played on opposing teams
+	Wins    int `json:"wins"`    // lobbies the player won against other
+	Losses  int `json:"losses"`  // lobbies the player lost against other
+
+	// average stats in lobbies played against each other
+	Player *CareerStats `json:"player"`
+	Other  *CareerStats `json:"other"`
+}
+
+//GetHeadToHead returns the player's record with and against other
+func (player *Player) GetHeadToHead(other *Player) *HeadToHead {
+	h := &HeadToHead{Player: &CareerStats{}, Other: &CareerStats{}}
+
+	rows, err := db.DB.Table("match_stats a").
+		Select("a.team, b.team, lobbies.red_score, lobbies.blu_score, a.kills, a.deaths, a.assists, a.damage, a.heals, a.ubers, a.drops, b.kills, b.deaths, b.assists, b.damage, b.heals, b.ubers, b.drops").
+		Joins("INNER JOIN match_stats b ON a.lobby_id = b.lobby_id").
+		Joins("INNER JOIN lobbies ON lobbies.id = a.lobby_id").
+		Where("a.player_id = ? AND b.player_id = ?", player.ID, other.ID).Rows()
+	if err != nil {
+		return h
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var team, otherTeam string
+		var redScore, bluScore int
+		var p, o MatchStats
+		rows.Scan(&team, &otherTeam, &redScore, &bluScore,
+			&p.Kills, &p.Deaths, &p.Assists, &p.Damage, &p.Heals, &p.Ubers, &p.Drops,
+			&o.Kills, &o.Deaths, &o.Assists, &o.Damage, &o.Heals, &o.Ubers, &o.Drops)
+
+		var winner string
+		switch {
+		case redScore > bluScore:
+			winner = "red"
+		case bluScore > redScore:
+			winner = "blu"
+		}
+
+		if team == otherTeam {
+			h.Together++
+			if winner != "" && team == winner {
+				h.TogetherWins++
+			}
+			continue
+		}
+
+		h.Against++
+		switch {
+		case winner == "":
+		case winner == team:
+			h.Wins++
+		case winner == otherTeam:
+			h.Losses++
+		}
+		h.Player.add(&p)
+		h.Other.add(&o)
+	}
+
+	h.Player.average()
+	h.Other.average()
+	return h
+}
+
+func (c *CareerStats) add(m *MatchStats) {
+	c.Matches++
+	c.Kills += float64(m.Kills)
+	c.Deaths += float64(m.Deaths)
+	c.Assists += float64(m.Assists)
+	c.Damage += float64(m.Damage)
+	c.Heals += float64(m.Heals)
+	c.Ubers += float64(m.Ubers)
+	c.Drops += float64(m.Drops)
+}
+
+//average turns the totals added with add into averages per match
+func (c *CareerStats) average() {
+	if c.Matches == 0 {
+		return
+	}
+
+	n := float64(c.Matches)
+	c.Kills /= n
+	c.Deaths /= n
+	c.Assists /= n
+	c.Damage /= n
+	c.Heals /= n
+	c.Ubers /= n
+	c.Drops /= n
+}
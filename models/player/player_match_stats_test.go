@@ -0,0 +1,67 @@
+package player_test
+
+import (
+	"testing"
+
+	db "github.com/TF2Stadium/Helen/database"
+	"github.com/TF2Stadium/Helen/internal/testhelpers"
+	. "github.com/TF2Stadium/Helen/models/player"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCareerStats(t *testing.T) {
+	t.Parallel()
+	p := testhelpers.CreatePlayer()
+
+	career := p.GetCareerStats()
+	assert.Zero(t, career.Matches)
+	assert.Empty(t, career.Classes)
+
+	for _, kills := range []int{10, 20} {
+		lob := testhelpers.CreateLobby()
+		db.DB.Create(&MatchStats{LobbyID: lob.ID, PlayerID: p.ID, Team: "red", Kills: kills, Deaths: 5})
+		db.DB.Exec("INSERT INTO lobby_class_times (lobby_id, player_id, class, seconds, kills, deaths) VALUES (?, ?, 'scout', 1800, ?, 5)", lob.ID, p.ID, kills)
+	}
+
+	career = p.GetCareerStats()
+	assert.Equal(t, 2, career.Matches)
+	assert.Equal(t, float64(15), career.Kills)
+	assert.Equal(t, float64(5), career.Deaths)
+	require.Contains(t, career.Classes, "scout")
+	assert.Equal(t, float64(1), career.Classes["scout"].Hours)
+	assert.Equal(t, float64(3), career.Classes["scout"].KD)
+}
+
+func TestHeadToHead(t *testing.T) {
+	t.Parallel()
+	p1 := testhelpers.CreatePlayer()
+	p2 := testhelpers.CreatePlayer()
+
+	// p1 beats p2, then they win together
+	games := []struct {
+		team1, team2       string
+		redScore, bluScore int
+	}{
+		{"red", "blu", 5, 1},
+		{"blu", "blu", 0, 3},
+	}
+	for _, game := range games {
+		lob := testhelpers.CreateLobby()
+		db.DB.Exec("UPDATE lobbies SET red_score = ?, blu_score = ? WHERE id = ?", game.redScore, game.bluScore, lob.ID)
+		db.DB.Create(&MatchStats{LobbyID: lob.ID, PlayerID: p1.ID, Team: game.team1, Kills: 10})
+		db.DB.Create(&MatchStats{LobbyID: lob.ID, PlayerID: p2.ID, Team: game.team2, Kills: 4})
+	}
+
+	h := p1.GetHeadToHead(p2)
+	assert.Equal(t, 1, h.Against)
+	assert.Equal(t, 1, h.Wins)
+	assert.Equal(t, 0, h.Losses)
+	assert.Equal(t, 1, h.Together)
+	assert.Equal(t, 1, h.TogetherWins)
+	assert.Equal(t, float64(10), h.Player.Kills)
+	assert.Equal(t, float64(4), h.Other.Kills)
+
+	h = p2.GetHeadToHead(p1)
+	assert.Equal(t, 1, h.Losses)
+}
@@ -0,0 +1,80 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package player
+
+import (
+	"time"
+
+	db "github.com/TF2Stadium/Helen/database"
+	"github.com/TF2Stadium/Helen/helpers/glicko2"
+	"github.com/TF2Stadium/Helen/models/lobby/format"
+)
+
+//PlayerRating stores a player's Glicko-2 rating for a lobby format
+type PlayerRating struct {
+	ID        uint      `gorm:"primary_key" json:"-"`
+	UpdatedAt time.Time `json:"-"`
+
+	PlayerID uint          `sql:"not null" json:"-"`
+	Format   format.Format `sql:"not null" json:"-"`
+
+	Rating     float64 `json:"rating"`
+	RD         float64 `json:"deviation"`
+	Volatility float64 `json:"-"`
+	Matches    int     `json:"matches"` // number of rated matches played
+}
+
+//GetRating returns the player's rating for the given format. If the player
+//hasn't played a rated match in the format yet, the default rating is returned.
+func (player *Player) GetRating(f format.Format) *PlayerRating {
+	rating := &PlayerRating{}
+	err := db.DB.Where("player_id = ? AND format = ?", player.ID, f).First(rating).Error
+	if err != nil {
+		def := glicko2.NewRating()
+		rating = &PlayerRating{
+			PlayerID:   player.ID,
+			Format:     f,
+			Rating:     def.Rating,
+			RD:         def.RD,
+			Volatility: def.Volatility,
+		}
+	}
+
+	return rating
+}
+
+//GetRatings returns all ratings for the player, keyed by the format's name
+func (player *Player) GetRatings() map[string]*PlayerRating {
+	var ratings []*PlayerRating
+	db.DB.Where("player_id = ?", player.ID).Find(&ratings)
+
+	m := make(map[string]*PlayerRating)
+	for _, rating := range ratings {
+		m[format.FriendlyNamesMap[rating.Format]] = rating
+	}
+
+	return m
+}
+
+//Glicko2 returns the rating as a glicko2.Rating
+func (r *PlayerRating) Glicko2() glicko2.Rating {
+	return glicko2.Rating{
+		Rating:     r.Rating,
+		RD:         r.RD,
+		Volatility: r.Volatility,
+	}
+}
+
+//Update sets the rating to new, and counts a rated match
+func (r *PlayerRating) Update(new glicko2.Rating) {
+	r.Rating = new.Rating
+	r.RD = new.RD
+	r.Volatility = new.Volatility
+	r.Matches++
+}
+
+func (r *PlayerRating) Save() error {
+	return db.DB.Save(r).Error
+}
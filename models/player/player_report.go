@@ -3,6 +3,7 @@ package player
 import (
 	"time"
 
+	"github.com/Sirupsen/logrus"
 	db "github.com/TF2Stadium/Helen/database"
 )
 
@@ -23,32 +24,74 @@ const (
 	RageQuit                     //rage quit
 )
 
-func (player *Player) NewReport(rtype ReportType, lobbyid uint) {
-	var count int
-
-	last := time.Now().Add(-30 * time.Minute)
-	db.DB.Model(&Report{}).Where("player_id = ? AND created_at > ? AND type = ?", player.ID, last, rtype).Count(&count)
-
-	switch rtype {
-	case Substitute:
-		if count == 1 {
-			player.BanUntil(time.Now().Add(30*time.Minute), BanJoin, "For !subbing twice in the last 30 minutes", 0)
-		}
-	case Vote:
-		if count != 0 {
-			player.BanUntil(time.Now().Add(30*time.Minute), BanJoin, "For getting !repped from a lobby multiple times in the last 30 minutes", 0)
-		}
-	case RageQuit:
-		if count != 0 {
-			player.BanUntil(time.Now().Add(30*time.Minute), BanJoin, "For ragequitting a lobby multiple times in the last 30 minutes", 0)
-		}
-
-	}
+func (t ReportType) String() string {
+	return map[ReportType]string{
+		Substitute: "!sub",
+		Vote:       "!rep",
+		RageQuit:   "rage quit",
+	}[t]
+}
 
+func (player *Player) NewReport(rtype ReportType, lobbyid uint) {
 	r := &Report{
 		LobbyID:  lobbyid,
 		PlayerID: player.ID,
 		Type:     rtype,
 	}
 	db.DB.Save(r)
+
+	player.applyBanRules(rtype)
+	player.UpdateReliability()
+}
+
+// how much a single report of each type counts against a player's reliability
+var reportWeight = map[ReportType]float64{
+	Substitute: 1,
+	Vote:       1,
+	RageQuit:   2,
+}
+
+//Reliability returns the reliability score for a player who has played the
+//given number of lobbies and has the given number of reports of each type.
+//The score is in [0, 1], players who haven't played or been reported yet have
+//a reliability of 1.
+func Reliability(lobbies int, reports map[ReportType]int) float64 {
+	var weighted float64
+	for rtype, count := range reports {
+		weighted += reportWeight[rtype] * float64(count)
+	}
+
+	if weighted == 0 {
+		return 1
+	}
+
+	return float64(lobbies) / (float64(lobbies) + weighted)
+}
+
+//UpdateReliability recomputes the player's reliability score from their
+//reports, and saves it in the player's stats.
+func (player *Player) UpdateReliability() {
+	rows, err := db.DB.Model(&Report{}).Select("type, count(*)").Where("player_id = ?", player.ID).Group("type").Rows()
+	if err != nil {
+		logrus.Error(err)
+		return
+	}
+	defer rows.Close()
+
+	reports := make(map[ReportType]int)
+	for rows.Next() {
+		var rtype ReportType
+		var count int
+		rows.Scan(&rtype, &count)
+		reports[rtype] = count
+	}
+
+	var stats PlayerStats
+	if err := db.DB.First(&stats, player.StatsID).Error; err != nil {
+		logrus.Error(err)
+		return
+	}
+
+	player.Stats.Reliability = Reliability(stats.TotalLobbies(), reports)
+	db.DB.Model(&PlayerStats{}).Where("id = ?", stats.ID).UpdateColumn("reliability", player.Stats.Reliability)
 }
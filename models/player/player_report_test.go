@@ -48,3 +48,25 @@ func TestReportVoted(t *testing.T) {
 	assert.True(t, banned, "Player should be banned from joining lobbies")
 	assert.WithinDuration(t, until, time.Now(), 30*time.Minute)
 }
+
+func TestReliability(t *testing.T) {
+	assert.Equal(t, 1.0, Reliability(0, nil))
+	assert.Equal(t, 1.0, Reliability(10, map[ReportType]int{}))
+	assert.Equal(t, 0.0, Reliability(0, map[ReportType]int{Substitute: 1}))
+	assert.InDelta(t, 0.9, Reliability(9, map[ReportType]int{Vote: 1}), 0.0001)
+	assert.InDelta(t, 0.8, Reliability(8, map[ReportType]int{RageQuit: 1}), 0.0001)
+}
+
+func TestUpdateReliability(t *testing.T) {
+	t.Parallel()
+	p := testhelpers.CreatePlayer()
+	l1 := testhelpers.CreateLobby()
+	defer l1.Close(false, false)
+
+	p.UpdateReliability()
+	assert.Equal(t, 1.0, p.Stats.Reliability)
+
+	p.NewReport(RageQuit, l1.ID)
+	p, _ = GetPlayerWithStats(p.SteamID)
+	assert.Equal(t, 0.0, p.Stats.Reliability)
+}
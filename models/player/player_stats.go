@@ -5,10 +5,12 @@
 package player
 
 import (
+	"strconv"
 	"time"
 
 	"github.com/TF2Stadium/Helen/database"
 	"github.com/TF2Stadium/Helen/models/lobby/format"
+	"github.com/jinzhu/gorm/dialects/postgres"
 )
 
 type PlayerStats struct {
@@ -20,6 +22,8 @@ type PlayerStats struct {
 	PlayedFoursCount      int `sql:"played_fours_count",json:"playedFoursCount" `
 	PlayedUltiduoCount    int `sql:"played_ultiduo_count",json:"playedUltiduoCount"`
 	PlayedBballCount      int `sql:"played_bball_count",json:"playedBballCount"`
+	// played counts for formats other than the ones above, format name -> count
+	PlayedCounts postgres.Hstore `json:"playedCounts"`
 
 	Scout         int           `json:"scout"`
 	ScoutHours    time.Duration `json:"scoutHours"`
@@ -41,10 +45,14 @@ type PlayerStats struct {
 	SpyHours      time.Duration `json:"spyHours"`
 
 	Substitutes int `json:"substitutes"`
+
+	// lobbies/(lobbies+weighted reports), where rage quit reports count twice,
+	// and 1 for players without reports. See (*Player).UpdateReliability
+	Reliability float64 `sql:"default:1" json:"reliability"`
 }
 
 func NewStats() PlayerStats {
-	stats := PlayerStats{}
+	stats := PlayerStats{Reliability: 1}
 
 	return stats
 }
@@ -54,7 +62,15 @@ func (ps *PlayerStats) Save() {
 }
 
 func (ps *PlayerStats) TotalLobbies() int {
-	return ps.PlayedSixesCount + ps.PlayedHighlanderCount + ps.PlayedFoursCount + ps.PlayedUltiduoCount + ps.PlayedBballCount
+	total := ps.PlayedSixesCount + ps.PlayedHighlanderCount + ps.PlayedFoursCount + ps.PlayedUltiduoCount + ps.PlayedBballCount
+	for _, count := range ps.PlayedCounts {
+		if count != nil {
+			n, _ := strconv.Atoi(*count)
+			total += n
+		}
+	}
+
+	return total
 }
 
 func (ps *PlayerStats) PlayedCountIncrease(lt format.Format) {
@@ -69,6 +85,23 @@ func (ps *PlayerStats) PlayedCountIncrease(lt format.Format) {
 		ps.PlayedBballCount++
 	case format.Ultiduo:
 		ps.PlayedUltiduoCount++
+	case format.Debug:
+		// debug lobbies aren't counted
+	default:
+		def, ok := format.Get(lt)
+		if !ok {
+			break
+		}
+		if ps.PlayedCounts == nil {
+			ps.PlayedCounts = make(postgres.Hstore)
+		}
+
+		var n int
+		if count := ps.PlayedCounts[def.Name]; count != nil {
+			n, _ = strconv.Atoi(*count)
+		}
+		count := strconv.Itoa(n + 1)
+		ps.PlayedCounts[def.Name] = &count
 	}
 	database.DB.Save(ps)
 }
@@ -79,11 +112,11 @@ func (ps *PlayerStats) IncreaseSubCount() {
 }
 
 func (ps *PlayerStats) IncreaseClassCount(f format.Format, slot int) {
-	_, class, _ := format.GetSlotTeamClass(f, slot)
+	class, _ := format.GetSlotClass(f, slot)
 	switch class {
-	case "scout", "scout1", "scout2":
+	case "scout":
 		ps.Scout++
-	case "roamer", "pocket", "soldier", "soldier1", "soldier2":
+	case "soldier":
 		ps.Soldier++
 	case "pyro":
 		ps.Pyro++
@@ -28,4 +28,7 @@ func TestLobbiesPlayed(t *testing.T) {
 	assert.Nil(t, err)
 
 	assert.Equal(t, 1, stats2.PlayedSixesCount)
+
+	stats1.PlayedCountIncrease(format.Debug)
+	assert.Equal(t, 1, stats1.TotalLobbies())
 }
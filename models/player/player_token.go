@@ -0,0 +1,173 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package player
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	db "github.com/TF2Stadium/Helen/database"
+)
+
+//Scopes for API tokens
+const (
+	ScopeReadProfile = "profile:read"
+	ScopeJoinQueue   = "queue:join"
+	ScopeCreateLobby = "lobby:create"
+)
+
+var validScopes = map[string]bool{
+	ScopeReadProfile: true,
+	ScopeJoinQueue:   true,
+	ScopeCreateLobby: true,
+}
+
+const (
+	// prefix for all API tokens, so they're easy to recognize
+	tokenPrefix = "tf2stadium_"
+	//MaxAPITokens is the maximum number of tokens a player can have
+	MaxAPITokens = 10
+)
+
+var (
+	ErrTokenNotFound = errors.New("API token not found")
+	ErrTooManyTokens = fmt.Errorf("You can only have %d API tokens", MaxAPITokens)
+)
+
+//APIToken is a personal access token, which bots and other third party
+//integrations can use to act as the player
+type APIToken struct {
+	ID        uint      `gorm:"primary_key" json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+
+	PlayerID uint   `sql:"not null" json:"-"`
+	Name     string `json:"name"`
+	// hex encoded SHA-256 hash of the token, the token itself isn't stored
+	Hash   string `sql:"not null;unique" json:"-"`
+	Scopes string `json:"-"` // comma separated list of scopes
+
+	RateLimit  int       `json:"rateLimit"` // maximum requests per minute
+	LastUsedAt time.Time `json:"lastUsedAt"`
+}
+
+func hashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+//NewAPIToken creates a token for the player with the given scopes, and
+//returns it along with the token string, which can't be retrieved later.
+func (player *Player) NewAPIToken(name string, scopes []string, rateLimit int) (*APIToken, string, error) {
+	if len(scopes) == 0 {
+		return nil, "", errors.New("No scopes given")
+	}
+	for _, scope := range scopes {
+		if !validScopes[scope] {
+			return nil, "", fmt.Errorf("Invalid scope %q", scope)
+		}
+	}
+
+	var count int
+	db.DB.Model(&APIToken{}).Where("player_id = ?", player.ID).Count(&count)
+	if count >= MaxAPITokens {
+		return nil, "", ErrTooManyTokens
+	}
+
+	randBytes := make([]byte, 32)
+	if _, err := rand.Read(randBytes); err != nil {
+		return nil, "", err
+	}
+	str := tokenPrefix + hex.EncodeToString(randBytes)
+
+	token := &APIToken{
+		PlayerID:  player.ID,
+		Name:      name,
+		Hash:      hashToken(str),
+		Scopes:    strings.Join(scopes, ","),
+		RateLimit: rateLimit,
+	}
+	if err := db.DB.Create(token).Error; err != nil {
+		return nil, "", err
+	}
+
+	return token, str, nil
+}
+
+//GetAPIToken returns the token with the given token string
+func GetAPIToken(str string) (*APIToken, error) {
+	if !strings.HasPrefix(str, tokenPrefix) {
+		return nil, ErrTokenNotFound
+	}
+
+	token := &APIToken{}
+	err := db.DB.Where("hash = ?", hashToken(str)).First(token).Error
+	if err != nil {
+		return nil, ErrTokenNotFound
+	}
+
+	return token, nil
+}
+
+//GetAPITokens returns all tokens created by the player
+func (player *Player) GetAPITokens() []*APIToken {
+	var tokens []*APIToken
+	db.DB.Where("player_id = ?", player.ID).Order("id").Find(&tokens)
+	return tokens
+}
+
+//RevokeAPIToken deletes the player's token with the given ID
+func (player *Player) RevokeAPIToken(id uint) error {
+	query := db.DB.Where("id = ? AND player_id = ?", id, player.ID).Delete(&APIToken{})
+	if query.Error != nil {
+		return query.Error
+	}
+	if query.RowsAffected == 0 {
+		return ErrTokenNotFound
+	}
+
+	return nil
+}
+
+//GetScopes returns the list of scopes the token has
+func (t *APIToken) GetScopes() []string {
+	return strings.Split(t.Scopes, ",")
+}
+
+//HasScope returns whether the token has the given scope
+func (t *APIToken) HasScope(scope string) bool {
+	for _, s := range t.GetScopes() {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+//Revoked returns whether the token has been revoked since it was loaded
+func (t *APIToken) Revoked() bool {
+	var count int
+	db.DB.Model(&APIToken{}).Where("id = ?", t.ID).Count(&count)
+	return count == 0
+}
+
+//Used updates the time the token was last used at
+func (t *APIToken) Used() {
+	t.LastUsedAt = time.Now()
+	db.DB.Model(&APIToken{}).Where("id = ?", t.ID).UpdateColumn("last_used_at", t.LastUsedAt)
+}
+
+func (t *APIToken) MarshalJSON() ([]byte, error) {
+	type token APIToken
+	return json.Marshal(struct {
+		*token
+		Scopes []string `json:"scopes"`
+	}{(*token)(t), t.GetScopes()})
+}
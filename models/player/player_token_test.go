@@ -0,0 +1,51 @@
+package player_test
+
+import (
+	"testing"
+
+	"github.com/TF2Stadium/Helen/internal/testhelpers"
+	. "github.com/TF2Stadium/Helen/models/player"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	testhelpers.CleanupDB()
+}
+
+func TestAPIToken(t *testing.T) {
+	t.Parallel()
+
+	p := testhelpers.CreatePlayer()
+	token, str, err := p.NewAPIToken("bot", []string{ScopeReadProfile, ScopeJoinQueue}, 30)
+	require.NoError(t, err)
+	assert.NotEqual(t, str, token.Hash)
+
+	token2, err := GetAPIToken(str)
+	require.NoError(t, err)
+	assert.Equal(t, token.ID, token2.ID)
+	assert.Equal(t, p.ID, token2.PlayerID)
+	assert.True(t, token2.HasScope(ScopeReadProfile))
+	assert.True(t, token2.HasScope(ScopeJoinQueue))
+	assert.False(t, token2.HasScope(ScopeCreateLobby))
+
+	_, err = GetAPIToken(str + "0")
+	assert.Equal(t, ErrTokenNotFound, err)
+
+	_, _, err = p.NewAPIToken("bad", []string{"admin"}, 30)
+	assert.Error(t, err)
+	_, _, err = p.NewAPIToken("none", nil, 30)
+	assert.Error(t, err)
+
+	p2 := testhelpers.CreatePlayer()
+	assert.Equal(t, ErrTokenNotFound, p2.RevokeAPIToken(token.ID))
+
+	assert.Len(t, p.GetAPITokens(), 1)
+	assert.False(t, token.Revoked())
+	assert.NoError(t, p.RevokeAPIToken(token.ID))
+	assert.Len(t, p.GetAPITokens(), 0)
+	assert.True(t, token.Revoked())
+
+	_, err = GetAPIToken(str)
+	assert.Equal(t, ErrTokenNotFound, err)
+}
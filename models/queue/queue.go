@@ -0,0 +1,282 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+//Package queue implements the matchmaking queue. Players queue up for a format
+//(and optionally a set of classes) in their region, and are matched into
+//lobby slots once enough of them are waiting to fill every slot of the format.
+package queue
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/TF2Stadium/Helen/models/lobby/format"
+)
+
+var (
+	ErrAlreadyQueued = errors.New("You are already in the queue.")
+	ErrNotQueued     = errors.New("You are not in the queue.")
+	ErrNoClasses     = errors.New("You need to select atleast one class.")
+	ErrInvalidClass  = errors.New("Invalid class")
+)
+
+//Entry represents a player waiting in the queue
+type Entry struct {
+	PlayerID uint
+	SteamID  string
+	Classes  []string // classes the player is willing to play
+	JoinedAt time.Time
+}
+
+func (e *Entry) plays(class string) bool {
+	for _, c := range e.Classes {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
+
+type key struct {
+	format format.Format
+	region string
+}
+
+var (
+	mu      = new(sync.Mutex)
+	queues  = make(map[key][]*Entry)
+	players = make(map[uint]key) // player ID -> queue the player is in
+)
+
+//Join adds the player to the queue for the given format and region.
+//If classes is empty, the player is queued for all classes in the format.
+func Join(lobbyType format.Format, region string, playerID uint, steamID string, classes []string) error {
+	valid := format.GetClasses(lobbyType)
+	if len(classes) == 0 {
+		classes = valid
+	}
+
+	for _, class := range classes {
+		found := false
+		for _, c := range valid {
+			if c == class {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return ErrInvalidClass
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if _, ok := players[playerID]; ok {
+		return ErrAlreadyQueued
+	}
+
+	k := key{lobbyType, region}
+	queues[k] = append(queues[k], &Entry{
+		PlayerID: playerID,
+		SteamID:  steamID,
+		Classes:  classes,
+		JoinedAt: time.Now(),
+	})
+	players[playerID] = k
+
+	return nil
+}
+
+//Leave removes the player from whichever queue they're in
+func Leave(playerID uint) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	k, ok := players[playerID]
+	if !ok {
+		return ErrNotQueued
+	}
+
+	entries := queues[k]
+	for i, e := range entries {
+		if e.PlayerID == playerID {
+			queues[k] = append(entries[:i], entries[i+1:]...)
+			break
+		}
+	}
+	delete(players, playerID)
+
+	return nil
+}
+
+//IsQueued returns the format and region of the queue the player is in, if any
+func IsQueued(playerID uint) (lobbyType format.Format, region string, ok bool) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	k, ok := players[playerID]
+	return k.format, k.region, ok
+}
+
+//Status represents the number of players waiting in a queue
+type Status struct {
+	Format  format.Format `json:"-"`
+	Type    string        `json:"type"`
+	Region  string        `json:"region"`
+	Players int           `json:"players"`
+	Needed  int           `json:"needed"`
+}
+
+//GetStatus returns the sizes of all non-empty queues
+func GetStatus() []Status {
+	mu.Lock()
+	defer mu.Unlock()
+
+	status := []Status{}
+	for k, entries := range queues {
+		if len(entries) == 0 {
+			continue
+		}
+
+		status = append(status, Status{
+			Format:  k.format,
+			Type:    format.FriendlyNamesMap[k.format],
+			Region:  k.region,
+			Players: len(entries),
+			Needed:  2 * format.NumberOfClassesMap[k.format],
+		})
+	}
+
+	sort.Sort(byFormatRegion(status))
+	return status
+}
+
+type byFormatRegion []Status
+
+func (s byFormatRegion) Len() int      { return len(s) }
+func (s byFormatRegion) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s byFormatRegion) Less(i, j int) bool {
+	if s[i].Format != s[j].Format {
+		return s[i].Format < s[j].Format
+	}
+	return s[i].Region < s[j].Region
+}
+
+//Match tries to assign queued players to every slot of the given format.
+//Players are considered in the order they joined the queue, so a player can
+//never be displaced by someone who queued after them. If every slot can be
+//filled, the matched players are removed from the queue and a slot -> entry
+//map is returned.
+func Match(lobbyType format.Format, region string) (map[int]*Entry, bool) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	k := key{lobbyType, region}
+	entries := queues[k]
+	slots := 2 * format.NumberOfClassesMap[lobbyType]
+	if slots == 0 || len(entries) < slots {
+		return nil, false
+	}
+
+	assignment := matchSlots(lobbyType, entries)
+	if len(assignment) != slots {
+		return nil, false
+	}
+
+	matched := make(map[uint]bool)
+	for _, e := range assignment {
+		matched[e.PlayerID] = true
+		delete(players, e.PlayerID)
+	}
+
+	remaining := []*Entry{}
+	for _, e := range entries {
+		if !matched[e.PlayerID] {
+			remaining = append(remaining, e)
+		}
+	}
+	queues[k] = remaining
+
+	return assignment, true
+}
+
+//Requeue puts the given entries back into the front of their queue, keeping
+//their original join time. Used when a lobby couldn't be formed for matched players.
+func Requeue(lobbyType format.Format, region string, entries []*Entry) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	k := key{lobbyType, region}
+	requeued := []*Entry{}
+	for _, e := range entries {
+		if _, ok := players[e.PlayerID]; ok {
+			continue
+		}
+		requeued = append(requeued, e)
+		players[e.PlayerID] = k
+	}
+
+	all := append(requeued, queues[k]...)
+	sort.Stable(byJoinTime(all))
+	queues[k] = all
+}
+
+type byJoinTime []*Entry
+
+func (e byJoinTime) Len() int           { return len(e) }
+func (e byJoinTime) Swap(i, j int)      { e[i], e[j] = e[j], e[i] }
+func (e byJoinTime) Less(i, j int) bool { return e[i].JoinedAt.Before(e[j].JoinedAt) }
+
+//matchSlots computes a maximum matching between entries and slots using
+//augmenting paths. Entries are added in queue order, and an augmenting
+//path never unmatches an already matched entry, so earlier entries have priority.
+func matchSlots(lobbyType format.Format, entries []*Entry) map[int]*Entry {
+	slots := 2 * format.NumberOfClassesMap[lobbyType]
+	slotClass := make([]string, slots)
+	for i := range slotClass {
+		_, slotClass[i], _ = format.GetSlotTeamClass(lobbyType, i)
+	}
+
+	slotEntry := make([]int, slots) // slot -> index in entries
+	for i := range slotEntry {
+		slotEntry[i] = -1
+	}
+
+	var augment func(int, []bool) bool
+	augment = func(e int, visited []bool) bool {
+		for slot := 0; slot < slots; slot++ {
+			if visited[slot] || !entries[e].plays(slotClass[slot]) {
+				continue
+			}
+			visited[slot] = true
+
+			if slotEntry[slot] == -1 || augment(slotEntry[slot], visited) {
+				slotEntry[slot] = e
+				return true
+			}
+		}
+		return false
+	}
+
+	matched := 0
+	for e := range entries {
+		if matched == slots {
+			break
+		}
+		if augment(e, make([]bool, slots)) {
+			matched++
+		}
+	}
+
+	assignment := make(map[int]*Entry)
+	for slot, e := range slotEntry {
+		if e != -1 {
+			assignment[slot] = entries[e]
+		}
+	}
+	return assignment
+}
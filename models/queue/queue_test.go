@@ -0,0 +1,76 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package queue_test
+
+import (
+	"testing"
+
+	"github.com/TF2Stadium/Helen/models/lobby/format"
+	. "github.com/TF2Stadium/Helen/models/queue"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJoinLeave(t *testing.T) {
+	require.NoError(t, Join(format.Ultiduo, "eu", 1, "a", nil))
+	assert.Equal(t, ErrAlreadyQueued, Join(format.Ultiduo, "eu", 1, "a", nil))
+	assert.Equal(t, ErrInvalidClass, Join(format.Ultiduo, "eu", 2, "b", []string{"scout"}))
+
+	lobbyType, region, ok := IsQueued(1)
+	assert.True(t, ok)
+	assert.Equal(t, format.Ultiduo, lobbyType)
+	assert.Equal(t, "eu", region)
+
+	require.NoError(t, Leave(1))
+	assert.Equal(t, ErrNotQueued, Leave(1))
+	_, _, ok = IsQueued(1)
+	assert.False(t, ok)
+}
+
+func TestMatch(t *testing.T) {
+	// 3 medics, 2 soldiers: ultiduo needs 2 of each
+	require.NoError(t, Join(format.Ultiduo, "na", 10, "a", []string{"medic"}))
+	require.NoError(t, Join(format.Ultiduo, "na", 11, "b", []string{"medic"}))
+	require.NoError(t, Join(format.Ultiduo, "na", 12, "c", []string{"medic"}))
+	require.NoError(t, Join(format.Ultiduo, "na", 13, "d", []string{"soldier"}))
+
+	_, ok := Match(format.Ultiduo, "na")
+	assert.False(t, ok)
+
+	require.NoError(t, Join(format.Ultiduo, "na", 14, "e", []string{"soldier", "medic"}))
+
+	assignment, ok := Match(format.Ultiduo, "na")
+	require.True(t, ok)
+	require.Len(t, assignment, 4)
+
+	matched := make(map[uint]bool)
+	for slot, e := range assignment {
+		_, class, _ := format.GetSlotTeamClass(format.Ultiduo, slot)
+		assert.Contains(t, e.Classes, class)
+		matched[e.PlayerID] = true
+	}
+	// the last medic to queue up should be left in the queue
+	assert.False(t, matched[12])
+
+	_, _, ok = IsQueued(12)
+	assert.True(t, ok)
+	_, _, ok = IsQueued(10)
+	assert.False(t, ok)
+
+	var entries []*Entry
+	for _, e := range assignment {
+		entries = append(entries, e)
+	}
+	Requeue(format.Ultiduo, "na", entries)
+	for _, e := range entries {
+		_, _, ok = IsQueued(e.PlayerID)
+		assert.True(t, ok)
+	}
+
+	status := GetStatus()
+	require.Len(t, status, 1)
+	assert.Equal(t, 5, status[0].Players)
+	assert.Equal(t, 4, status[0].Needed)
+}
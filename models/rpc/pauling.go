@@ -5,6 +5,7 @@
 package rpc
 
 import (
+	"github.com/TF2Stadium/Helen/config"
 	"github.com/TF2Stadium/Helen/models/gameserver"
 	"github.com/TF2Stadium/Helen/models/lobby/format"
 )
@@ -21,6 +22,8 @@ type Args struct {
 	Slot      string
 	Text      string
 	ChangeMap bool
+
+	LogAddress string // address the server should send its logs to, with logaddress_add
 }
 
 func DisallowPlayer(lobbyId uint, steamId string, playerID uint) error {
@@ -47,7 +50,10 @@ func SetupServer(lobbyId uint, info gameserver.ServerRecord, lobbyType format.Fo
 		Type:      lobbyType,
 		League:    league,
 		Whitelist: whitelist,
-		Map:       mapName}
+		Map:       mapName,
+
+		LogAddress: config.Constants.LogPublicAddress,
+	}
 	return pauling.Call("Pauling.SetupServer", args, &struct{}{})
 }
 
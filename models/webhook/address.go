@@ -0,0 +1,116 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package webhook
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/TF2Stadium/Helen/config"
+)
+
+var ErrPrivateAddress = errors.New("Webhooks can't be sent to private or local addresses")
+
+var privateNets []*net.IPNet
+
+func init() {
+	for _, cidr := range []string{
+		"0.0.0.0/8",
+		"10.0.0.0/8",
+		"100.64.0.0/10", // carrier-grade NAT
+		"172.16.0.0/12",
+		"192.168.0.0/16",
+		"fc00::/7", // unique local addresses
+	} {
+		_, ipnet, _ := net.ParseCIDR(cidr)
+		privateNets = append(privateNets, ipnet)
+	}
+}
+
+func isPublic(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return false
+	}
+
+	for _, ipnet := range privateNets {
+		if ipnet.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// hostname returns the host in a URL's host, without the port
+func hostname(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		return h
+	}
+	return strings.Trim(host, "[]")
+}
+
+//lookupPublic resolves host, and returns its addresses if all of them are
+//public. Player webhooks are checked with this both when they're registered
+//and when they're sent, since the host can resolve to a different address
+//later.
+func lookupPublic(host string) ([]net.IP, error) {
+	ips, err := net.LookupIP(host)
+	if err != nil {
+		return nil, err
+	}
+	if config.Constants.WebhookPrivateAddrs {
+		return ips, nil
+	}
+
+	for _, ip := range ips {
+		if !isPublic(ip) {
+			return nil, ErrPrivateAddress
+		}
+	}
+	return ips, nil
+}
+
+var dialer = &net.Dialer{Timeout: 10 * time.Second}
+
+// dialPublic connects to the address checked by lookupPublic, instead of
+// letting the dialer resolve the host again
+func dialPublic(network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	ips, err := lookupPublic(host)
+	if err != nil {
+		return nil, err
+	}
+	return dialer.Dial(network, net.JoinHostPort(ips[0].String(), port))
+}
+
+func newClient(dial func(network, addr string) (net.Conn, error)) *http.Client {
+	return &http.Client{
+		Timeout: 20 * time.Second,
+		Transport: &http.Transport{
+			Dial:                dial,
+			TLSHandshakeTimeout: 10 * time.Second,
+		},
+		// the redirect could point anywhere, including private addresses.
+		// Redirects aren't followed, and count as failed attempts.
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+var (
+	// client for player webhooks
+	publicClient = newClient(dialPublic)
+	// client for server-wide webhooks, which are registered by admins and
+	// can be sent to internal services
+	client = newClient(dialer.Dial)
+)
@@ -0,0 +1,218 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package webhook
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	db "github.com/TF2Stadium/Helen/database"
+)
+
+//DeliveryState is the state of a delivery
+type DeliveryState int
+
+//Delivery states
+const (
+	Pending DeliveryState = iota
+	Delivered
+	Failed
+)
+
+const (
+	//MaxAttempts is the number of times a delivery is attempted before it's
+	//marked as failed
+	MaxAttempts = 8
+
+	// delay before the first retry, doubled after each attempt
+	retryDelay = 30 * time.Second
+	// how often the database is checked for pending deliveries
+	pollInterval = 10 * time.Second
+	// deliveries are claimed for this long while they're being sent, so
+	// other workers don't send them at the same time
+	claimTime = time.Minute
+)
+
+//Delivery is a single event queued for delivery to a webhook
+type Delivery struct {
+	ID        uint      `gorm:"primary_key" json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+
+	WebhookID uint          `json:"-"`
+	Event     string        `json:"event"`
+	Payload   string        `sql:"type:text" json:"-"`
+	State     DeliveryState `json:"state"`
+
+	Attempts      []Attempt `json:"attempts"`
+	NextAttemptAt time.Time `json:"-"`
+}
+
+//TableName returns the delivery table's name
+func (Delivery) TableName() string {
+	return "webhook_deliveries"
+}
+
+//Attempt is the log of a single attempt at sending a delivery
+type Attempt struct {
+	ID        uint      `gorm:"primary_key" json:"-"`
+	CreatedAt time.Time `json:"createdAt"`
+
+	DeliveryID uint          `json:"-"`
+	StatusCode int           `json:"statusCode"` // 0 if the request failed
+	Error      string        `json:"error,omitempty"`
+	Duration   time.Duration `json:"duration"`
+}
+
+//TableName returns the attempt table's name
+func (Attempt) TableName() string {
+	return "webhook_attempts"
+}
+
+func (s DeliveryState) String() string {
+	switch s {
+	case Pending:
+		return "pending"
+	case Delivered:
+		return "delivered"
+	case Failed:
+		return "failed"
+	}
+	return "unknown"
+}
+
+func (s DeliveryState) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+//RetryDelay returns how long to wait before retrying a delivery after the
+//given number of failed attempts
+func RetryDelay(attempts int) time.Duration {
+	return retryDelay << uint(attempts-1)
+}
+
+var wakeCh = make(chan struct{}, 1)
+
+// wake makes the worker check for pending deliveries right away
+func wake() {
+	select {
+	case wakeCh <- struct{}{}:
+	default:
+	}
+}
+
+//StartDelivering starts the worker which sends pending deliveries. Deliveries
+//which were pending when Helen stopped are sent once it's started again.
+func StartDelivering() {
+	go func() {
+		ticker := time.NewTicker(pollInterval)
+		for {
+			sendPending()
+
+			select {
+			case <-ticker.C:
+			case <-wakeCh:
+			}
+		}
+	}()
+}
+
+func sendPending() {
+	var deliveries []*Delivery
+	db.DB.Where("state = ? AND next_attempt_at <= ?", Pending, time.Now()).Order("id").Limit(50).Find(&deliveries)
+
+	for _, delivery := range deliveries {
+		// claim the delivery, skip it if it's being sent by someone else
+		rows := db.DB.Model(&Delivery{}).
+			Where("id = ? AND next_attempt_at = ?", delivery.ID, delivery.NextAttemptAt).
+			UpdateColumn("next_attempt_at", time.Now().Add(claimTime)).RowsAffected
+		if rows == 0 {
+			continue
+		}
+
+		go delivery.send()
+	}
+}
+
+func (delivery *Delivery) attempts() int {
+	var count int
+	db.DB.Model(&Attempt{}).Where("delivery_id = ?", delivery.ID).Count(&count)
+	return count
+}
+
+func (delivery *Delivery) setState(state DeliveryState, next time.Time) {
+	db.DB.Model(&Delivery{}).Where("id = ?", delivery.ID).Updates(map[string]interface{}{
+		"state":           state,
+		"next_attempt_at": next,
+	})
+}
+
+func (delivery *Delivery) send() {
+	webhook := &Webhook{}
+	if err := db.DB.First(webhook, delivery.WebhookID).Error; err != nil {
+		// the webhook has been removed
+		delivery.setState(Failed, delivery.NextAttemptAt)
+		return
+	}
+
+	attempt := &Attempt{DeliveryID: delivery.ID}
+	start := time.Now()
+	attempt.StatusCode, attempt.Error = post(webhook, delivery)
+	attempt.Duration = time.Since(start)
+	db.DB.Create(attempt)
+
+	if attempt.Error == "" {
+		delivery.setState(Delivered, delivery.NextAttemptAt)
+		return
+	}
+
+	count := delivery.attempts()
+	logrus.Warningf("webhook: delivery #%d to %s failed (attempt %d/%d): %s",
+		delivery.ID, webhook.URL, count, MaxAttempts, attempt.Error)
+	if count >= MaxAttempts {
+		delivery.setState(Failed, delivery.NextAttemptAt)
+		return
+	}
+
+	delivery.setState(Pending, time.Now().Add(RetryDelay(count)))
+}
+
+// post sends the delivery to the webhook, and returns the status code and
+// the error (if any)
+func post(webhook *Webhook, delivery *Delivery) (int, string) {
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequest("POST", webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err.Error()
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "TF2Stadium-Webhook")
+	req.Header.Set("X-TF2Stadium-Event", delivery.Event)
+	req.Header.Set("X-TF2Stadium-Delivery", strconv.FormatUint(uint64(delivery.ID), 10))
+	req.Header.Set("X-TF2Stadium-Signature", Sign(webhook.Secret, body))
+
+	c := publicClient
+	if webhook.PlayerID == 0 {
+		c = client
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return 0, err.Error()
+	}
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 1<<16))
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Sprintf("Unexpected status %s", resp.Status)
+	}
+
+	return resp.StatusCode, ""
+}
@@ -0,0 +1,241 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+//Package webhook implements outbound webhooks for lobby events. Events are
+//stored as deliveries in the database, and sent as signed JSON POST requests
+//by a background worker, which retries failed deliveries with backoff.
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	db "github.com/TF2Stadium/Helen/database"
+)
+
+//Event names
+const (
+	LobbyCreated      = "lobbyCreated"
+	LobbyFilled       = "lobbyFilled"
+	ReadyUpStarted    = "readyUpStarted"
+	MatchStarted      = "matchStarted"
+	PlayerSubstituted = "playerSubstituted"
+	MatchEnded        = "matchEnded"
+	LobbyClosed       = "lobbyClosed"
+)
+
+var validEvents = map[string]bool{
+	LobbyCreated:      true,
+	LobbyFilled:       true,
+	ReadyUpStarted:    true,
+	MatchStarted:      true,
+	PlayerSubstituted: true,
+	MatchEnded:        true,
+	LobbyClosed:       true,
+}
+
+//MaxPlayerWebhooks is the maximum number of webhooks a player can register
+const MaxPlayerWebhooks = 5
+
+var (
+	ErrWebhookNotFound = errors.New("Webhook not found")
+	ErrTooManyWebhooks = fmt.Errorf("You can only have %d webhooks", MaxPlayerWebhooks)
+	ErrInvalidURL      = errors.New("Webhook URL must be a valid http(s) URL")
+)
+
+//Webhook is a URL events are sent to. Server-wide webhooks (with PlayerID = 0)
+//receive events for all lobbies, while player webhooks only receive events for
+//lobbies the player has created or is playing in.
+type Webhook struct {
+	ID        uint      `gorm:"primary_key" json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+
+	PlayerID uint   `json:"-"`
+	URL      string `sql:"not null" json:"url"`
+	// key for signing payloads, sent in the X-TF2Stadium-Signature header
+	Secret string `sql:"not null" json:"-"`
+	Events string `json:"-"` // comma separated list of events, empty for all events
+}
+
+//NewWebhook registers a webhook for the given player (or a server-wide one
+//if playerID is 0), which receives the given events (or all events if none
+//are given). Player webhooks can only be sent to public addresses.
+func NewWebhook(playerID uint, rawurl string, events []string) (*Webhook, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, ErrInvalidURL
+	}
+	if playerID != 0 {
+		if _, err := lookupPublic(hostname(u.Host)); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, event := range events {
+		if !validEvents[event] {
+			return nil, fmt.Errorf("Invalid event %q", event)
+		}
+	}
+
+	if playerID != 0 {
+		var count int
+		db.DB.Model(&Webhook{}).Where("player_id = ?", playerID).Count(&count)
+		if count >= MaxPlayerWebhooks {
+			return nil, ErrTooManyWebhooks
+		}
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+
+	webhook := &Webhook{
+		PlayerID: playerID,
+		URL:      rawurl,
+		Secret:   hex.EncodeToString(secret),
+		Events:   strings.Join(events, ","),
+	}
+	err = db.DB.Create(webhook).Error
+	return webhook, err
+}
+
+//GetWebhooks returns all webhooks registered by the player, or all
+//server-wide webhooks if playerID is 0
+func GetWebhooks(playerID uint) []*Webhook {
+	var webhooks []*Webhook
+	db.DB.Where("player_id = ?", playerID).Order("id").Find(&webhooks)
+	return webhooks
+}
+
+//GetWebhook returns the player's webhook with the given ID
+func GetWebhook(id, playerID uint) (*Webhook, error) {
+	webhook := &Webhook{}
+	err := db.DB.Where("id = ? AND player_id = ?", id, playerID).First(webhook).Error
+	if err != nil {
+		return nil, ErrWebhookNotFound
+	}
+
+	return webhook, nil
+}
+
+//RemoveWebhook deletes the player's webhook with the given ID, along with
+//its pending deliveries
+func RemoveWebhook(id, playerID uint) error {
+	query := db.DB.Where("id = ? AND player_id = ?", id, playerID).Delete(&Webhook{})
+	if query.Error != nil {
+		return query.Error
+	}
+	if query.RowsAffected == 0 {
+		return ErrWebhookNotFound
+	}
+
+	db.DB.Model(&Delivery{}).Where("webhook_id = ? AND state = ?", id, Pending).UpdateColumn("state", Failed)
+	return nil
+}
+
+//GetEvents returns the events the webhook receives
+func (w *Webhook) GetEvents() []string {
+	if w.Events == "" {
+		return []string{}
+	}
+	return strings.Split(w.Events, ",")
+}
+
+//Receives returns whether the webhook receives the given event
+func (w *Webhook) Receives(event string) bool {
+	if w.Events == "" {
+		return true
+	}
+
+	for _, e := range w.GetEvents() {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+//Deliveries returns the most recent deliveries for the webhook
+func (w *Webhook) Deliveries(limit int) []*Delivery {
+	var deliveries []*Delivery
+	db.DB.Preload("Attempts").Where("webhook_id = ?", w.ID).Order("id desc").Limit(limit).Find(&deliveries)
+	return deliveries
+}
+
+func (w *Webhook) MarshalJSON() ([]byte, error) {
+	type webhook Webhook
+	return json.Marshal(struct {
+		*webhook
+		Events []string `json:"events"`
+	}{(*webhook)(w), w.GetEvents()})
+}
+
+//Sign returns the signature for the given payload, which is the hex encoded
+//HMAC-SHA256 of the payload, with the webhook's secret as the key.
+func Sign(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+type payload struct {
+	Event     string      `json:"event"`
+	Timestamp int64       `json:"timestamp"`
+	Data      interface{} `json:"data"`
+}
+
+//Send queues the event for delivery to all server-wide webhooks, and the
+//webhooks of the given players
+func Send(event string, playerIDs []uint, data interface{}) {
+	var webhooks []*Webhook
+	query := db.DB
+	if len(playerIDs) == 0 {
+		query = query.Where("player_id = 0")
+	} else {
+		query = query.Where("player_id = 0 OR player_id IN (?)", playerIDs)
+	}
+	query.Find(&webhooks)
+	if len(webhooks) == 0 {
+		return
+	}
+
+	bytes, err := json.Marshal(payload{event, time.Now().Unix(), data})
+	if err != nil {
+		logrus.Error(err)
+		return
+	}
+
+	queued := false
+	for _, webhook := range webhooks {
+		if !webhook.Receives(event) {
+			continue
+		}
+
+		delivery := &Delivery{
+			WebhookID:     webhook.ID,
+			Event:         event,
+			Payload:       string(bytes),
+			NextAttemptAt: time.Now(),
+		}
+		if err := db.DB.Create(delivery).Error; err != nil {
+			logrus.Error(err)
+			continue
+		}
+		queued = true
+	}
+
+	if queued {
+		wake()
+	}
+}
@@ -0,0 +1,108 @@
+package webhook_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/TF2Stadium/Helen/config"
+	db "github.com/TF2Stadium/Helen/database"
+	"github.com/TF2Stadium/Helen/internal/testhelpers"
+	. "github.com/TF2Stadium/Helen/models/webhook"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	testhelpers.CleanupDB()
+}
+
+func TestSign(t *testing.T) {
+	// echo -n '{}' | openssl dgst -sha256 -hmac secret
+	assert.Equal(t, "sha256=77325902caca812dc259733aacd046b73817372c777b8d95b402647474516e13", Sign("secret", []byte("{}")))
+	assert.NotEqual(t, Sign("secret", []byte("{}")), Sign("secret2", []byte("{}")))
+}
+
+func TestRetryDelay(t *testing.T) {
+	assert.Equal(t, 30*time.Second, RetryDelay(1))
+	assert.Equal(t, time.Minute, RetryDelay(2))
+	assert.Equal(t, 4*time.Minute, RetryDelay(4))
+}
+
+func TestNewWebhook(t *testing.T) {
+	t.Parallel()
+	p := testhelpers.CreatePlayer()
+
+	_, err := NewWebhook(p.ID, "ftp://203.0.113.1", nil)
+	assert.Equal(t, ErrInvalidURL, err)
+	_, err = NewWebhook(p.ID, "http://203.0.113.1", []string{"foo"})
+	assert.Error(t, err)
+
+	// player webhooks can't be sent to local or private addresses
+	for _, u := range []string{"http://127.0.0.1:8080/", "http://10.0.0.1/", "http://169.254.169.254/", "http://[::1]/", "http://0.0.0.0/"} {
+		_, err = NewWebhook(p.ID, u, nil)
+		assert.Equal(t, ErrPrivateAddress, err, u)
+	}
+	admin, err := NewWebhook(0, "http://127.0.0.1:8080/", nil)
+	require.NoError(t, err)
+	RemoveWebhook(admin.ID, 0)
+
+	hook, err := NewWebhook(p.ID, "http://203.0.113.1", []string{MatchEnded})
+	require.NoError(t, err)
+	assert.True(t, hook.Receives(MatchEnded))
+	assert.False(t, hook.Receives(LobbyCreated))
+	assert.Len(t, GetWebhooks(p.ID), 1)
+
+	assert.Equal(t, ErrWebhookNotFound, RemoveWebhook(hook.ID, p.ID+1))
+	assert.NoError(t, RemoveWebhook(hook.ID, p.ID))
+	assert.Len(t, GetWebhooks(p.ID), 0)
+}
+
+func TestDelivery(t *testing.T) {
+	received := make(chan *http.Request, 1)
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = ioutil.ReadAll(r.Body)
+		received <- r
+	}))
+	defer server.Close()
+
+	// the test server is on localhost
+	config.Constants.WebhookPrivateAddrs = true
+	defer func() { config.Constants.WebhookPrivateAddrs = false }()
+
+	p := testhelpers.CreatePlayer()
+	other := testhelpers.CreatePlayer()
+	hook, err := NewWebhook(p.ID, server.URL, []string{MatchEnded})
+	require.NoError(t, err)
+
+	// webhooks don't get events they haven't subscribed to, or events for
+	// lobbies their player isn't in
+	Send(LobbyCreated, []uint{p.ID}, nil)
+	Send(MatchEnded, []uint{other.ID}, nil)
+	var count int
+	db.DB.Model(&Delivery{}).Where("webhook_id = ?", hook.ID).Count(&count)
+	assert.Zero(t, count)
+
+	StartDelivering()
+	Send(MatchEnded, []uint{p.ID}, map[string]int{"logsID": 1})
+
+	select {
+	case r := <-received:
+		assert.Equal(t, MatchEnded, r.Header.Get("X-TF2Stadium-Event"))
+		assert.Equal(t, Sign(hook.Secret, body), r.Header.Get("X-TF2Stadium-Signature"))
+
+		var payload struct {
+			Event string
+			Data  map[string]int
+		}
+		require.NoError(t, json.Unmarshal(body, &payload))
+		assert.Equal(t, MatchEnded, payload.Event)
+		assert.Equal(t, 1, payload.Data["logsID"])
+	case <-time.After(5 * time.Second):
+		t.Fatal("Webhook wasn't delivered")
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/TF2Stadium/Helen/config"
 	"github.com/TF2Stadium/Helen/controllers"
 	"github.com/TF2Stadium/Helen/controllers/admin"
+	"github.com/TF2Stadium/Helen/controllers/api"
 	chelpers "github.com/TF2Stadium/Helen/controllers/controllerhelpers"
 	"github.com/TF2Stadium/Helen/controllers/login"
 	"github.com/TF2Stadium/Helen/controllers/stats"
@@ -36,15 +37,50 @@ var routes = []route{
 	{"/admin/roles", chelpers.FilterHTTPRequest(helpers.ActionViewPage, admin.ChangeRole)},
 	{"/admin/ban", chelpers.FilterHTTPRequest(helpers.ActionViewPage, admin.BanPlayer)},
 	{"/admin/chatlogs", chelpers.FilterHTTPRequest(helpers.ActionViewLogs, admin.GetChatLogs)},
+	{"/admin/chatfilter", chelpers.FilterHTTPRequest(helpers.ActionViewLogs, admin.ViewChatFilter)},
+	{"/admin/chatfilter/add", chelpers.FilterHTTPRequest(helpers.ActionBanChat, admin.AddChatFilterRule)},
+	{"/admin/chatfilter/remove", chelpers.FilterHTTPRequest(helpers.ActionBanChat, admin.RemoveChatFilterRule)},
 	{"/admin/banlogs", chelpers.FilterHTTPRequest(helpers.ActionViewLogs, admin.GetBanLogs)},
+	{"/admin/player", chelpers.FilterHTTPRequest(helpers.ActionViewLogs, admin.ViewPlayer)},
+	{"/admin/alts", chelpers.FilterHTTPRequest(helpers.ActionViewLogs, admin.ViewAltFlags)},
+	{"/admin/alts/review", chelpers.FilterHTTPRequest(helpers.ActionBanJoin, admin.ReviewAltFlag)},
+	{"/admin/alts/confirm", chelpers.FilterHTTPRequest(helpers.ActionBanJoin, admin.ConfirmAlt)},
+	{"/admin/appeals", chelpers.FilterHTTPRequest(helpers.ActionBanJoin, admin.ViewBanAppeals)},
+	{"/admin/appeals/review", chelpers.FilterHTTPRequest(helpers.ActionBanJoin, admin.ReviewBanAppeal)},
+	{"/admin/banrules", chelpers.FilterHTTPRequest(helpers.ActionViewLogs, admin.ViewBanRules)},
+	{"/admin/banrules/preview", chelpers.FilterHTTPRequest(helpers.ActionViewLogs, admin.PreviewBanRule)},
+	{"/admin/banrules/save", chelpers.FilterHTTPRequest(helpers.ActionBanJoin, admin.SaveBanRule)},
+	{"/admin/reports", chelpers.FilterHTTPRequest(helpers.ActionViewLogs, admin.ViewReports)},
+	{"/admin/reports/view", chelpers.FilterHTTPRequest(helpers.ActionViewLogs, admin.ViewReport)},
+	{"/admin/reports/action", chelpers.FilterHTTPRequest(helpers.ActionBanJoin, admin.ReportAction)},
 	{"/admin/server/", chelpers.FilterHTTPRequest(helpers.ModifyServers, admin.ViewServerPage)},
 	{"/admin/server/add", chelpers.FilterHTTPRequest(helpers.ModifyServers, admin.AddServer)},
 	{"/admin/server/remove", chelpers.FilterHTTPRequest(helpers.ModifyServers, admin.RemoveServer)},
+	{"/admin/server/check", chelpers.FilterHTTPRequest(helpers.ModifyServers, admin.CheckServers)},
 	{"/admin/lobbies", chelpers.FilterHTTPRequest(helpers.ActionViewLogs, admin.ViewOpenLobbies)},
+	{"/admin/webhooks/", chelpers.FilterHTTPRequest(helpers.ModifyWebhooks, admin.ViewWebhooksPage)},
+	{"/admin/webhooks/add", chelpers.FilterHTTPRequest(helpers.ModifyWebhooks, admin.AddWebhook)},
+	{"/admin/webhooks/remove", chelpers.FilterHTTPRequest(helpers.ModifyWebhooks, admin.RemoveWebhook)},
+	{"/admin/seasons/", chelpers.FilterHTTPRequest(helpers.ModifySeasons, admin.ViewSeasons)},
+	{"/admin/seasons/add", chelpers.FilterHTTPRequest(helpers.ModifySeasons, admin.AddSeason)},
+	{"/admin/seasons/remove", chelpers.FilterHTTPRequest(helpers.ModifySeasons, admin.RemoveSeason)},
+	{"/admin/seasons/compute", chelpers.FilterHTTPRequest(helpers.ModifySeasons, admin.ComputeSeason)},
+
+	{"/admin/lobbysettings/", chelpers.FilterHTTPRequest(helpers.ModifyLobbySettings, admin.ViewLobbySettings)},
+	{"/admin/lobbysettings/save", chelpers.FilterHTTPRequest(helpers.ModifyLobbySettings, admin.SaveLobbySetting)},
+	{"/admin/lobbysettings/remove", chelpers.FilterHTTPRequest(helpers.ModifyLobbySettings, admin.RemoveLobbySetting)},
+
+	{"/api/v1/lobbies", api.Lobbies},
+	{"/api/v1/lobbies/", api.Lobby},
+	{"/api/v1/players/", api.Player},
+	{"/api/v1/me", api.Me},
+	{"/api/v1/seasons", api.Seasons},
+	{"/api/v1/leaderboards", api.Leaderboard},
 
 	{"/stats", stats.StatsHandler},
 	{"/badge/", controllers.TwitchBadge},
 	{"/resetMumblePassword", controllers.ResetMumblePassword},
+	{"/logs/upload", controllers.UploadLog},
 }
 
 func SetupHTTP(mux *http.ServeMux) {
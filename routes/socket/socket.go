@@ -18,10 +18,17 @@ var (
 	UnauthServer = wsevent.NewServer(middleware.JSONCodec{}, func(_ *wsevent.Client, _ struct{}) interface{} {
 		return errors.New("You aren't logged in.")
 	})
+	//TokenServer is the wsevent server where sockets authenticated with an
+	//API token are added to. Only requests allowed for API tokens are
+	//registered on it.
+	TokenServer = wsevent.NewServer(middleware.JSONCodec{}, func(_ *wsevent.Client, _ struct{}) interface{} {
+		return errors.New("No such request.")
+	})
 )
 
 // Wait for all websocket requests to complete
 func Wait() {
 	AuthServer.Requests.Wait()
 	UnauthServer.Requests.Wait()
+	TokenServer.Requests.Wait()
 }